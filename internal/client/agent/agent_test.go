@@ -0,0 +1,324 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap"
+
+	"github.com/h2p2f/dedicated-vault/internal/client/agent/mocks"
+	"github.com/h2p2f/dedicated-vault/internal/client/clienterrors"
+	"github.com/h2p2f/dedicated-vault/internal/client/config"
+	"github.com/h2p2f/dedicated-vault/internal/client/models"
+)
+
+// startAgent starts agent with backend and returns client of it
+func startAgent(t *testing.T, backend *mocks.Backend, agentConf *config.ClientConfig) *Client {
+	socket := filepath.Join(t.TempDir(), "agent", "agent.sock")
+	ctx, cancel := context.WithCancel(context.Background())
+	server := NewServer(backend, agentConf, zap.NewNop())
+	done := make(chan error)
+	go func() {
+		done <- server.Serve(ctx, socket)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		assert.NoError(t, <-done)
+	})
+	clientConf := config.NewClientConfig()
+	var client *Client
+	assert.Eventually(t, func() bool {
+		var err error
+		client, err = Dial(context.Background(), socket, clientConf)
+		return err == nil
+	}, time.Second, 10*time.Millisecond)
+	return client
+}
+
+func TestAgent_Login(t *testing.T) {
+	agentConf := config.NewClientConfig()
+	backend := mocks.NewBackend(t)
+	backend.On("LoginUser", mock.Anything, "testuser", "password", "passphrase", "").
+		Run(func(args mock.Arguments) {
+			agentConf.UpdateSession(func(s *config.Session) {
+				s.User = "testuser"
+				s.Token = "jwt"
+			})
+		}).Return(nil)
+	backend.On("GetDataByType", "cr").Return([]models.Data{{UUID: "uuid1", Meta: "Prod DB", DataType: "cr"}}, nil)
+	backend.On("Lock").Run(func(args mock.Arguments) {
+		agentConf.UpdateSession(func(s *config.Session) { s.Token = "" })
+	}).Return()
+	client := startAgent(t, backend, agentConf)
+
+	status, err := client.Status(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, Status{}, status)
+
+	err = client.LoginUser(context.Background(), "testuser", "password", "passphrase", "")
+	assert.NoError(t, err)
+	// token stays in agent
+	assert.Equal(t, "testuser", client.config.User)
+	assert.Equal(t, agentToken, client.config.Token)
+
+	data, err := client.GetDataByType("cr")
+	assert.NoError(t, err)
+	assert.Equal(t, []models.Data{{UUID: "uuid1", Meta: "Prod DB", DataType: "cr"}}, data)
+
+	err = client.Lock(context.Background())
+	assert.NoError(t, err)
+	status, err = client.Status(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, Status{User: "testuser"}, status)
+	assert.Equal(t, "", client.config.Token)
+}
+
+func TestAgent_GetDataByTypeUndecryptable(t *testing.T) {
+	agentConf := config.NewClientConfig()
+	agentConf.User = "testuser"
+	agentConf.Token = "jwt"
+	backend := mocks.NewBackend(t)
+	backend.On("GetDataByType", "cr").Return([]models.Data{{UUID: "uuid1", Meta: "Prod DB", DataType: "cr"}},
+		&clienterrors.UndecryptableError{Items: map[string]string{"uuid2": "broken"}})
+	backend.On("Lock").Return()
+	client := startAgent(t, backend, agentConf)
+
+	// decrypted items are returned with error about skipped ones
+	data, err := client.GetDataByType("cr")
+	assert.ErrorIs(t, err, clienterrors.Undecryptable)
+	assert.Contains(t, err.Error(), "uuid2")
+	assert.Equal(t, []models.Data{{UUID: "uuid1", Meta: "Prod DB", DataType: "cr"}}, data)
+}
+
+func TestAgent_Logout(t *testing.T) {
+	agentConf := config.NewClientConfig()
+	agentConf.User = "testuser"
+	agentConf.Token = "jwt"
+	backend := mocks.NewBackend(t)
+	backend.On("Logout", mock.Anything).Run(func(args mock.Arguments) {
+		agentConf.UpdateSession(func(s *config.Session) { s.Token = "" })
+	}).Return(nil)
+	backend.On("Lock").Return()
+	client := startAgent(t, backend, agentConf)
+
+	err := client.Logout(context.Background())
+	assert.NoError(t, err)
+	status, err := client.Status(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, Status{User: "testuser"}, status)
+}
+
+func TestAgent_Sessions(t *testing.T) {
+	agentConf := config.NewClientConfig()
+	backend := mocks.NewBackend(t)
+	sessions := []models.Session{
+		{ID: "session1", DeviceName: "laptop", IP: "192.0.2.10", LastSeen: 2},
+		{ID: "session2", DeviceName: "desktop", Current: true, LastSeen: 1},
+	}
+	backend.On("ListSessions", mock.Anything).Return(sessions, nil)
+	backend.On("RevokeSession", mock.Anything, "session1").Return(nil)
+	backend.On("Lock").Return()
+	client := startAgent(t, backend, agentConf)
+
+	got, err := client.ListSessions(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, sessions, got)
+	err = client.RevokeSession(context.Background(), "session1")
+	assert.NoError(t, err)
+}
+
+func TestAgent_TwoFactor(t *testing.T) {
+	agentConf := config.NewClientConfig()
+	backend := mocks.NewBackend(t)
+	backend.On("LoginUser", mock.Anything, "testuser", "password", "passphrase", "").Return(clienterrors.TwoFactorRequired)
+	backend.On("LoginUser", mock.Anything, "testuser", "password", "passphrase", "123456").Return(nil)
+	backend.On("EnrollTwoFactor", mock.Anything, "testuser", "password").Return("otpauth://totp/uri", nil)
+	backend.On("ConfirmTwoFactor", mock.Anything, "testuser", "password", "123456").Return([]string{"aaaa-bbbb-cccc-dddd"}, nil)
+	backend.On("DisableTwoFactor", mock.Anything, "testuser", "password", "654321").Return(clienterrors.InvalidTwoFactorCode)
+	backend.On("Lock").Return()
+	client := startAgent(t, backend, agentConf)
+
+	err := client.LoginUser(context.Background(), "testuser", "password", "passphrase", "")
+	assert.ErrorIs(t, err, clienterrors.TwoFactorRequired)
+	err = client.LoginUser(context.Background(), "testuser", "password", "passphrase", "123456")
+	assert.NoError(t, err)
+	uri, err := client.EnrollTwoFactor(context.Background(), "testuser", "password")
+	assert.NoError(t, err)
+	assert.Equal(t, "otpauth://totp/uri", uri)
+	codes, err := client.ConfirmTwoFactor(context.Background(), "testuser", "password", "123456")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"aaaa-bbbb-cccc-dddd"}, codes)
+	err = client.DisableTwoFactor(context.Background(), "testuser", "password", "654321")
+	assert.ErrorIs(t, err, clienterrors.InvalidTwoFactorCode)
+}
+
+func TestAgent_Errors(t *testing.T) {
+	agentConf := config.NewClientConfig()
+	backend := mocks.NewBackend(t)
+	serverCopy := models.StoredData{UUID: "uuid1", Version: 3}
+	backend.On("LoginUser", mock.Anything, "testuser", "password", "wrong", "").Return(clienterrors.WrongPassphrase)
+	backend.On("UpgradePassword", mock.Anything, "testuser", "password", "passphrase", "").
+		Return(clienterrors.PasswordUpgradeRefused)
+	backend.On("ChangeData", mock.Anything, mock.AnythingOfType("models.Data")).
+		Return(&clienterrors.ConflictError{Server: serverCopy})
+	backend.On("Sync", mock.Anything).Return(errors.New("some error"))
+	backend.On("Lock").Return()
+	client := startAgent(t, backend, agentConf)
+
+	err := client.LoginUser(context.Background(), "testuser", "password", "wrong", "")
+	assert.ErrorIs(t, err, clienterrors.WrongPassphrase)
+	err = client.UpgradePassword(context.Background(), "testuser", "password", "passphrase", "")
+	assert.ErrorIs(t, err, clienterrors.PasswordUpgradeRefused)
+
+	err = client.ChangeData(context.Background(), models.Data{UUID: "uuid1", Version: 2})
+	var conflictErr *clienterrors.ConflictError
+	if assert.ErrorAs(t, err, &conflictErr) {
+		assert.Equal(t, serverCopy, conflictErr.Server)
+	}
+
+	err = client.Sync(context.Background())
+	assert.EqualError(t, err, "some error")
+}
+
+func TestAgent_Binary(t *testing.T) {
+	agentConf := config.NewClientConfig()
+	backend := mocks.NewBackend(t)
+	// content is larger than frame
+	content := bytes.Repeat([]byte("0123456789"), maxFrameSize/4)
+	data := models.Data{UUID: "uuid1", DataType: "bi", Version: 1,
+		Folder: models.Folder{Binary: models.BinaryData{Name: "file", Size: int64(len(content)), StreamKey: []byte("key")}}}
+	var uploaded []byte
+	backend.On("UploadBinary", mock.Anything, data, mock.Anything, int64(len(content)), mock.Anything).
+		Run(func(args mock.Arguments) {
+			var err error
+			uploaded, err = io.ReadAll(args.Get(2).(io.Reader))
+			assert.NoError(t, err)
+		}).Return(nil)
+	backend.On("DownloadBinary", mock.Anything, data, mock.Anything, mock.Anything).
+		Return(func(_ context.Context, _ models.Data, w io.Writer, _ func(done, total int64)) error {
+			_, err := w.Write(content)
+			return err
+		}).Once()
+	backend.On("DownloadBinary", mock.Anything, data, mock.Anything, mock.Anything).
+		Return(func(_ context.Context, _ models.Data, w io.Writer, _ func(done, total int64)) error {
+			_, err := w.Write(content[:10])
+			assert.NoError(t, err)
+			return models.ErrStreamTruncated
+		}).Once()
+	backend.On("Lock").Return()
+	client := startAgent(t, backend, agentConf)
+
+	var progress int64
+	err := client.UploadBinary(context.Background(), data, bytes.NewReader(content), int64(len(content)),
+		func(done, total int64) { progress = done })
+	assert.NoError(t, err)
+	assert.Equal(t, content, uploaded)
+	assert.Equal(t, int64(len(content)), progress)
+
+	var downloaded bytes.Buffer
+	err = client.DownloadBinary(context.Background(), data, &downloaded, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, content, downloaded.Bytes())
+
+	err = client.DownloadBinary(context.Background(), data, io.Discard, nil)
+	assert.ErrorIs(t, err, models.ErrStreamTruncated)
+}
+
+func TestAgent_StreamDoesNotBlockRequests(t *testing.T) {
+	agentConf := config.NewClientConfig()
+	backend := mocks.NewBackend(t)
+	data := models.Data{UUID: "uuid1", DataType: "bi", Version: 1}
+	started := make(chan struct{})
+	release := make(chan struct{})
+	backend.On("DownloadBinary", mock.Anything, data, mock.Anything, mock.Anything).
+		Return(func(_ context.Context, _ models.Data, w io.Writer, _ func(done, total int64)) error {
+			close(started)
+			<-release
+			_, err := w.Write([]byte("content"))
+			return err
+		})
+	backend.On("Lock").Return()
+	client := startAgent(t, backend, agentConf)
+
+	done := make(chan error, 1)
+	var downloaded bytes.Buffer
+	go func() {
+		done <- client.DownloadBinary(context.Background(), data, &downloaded, nil)
+	}()
+	<-started
+	// download is still running, other clients are served meanwhile
+	_, err := NewClient(client.socket, config.NewClientConfig()).Status(context.Background())
+	assert.NoError(t, err)
+	close(release)
+	assert.NoError(t, <-done)
+	assert.Equal(t, "content", downloaded.String())
+}
+
+func TestAgent_LockWhenIdle(t *testing.T) {
+	agentConf := config.NewClientConfig()
+	agentConf.AgentIdleTimeout = time.Millisecond
+	agentConf.User = "testuser"
+	agentConf.Token = "jwt"
+	backend := mocks.NewBackend(t)
+	backend.On("Lock").Run(func(args mock.Arguments) {
+		agentConf.UpdateSession(func(s *config.Session) { s.Token = "" })
+	}).Return()
+	client := startAgent(t, backend, agentConf)
+
+	assert.Eventually(t, func() bool {
+		status, err := client.Status(context.Background())
+		return err == nil && !status.Unlocked
+	}, 3*time.Second, 100*time.Millisecond)
+}
+
+func TestAgent_SocketPermissions(t *testing.T) {
+	backend := mocks.NewBackend(t)
+	backend.On("Lock").Return()
+	client := startAgent(t, backend, config.NewClientConfig())
+
+	info, err := os.Stat(client.socket)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+	info, err = os.Stat(filepath.Dir(client.socket))
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o700), info.Mode().Perm())
+
+	// the second agent doesn't take socket of running one
+	_, err = Listen(client.socket)
+	assert.ErrorContains(t, err, "agent is already running")
+}
+
+func TestPeerUID(t *testing.T) {
+	listener, err := Listen(filepath.Join(t.TempDir(), "agent", "agent.sock"))
+	assert.NoError(t, err)
+	defer listener.Close()
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err == nil {
+			accepted <- conn
+		}
+		close(accepted)
+	}()
+	client, err := net.Dial("unix", listener.Addr().String())
+	assert.NoError(t, err)
+	defer client.Close()
+	conn, ok := <-accepted
+	if !assert.True(t, ok) {
+		return
+	}
+	defer conn.Close()
+
+	uid, err := peerUID(conn)
+	assert.NoError(t, err)
+	assert.Equal(t, os.Getuid(), uid)
+}
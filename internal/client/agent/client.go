@@ -0,0 +1,347 @@
+// Package: agent
+// in this file we have client of unlock agent, it is used by CLI and GUI instead of local client logic
+package agent
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/h2p2f/dedicated-vault/internal/client/config"
+	"github.com/h2p2f/dedicated-vault/internal/client/models"
+)
+
+// agentToken marks configuration of client as logged in while agent keeps vault unlocked,
+// the real token is kept only by agent
+const agentToken = "agent"
+
+// dialTimeout - how long client waits for agent to accept connection
+const dialTimeout = time.Second
+
+// ErrNotRunning - agent is not running on socket
+var ErrNotRunning = errors.New("agent is not running")
+
+// Client is a client of unlock agent
+// state of agent is copied to configuration after every request, so GUI sees if vault is locked
+type Client struct {
+	socket string
+	config *config.ClientConfig
+}
+
+// NewClient creates a new Client
+func NewClient(socket string, config *config.ClientConfig) *Client {
+	return &Client{
+		socket: socket,
+		config: config,
+	}
+}
+
+// Dial connects to agent and returns its client, error is returned if agent is not running
+func Dial(ctx context.Context, socket string, config *config.ClientConfig) (*Client, error) {
+	c := NewClient(socket, config)
+	_, err := c.Status(ctx)
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return nil, fmt.Errorf("%w: %s", ErrNotRunning, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// call makes request to agent
+// upload is sent as content after request, content of response is written to download
+func (c *Client) call(ctx context.Context, method string, params, result any, upload io.Reader, download io.Writer) error {
+	dialer := net.Dialer{Timeout: dialTimeout}
+	conn, err := dialer.DialContext(ctx, "unix", c.socket)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	// connection is closed when ctx is done, so request can be canceled
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	req := request{Method: method, ConflictPolicy: c.config.ConflictPolicyOf()}
+	if params != nil {
+		req.Params, err = json.Marshal(params)
+		if err != nil {
+			return err
+		}
+	}
+	encoded, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	r := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+	_, err = w.Write(append(encoded, '\n'))
+	if err == nil {
+		err = w.Flush()
+	}
+	if err != nil {
+		return err
+	}
+	if upload != nil {
+		content := &frameWriter{w: w}
+		_, err = io.Copy(content, upload)
+		if err != nil {
+			return err
+		}
+		err = content.Close()
+		if err != nil {
+			return err
+		}
+	}
+	if download != nil {
+		_, err = io.Copy(download, &frameReader{r: r})
+		if err != nil {
+			return err
+		}
+	}
+
+	line, err := r.ReadBytes('\n')
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return err
+	}
+	var resp response
+	err = json.Unmarshal(line, &resp)
+	if err != nil {
+		return err
+	}
+	c.config.UpdateSession(func(s *config.Session) {
+		s.User = resp.User
+		s.Token = ""
+		if resp.Unlocked {
+			s.Token = agentToken
+		}
+	})
+	// result is kept with error too, e.g. items which are decrypted with undecryptable ones
+	if result != nil && resp.Result != nil {
+		if err := json.Unmarshal(resp.Result, result); err != nil {
+			return err
+		}
+	}
+	return resp.err()
+}
+
+// Status returns state of agent
+func (c *Client) Status(ctx context.Context) (Status, error) {
+	err := c.call(ctx, methodStatus, nil, nil, nil, nil)
+	if err != nil {
+		return Status{}, err
+	}
+	session := c.config.Session()
+	return Status{User: session.User, Unlocked: session.Token != ""}, nil
+}
+
+// Unlocked checks that agent keeps vault of user unlocked
+func (c *Client) Unlocked(ctx context.Context, userName string) (bool, error) {
+	status, err := c.Status(ctx)
+	if err != nil {
+		return false, err
+	}
+	return status.Unlocked && status.User == userName, nil
+}
+
+// Lock locks vault in agent
+func (c *Client) Lock(ctx context.Context) error {
+	return c.call(ctx, methodLock, nil, nil, nil, nil)
+}
+
+// Logout revokes session of user on server and locks vault in agent
+func (c *Client) Logout(ctx context.Context) error {
+	return c.call(ctx, methodLogout, nil, nil, nil, nil)
+}
+
+// ListSessions gets sessions of user from server
+func (c *Client) ListSessions(ctx context.Context) ([]models.Session, error) {
+	var sessions []models.Session
+	err := c.call(ctx, methodListSessions, nil, &sessions, nil, nil)
+	return sessions, err
+}
+
+// RevokeSession revokes session of another device of user
+func (c *Client) RevokeSession(ctx context.Context, sessionID string) error {
+	return c.call(ctx, methodRevokeSession, sessionParams{ID: sessionID}, nil, nil, nil)
+}
+
+// CreateUser creates a new user
+func (c *Client) CreateUser(ctx context.Context, userName, password, passphrase string) error {
+	return c.call(ctx, methodCreateUser, userParams{User: userName, Password: password, Passphrase: passphrase},
+		nil, nil, nil)
+}
+
+// LoginUser login user, vault stays unlocked in agent
+func (c *Client) LoginUser(ctx context.Context, userName, password, passphrase, code string) error {
+	return c.call(ctx, methodLoginUser,
+		userParams{User: userName, Password: password, Passphrase: passphrase, Code: code},
+		nil, nil, nil)
+}
+
+// UpgradePassword login user registered before password verifiers by password sent in clear once,
+// vault stays unlocked in agent
+func (c *Client) UpgradePassword(ctx context.Context, userName, password, passphrase, code string) error {
+	return c.call(ctx, methodUpgradePassword,
+		userParams{User: userName, Password: password, Passphrase: passphrase, Code: code},
+		nil, nil, nil)
+}
+
+// EnrollTwoFactor starts enrollment of two-factor authentication of user and returns provisioning URI
+func (c *Client) EnrollTwoFactor(ctx context.Context, userName, password string) (string, error) {
+	var uri string
+	err := c.call(ctx, methodEnrollTwoFactor, userParams{User: userName, Password: password}, &uri, nil, nil)
+	return uri, err
+}
+
+// ConfirmTwoFactor enables two-factor authentication of user and returns recovery codes
+func (c *Client) ConfirmTwoFactor(ctx context.Context, userName, password, code string) ([]string, error) {
+	var recoveryCodes []string
+	err := c.call(ctx, methodConfirmTwoFactor, userParams{User: userName, Password: password, Code: code},
+		&recoveryCodes, nil, nil)
+	return recoveryCodes, err
+}
+
+// DisableTwoFactor disables two-factor authentication of user
+func (c *Client) DisableTwoFactor(ctx context.Context, userName, password, code string) error {
+	return c.call(ctx, methodDisableTwoFactor, userParams{User: userName, Password: password, Code: code},
+		nil, nil, nil)
+}
+
+// ChangePassword changes password of user
+func (c *Client) ChangePassword(ctx context.Context, userName, password, newPassword string) error {
+	return c.call(ctx, methodChangePassword, userParams{User: userName, Password: password, NewPassword: newPassword},
+		nil, nil, nil)
+}
+
+// ChangePassphrase changes passphrase of vault
+func (c *Client) ChangePassphrase(ctx context.Context, oldPassphrase, newPassphrase string) error {
+	return c.call(ctx, methodChangePassphrase, userParams{Passphrase: oldPassphrase, NewPassphrase: newPassphrase},
+		nil, nil, nil)
+}
+
+// SaveData saves data
+func (c *Client) SaveData(ctx context.Context, data models.Data) error {
+	return c.call(ctx, methodSaveData, dataParams{Data: data}, nil, nil, nil)
+}
+
+// ChangeData changes data
+func (c *Client) ChangeData(ctx context.Context, data models.Data) error {
+	return c.call(ctx, methodChangeData, dataParams{Data: data}, nil, nil, nil)
+}
+
+// DeleteData deletes data
+func (c *Client) DeleteData(ctx context.Context, data models.Data) error {
+	return c.call(ctx, methodDeleteData, dataParams{Data: data}, nil, nil, nil)
+}
+
+// GetDataByType gets data by type
+func (c *Client) GetDataByType(dataType string) ([]models.Data, error) {
+	var data []models.Data
+	err := c.call(context.Background(), methodGetDataByType, typeParams{DataType: dataType}, &data, nil, nil)
+	return data, err
+}
+
+// Sync is a two-way sync with remote server
+func (c *Client) Sync(ctx context.Context) error {
+	return c.call(ctx, methodSync, nil, nil, nil, nil)
+}
+
+// FullSync replaces local data with server copy
+func (c *Client) FullSync(ctx context.Context) error {
+	return c.call(ctx, methodFullSync, nil, nil, nil, nil)
+}
+
+// Flush pushes local changes to server
+func (c *Client) Flush(ctx context.Context) error {
+	return c.call(ctx, methodFlush, nil, nil, nil, nil)
+}
+
+// PendingChanges returns local changes not pushed to server
+func (c *Client) PendingChanges() ([]models.OutboxEntry, error) {
+	var entries []models.OutboxEntry
+	err := c.call(context.Background(), methodPendingChanges, nil, &entries, nil, nil)
+	return entries, err
+}
+
+// Conflicts returns conflicts waiting for user decision
+func (c *Client) Conflicts() ([]models.DataConflict, error) {
+	var conflicts []models.DataConflict
+	err := c.call(context.Background(), methodConflicts, nil, &conflicts, nil, nil)
+	return conflicts, err
+}
+
+// ResolveConflict resolves conflict with policy
+func (c *Client) ResolveConflict(ctx context.Context, dataUUID string, policy models.ConflictPolicy) error {
+	return c.call(ctx, methodResolveConflict, conflictParams{UUID: dataUUID, Policy: policy}, nil, nil, nil)
+}
+
+// UploadBinary saves binary data with content read from r
+// progress is called with the number of bytes sent to agent
+func (c *Client) UploadBinary(ctx context.Context, data models.Data, r io.Reader, size int64, progress func(done, total int64)) error {
+	return c.call(ctx, methodUploadBinary, dataParams{Data: data, Size: size}, nil,
+		&progressReader{r: r, total: size, progress: progress}, nil)
+}
+
+// DownloadBinary writes content of binary data to w
+// progress is called with the number of bytes received from agent
+func (c *Client) DownloadBinary(ctx context.Context, data models.Data, w io.Writer, progress func(done, total int64)) error {
+	size := data.Folder.Binary.Size
+	if !data.Folder.Binary.IsStreamed() {
+		size = int64(len(data.Folder.Binary.Data))
+	}
+	return c.call(ctx, methodDownloadBinary, dataParams{Data: data}, nil, nil,
+		&progressWriter{w: w, total: size, progress: progress})
+}
+
+// progressReader reports progress of reading
+type progressReader struct {
+	r        io.Reader
+	done     int64
+	total    int64
+	progress func(done, total int64)
+}
+
+// Read reads from underlying reader
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.done += int64(n)
+	if n > 0 && p.progress != nil {
+		p.progress(p.done, p.total)
+	}
+	return n, err
+}
+
+// progressWriter reports progress of writing
+type progressWriter struct {
+	w        io.Writer
+	done     int64
+	total    int64
+	progress func(done, total int64)
+}
+
+// Write writes to underlying writer
+func (p *progressWriter) Write(b []byte) (int, error) {
+	n, err := p.w.Write(b)
+	p.done += int64(n)
+	if n > 0 && p.progress != nil {
+		p.progress(p.done, p.total)
+	}
+	return n, err
+}
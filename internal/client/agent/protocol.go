@@ -0,0 +1,253 @@
+// Package: agent
+// in this file we have protocol of unlock agent
+package agent
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/h2p2f/dedicated-vault/internal/client/clienterrors"
+	"github.com/h2p2f/dedicated-vault/internal/client/models"
+)
+
+/*
+one request is made per connection:
+client sends request as a JSON line, content of uploaded binary follows it as frames,
+agent sends content of downloaded binary as frames, then response as a JSON line
+frame is length (4 bytes, big endian) and data, frame with zero length ends content
+*/
+
+// agent methods
+const (
+	methodStatus           = "Status"
+	methodLock             = "Lock"
+	methodLogout           = "Logout"
+	methodListSessions     = "ListSessions"
+	methodRevokeSession    = "RevokeSession"
+	methodCreateUser       = "CreateUser"
+	methodLoginUser        = "LoginUser"
+	methodUpgradePassword  = "UpgradePassword"
+	methodChangePassword   = "ChangePassword"
+	methodChangePassphrase = "ChangePassphrase"
+	methodEnrollTwoFactor  = "EnrollTwoFactor"
+	methodConfirmTwoFactor = "ConfirmTwoFactor"
+	methodDisableTwoFactor = "DisableTwoFactor"
+	methodSaveData         = "SaveData"
+	methodChangeData       = "ChangeData"
+	methodDeleteData       = "DeleteData"
+	methodGetDataByType    = "GetDataByType"
+	methodSync             = "Sync"
+	methodFullSync         = "FullSync"
+	methodFlush            = "Flush"
+	methodPendingChanges   = "PendingChanges"
+	methodConflicts        = "Conflicts"
+	methodResolveConflict  = "ResolveConflict"
+	methodUploadBinary     = "UploadBinary"
+	methodDownloadBinary   = "DownloadBinary"
+)
+
+// maxFrameSize - frames are not larger, so a broken peer can't make agent allocate a lot of memory
+const maxFrameSize = 1 << 20
+
+// request - request to agent
+// conflict policy of client is applied to request, so settings of client are used by agent
+type request struct {
+	Method         string          `json:"method"`
+	ConflictPolicy string          `json:"conflict_policy,omitempty"`
+	Params         json.RawMessage `json:"params,omitempty"`
+}
+
+// response - response of agent, user and unlocked are state of agent after request
+type response struct {
+	Result   json.RawMessage    `json:"result,omitempty"`
+	Error    string             `json:"error,omitempty"`
+	Code     string             `json:"code,omitempty"`
+	Conflict *models.StoredData `json:"conflict,omitempty"`
+	User     string             `json:"user"`
+	Unlocked bool               `json:"unlocked"`
+}
+
+// Status - state of agent
+type Status struct {
+	User     string `json:"user"`
+	Unlocked bool   `json:"unlocked"`
+}
+
+// params of agent methods
+type (
+	userParams struct {
+		User          string `json:"user"`
+		Password      string `json:"password"`
+		NewPassword   string `json:"new_password,omitempty"`
+		Passphrase    string `json:"passphrase,omitempty"`
+		NewPassphrase string `json:"new_passphrase,omitempty"`
+		// Code - two-factor authentication code
+		Code string `json:"code,omitempty"`
+	}
+	dataParams struct {
+		Data models.Data `json:"data"`
+		Size int64       `json:"size,omitempty"`
+	}
+	typeParams struct {
+		DataType string `json:"data_type"`
+	}
+	conflictParams struct {
+		UUID   string                `json:"uuid"`
+		Policy models.ConflictPolicy `json:"policy"`
+	}
+	sessionParams struct {
+		ID string `json:"id"`
+	}
+)
+
+// errorCodes - errors which identity is kept between agent and client, so clients can check them with errors.Is
+var errorCodes = map[string]error{
+	"user_not_found":                 clienterrors.UserNotFound,
+	"version_conflict":               clienterrors.VersionConflict,
+	"server_unavailable":             clienterrors.ServerUnavailable,
+	"data_already_exists":            clienterrors.DataAlreadyExists,
+	"data_not_found":                 clienterrors.DataNotFound,
+	"pending_changes":                clienterrors.PendingChanges,
+	"local_data_not_found":           clienterrors.LocalDataNotFound,
+	"conflict_not_found":             clienterrors.ConflictNotFound,
+	"wrong_passphrase":               clienterrors.WrongPassphrase,
+	"key_mismatch":                   clienterrors.KeyMismatch,
+	"undecryptable":                  clienterrors.Undecryptable,
+	"legacy_data_not_migrated":       clienterrors.LegacyDataNotMigrated,
+	"two_factor_required":            clienterrors.TwoFactorRequired,
+	"two_factor_enrollment_required": clienterrors.TwoFactorEnrollmentRequired,
+	"invalid_two_factor_code":        clienterrors.InvalidTwoFactorCode,
+	"two_factor_already_enabled":     clienterrors.TwoFactorAlreadyEnabled,
+	"two_factor_locked":              clienterrors.TwoFactorLocked,
+	"another_user_logged_in":         clienterrors.AnotherUserLoggedIn,
+	"wrong_password":                 clienterrors.WrongPassword,
+	"server_proof_mismatch":          clienterrors.ServerProofMismatch,
+	"weak_password_params":           clienterrors.WeakPasswordParams,
+	"costly_password_params":         clienterrors.CostlyPasswordParams,
+	"password_upgrade_required":      clienterrors.PasswordUpgradeRequired,
+	"password_upgrade_refused":       clienterrors.PasswordUpgradeRefused,
+	"too_many_handshakes":            clienterrors.TooManyHandshakes,
+	"vault_locked":                   models.ErrVaultLocked,
+	"context_mismatch":               models.ErrContextMismatch,
+	"stream_truncated":               models.ErrStreamTruncated,
+}
+
+// errorCode - code of known error
+func errorCode(err error) string {
+	for code, known := range errorCodes {
+		if errors.Is(err, known) {
+			return code
+		}
+	}
+	return ""
+}
+
+// remoteError - error returned by agent
+type remoteError struct {
+	msg   string
+	known error
+}
+
+// Error returns error message
+func (e *remoteError) Error() string {
+	return e.msg
+}
+
+// Unwrap allows errors.Is with known errors
+func (e *remoteError) Unwrap() error {
+	return e.known
+}
+
+// err - error of response
+func (r *response) err() error {
+	if r.Error == "" {
+		return nil
+	}
+	if r.Conflict != nil {
+		return &clienterrors.ConflictError{Server: *r.Conflict}
+	}
+	return &remoteError{msg: r.Error, known: errorCodes[r.Code]}
+}
+
+// frameWriter writes content as frames
+type frameWriter struct {
+	w *bufio.Writer
+}
+
+// Write writes p as frames
+func (f *frameWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		n := len(p)
+		if n > maxFrameSize {
+			n = maxFrameSize
+		}
+		err := f.frame(p[:n])
+		if err != nil {
+			return written, err
+		}
+		written += n
+		p = p[n:]
+	}
+	return written, nil
+}
+
+// Close writes the end of content
+func (f *frameWriter) Close() error {
+	return f.frame(nil)
+}
+
+// frame writes one frame
+func (f *frameWriter) frame(p []byte) error {
+	var header [4]byte
+	binary.BigEndian.PutUint32(header[:], uint32(len(p)))
+	if _, err := f.w.Write(header[:]); err != nil {
+		return err
+	}
+	if _, err := f.w.Write(p); err != nil {
+		return err
+	}
+	return f.w.Flush()
+}
+
+// frameReader reads content written by frameWriter, io.EOF is returned after the end of content
+type frameReader struct {
+	r    *bufio.Reader
+	left int
+	done bool
+}
+
+// Read reads content
+func (f *frameReader) Read(p []byte) (int, error) {
+	if f.done {
+		return 0, io.EOF
+	}
+	if f.left == 0 {
+		var header [4]byte
+		if _, err := io.ReadFull(f.r, header[:]); err != nil {
+			return 0, err
+		}
+		size := binary.BigEndian.Uint32(header[:])
+		if size > maxFrameSize {
+			return 0, fmt.Errorf("frame of %d bytes is too large", size)
+		}
+		if size == 0 {
+			f.done = true
+			return 0, io.EOF
+		}
+		f.left = int(size)
+	}
+	if len(p) > f.left {
+		p = p[:f.left]
+	}
+	n, err := f.r.Read(p)
+	f.left -= n
+	if errors.Is(err, io.EOF) {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
@@ -0,0 +1,354 @@
+// Package: agent
+// in this file we have unlock agent, it keeps vault unlocked between commands of clients
+package agent
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/h2p2f/dedicated-vault/internal/client/clienterrors"
+	"github.com/h2p2f/dedicated-vault/internal/client/config"
+	"github.com/h2p2f/dedicated-vault/internal/client/models"
+)
+
+// Backend is an interface of client logic used by agent
+//
+//go:generate mockery --name Backend --output ./mocks --filename mocks_backend.go
+type Backend interface {
+	CreateUser(ctx context.Context, userName, password, passphrase string) error
+	LoginUser(ctx context.Context, userName, password, passphrase, code string) error
+	UpgradePassword(ctx context.Context, userName, password, passphrase, code string) error
+	ChangePassword(ctx context.Context, userName, password, newPassword string) error
+	ChangePassphrase(ctx context.Context, oldPassphrase, newPassphrase string) error
+	SaveData(ctx context.Context, data models.Data) error
+	ChangeData(ctx context.Context, data models.Data) error
+	DeleteData(ctx context.Context, data models.Data) error
+	GetDataByType(dataType string) ([]models.Data, error)
+	Sync(ctx context.Context) error
+	FullSync(ctx context.Context) error
+	Flush(ctx context.Context) error
+	PendingChanges() ([]models.OutboxEntry, error)
+	Conflicts() ([]models.DataConflict, error)
+	ResolveConflict(ctx context.Context, dataUUID string, policy models.ConflictPolicy) error
+	UploadBinary(ctx context.Context, data models.Data, r io.Reader, size int64, progress func(done, total int64)) error
+	DownloadBinary(ctx context.Context, data models.Data, w io.Writer, progress func(done, total int64)) error
+	Lock()
+	Logout(ctx context.Context) error
+	ListSessions(ctx context.Context) ([]models.Session, error)
+	RevokeSession(ctx context.Context, sessionID string) error
+	EnrollTwoFactor(ctx context.Context, userName, password string) (string, error)
+	ConfirmTwoFactor(ctx context.Context, userName, password, code string) ([]string, error)
+	DisableTwoFactor(ctx context.Context, userName, password, code string) error
+}
+
+// Server is unlock agent
+// it holds master key and token of logged in user in memory and serves clients on unix socket,
+// vault is locked after idle timeout without requests
+type Server struct {
+	backend Backend
+	config  *config.ClientConfig
+	logger  *zap.Logger
+	// mu serializes requests which change state of backend, content of binaries is streamed without it,
+	// streams is the number of running streams, vault is not locked after idle timeout while they run
+	mu       sync.Mutex
+	lastUsed time.Time
+	streams  int
+}
+
+// NewServer creates a new Server
+func NewServer(backend Backend, config *config.ClientConfig, logger *zap.Logger) *Server {
+	return &Server{
+		backend:  backend,
+		config:   config,
+		logger:   logger,
+		lastUsed: time.Now(),
+	}
+}
+
+// Serve serves clients on socket until ctx is done, vault is locked when agent stops
+func (s *Server) Serve(ctx context.Context, socket string) error {
+	listener, err := Listen(socket)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(socket)
+	defer s.lock()
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+	}()
+	go s.lockWhenIdle(ctx)
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		// only processes of the same user are served, even if permissions of socket are changed
+		uid, err := peerUID(conn)
+		if err != nil || uid != os.Getuid() {
+			s.logger.Warn("connection of another user is refused", zap.Int("uid", uid), zap.Error(err))
+			conn.Close()
+			continue
+		}
+		go s.handle(ctx, conn)
+	}
+}
+
+// Listen listens on unix socket, which is accessible only by owner
+// socket directory must not be accessible by others, stale socket of stopped agent is removed
+func Listen(socket string) (net.Listener, error) {
+	dir := filepath.Dir(socket)
+	err := os.MkdirAll(dir, 0o700)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Lstat(dir)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() || info.Mode().Perm()&0o077 != 0 {
+		return nil, fmt.Errorf("socket directory %s must be accessible only by owner", dir)
+	}
+	if conn, err := net.Dial("unix", socket); err == nil {
+		conn.Close()
+		return nil, fmt.Errorf("agent is already running on %s", socket)
+	}
+	err = os.Remove(socket)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		return nil, err
+	}
+	err = os.Chmod(socket, 0o600)
+	if err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return listener, nil
+}
+
+// lockWhenIdle locks vault after idle timeout without requests
+func (s *Server) lockWhenIdle(ctx context.Context) {
+	timeout := s.config.AgentIdleTimeout
+	if timeout <= 0 {
+		return
+	}
+	interval := timeout / 10
+	if interval < time.Second {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.mu.Lock()
+			if s.streams == 0 && s.config.AccessToken() != "" && time.Since(s.lastUsed) >= timeout {
+				s.backend.Lock()
+				s.logger.Info("vault is locked after idle timeout", zap.String("user", s.config.UserName()))
+			}
+			s.mu.Unlock()
+		}
+	}
+}
+
+// lock locks vault
+func (s *Server) lock() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.backend.Lock()
+}
+
+// handle handles one request of client
+func (s *Server) handle(ctx context.Context, conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+	var resp response
+	line, err := r.ReadBytes('\n')
+	var req request
+	if err == nil {
+		err = json.Unmarshal(line, &req)
+	}
+	if err != nil {
+		s.logger.Error("invalid request", zap.Error(err))
+		return
+	}
+
+	result, err := s.serve(ctx, req, r, w)
+	session := s.config.Session()
+	resp.User = session.User
+	resp.Unlocked = session.Token != ""
+
+	// result is sent with error too, e.g. items which are decrypted with undecryptable ones
+	if result != nil {
+		var encodeErr error
+		resp.Result, encodeErr = json.Marshal(result)
+		if encodeErr != nil && err == nil {
+			err = encodeErr
+		}
+	}
+	if err != nil {
+		resp.Error = err.Error()
+		resp.Code = errorCode(err)
+		var conflictErr *clienterrors.ConflictError
+		if errors.As(err, &conflictErr) {
+			resp.Conflict = &conflictErr.Server
+		}
+	}
+	encoded, err := json.Marshal(resp)
+	if err != nil {
+		s.logger.Error("error encoding response", zap.Error(err))
+		return
+	}
+	_, err = w.Write(append(encoded, '\n'))
+	if err == nil {
+		err = w.Flush()
+	}
+	if err != nil {
+		s.logger.Error("error sending response", zap.String("method", req.Method), zap.Error(err))
+	}
+}
+
+// serve calls backend method of request
+// content of binaries is streamed without mu, so a slow client doesn't block other clients
+func (s *Server) serve(ctx context.Context, req request, r *bufio.Reader, w *bufio.Writer) (any, error) {
+	s.mu.Lock()
+	// status is checked by clients before use, so it doesn't keep vault unlocked
+	if req.Method != methodStatus {
+		s.lastUsed = time.Now()
+	}
+	if req.ConflictPolicy != "" {
+		s.config.SetConflictPolicy(req.ConflictPolicy)
+	}
+	if req.Method != methodUploadBinary && req.Method != methodDownloadBinary {
+		defer s.mu.Unlock()
+		return s.call(ctx, req, r, w)
+	}
+	s.streams++
+	s.mu.Unlock()
+
+	result, err := s.call(ctx, req, r, w)
+	s.mu.Lock()
+	s.streams--
+	s.lastUsed = time.Now()
+	s.mu.Unlock()
+	return result, err
+}
+
+// call calls backend method of request
+func (s *Server) call(ctx context.Context, req request, r *bufio.Reader, w *bufio.Writer) (any, error) {
+	var user userParams
+	var data dataParams
+	switch req.Method {
+	case methodCreateUser, methodLoginUser, methodUpgradePassword, methodChangePassword, methodChangePassphrase,
+		methodEnrollTwoFactor, methodConfirmTwoFactor, methodDisableTwoFactor:
+		if err := json.Unmarshal(req.Params, &user); err != nil {
+			return nil, err
+		}
+	case methodSaveData, methodChangeData, methodDeleteData, methodUploadBinary, methodDownloadBinary:
+		if err := json.Unmarshal(req.Params, &data); err != nil {
+			// client of download waits for content before response
+			if req.Method == methodDownloadBinary {
+				_ = (&frameWriter{w: w}).Close()
+			}
+			return nil, err
+		}
+	}
+
+	switch req.Method {
+	case methodStatus:
+		return nil, nil
+	case methodLock:
+		s.backend.Lock()
+		return nil, nil
+	case methodLogout:
+		return nil, s.backend.Logout(ctx)
+	case methodListSessions:
+		return s.backend.ListSessions(ctx)
+	case methodRevokeSession:
+		var params sessionParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, err
+		}
+		return nil, s.backend.RevokeSession(ctx, params.ID)
+	case methodCreateUser:
+		return nil, s.backend.CreateUser(ctx, user.User, user.Password, user.Passphrase)
+	case methodLoginUser:
+		return nil, s.backend.LoginUser(ctx, user.User, user.Password, user.Passphrase, user.Code)
+	case methodUpgradePassword:
+		return nil, s.backend.UpgradePassword(ctx, user.User, user.Password, user.Passphrase, user.Code)
+	case methodChangePassword:
+		return nil, s.backend.ChangePassword(ctx, user.User, user.Password, user.NewPassword)
+	case methodChangePassphrase:
+		return nil, s.backend.ChangePassphrase(ctx, user.Passphrase, user.NewPassphrase)
+	case methodEnrollTwoFactor:
+		return s.backend.EnrollTwoFactor(ctx, user.User, user.Password)
+	case methodConfirmTwoFactor:
+		return s.backend.ConfirmTwoFactor(ctx, user.User, user.Password, user.Code)
+	case methodDisableTwoFactor:
+		return nil, s.backend.DisableTwoFactor(ctx, user.User, user.Password, user.Code)
+	case methodSaveData:
+		return nil, s.backend.SaveData(ctx, data.Data)
+	case methodChangeData:
+		return nil, s.backend.ChangeData(ctx, data.Data)
+	case methodDeleteData:
+		return nil, s.backend.DeleteData(ctx, data.Data)
+	case methodGetDataByType:
+		var params typeParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, err
+		}
+		return s.backend.GetDataByType(params.DataType)
+	case methodSync:
+		return nil, s.backend.Sync(ctx)
+	case methodFullSync:
+		return nil, s.backend.FullSync(ctx)
+	case methodFlush:
+		return nil, s.backend.Flush(ctx)
+	case methodPendingChanges:
+		return s.backend.PendingChanges()
+	case methodConflicts:
+		return s.backend.Conflicts()
+	case methodResolveConflict:
+		var params conflictParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, err
+		}
+		return nil, s.backend.ResolveConflict(ctx, params.UUID, params.Policy)
+	case methodUploadBinary:
+		content := &frameReader{r: r}
+		err := s.backend.UploadBinary(ctx, data.Data, content, data.Size, nil)
+		// the rest of content is read, so client is not blocked on sending it
+		_, _ = io.Copy(io.Discard, content)
+		return nil, err
+	case methodDownloadBinary:
+		content := &frameWriter{w: w}
+		err := s.backend.DownloadBinary(ctx, data.Data, content, nil)
+		closeErr := content.Close()
+		if err == nil {
+			err = closeErr
+		}
+		return nil, err
+	}
+	return nil, fmt.Errorf("unknown method %q", req.Method)
+}
@@ -4,9 +4,11 @@ package app
 
 import (
 	"context"
+	"time"
 
 	"go.uber.org/zap"
 
+	"github.com/h2p2f/dedicated-vault/internal/client/agent"
 	"github.com/h2p2f/dedicated-vault/internal/client/config"
 	"github.com/h2p2f/dedicated-vault/internal/client/grpcclient"
 	"github.com/h2p2f/dedicated-vault/internal/client/gui"
@@ -15,6 +17,9 @@ import (
 	"github.com/h2p2f/dedicated-vault/internal/client/usecase"
 )
 
+// replayInterval is how often local changes are pushed to server in background
+const replayInterval = 30 * time.Second
+
 // Run launches the main client logic
 func Run(ctx context.Context) {
 	var err error
@@ -23,6 +28,13 @@ func Run(ctx context.Context) {
 
 	// create logger
 	logger := zap.NewExample()
+	// unlock agent is used as backend if it is running, vault stays unlocked for CLI and GUI
+	agentClient, err := agent.Dial(ctx, conf.AgentSocket, conf)
+	if err == nil {
+		guiApp := gui.NewGraphicApp(agentClient, conf)
+		guiApp.Run(ctx)
+		return
+	}
 	//
 	db := storage.NewClientStorage(logger, conf)
 	//load tls
@@ -33,6 +45,8 @@ func Run(ctx context.Context) {
 	// create grpc client
 	tr := grpcclient.NewClient(conf, logger)
 	uc := usecase.NewClientUseCase(conf, db, tr)
+	// push local changes made offline when server becomes reachable
+	go uc.RunReplayer(ctx, replayInterval)
 	// create gui
 	guiApp := gui.NewGraphicApp(uc, conf)
 	guiApp.Run(ctx)
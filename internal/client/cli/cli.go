@@ -0,0 +1,624 @@
+// Package: cli
+// in this file we have main logic for headless command line client
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/h2p2f/dedicated-vault/internal/client/agent"
+	"github.com/h2p2f/dedicated-vault/internal/client/clienterrors"
+	"github.com/h2p2f/dedicated-vault/internal/client/config"
+	"github.com/h2p2f/dedicated-vault/internal/client/models"
+)
+
+// exit codes of command line client
+const (
+	ExitOK    = 0
+	ExitError = 1
+	ExitUsage = 2
+)
+
+const usage = `Usage: vaultcli [global flags] <command> [flags] [args]
+
+Commands:
+  register                      register user on server
+  login [-upgrade-password]     check that user can log in and unlock the vault,
+                                -upgrade-password sends password in clear once to let server
+                                of account registered before password verifiers replace it by verifier
+  list [-type type]             list items without secret values
+  get <type> <item>             print item, item is its meta or uuid
+  add <type> -meta meta         add item
+  edit <type> <item>            change fields of item, add and edit of any type take
+                                -add-url, -rm-url, -notes, -custom name[:kind]=value and -rm-custom
+  rm <type> <item>              remove item
+  sync [-full]                  sync local database with server
+  run -env NAME=item:field -- command [args]
+                                run command with fields of items in its environment,
+                                -env-file reads NAME=item:field lines from file
+  agent [-idle-timeout 15m]     run unlock agent, it keeps vault unlocked for other commands
+  lock                          lock vault in agent
+  template <file> [-out file]   render Go text/template with {{ secret "meta" "field" }}
+                                and {{ (item "meta").field }}, output file is readable only by owner
+  git-credential <get|store|erase>
+                                git credential helper, credentials are matched by url, path and login,
+                                use it with unlock agent or -password-fd and -passphrase-fd
+  ssh-agent [-socket path] [-confirm] [-askpass program]
+                                serve ssh keys of vault to ssh clients, set SSH_AUTH_SOCK to socket,
+                                keys are available only while vault is unlocked
+  otp [-type type] [-code] <item>
+                                print TOTP or HOTP code of authenticator or credentials with otp,
+                                counter of HOTP is increased
+  2fa enroll | confirm <code> | disable <code>
+                                manage two-factor authentication of user, only password is read:
+                                enroll prints otpauth URI for authenticator app,
+                                confirm enables it with code of app and prints recovery codes,
+                                disable needs a current code of app and login of user: unlocked agent
+                                or password, passphrase and -2fa-code with another code
+
+Types: %s
+
+Password and passphrase are read as lines from -password-fd and -passphrase-fd,
+by default both are read from stdin: password first, then passphrase.
+If unlock agent is running, commands are run by it and password and passphrase
+are needed only while vault is locked.
+If user has two-factor authentication, login needs -2fa-code with code of authenticator app
+or one of recovery codes.
+Results are printed to stdout as JSON, errors are printed to stderr as JSON {"error": "..."}.
+
+Global flags:
+`
+
+// Processor is an interface for processing data
+//
+//go:generate mockery --name Processor --output ./mocks --filename mocks_processor.go
+type Processor interface {
+	CreateUser(ctx context.Context, userName, password, passphrase string) error
+	LoginUser(ctx context.Context, userName, password, passphrase, code string) error
+	UpgradePassword(ctx context.Context, userName, password, passphrase, code string) error
+	EnrollTwoFactor(ctx context.Context, userName, password string) (string, error)
+	ConfirmTwoFactor(ctx context.Context, userName, password, code string) ([]string, error)
+	DisableTwoFactor(ctx context.Context, userName, password, code string) error
+	SaveData(ctx context.Context, data models.Data) error
+	ChangeData(ctx context.Context, data models.Data) error
+	DeleteData(ctx context.Context, data models.Data) error
+	GetDataByType(dataType string) ([]models.Data, error)
+	FullSync(ctx context.Context) error
+	UploadBinary(ctx context.Context, data models.Data, r io.Reader, size int64, progress func(done, total int64)) error
+	DownloadBinary(ctx context.Context, data models.Data, w io.Writer, progress func(done, total int64)) error
+}
+
+// Options - global options of command line client
+type Options struct {
+	User         string
+	PasswordFD   int
+	PassphraseFD int
+	AgentSocket  string
+	NoAgent      bool
+	// SSHAgentSocket - default socket of ssh agent
+	SSHAgentSocket string
+	// TwoFactorCode - TOTP code or recovery code for login of user with two-factor authentication
+	TwoFactorCode string
+}
+
+// ParseFlags parses global flags to options and client configuration
+// the rest of args is the command with its args
+func ParseFlags(conf *config.ClientConfig, args []string, stderr io.Writer) (*Options, []string, error) {
+	opts := &Options{SSHAgentSocket: conf.SSHAgentSocket}
+	fs := flag.NewFlagSet("vaultcli", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, usage, typesHelp())
+		fs.PrintDefaults()
+	}
+	fs.StringVar(&conf.StorageAddress, "server", conf.StorageAddress, "server address")
+	fs.StringVar(&conf.DBPath, "db", conf.DBPath, "path to local database")
+	fs.StringVar(&conf.ClientCA, "ca", conf.ClientCA, "path to CA certificate")
+	fs.StringVar(&conf.ClientCert, "cert", conf.ClientCert, "path to client certificate")
+	fs.StringVar(&conf.ClientKey, "key", conf.ClientKey, "path to client key")
+	fs.StringVar(&conf.ConflictPolicy, "conflict-policy", conf.ConflictPolicy,
+		"conflict policy: server-wins, client-wins, keep-both or ask")
+	fs.StringVar(&opts.User, "user", "", "user name, user of unlocked agent if empty")
+	fs.StringVar(&opts.AgentSocket, "agent", conf.AgentSocket, "unix socket of unlock agent")
+	fs.BoolVar(&opts.NoAgent, "no-agent", false, "don't use unlock agent even if it is running")
+	fs.IntVar(&opts.PasswordFD, "password-fd", 0, "file descriptor to read password from")
+	fs.IntVar(&opts.PassphraseFD, "passphrase-fd", 0, "file descriptor to read passphrase from")
+	fs.StringVar(&opts.TwoFactorCode, "2fa-code", "", "two-factor authentication code or recovery code for login")
+	err := fs.Parse(args)
+	if err != nil {
+		return nil, nil, err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return nil, nil, flag.ErrHelp
+	}
+	return opts, fs.Args(), nil
+}
+
+// CLI is a headless command line client
+type CLI struct {
+	processor Processor
+	opts      *Options
+	secrets   *secretReader
+	stdout    io.Writer
+	stderr    io.Writer
+	// now - current time of one-time passwords
+	now func() time.Time
+}
+
+// NewCLI creates a new CLI
+func NewCLI(proc Processor, opts *Options, stdin io.Reader, stdout, stderr io.Writer) *CLI {
+	return &CLI{
+		processor: proc,
+		opts:      opts,
+		secrets:   newSecretReader(stdin),
+		stdout:    stdout,
+		stderr:    stderr,
+		now:       time.Now,
+	}
+}
+
+// Execute runs the command and returns exit code
+func (c *CLI) Execute(ctx context.Context, args []string) int {
+	err := c.execute(ctx, args)
+	if errors.Is(err, flag.ErrHelp) {
+		return ExitUsage
+	}
+	// exit code of child process is returned as is
+	var exitErr *exitCodeError
+	if errors.As(err, &exitErr) {
+		return exitErr.code
+	}
+	var usageErr *usageError
+	if errors.As(err, &usageErr) {
+		printError(c.stderr, err)
+		return ExitUsage
+	}
+	if err != nil {
+		printError(c.stderr, err)
+		return ExitError
+	}
+	return ExitOK
+}
+
+// execute dispatches the command
+func (c *CLI) execute(ctx context.Context, args []string) error {
+	command, args := args[0], args[1:]
+	commands := map[string]func(ctx context.Context, args []string) error{
+		"register":  c.register,
+		"login":     c.login,
+		"list":      c.list,
+		"get":       c.get,
+		"add":       c.add,
+		"edit":      c.edit,
+		"rm":        c.remove,
+		"sync":      c.sync,
+		"run":       c.run,
+		"template":  c.render,
+		"lock":      c.lock,
+		"ssh-agent": c.sshAgent,
+		"otp":       c.otp,
+		"2fa":       c.twoFactor,
+		// git runs it as credential.helper "!vaultcli git-credential" with operation as the last arg
+		"git-credential": c.gitCredentialHelper,
+	}
+	run, ok := commands[command]
+	if !ok {
+		return usageErrorf("unknown command %q", command)
+	}
+	return run(ctx, args)
+}
+
+// credentials reads password and passphrase of user
+func (c *CLI) credentials() (string, string, error) {
+	password, err := c.secrets.line(c.opts.PasswordFD)
+	if err != nil {
+		return "", "", fmt.Errorf("read password: %w", err)
+	}
+	passphrase, err := c.secrets.line(c.opts.PassphraseFD)
+	if err != nil {
+		return "", "", fmt.Errorf("read passphrase: %w", err)
+	}
+	return password, passphrase, nil
+}
+
+// session is implemented by processors which keep vault unlocked between commands
+type session interface {
+	Status(ctx context.Context) (agent.Status, error)
+	Lock(ctx context.Context) error
+}
+
+// unlock logs user in, local database is synced with server by login
+// if agent keeps vault of user unlocked, password and passphrase are not needed
+func (c *CLI) unlock(ctx context.Context) error {
+	if s, ok := c.processor.(session); ok {
+		status, err := s.Status(ctx)
+		if err != nil {
+			return err
+		}
+		if status.Unlocked && (c.opts.User == "" || c.opts.User == status.User) {
+			c.opts.User = status.User
+			return nil
+		}
+	}
+	if c.opts.User == "" {
+		return usageErrorf("flag -user is required")
+	}
+	password, passphrase, err := c.credentials()
+	if err != nil {
+		return err
+	}
+	return c.processor.LoginUser(ctx, c.opts.User, password, passphrase, c.opts.TwoFactorCode)
+}
+
+// upgradePassword logs user in like unlock, but password is sent to server in clear once
+// if server has no verifier of it yet, it is refused for user known to have a verifier
+func (c *CLI) upgradePassword(ctx context.Context) error {
+	if c.opts.User == "" {
+		return usageErrorf("flag -user is required")
+	}
+	password, passphrase, err := c.credentials()
+	if err != nil {
+		return err
+	}
+	return c.processor.UpgradePassword(ctx, c.opts.User, password, passphrase, c.opts.TwoFactorCode)
+}
+
+// lock locks vault in agent
+func (c *CLI) lock(ctx context.Context, args []string) error {
+	fs := c.flagSet("lock", "")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	s, ok := c.processor.(session)
+	if !ok {
+		return errors.New("agent is not running")
+	}
+	err := s.Lock(ctx)
+	if err != nil {
+		return err
+	}
+	return c.print(map[string]string{"status": "locked"})
+}
+
+// register registers user on server
+func (c *CLI) register(ctx context.Context, args []string) error {
+	fs := c.flagSet("register", "")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if c.opts.User == "" {
+		return usageErrorf("flag -user is required")
+	}
+	password, passphrase, err := c.credentials()
+	if err != nil {
+		return err
+	}
+	err = c.processor.CreateUser(ctx, c.opts.User, password, passphrase)
+	if err != nil {
+		return err
+	}
+	return c.print(map[string]string{"user": c.opts.User, "status": "registered"})
+}
+
+// login checks that user can log in and unlock the vault
+func (c *CLI) login(ctx context.Context, args []string) error {
+	fs := c.flagSet("login", "")
+	upgrade := fs.Bool("upgrade-password", false, "send password in clear once, if server has no password verifier of user yet")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *upgrade {
+		if err := c.upgradePassword(ctx); err != nil {
+			return err
+		}
+		return c.print(map[string]string{"user": c.opts.User, "status": "logged in"})
+	}
+	if err := c.unlock(ctx); err != nil {
+		return err
+	}
+	return c.print(map[string]string{"user": c.opts.User, "status": "logged in"})
+}
+
+// list prints items without secret values
+func (c *CLI) list(ctx context.Context, args []string) error {
+	fs := c.flagSet("list", "")
+	typeName := fs.String("type", "", "type of items, all types if empty")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	types := dataTypes()
+	if *typeName != "" {
+		dataType, err := parseType(*typeName)
+		if err != nil {
+			return err
+		}
+		types = []models.FolderDataType{dataType}
+	}
+	if err := c.unlock(ctx); err != nil {
+		return err
+	}
+	items := make([]itemSummary, 0)
+	for _, dataType := range types {
+		data, err := c.dataByType(dataType)
+		if err != nil {
+			return err
+		}
+		for _, d := range data {
+			items = append(items, newItemSummary(d))
+		}
+	}
+	return c.print(items)
+}
+
+// get prints item, a single field is printed as is
+func (c *CLI) get(ctx context.Context, args []string) error {
+	fs := c.flagSet("get", "<type> <item>")
+	field := fs.String("field", "", "print only this field as is, without JSON")
+	out := fs.String("out", "", "write content of binary item to this file")
+	dataType, ref, err := c.parseItemArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := c.unlock(ctx); err != nil {
+		return err
+	}
+	data, err := c.find(dataType, ref)
+	if err != nil {
+		return err
+	}
+	if *out != "" {
+		if !hasContent(dataType) {
+			return usageErrorf("flag -out is only for binary items")
+		}
+		err = c.writeContent(ctx, data, *out)
+		if err != nil {
+			return err
+		}
+	}
+	if *field != "" {
+		value, err := fieldValue(data, *field)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(c.stdout, value)
+		return err
+	}
+	return c.print(newItem(data))
+}
+
+// add adds item
+func (c *CLI) add(ctx context.Context, args []string) error {
+	fs := c.flagSet("add", "<type>")
+	meta := fs.String("meta", "", "meta (title) of item")
+	fields := newFieldFlags(fs)
+	if len(args) == 0 {
+		fs.Usage()
+		return usageErrorf("type is required")
+	}
+	dataType, err := parseType(args[0])
+	if err != nil {
+		return err
+	}
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return usageErrorf("unexpected arguments %v", fs.Args())
+	}
+	if strings.TrimSpace(*meta) == "" {
+		return usageErrorf("flag -meta is required")
+	}
+	if hasContent(dataType) && fields.file == "" {
+		return usageErrorf("flag -file is required for binary items")
+	}
+	if err := c.unlock(ctx); err != nil {
+		return err
+	}
+	data := models.Data{
+		UUID:     uuid.New().String(),
+		Meta:     *meta,
+		DataType: dataType,
+	}
+	err = fields.apply(fs, c.secrets, &data)
+	if err != nil {
+		return err
+	}
+	if hasContent(dataType) {
+		err = c.upload(ctx, data, fields.file)
+		// uploaded binary gets the first version
+		data.Version = 1
+	} else {
+		err = c.processor.SaveData(ctx, data)
+		data.Version = 1
+	}
+	if err != nil {
+		return err
+	}
+	return c.print(newItemSummary(data))
+}
+
+// edit changes fields of item, fields without flags stay the same
+func (c *CLI) edit(ctx context.Context, args []string) error {
+	fs := c.flagSet("edit", "<type> <item>")
+	meta := fs.String("meta", "", "new meta (title) of item")
+	fields := newFieldFlags(fs)
+	dataType, ref, err := c.parseItemArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := c.unlock(ctx); err != nil {
+		return err
+	}
+	data, err := c.find(dataType, ref)
+	if err != nil {
+		return err
+	}
+	if *meta != "" {
+		data.Meta = *meta
+	}
+	err = fields.apply(fs, c.secrets, &data)
+	if err != nil {
+		return err
+	}
+	if hasContent(dataType) && fields.file != "" {
+		err = c.upload(ctx, data, fields.file)
+	} else {
+		err = c.processor.ChangeData(ctx, data)
+	}
+	if err != nil {
+		return err
+	}
+	data.Version++
+	return c.print(newItemSummary(data))
+}
+
+// remove removes item
+func (c *CLI) remove(ctx context.Context, args []string) error {
+	fs := c.flagSet("rm", "<type> <item>")
+	dataType, ref, err := c.parseItemArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := c.unlock(ctx); err != nil {
+		return err
+	}
+	data, err := c.find(dataType, ref)
+	if err != nil {
+		return err
+	}
+	err = c.processor.DeleteData(ctx, data)
+	if err != nil {
+		return err
+	}
+	return c.print(map[string]string{"uuid": data.UUID, "status": "removed"})
+}
+
+// sync syncs local database with server
+func (c *CLI) sync(ctx context.Context, args []string) error {
+	fs := c.flagSet("sync", "")
+	full := fs.Bool("full", false, "replace local database with server copy")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := c.unlock(ctx); err != nil {
+		return err
+	}
+	if *full {
+		if err := c.processor.FullSync(ctx); err != nil {
+			return err
+		}
+	}
+	return c.print(map[string]string{"status": "synced"})
+}
+
+// upload uploads content of file as content of binary item
+func (c *CLI) upload(ctx context.Context, data models.Data, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	return c.processor.UploadBinary(ctx, data, file, info.Size(), nil)
+}
+
+// writeContent writes content of binary item to file, which is readable only by owner
+func (c *CLI) writeContent(ctx context.Context, data models.Data, path string) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	err = c.processor.DownloadBinary(ctx, data, file, nil)
+	closeErr := file.Close()
+	if err != nil {
+		return err
+	}
+	return closeErr
+}
+
+// parseItemArgs parses flags of command with type and item args
+func (c *CLI) parseItemArgs(fs *flag.FlagSet, args []string) (models.FolderDataType, string, error) {
+	if len(args) < 2 {
+		fs.Usage()
+		return "", "", usageErrorf("type and item are required")
+	}
+	dataType, err := parseType(args[0])
+	if err != nil {
+		return "", "", err
+	}
+	if err := fs.Parse(args[2:]); err != nil {
+		return "", "", err
+	}
+	if fs.NArg() != 0 {
+		return "", "", usageErrorf("unexpected arguments %v", fs.Args())
+	}
+	return dataType, args[1], nil
+}
+
+// find finds item of type by its uuid or meta
+func (c *CLI) find(dataType models.FolderDataType, ref string) (models.Data, error) {
+	data, err := c.dataByType(dataType)
+	if err != nil {
+		return models.Data{}, err
+	}
+	return findItem(data, ref)
+}
+
+// dataByType gets items of type, items which can't be decrypted are reported to stderr and skipped
+func (c *CLI) dataByType(dataType models.FolderDataType) ([]models.Data, error) {
+	data, err := c.processor.GetDataByType(string(dataType))
+	if errors.Is(err, clienterrors.Undecryptable) {
+		_ = json.NewEncoder(c.stderr).Encode(map[string]string{"warning": err.Error()})
+		return data, nil
+	}
+	return data, err
+}
+
+// flagSet creates flag set of command
+func (c *CLI) flagSet(command, args string) *flag.FlagSet {
+	fs := flag.NewFlagSet(command, flag.ContinueOnError)
+	fs.SetOutput(c.stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(c.stderr, "Usage: vaultcli %s [flags] %s\n", command, args)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// print prints result as JSON
+func (c *CLI) print(v any) error {
+	return json.NewEncoder(c.stdout).Encode(v)
+}
+
+// printError prints error as JSON
+func printError(w io.Writer, err error) {
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
+
+// usageError - command is used wrong
+type usageError struct {
+	msg string
+}
+
+// Error returns error message
+func (e *usageError) Error() string {
+	return e.msg
+}
+
+// usageErrorf creates usage error
+func usageErrorf(format string, args ...any) error {
+	return &usageError{msg: fmt.Sprintf(format, args...)}
+}
@@ -0,0 +1,1089 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/h2p2f/dedicated-vault/internal/client/agent"
+	"github.com/h2p2f/dedicated-vault/internal/client/cli/mocks"
+	"github.com/h2p2f/dedicated-vault/internal/client/clienterrors"
+	"github.com/h2p2f/dedicated-vault/internal/client/models"
+)
+
+var testCredentials = []models.Data{
+	{
+		UUID:     "uuid1",
+		Meta:     "Prod DB",
+		DataType: models.FolderData.Credentials,
+		Version:  2,
+		Folder:   models.Folder{Credentials: models.Credentials{Login: "root", Password: "secret"}},
+	},
+	{
+		UUID:     "uuid2",
+		Meta:     "Staging DB",
+		DataType: models.FolderData.Credentials,
+		Version:  1,
+		Folder:   models.Folder{Credentials: models.Credentials{Login: "stage", Password: "stage secret"}},
+	},
+}
+
+func TestCLI_Execute(t *testing.T) {
+	_, sshKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(sshKey)
+	assert.NoError(t, err)
+	sshKeyPEM := string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+	tests := []struct {
+		name       string
+		args       []string
+		stdin      string
+		prepare    func(p *mocks.Processor)
+		wantCode   int
+		wantStdout string
+		wantStderr string
+	}{
+		{
+			name:  "Login",
+			args:  []string{"login"},
+			stdin: "password\npassphrase\n",
+			prepare: func(p *mocks.Processor) {
+				p.On("LoginUser", mock.Anything, "testuser", "password", "passphrase", "").Return(nil)
+			},
+			wantCode:   ExitOK,
+			wantStdout: `{"status":"logged in","user":"testuser"}`,
+		},
+		{
+			name:  "Login with password upgrade",
+			args:  []string{"login", "-upgrade-password"},
+			stdin: "password\npassphrase\n",
+			prepare: func(p *mocks.Processor) {
+				p.On("UpgradePassword", mock.Anything, "testuser", "password", "passphrase", "").Return(nil)
+			},
+			wantCode:   ExitOK,
+			wantStdout: `{"status":"logged in","user":"testuser"}`,
+		},
+		{
+			name:  "Login without password upgrade",
+			args:  []string{"login"},
+			stdin: "password\npassphrase\n",
+			prepare: func(p *mocks.Processor) {
+				p.On("LoginUser", mock.Anything, "testuser", "password", "passphrase", "").
+					Return(clienterrors.PasswordUpgradeRequired)
+			},
+			wantCode:   ExitError,
+			wantStderr: `{"error":"wrong login or password, account registered before password verifiers logs in once with password upgrade"}`,
+		},
+		{
+			name:  "Wrong passphrase",
+			args:  []string{"login"},
+			stdin: "password\nwrong\n",
+			prepare: func(p *mocks.Processor) {
+				p.On("LoginUser", mock.Anything, "testuser", "password", "wrong", "").Return(clienterrors.WrongPassphrase)
+			},
+			wantCode:   ExitError,
+			wantStderr: `{"error":"wrong passphrase"}`,
+		},
+		{
+			name:       "No passphrase",
+			args:       []string{"login"},
+			stdin:      "password",
+			prepare:    func(p *mocks.Processor) {},
+			wantCode:   ExitError,
+			wantStderr: `{"error":"read passphrase: no input on file descriptor 0"}`,
+		},
+		{
+			name:  "List",
+			args:  []string{"list", "-type", "credentials"},
+			stdin: "password\npassphrase\n",
+			prepare: func(p *mocks.Processor) {
+				p.On("LoginUser", mock.Anything, "testuser", "password", "passphrase", "").Return(nil)
+				p.On("GetDataByType", "cr").Return(testCredentials, nil)
+			},
+			wantCode: ExitOK,
+			wantStdout: `[{"uuid":"uuid1","meta":"Prod DB","type":"cr","version":2},` +
+				`{"uuid":"uuid2","meta":"Staging DB","type":"cr","version":1}]`,
+		},
+		{
+			name:  "List skips undecryptable items",
+			args:  []string{"list", "-type", "credentials"},
+			stdin: "password\npassphrase\n",
+			prepare: func(p *mocks.Processor) {
+				p.On("LoginUser", mock.Anything, "testuser", "password", "passphrase", "").Return(nil)
+				p.On("GetDataByType", "cr").Return(testCredentials[:1],
+					&clienterrors.UndecryptableError{Items: map[string]string{"uuid3": "broken"}})
+			},
+			wantCode:   ExitOK,
+			wantStdout: `[{"uuid":"uuid1","meta":"Prod DB","type":"cr","version":2}]`,
+			wantStderr: `{"warning":"some items can't be decrypted, they are skipped: uuid3: broken"}`,
+		},
+		{
+			name:  "Get by meta",
+			args:  []string{"get", "cr", "prod db"},
+			stdin: "password\npassphrase\n",
+			prepare: func(p *mocks.Processor) {
+				p.On("LoginUser", mock.Anything, "testuser", "password", "passphrase", "").Return(nil)
+				p.On("GetDataByType", "cr").Return(testCredentials, nil)
+			},
+			wantCode: ExitOK,
+			wantStdout: `{"uuid":"uuid1","meta":"Prod DB","type":"cr","version":2,` +
+				`"data":{"login":"root","otp":"","password":"secret","url":""}}`,
+		},
+		{
+			name:  "Get field by uuid",
+			args:  []string{"get", "cr", "uuid2", "-field", "password"},
+			stdin: "password\npassphrase\n",
+			prepare: func(p *mocks.Processor) {
+				p.On("LoginUser", mock.Anything, "testuser", "password", "passphrase", "").Return(nil)
+				p.On("GetDataByType", "cr").Return(testCredentials, nil)
+			},
+			wantCode:   ExitOK,
+			wantStdout: "stage secret",
+		},
+		{
+			name:  "Get unknown item",
+			args:  []string{"get", "cr", "dev db"},
+			stdin: "password\npassphrase\n",
+			prepare: func(p *mocks.Processor) {
+				p.On("LoginUser", mock.Anything, "testuser", "password", "passphrase", "").Return(nil)
+				p.On("GetDataByType", "cr").Return(testCredentials, nil)
+			},
+			wantCode:   ExitError,
+			wantStderr: `{"error":"item not found: dev db"}`,
+		},
+		{
+			name:  "Add with secret from stdin",
+			args:  []string{"add", "cr", "-meta", "Dev DB", "-login", "dev", "-secret-fd", "0"},
+			stdin: "password\npassphrase\ndev secret\n",
+			prepare: func(p *mocks.Processor) {
+				p.On("LoginUser", mock.Anything, "testuser", "password", "passphrase", "").Return(nil)
+				p.On("SaveData", mock.Anything, mock.MatchedBy(func(d models.Data) bool {
+					return d.UUID != "" && d.Meta == "Dev DB" && d.DataType == models.FolderData.Credentials &&
+						d.Folder.Credentials == models.Credentials{Login: "dev", Password: "dev secret"}
+				})).Return(nil)
+			},
+			wantCode: ExitOK,
+		},
+		{
+			name:  "Edit keeps other fields",
+			args:  []string{"edit", "cr", "Prod DB", "-login", "admin"},
+			stdin: "password\npassphrase\n",
+			prepare: func(p *mocks.Processor) {
+				p.On("LoginUser", mock.Anything, "testuser", "password", "passphrase", "").Return(nil)
+				p.On("GetDataByType", "cr").Return(testCredentials, nil)
+				p.On("ChangeData", mock.Anything, mock.MatchedBy(func(d models.Data) bool {
+					return d.UUID == "uuid1" && d.Version == 2 &&
+						d.Folder.Credentials == models.Credentials{Login: "admin", Password: "secret"}
+				})).Return(nil)
+			},
+			wantCode:   ExitOK,
+			wantStdout: `{"uuid":"uuid1","meta":"Prod DB","type":"cr","version":3}`,
+		},
+		{
+			name:       "Field of another type",
+			args:       []string{"edit", "cr", "Prod DB", "-cvv", "123"},
+			stdin:      "password\npassphrase\n",
+			wantCode:   ExitUsage,
+			wantStderr: `{"error":"flag -cvv is not supported for type cr"}`,
+			prepare: func(p *mocks.Processor) {
+				p.On("LoginUser", mock.Anything, "testuser", "password", "passphrase", "").Return(nil)
+				p.On("GetDataByType", "cr").Return(testCredentials, nil)
+			},
+		},
+		{
+			name:  "Remove",
+			args:  []string{"rm", "cr", "Staging DB"},
+			stdin: "password\npassphrase\n",
+			prepare: func(p *mocks.Processor) {
+				p.On("LoginUser", mock.Anything, "testuser", "password", "passphrase", "").Return(nil)
+				p.On("GetDataByType", "cr").Return(testCredentials, nil)
+				p.On("DeleteData", mock.Anything, testCredentials[1]).Return(nil)
+			},
+			wantCode:   ExitOK,
+			wantStdout: `{"status":"removed","uuid":"uuid2"}`,
+		},
+		{
+			name:  "Server unavailable",
+			args:  []string{"sync"},
+			stdin: "password\npassphrase\n",
+			prepare: func(p *mocks.Processor) {
+				p.On("LoginUser", mock.Anything, "testuser", "password", "passphrase", "").
+					Return(errors.New("server is unavailable"))
+			},
+			wantCode:   ExitError,
+			wantStderr: `{"error":"server is unavailable"}`,
+		},
+		{
+			name: "Run with secrets in environment",
+			args: []string{"run", "-env", "DB_PASS=Prod DB:password", "-env", "DB_USER=uuid2:login", "--",
+				"sh", "-c", `printf '%s %s' "$DB_USER" "$DB_PASS"`},
+			stdin: "password\npassphrase\n",
+			prepare: func(p *mocks.Processor) {
+				p.On("LoginUser", mock.Anything, "testuser", "password", "passphrase", "").Return(nil)
+				p.On("GetDataByType", "cr").Return(testCredentials, nil)
+				p.On("GetDataByType", "cc").Return(nil, nil)
+				p.On("GetDataByType", "tx").Return(nil, nil)
+				p.On("GetDataByType", "at").Return(nil, nil)
+			},
+			wantCode:   ExitOK,
+			wantStdout: "stage secret",
+		},
+		{
+			name:  "Run passes exit code and stdin of command",
+			args:  []string{"run", "-env", "DB_PASS=Prod DB:password", "--", "sh", "-c", `read line; exit "$line"`},
+			stdin: "password\npassphrase\n3\n",
+			prepare: func(p *mocks.Processor) {
+				p.On("LoginUser", mock.Anything, "testuser", "password", "passphrase", "").Return(nil)
+				p.On("GetDataByType", "cr").Return(testCredentials, nil)
+				p.On("GetDataByType", "cc").Return(nil, nil)
+				p.On("GetDataByType", "tx").Return(nil, nil)
+				p.On("GetDataByType", "at").Return(nil, nil)
+			},
+			wantCode: 3,
+		},
+		{
+			name:  "Run with unknown field",
+			args:  []string{"run", "-env", "DB_PASS=Prod DB:pass", "--", "true"},
+			stdin: "password\npassphrase\n",
+			prepare: func(p *mocks.Processor) {
+				p.On("LoginUser", mock.Anything, "testuser", "password", "passphrase", "").Return(nil)
+				p.On("GetDataByType", "cr").Return(testCredentials, nil)
+				p.On("GetDataByType", "cc").Return(nil, nil)
+				p.On("GetDataByType", "tx").Return(nil, nil)
+				p.On("GetDataByType", "at").Return(nil, nil)
+			},
+			wantCode: ExitError,
+			wantStderr: `{"error":"DB_PASS: item \"Prod DB\" has no field \"pass\", ` +
+				`fields: login, meta, otp, password, type, url, uuid, version"}`,
+		},
+		{
+			name:       "Run with invalid variable name",
+			args:       []string{"run", "-env", "DB-PASS=Prod DB:password", "--", "true"},
+			prepare:    func(p *mocks.Processor) {},
+			wantCode:   ExitUsage,
+			wantStderr: `{"error":"flag -env \"DB-PASS=Prod DB:password\": invalid variable name \"DB-PASS\""}`,
+		},
+		{
+			name:  "Add ssh key from stdin",
+			args:  []string{"add", "ssh", "-meta", "Deploy key", "-comment", "deploy@ci", "-confirm", "-secret-fd", "0"},
+			stdin: "password\npassphrase\n" + sshKeyPEM,
+			prepare: func(p *mocks.Processor) {
+				p.On("LoginUser", mock.Anything, "testuser", "password", "passphrase", "").Return(nil)
+				p.On("SaveData", mock.Anything, mock.MatchedBy(func(d models.Data) bool {
+					return d.DataType == models.FolderData.SSHKey && d.Folder.SSHKey.PrivateKey == strings.TrimSpace(sshKeyPEM) &&
+						strings.HasPrefix(d.Folder.SSHKey.PublicKey, "ssh-ed25519 ") &&
+						d.Folder.SSHKey.Comment == "deploy@ci" && d.Folder.SSHKey.Confirm
+				})).Return(nil)
+			},
+			wantCode: ExitOK,
+		},
+		{
+			name:  "Add invalid ssh key",
+			args:  []string{"add", "ssh", "-meta", "Deploy key", "-secret-fd", "0"},
+			stdin: "password\npassphrase\nnot a key",
+			prepare: func(p *mocks.Processor) {
+				p.On("LoginUser", mock.Anything, "testuser", "password", "passphrase", "").Return(nil)
+			},
+			wantCode:   ExitError,
+			wantStderr: `{"error":"invalid ssh key: ssh: no key found"}`,
+		},
+		{
+			name:  "Flag of ssh key for credentials",
+			args:  []string{"add", "cr", "-meta", "Dev DB", "-confirm"},
+			stdin: "password\npassphrase\n",
+			prepare: func(p *mocks.Processor) {
+				p.On("LoginUser", mock.Anything, "testuser", "password", "passphrase", "").Return(nil)
+			},
+			wantCode:   ExitUsage,
+			wantStderr: `{"error":"flag -confirm is not supported for type cr"}`,
+		},
+		{
+			name: "Add card with invalid cvv",
+			args: []string{"add", "card", "-meta", "Visa", "-number", "4111 1111 1111 1111", "-name-on-card", "J DOE",
+				"-expire-date", "12/29", "-cvv", "12a"},
+			stdin: "password\npassphrase\n",
+			prepare: func(p *mocks.Processor) {
+				p.On("LoginUser", mock.Anything, "testuser", "password", "passphrase", "").Return(nil)
+			},
+			wantCode:   ExitError,
+			wantStderr: `{"error":"invalid field: cvv must have 3 to 4 digits"}`,
+		},
+		{
+			name: "Add card",
+			args: []string{"add", "card", "-meta", "Visa", "-number", "4111 1111 1111 1111", "-name-on-card", "J DOE",
+				"-expire-date", "12/29", "-secret-fd", "0"},
+			stdin: "password\npassphrase\n123\n",
+			prepare: func(p *mocks.Processor) {
+				p.On("LoginUser", mock.Anything, "testuser", "password", "passphrase", "").Return(nil)
+				p.On("SaveData", mock.Anything, mock.MatchedBy(func(d models.Data) bool {
+					return d.Folder.Card == models.CreditCard{
+						Number: "4111 1111 1111 1111", NameOnCard: "J DOE", ExpireDate: "12/29", CVV: "123"}
+				})).Return(nil)
+			},
+			wantCode: ExitOK,
+		},
+		{
+			name:  "Add bank account with invalid iban",
+			args:  []string{"add", "bank", "-meta", "Salary", "-holder", "J DOE", "-iban", "DE88 3704 0044 0532 0130 00"},
+			stdin: "password\npassphrase\n",
+			prepare: func(p *mocks.Processor) {
+				p.On("LoginUser", mock.Anything, "testuser", "password", "passphrase", "").Return(nil)
+			},
+			wantCode:   ExitError,
+			wantStderr: `{"error":"invalid field: iban has wrong check digits"}`,
+		},
+		{
+			name: "Add bank account",
+			args: []string{"add", "bank", "-meta", "Salary", "-holder", "J DOE", "-iban", "de89 3704 0044 0532 0130 00",
+				"-swift", "cobadeffxxx"},
+			stdin: "password\npassphrase\n",
+			prepare: func(p *mocks.Processor) {
+				p.On("LoginUser", mock.Anything, "testuser", "password", "passphrase", "").Return(nil)
+				p.On("SaveData", mock.Anything, mock.MatchedBy(func(d models.Data) bool {
+					return d.DataType == models.FolderData.BankAccount && d.Folder.Fields["holder"] == "J DOE" &&
+						d.Folder.Fields["iban"] == "DE89370400440532013000" && d.Folder.Fields["swift"] == "COBADEFFXXX"
+				})).Return(nil)
+			},
+			wantCode: ExitOK,
+		},
+		{
+			name:  "Add Wi-Fi with short password",
+			args:  []string{"add", "wifi", "-meta", "Home", "-ssid", "home", "-password", "1234567"},
+			stdin: "password\npassphrase\n",
+			prepare: func(p *mocks.Processor) {
+				p.On("LoginUser", mock.Anything, "testuser", "password", "passphrase", "").Return(nil)
+			},
+			wantCode:   ExitError,
+			wantStderr: `{"error":"invalid field: password of WPA2 must have 8 to 63 characters"}`,
+		},
+		{
+			name:  "Add hidden Wi-Fi",
+			args:  []string{"add", "wifi", "-meta", "Home", "-ssid", "home;1", "-security", "wpa3", "-hidden", "-secret-fd", "0"},
+			stdin: "password\npassphrase\n12345678\n",
+			prepare: func(p *mocks.Processor) {
+				p.On("LoginUser", mock.Anything, "testuser", "password", "passphrase", "").Return(nil)
+				p.On("SaveData", mock.Anything, mock.MatchedBy(func(d models.Data) bool {
+					s, _ := models.SchemaOf(d.DataType)
+					return s.Values(&d.Folder)["wifi_uri"] == `WIFI:T:WPA;S:home\;1;P:12345678;H:true;;`
+				})).Return(nil)
+			},
+			wantCode: ExitOK,
+		},
+		{
+			name: "Add identity with invalid date",
+			args: []string{"add", "identity", "-meta", "Passport", "-full-name", "J DOE", "-document-type", "passport",
+				"-number", "X123", "-expire-date", "12/29"},
+			stdin: "password\npassphrase\n",
+			prepare: func(p *mocks.Processor) {
+				p.On("LoginUser", mock.Anything, "testuser", "password", "passphrase", "").Return(nil)
+			},
+			wantCode:   ExitError,
+			wantStderr: `{"error":"invalid field: expire_date must be a date YYYY-MM-DD"}`,
+		},
+		{
+			name:  "Add expired API token",
+			args:  []string{"add", "token", "-meta", "CI", "-token", "t0ken", "-expires", "2020-01-31"},
+			stdin: "password\npassphrase\n",
+			prepare: func(p *mocks.Processor) {
+				p.On("LoginUser", mock.Anything, "testuser", "password", "passphrase", "").Return(nil)
+				p.On("SaveData", mock.Anything, mock.MatchedBy(func(d models.Data) bool {
+					s, _ := models.SchemaOf(d.DataType)
+					return s.Values(&d.Folder)["expired"] == "true" && d.Folder.Fields["token"] == "t0ken"
+				})).Return(nil)
+			},
+			wantCode: ExitOK,
+		},
+		{
+			name:       "Unknown command",
+			args:       []string{"show"},
+			prepare:    func(p *mocks.Processor) {},
+			wantCode:   ExitUsage,
+			wantStderr: `{"error":"unknown command \"show\""}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			processor := mocks.NewProcessor(t)
+			tt.prepare(processor)
+			var stdout, stderr bytes.Buffer
+			c := NewCLI(processor, &Options{User: "testuser"}, strings.NewReader(tt.stdin), &stdout, &stderr)
+
+			code := c.Execute(context.Background(), tt.args)
+			assert.Equal(t, tt.wantCode, code)
+			if tt.wantStdout != "" {
+				assert.Equal(t, tt.wantStdout, strings.TrimSpace(stdout.String()))
+			}
+			if tt.wantStderr != "" {
+				assert.Equal(t, tt.wantStderr, strings.TrimSpace(stderr.String()))
+			}
+		})
+	}
+}
+
+func TestParseEnvFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		want    []envMapping
+		wantErr string
+	}{
+		{
+			name: "Mappings",
+			file: "# database\n\nDB_USER=Prod DB:login\nexport DB_PASS=\"Prod DB:password\"\nURL='api: prod:text'\n",
+			want: []envMapping{
+				{name: "DB_USER", item: "Prod DB", field: "login"},
+				{name: "DB_PASS", item: "Prod DB", field: "password"},
+				{name: "URL", item: "api: prod", field: "text"},
+			},
+		},
+		{
+			name:    "No field",
+			file:    "DB_USER=Prod DB:login\nDB_PASS=Prod DB\n",
+			wantErr: ".env.vault:2: expected NAME=item:field",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mappings, err := parseEnvFile(strings.NewReader(tt.file), ".env.vault")
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, mappings)
+		})
+	}
+}
+
+func TestCLI_Template(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		want     string
+		wantErr  string
+	}{
+		{
+			name: "Fields of items",
+			template: "db:\n  user: {{ secret \"prod db\" \"login\" }}\n  password: {{ secret \"Prod DB\" \"password\" }}\n" +
+				"{{ with item \"uuid2\" }}staging: {{ .login }}/{{ .password }}{{ end }}\n",
+			want: "db:\n  user: root\n  password: secret\nstaging: stage/stage secret\n",
+		},
+		{
+			name:     "Unknown item",
+			template: "password: {{ secret \"Dev DB\" \"password\" }}\n",
+			wantErr:  "item not found: Dev DB",
+		},
+		{
+			name:     "Unknown field",
+			template: "password: {{ (item \"Prod DB\").pass }}\n",
+			wantErr:  `map has no entry for key "pass"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			tmplPath := filepath.Join(dir, "config.yaml.tmpl")
+			outPath := filepath.Join(dir, "config.yaml")
+			assert.NoError(t, os.WriteFile(tmplPath, []byte(tt.template), 0o644))
+
+			processor := mocks.NewProcessor(t)
+			processor.On("LoginUser", mock.Anything, "testuser", "password", "passphrase", "").Return(nil)
+			processor.On("GetDataByType", "cr").Return(testCredentials, nil)
+			processor.On("GetDataByType", "cc").Return(nil, nil)
+			processor.On("GetDataByType", "tx").Return(nil, nil)
+			processor.On("GetDataByType", "at").Return(nil, nil)
+			var stdout, stderr bytes.Buffer
+			c := NewCLI(processor, &Options{User: "testuser"}, strings.NewReader("password\npassphrase\n"), &stdout, &stderr)
+
+			code := c.Execute(context.Background(), []string{"template", tmplPath, "-out", outPath})
+			if tt.wantErr != "" {
+				assert.Equal(t, ExitError, code)
+				var printed struct {
+					Error string `json:"error"`
+				}
+				assert.NoError(t, json.Unmarshal(stderr.Bytes(), &printed))
+				assert.Contains(t, printed.Error, tt.wantErr)
+				// nothing is written if template fails
+				_, err := os.Stat(outPath)
+				assert.True(t, os.IsNotExist(err))
+				return
+			}
+			assert.Equal(t, ExitOK, code)
+			content, err := os.ReadFile(outPath)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, string(content))
+			info, err := os.Stat(outPath)
+			assert.NoError(t, err)
+			assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+			entries, err := os.ReadDir(dir)
+			assert.NoError(t, err)
+			assert.Len(t, entries, 2)
+		})
+	}
+}
+
+// agentProcessor - processor of unlock agent
+type agentProcessor struct {
+	*mocks.Processor
+	status agent.Status
+	locked bool
+}
+
+func (p *agentProcessor) Status(ctx context.Context) (agent.Status, error) {
+	return p.status, nil
+}
+
+func (p *agentProcessor) Lock(ctx context.Context) error {
+	p.locked = true
+	return nil
+}
+
+func TestCLI_Agent(t *testing.T) {
+	tests := []struct {
+		name      string
+		user      string
+		status    agent.Status
+		stdin     string
+		wantLogin bool
+	}{
+		{
+			name:   "Unlocked agent without user",
+			status: agent.Status{User: "testuser", Unlocked: true},
+		},
+		{
+			name:   "Unlocked agent with the same user",
+			user:   "testuser",
+			status: agent.Status{User: "testuser", Unlocked: true},
+		},
+		{
+			name:      "Locked agent",
+			user:      "testuser",
+			status:    agent.Status{User: "testuser"},
+			stdin:     "password\npassphrase\n",
+			wantLogin: true,
+		},
+		{
+			name:      "Agent unlocked for another user",
+			user:      "testuser",
+			status:    agent.Status{User: "another", Unlocked: true},
+			stdin:     "password\npassphrase\n",
+			wantLogin: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			processor := &agentProcessor{Processor: mocks.NewProcessor(t), status: tt.status}
+			if tt.wantLogin {
+				processor.On("LoginUser", mock.Anything, "testuser", "password", "passphrase", "").Return(nil)
+			}
+			processor.On("GetDataByType", "cr").Return(testCredentials, nil)
+			var stdout, stderr bytes.Buffer
+			c := NewCLI(processor, &Options{User: tt.user}, strings.NewReader(tt.stdin), &stdout, &stderr)
+
+			code := c.Execute(context.Background(), []string{"get", "cr", "Prod DB", "-field", "password"})
+			assert.Equal(t, ExitOK, code)
+			assert.Equal(t, "secret\n", stdout.String())
+
+			code = c.Execute(context.Background(), []string{"lock"})
+			assert.Equal(t, ExitOK, code)
+			assert.True(t, processor.locked)
+		})
+	}
+}
+
+func TestCLI_GitCredential(t *testing.T) {
+	data := []models.Data{
+		{
+			UUID:     "uuid1",
+			Meta:     "GitHub",
+			DataType: models.FolderData.Credentials,
+			Version:  1,
+			Folder: models.Folder{Credentials: models.Credentials{
+				Login: "bot", Password: "token", URL: "https://github.com"}},
+		},
+		{
+			UUID:     "uuid2",
+			Meta:     "GitHub org",
+			DataType: models.FolderData.Credentials,
+			Version:  3,
+			Folder: models.Folder{Credentials: models.Credentials{
+				Login: "deployer", Password: "org token", URL: "https://github.com/org/"}},
+		},
+		{
+			UUID:     "uuid3",
+			Meta:     "Gitea",
+			DataType: models.FolderData.Credentials,
+			Version:  1,
+			Folder: models.Folder{Credentials: models.Credentials{
+				Login: "admin", Password: "gitea", URL: "git.example.com:3000"},
+				URLs: []string{"https://gitea.internal"}},
+		},
+	}
+	tests := []struct {
+		name       string
+		args       []string
+		stdin      string
+		prepare    func(p *mocks.Processor)
+		wantStdout string
+	}{
+		{
+			name:       "Get by host",
+			args:       []string{"get"},
+			stdin:      "protocol=https\nhost=github.com\n\n",
+			wantStdout: "username=bot\npassword=token\n",
+		},
+		{
+			name:       "Get by path prefix",
+			args:       []string{"get"},
+			stdin:      "protocol=https\nhost=github.com\npath=org/repo.git\n",
+			wantStdout: "username=deployer\npassword=org token\n",
+		},
+		{
+			name:       "Path is compared by segments",
+			args:       []string{"get"},
+			stdin:      "protocol=https\nhost=github.com\npath=organization/repo.git\n",
+			wantStdout: "username=bot\npassword=token\n",
+		},
+		{
+			name:       "Get by username",
+			args:       []string{"get"},
+			stdin:      "protocol=https\nhost=github.com\nusername=deployer\n",
+			wantStdout: "username=deployer\npassword=org token\n",
+		},
+		{
+			name:       "Get by url without protocol",
+			args:       []string{"get"},
+			stdin:      "url=https://git.example.com:3000/team/repo.git\n",
+			wantStdout: "username=admin\npassword=gitea\n",
+		},
+		{
+			name:  "Url without protocol doesn't match http",
+			args:  []string{"get"},
+			stdin: "url=http://git.example.com:3000/team/repo.git\n",
+		},
+		{
+			name:       "Get by additional url",
+			args:       []string{"get"},
+			stdin:      "protocol=https\nhost=gitea.internal\npath=team/repo.git\n",
+			wantStdout: "username=admin\npassword=gitea\n",
+		},
+		{
+			name:  "No matching credentials",
+			args:  []string{"get"},
+			stdin: "protocol=http\nhost=github.com\n",
+		},
+		{
+			name:  "Store new credentials",
+			args:  []string{"store"},
+			stdin: "protocol=https\nhost=gitlab.com\nusername=ci\npassword=secret\n",
+			prepare: func(p *mocks.Processor) {
+				p.On("SaveData", mock.Anything, mock.MatchedBy(func(d models.Data) bool {
+					return d.UUID != "" && d.Meta == "gitlab.com" && d.Folder.Credentials ==
+						models.Credentials{Login: "ci", Password: "secret", URL: "https://gitlab.com"}
+				})).Return(nil)
+			},
+		},
+		{
+			name:  "Store changed password",
+			args:  []string{"store"},
+			stdin: "protocol=https\nhost=github.com\npath=org\nusername=deployer\npassword=new token\n",
+			prepare: func(p *mocks.Processor) {
+				p.On("ChangeData", mock.Anything, mock.MatchedBy(func(d models.Data) bool {
+					return d.UUID == "uuid2" && d.Version == 3 && d.Folder.Credentials.Password == "new token"
+				})).Return(nil)
+			},
+		},
+		{
+			name:  "Store the same password",
+			args:  []string{"store"},
+			stdin: "protocol=https\nhost=github.com\nusername=bot\npassword=token\n",
+		},
+		{
+			name:  "Erase rejected credentials",
+			args:  []string{"erase"},
+			stdin: "protocol=https\nhost=github.com\nusername=bot\npassword=token\n",
+			prepare: func(p *mocks.Processor) {
+				p.On("DeleteData", mock.Anything, mock.MatchedBy(func(d models.Data) bool {
+					return d.UUID == "uuid1"
+				})).Return(nil)
+			},
+		},
+		{
+			name:  "Credentials changed since rejection are kept",
+			args:  []string{"erase"},
+			stdin: "protocol=https\nhost=github.com\nusername=bot\npassword=old token\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			processor := &agentProcessor{
+				Processor: mocks.NewProcessor(t),
+				status:    agent.Status{User: "testuser", Unlocked: true},
+			}
+			processor.On("GetDataByType", "cr").Return(data, nil)
+			if tt.prepare != nil {
+				tt.prepare(processor.Processor)
+			}
+			var stdout, stderr bytes.Buffer
+			c := NewCLI(processor, &Options{}, strings.NewReader(tt.stdin), &stdout, &stderr)
+
+			code := c.Execute(context.Background(), append([]string{"git-credential"}, tt.args...))
+			assert.Equal(t, ExitOK, code, stderr.String())
+			assert.Equal(t, tt.wantStdout, stdout.String())
+		})
+	}
+}
+
+func TestCLI_OTP(t *testing.T) {
+	// secrets of test vectors of RFC 4226 and RFC 6238
+	seed := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	seed32 := seed + "GEZDGNBVGY3TQOJQGEZA"
+	seed64 := seed + seed + seed + "GEZDGNA"
+	authenticators := []models.Data{
+		{
+			UUID: "uuid1", Meta: "Bank", DataType: models.FolderData.Authenticator, Version: 1,
+			Folder: models.Folder{Fields: map[string]string{
+				"uri": "otpauth://totp/Bank:alice?secret=" + seed + "&digits=8&issuer=Bank"}},
+		},
+		{
+			UUID: "uuid2", Meta: "Bank SHA256", DataType: models.FolderData.Authenticator, Version: 1,
+			Folder: models.Folder{Fields: map[string]string{
+				"uri": "otpauth://totp/Bank:alice?secret=" + seed32 + "&digits=8&algorithm=SHA256"}},
+		},
+		{
+			UUID: "uuid3", Meta: "Bank SHA512", DataType: models.FolderData.Authenticator, Version: 1,
+			Folder: models.Folder{Fields: map[string]string{
+				"uri": "otpauth://totp/Bank:alice?secret=" + seed64 + "&digits=8&algorithm=SHA512"}},
+		},
+		{
+			UUID: "uuid4", Meta: "Token", DataType: models.FolderData.Authenticator, Version: 5,
+			Folder: models.Folder{Fields: map[string]string{
+				"uri": "otpauth://hotp/Token?secret=" + seed + "&counter=1"}},
+		},
+	}
+	credentials := []models.Data{
+		{
+			UUID: "uuid5", Meta: "Mail", DataType: models.FolderData.Credentials, Version: 1,
+			Folder: models.Folder{Credentials: models.Credentials{Login: "alice", Password: "secret", OTP: seed}},
+		},
+		{
+			UUID: "uuid6", Meta: "Bank", DataType: models.FolderData.Credentials, Version: 1,
+			Folder: models.Folder{Credentials: models.Credentials{Login: "alice", Password: "secret"}},
+		},
+	}
+	tests := []struct {
+		name       string
+		args       []string
+		prepare    func(p *mocks.Processor)
+		wantCode   int
+		wantStdout string
+		wantStderr string
+	}{
+		{
+			name:       "TOTP SHA1",
+			args:       []string{"Bank"},
+			wantStdout: `{"uuid":"uuid1","meta":"Bank","type":"totp","code":"94287082","expires_in":1}` + "\n",
+		},
+		{
+			name:       "TOTP SHA256",
+			args:       []string{"-code", "Bank SHA256"},
+			wantStdout: "46119246\n",
+		},
+		{
+			name:       "TOTP SHA512",
+			args:       []string{"-code", "uuid3"},
+			wantStdout: "90693936\n",
+		},
+		{
+			name:       "Seed of credentials",
+			args:       []string{"-type", "cr", "-code", "Mail"},
+			wantStdout: "287082\n",
+		},
+		{
+			name: "HOTP counter is increased",
+			args: []string{"Token"},
+			prepare: func(p *mocks.Processor) {
+				p.On("ChangeData", mock.Anything, mock.MatchedBy(func(d models.Data) bool {
+					return d.UUID == "uuid4" && d.Version == 5 &&
+						d.Folder.Fields["uri"] == "otpauth://hotp/Token?counter=2&secret="+seed
+				})).Return(nil)
+			},
+			wantStdout: `{"uuid":"uuid4","meta":"Token","type":"hotp","code":"287082"}` + "\n",
+		},
+		{
+			name:       "Item without otp",
+			args:       []string{"-type", "cr", "Bank"},
+			wantCode:   ExitError,
+			wantStderr: `{"error":"item not found: Bank"}` + "\n",
+		},
+		{
+			name:       "Type without otp",
+			args:       []string{"-type", "tx", "Bank"},
+			wantCode:   ExitUsage,
+			wantStderr: `{"error":"items of type tx have no one-time password"}` + "\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			processor := &agentProcessor{
+				Processor: mocks.NewProcessor(t),
+				status:    agent.Status{User: "testuser", Unlocked: true},
+			}
+			processor.On("GetDataByType", "ot").Return(authenticators, nil).Maybe()
+			processor.On("GetDataByType", "cr").Return(credentials, nil).Maybe()
+			if tt.prepare != nil {
+				tt.prepare(processor.Processor)
+			}
+			var stdout, stderr bytes.Buffer
+			c := NewCLI(processor, &Options{}, strings.NewReader(""), &stdout, &stderr)
+			c.now = func() time.Time { return time.Unix(59, 0) }
+
+			code := c.Execute(context.Background(), append([]string{"otp"}, tt.args...))
+			assert.Equal(t, tt.wantCode, code, stderr.String())
+			assert.Equal(t, tt.wantStdout, stdout.String())
+			if tt.wantStderr != "" {
+				assert.Equal(t, tt.wantStderr, stderr.String())
+			}
+		})
+	}
+}
+
+func TestCLI_Extras(t *testing.T) {
+	data := []models.Data{
+		{
+			UUID:     "uuid1",
+			Meta:     "Router",
+			DataType: models.FolderData.Text,
+			Version:  1,
+			Folder: models.Folder{
+				Text:   models.TextData{Text: "admin console"},
+				URLs:   []string{"https://192.168.1.1", "https://router.lan"},
+				Notes:  "in the hall",
+				Custom: []models.CustomField{{Name: "pin", Kind: models.CustomFieldKinds.Hidden, Value: "1234"}},
+			},
+		},
+	}
+	tests := []struct {
+		name       string
+		args       []string
+		prepare    func(p *mocks.Processor)
+		wantCode   int
+		wantStdout string
+		wantStderr string
+	}{
+		{
+			name: "Add with extras",
+			args: []string{"add", "tx", "-meta", "NAS", "-text", "backup", "-add-url", "https://nas.lan",
+				"-add-url", "nas.example.com", "-notes", "rack 2", "-custom", "pin:hidden=0000", "-custom", "bought:date=2024-01-31"},
+			prepare: func(p *mocks.Processor) {
+				p.On("SaveData", mock.Anything, mock.MatchedBy(func(d models.Data) bool {
+					return assert.ObjectsAreEqual([]string{"https://nas.lan", "nas.example.com"}, d.Folder.URLs) &&
+						d.Folder.Notes == "rack 2" && assert.ObjectsAreEqual([]models.CustomField{
+						{Name: "pin", Kind: models.CustomFieldKinds.Hidden, Value: "0000"},
+						{Name: "bought", Kind: models.CustomFieldKinds.Date, Value: "2024-01-31"},
+					}, d.Folder.Custom)
+				})).Return(nil)
+			},
+			wantStdout: `{"uuid":"","meta":"NAS","type":"tx","version":1}`,
+		},
+		{
+			name: "Edit keeps kind of custom field",
+			args: []string{"edit", "tx", "Router", "-custom", "pin=5678", "-custom", "wps=false", "-rm-url", "https://192.168.1.1"},
+			prepare: func(p *mocks.Processor) {
+				p.On("GetDataByType", "tx").Return(data, nil)
+				p.On("ChangeData", mock.Anything, mock.MatchedBy(func(d models.Data) bool {
+					return assert.ObjectsAreEqual([]string{"https://router.lan"}, d.Folder.URLs) &&
+						d.Folder.Notes == "in the hall" && assert.ObjectsAreEqual([]models.CustomField{
+						{Name: "pin", Kind: models.CustomFieldKinds.Hidden, Value: "5678"},
+						{Name: "wps", Kind: models.CustomFieldKinds.Text, Value: "false"},
+					}, d.Folder.Custom)
+				})).Return(nil)
+			},
+			wantStdout: `{"uuid":"uuid1","meta":"Router","type":"tx","version":2}`,
+		},
+		{
+			name: "Invalid date of custom field",
+			args: []string{"edit", "tx", "Router", "-custom", "bought:date=31.01.2024"},
+			prepare: func(p *mocks.Processor) {
+				p.On("GetDataByType", "tx").Return(data, nil)
+			},
+			wantCode:   ExitError,
+			wantStderr: `{"error":"invalid field: bought must be a date YYYY-MM-DD"}`,
+		},
+		{
+			name: "Remove unknown custom field",
+			args: []string{"edit", "tx", "Router", "-rm-custom", "puk"},
+			prepare: func(p *mocks.Processor) {
+				p.On("GetDataByType", "tx").Return(data, nil)
+			},
+			wantCode:   ExitUsage,
+			wantStderr: `{"error":"item has no custom field \"puk\""}`,
+		},
+		{
+			name: "Get custom field",
+			args: []string{"get", "tx", "Router", "-field", "pin"},
+			prepare: func(p *mocks.Processor) {
+				p.On("GetDataByType", "tx").Return(data, nil)
+			},
+			wantStdout: "1234",
+		},
+		{
+			name: "Get with extras",
+			args: []string{"get", "tx", "Router"},
+			prepare: func(p *mocks.Processor) {
+				p.On("GetDataByType", "tx").Return(data, nil)
+			},
+			wantStdout: `{"uuid":"uuid1","meta":"Router","type":"tx","version":1,"data":{"text":"admin console"},` +
+				`"urls":["https://192.168.1.1","https://router.lan"],"notes":"in the hall",` +
+				`"custom":[{"name":"pin","kind":"hidden","value":"1234"}]}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			processor := &agentProcessor{
+				Processor: mocks.NewProcessor(t),
+				status:    agent.Status{User: "testuser", Unlocked: true},
+			}
+			tt.prepare(processor.Processor)
+			var stdout, stderr bytes.Buffer
+			c := NewCLI(processor, &Options{}, strings.NewReader(""), &stdout, &stderr)
+
+			code := c.Execute(context.Background(), tt.args)
+			assert.Equal(t, tt.wantCode, code, stderr.String())
+			if tt.wantStdout != "" {
+				// uuid of new item is random
+				stdout := regexp.MustCompile(`"uuid":"[0-9a-f-]{36}"`).ReplaceAllString(stdout.String(), `"uuid":""`)
+				assert.Equal(t, tt.wantStdout, strings.TrimSpace(stdout))
+			}
+			if tt.wantStderr != "" {
+				assert.Equal(t, tt.wantStderr, strings.TrimSpace(stderr.String()))
+			}
+		})
+	}
+}
+
+func TestCLI_TwoFactor(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       []string
+		code       string
+		stdin      string
+		prepare    func(p *mocks.Processor)
+		wantCode   int
+		wantStdout string
+		wantStderr string
+	}{
+		{
+			name:  "Login with code",
+			args:  []string{"login"},
+			code:  "123456",
+			stdin: "password\npassphrase\n",
+			prepare: func(p *mocks.Processor) {
+				p.On("LoginUser", mock.Anything, "testuser", "password", "passphrase", "123456").Return(nil)
+			},
+			wantCode:   ExitOK,
+			wantStdout: `{"status":"logged in","user":"testuser"}`,
+		},
+		{
+			name:  "Login without code",
+			args:  []string{"login"},
+			stdin: "password\npassphrase\n",
+			prepare: func(p *mocks.Processor) {
+				p.On("LoginUser", mock.Anything, "testuser", "password", "passphrase", "").
+					Return(clienterrors.TwoFactorRequired)
+			},
+			wantCode:   ExitError,
+			wantStderr: `{"error":"two-factor authentication code is required"}`,
+		},
+		{
+			name:  "Enroll",
+			args:  []string{"2fa", "enroll"},
+			stdin: "password\n",
+			prepare: func(p *mocks.Processor) {
+				p.On("EnrollTwoFactor", mock.Anything, "testuser", "password").Return("otpauth://totp/uri", nil)
+			},
+			wantCode:   ExitOK,
+			wantStdout: `{"provisioning_uri":"otpauth://totp/uri","status":"add uri to authenticator app and confirm it with code"}`,
+		},
+		{
+			name:  "Confirm",
+			args:  []string{"2fa", "confirm", "123456"},
+			stdin: "password\n",
+			prepare: func(p *mocks.Processor) {
+				p.On("ConfirmTwoFactor", mock.Anything, "testuser", "password", "123456").
+					Return([]string{"aaaa-bbbb-cccc-dddd"}, nil)
+			},
+			wantCode:   ExitOK,
+			wantStdout: `{"recovery_codes":["aaaa-bbbb-cccc-dddd"],"status":"enabled"}`,
+		},
+		{
+			name:  "Disable with wrong code",
+			args:  []string{"2fa", "disable", "654321"},
+			code:  "123456",
+			stdin: "password\npassphrase\n",
+			prepare: func(p *mocks.Processor) {
+				p.On("LoginUser", mock.Anything, "testuser", "password", "passphrase", "123456").Return(nil)
+				p.On("DisableTwoFactor", mock.Anything, "testuser", "password", "654321").
+					Return(clienterrors.InvalidTwoFactorCode)
+			},
+			wantCode:   ExitError,
+			wantStderr: `{"error":"invalid two-factor authentication code"}`,
+		},
+		{
+			name:  "Disable without login code",
+			args:  []string{"2fa", "disable", "654321"},
+			stdin: "password\npassphrase\n",
+			prepare: func(p *mocks.Processor) {
+				p.On("LoginUser", mock.Anything, "testuser", "password", "passphrase", "").
+					Return(clienterrors.TwoFactorRequired)
+			},
+			wantCode:   ExitError,
+			wantStderr: `{"error":"two-factor authentication code is required"}`,
+		},
+		{
+			name:     "Confirm without code",
+			args:     []string{"2fa", "confirm"},
+			prepare:  func(p *mocks.Processor) {},
+			wantCode: ExitUsage,
+		},
+		{
+			name:       "Unknown subcommand",
+			args:       []string{"2fa", "reset"},
+			prepare:    func(p *mocks.Processor) {},
+			wantCode:   ExitUsage,
+			wantStderr: `{"error":"unknown subcommand \"reset\""}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			processor := mocks.NewProcessor(t)
+			tt.prepare(processor)
+			var stdout, stderr bytes.Buffer
+			opts := &Options{User: "testuser", TwoFactorCode: tt.code}
+			c := NewCLI(processor, opts, strings.NewReader(tt.stdin), &stdout, &stderr)
+
+			code := c.Execute(context.Background(), tt.args)
+			assert.Equal(t, tt.wantCode, code)
+			if tt.wantStdout != "" {
+				assert.Equal(t, tt.wantStdout, strings.TrimSpace(stdout.String()))
+			}
+			if tt.wantStderr != "" {
+				assert.Equal(t, tt.wantStderr, strings.TrimSpace(stderr.String()))
+			}
+		})
+	}
+}
@@ -0,0 +1,269 @@
+// Package: cli
+// in this file we have git credential helper, it gives credentials of vault to git
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/h2p2f/dedicated-vault/internal/client/models"
+)
+
+// gitCredential - credential description of git credential helper protocol
+type gitCredential struct {
+	protocol string
+	host     string
+	path     string
+	username string
+	password string
+}
+
+// gitCredentialHelper runs git credential helper operation: get, store or erase
+// git sends credential description to stdin, so password and passphrase must be read from other
+// descriptors or vault must be unlocked by agent
+func (c *CLI) gitCredentialHelper(ctx context.Context, args []string) error {
+	fs := c.flagSet("git-credential", "<get|store|erase>")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return usageErrorf("operation is required")
+	}
+	operation := fs.Arg(0)
+	// description is read before unlock, so git is not blocked on writing it
+	cred, err := c.readGitCredential()
+	if err != nil {
+		return err
+	}
+	operations := map[string]func(ctx context.Context, cred gitCredential) error{
+		"get":   c.getGitCredential,
+		"store": c.storeGitCredential,
+		"erase": c.eraseGitCredential,
+	}
+	run, ok := operations[operation]
+	if !ok {
+		// helpers must ignore unknown operations, so new operations of git don't break them
+		return nil
+	}
+	if err := c.unlock(ctx); err != nil {
+		return err
+	}
+	return run(ctx, cred)
+}
+
+// readGitCredential reads credential description, it ends with empty line or end of input
+func (c *CLI) readGitCredential() (gitCredential, error) {
+	var cred gitCredential
+	r, err := c.secrets.reader(0)
+	if err != nil {
+		return cred, err
+	}
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return cred, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			return cred, nil
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return cred, fmt.Errorf("invalid git credential line %q", line)
+		}
+		switch key {
+		case "protocol":
+			cred.protocol = value
+		case "host":
+			cred.host = value
+		case "path":
+			cred.path = value
+		case "username":
+			cred.username = value
+		case "password":
+			cred.password = value
+		case "url":
+			u, err := url.Parse(value)
+			if err != nil {
+				return cred, fmt.Errorf("invalid git credential url: %w", err)
+			}
+			cred.protocol, cred.host, cred.path = u.Scheme, u.Host, strings.TrimPrefix(u.Path, "/")
+			if u.User != nil {
+				cred.username = u.User.Username()
+			}
+		}
+		// the last line has no line ending
+		if err != nil {
+			return cred, nil
+		}
+	}
+}
+
+// getGitCredential prints username and password of the best matching credentials
+// nothing is printed if there are no matching credentials, so git asks user or the next helper
+func (c *CLI) getGitCredential(ctx context.Context, cred gitCredential) error {
+	data, err := c.dataByType(models.FolderData.Credentials)
+	if err != nil {
+		return err
+	}
+	found, ok := matchGitCredential(data, cred)
+	if !ok {
+		return nil
+	}
+	_, err = fmt.Fprintf(c.stdout, "username=%s\npassword=%s\n",
+		found.Folder.Credentials.Login, found.Folder.Credentials.Password)
+	return err
+}
+
+// storeGitCredential saves credentials accepted by server
+// credentials of the same url and username are changed, new credentials are added
+func (c *CLI) storeGitCredential(ctx context.Context, cred gitCredential) error {
+	if cred.host == "" || cred.username == "" || cred.password == "" {
+		return nil
+	}
+	data, err := c.dataByType(models.FolderData.Credentials)
+	if err != nil {
+		return err
+	}
+	if found, ok := sameGitCredential(data, cred); ok {
+		if found.Folder.Credentials.Password == cred.password {
+			return nil
+		}
+		found.Folder.Credentials.Password = cred.password
+		return c.processor.ChangeData(ctx, found)
+	}
+	address := cred.url()
+	return c.processor.SaveData(ctx, models.Data{
+		UUID:     uuid.New().String(),
+		Meta:     strings.TrimPrefix(address, cred.protocol+"://"),
+		DataType: models.FolderData.Credentials,
+		Folder: models.Folder{Credentials: models.Credentials{
+			Login:    cred.username,
+			Password: cred.password,
+			URL:      address,
+		}},
+	})
+}
+
+// eraseGitCredential removes credentials rejected by server
+// only credentials with the rejected password are removed, credentials changed in vault since then stay
+func (c *CLI) eraseGitCredential(ctx context.Context, cred gitCredential) error {
+	if cred.host == "" || cred.username == "" || cred.password == "" {
+		return nil
+	}
+	data, err := c.dataByType(models.FolderData.Credentials)
+	if err != nil {
+		return err
+	}
+	found, ok := sameGitCredential(data, cred)
+	if !ok || found.Folder.Credentials.Password != cred.password {
+		return nil
+	}
+	return c.processor.DeleteData(ctx, found)
+}
+
+// url returns url of credential description without username
+func (g gitCredential) url() string {
+	u := url.URL{Scheme: g.protocol, Host: g.host}
+	if g.path != "" {
+		u.Path = "/" + g.path
+	}
+	return u.String()
+}
+
+// parseCredentialURL parses url of credentials, url without protocol is https,
+// so password is never sent over http unless url of credentials says so
+func parseCredentialURL(address string) (gitCredential, bool) {
+	address = strings.TrimSpace(address)
+	if address == "" {
+		return gitCredential{}, false
+	}
+	if !strings.Contains(address, "://") {
+		address = "https://" + address
+	}
+	u, err := url.Parse(address)
+	if err != nil || u.Host == "" {
+		return gitCredential{}, false
+	}
+	cred := gitCredential{
+		protocol: strings.ToLower(u.Scheme),
+		host:     strings.ToLower(u.Host),
+		path:     strings.Trim(u.Path, "/"),
+	}
+	if u.User != nil {
+		cred.username = u.User.Username()
+	}
+	return cred, true
+}
+
+// matchScore - how well url of credentials matches description, ok is false if it doesn't match
+// credentials with longer path are better, credentials without path are better if path is not given by git
+func matchScore(d models.Data, address string, cred gitCredential) (int, bool) {
+	item, ok := parseCredentialURL(address)
+	if !ok {
+		return 0, false
+	}
+	if item.protocol != strings.ToLower(cred.protocol) {
+		return 0, false
+	}
+	if item.host != strings.ToLower(cred.host) {
+		return 0, false
+	}
+	login := d.Folder.Credentials.Login
+	if item.username != "" {
+		login = item.username
+	}
+	if cred.username != "" && login != cred.username {
+		return 0, false
+	}
+	path := strings.Trim(cred.path, "/")
+	if path == "" {
+		if item.path == "" {
+			return 1, true
+		}
+		return 0, true
+	}
+	// path is compared by segments, so org matches org/repo.git, but not organization/repo.git
+	if item.path != "" && path != item.path && !strings.HasPrefix(path, item.path+"/") {
+		return 0, false
+	}
+	return len(item.path) + 1, true
+}
+
+// matchGitCredential finds credentials which match description best
+func matchGitCredential(data []models.Data, cred gitCredential) (models.Data, bool) {
+	var found models.Data
+	best := -1
+	for _, d := range data {
+		// any url of credentials can match, the best one is taken
+		for _, address := range append([]string{d.Folder.Credentials.URL}, d.Folder.URLs...) {
+			score, ok := matchScore(d, address, cred)
+			if ok && score > best {
+				found, best = d, score
+			}
+		}
+	}
+	return found, best >= 0
+}
+
+// sameGitCredential finds credentials of the same url and username as description
+func sameGitCredential(data []models.Data, cred gitCredential) (models.Data, bool) {
+	for _, d := range data {
+		item, ok := parseCredentialURL(d.Folder.Credentials.URL)
+		if !ok || d.Folder.Credentials.Login != cred.username {
+			continue
+		}
+		if item.protocol == strings.ToLower(cred.protocol) &&
+			item.host == strings.ToLower(cred.host) && item.path == strings.Trim(cred.path, "/") {
+			return d, true
+		}
+	}
+	return models.Data{}, false
+}
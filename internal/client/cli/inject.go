@@ -0,0 +1,204 @@
+// Package: cli
+// in this file we have injection of secrets into environment of child process
+package cli
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	"github.com/h2p2f/dedicated-vault/internal/client/models"
+)
+
+// envName - valid name of environment variable
+var envName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// secretTypes - types of items which fields can be injected into environment and templates
+var secretTypes = []models.FolderDataType{
+	models.FolderData.Credentials,
+	models.FolderData.CreditCard,
+	models.FolderData.Text,
+	models.FolderData.APIToken,
+}
+
+// envMapping - environment variable and field of item it is taken from
+type envMapping struct {
+	name  string
+	item  string
+	field string
+}
+
+// repeatedFlag - flag which can be repeated, like -env
+type repeatedFlag []string
+
+// String returns flags as string
+func (e *repeatedFlag) String() string {
+	return strings.Join(*e, ", ")
+}
+
+// Set adds flag value
+func (e *repeatedFlag) Set(value string) error {
+	*e = append(*e, value)
+	return nil
+}
+
+// exitCodeError - command finished with exit code, it is returned by the client as is
+type exitCodeError struct {
+	code int
+}
+
+// Error returns error message
+func (e *exitCodeError) Error() string {
+	return fmt.Sprintf("exit code %d", e.code)
+}
+
+// run runs command with secrets from vault in its environment
+// secrets are passed only to environment of child process, they are never written to disk
+func (c *CLI) run(ctx context.Context, args []string) error {
+	fs := c.flagSet("run", "-- command [args]")
+	var envs repeatedFlag
+	fs.Var(&envs, "env", "NAME=item:field, environment variable and field of item, can be repeated")
+	envFile := fs.String("env-file", "", "file with NAME=item:field lines")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return usageErrorf("command is required")
+	}
+	var mappings []envMapping
+	if *envFile != "" {
+		fileMappings, err := readEnvFile(*envFile)
+		if err != nil {
+			return err
+		}
+		mappings = append(mappings, fileMappings...)
+	}
+	for _, env := range envs {
+		mapping, err := parseEnvMapping(env)
+		if err != nil {
+			return usageErrorf("flag -env %q: %s", env, err)
+		}
+		mappings = append(mappings, mapping)
+	}
+	if len(mappings) == 0 {
+		return usageErrorf("flag -env or -env-file is required")
+	}
+	if err := c.unlock(ctx); err != nil {
+		return err
+	}
+	environ, err := c.resolveEnv(mappings)
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.CommandContext(ctx, fs.Arg(0), fs.Args()[1:]...)
+	cmd.Env = append(os.Environ(), environ...)
+	// password and passphrase are already read, the rest of stdin belongs to command
+	cmd.Stdin = c.secrets.rest()
+	cmd.Stdout = c.stdout
+	cmd.Stderr = c.stderr
+	err = cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return &exitCodeError{code: exitErr.ExitCode()}
+	}
+	return err
+}
+
+// secretData - items which fields can be injected
+func (c *CLI) secretData() ([]models.Data, error) {
+	var data []models.Data
+	for _, dataType := range secretTypes {
+		d, err := c.dataByType(dataType)
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, d...)
+	}
+	return data, nil
+}
+
+// resolveEnv resolves mappings to NAME=value pairs
+func (c *CLI) resolveEnv(mappings []envMapping) ([]string, error) {
+	data, err := c.secretData()
+	if err != nil {
+		return nil, err
+	}
+	environ := make([]string, 0, len(mappings))
+	for _, mapping := range mappings {
+		d, err := findItem(data, mapping.item)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", mapping.name, err)
+		}
+		value, err := fieldValue(d, mapping.field)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", mapping.name, err)
+		}
+		environ = append(environ, mapping.name+"="+value)
+	}
+	return environ, nil
+}
+
+// parseEnvMapping parses NAME=item:field
+// item is meta or uuid, meta can contain colons, so field is taken after the last one
+func parseEnvMapping(s string) (envMapping, error) {
+	name, ref, ok := strings.Cut(s, "=")
+	if !ok {
+		return envMapping{}, errors.New("expected NAME=item:field")
+	}
+	name = strings.TrimSpace(name)
+	if !envName.MatchString(name) {
+		return envMapping{}, fmt.Errorf("invalid variable name %q", name)
+	}
+	ref = strings.TrimSpace(ref)
+	pos := strings.LastIndex(ref, ":")
+	if pos <= 0 || pos == len(ref)-1 {
+		return envMapping{}, errors.New("expected NAME=item:field")
+	}
+	return envMapping{name: name, item: ref[:pos], field: ref[pos+1:]}, nil
+}
+
+// readEnvFile reads mappings from .env-style file
+// file has NAME=item:field lines, it has no secret values, so it can be committed with the project
+// empty lines and lines started with # are skipped, export prefix and quotes are allowed like in .env files
+func readEnvFile(path string) ([]envMapping, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return parseEnvFile(file, path)
+}
+
+// parseEnvFile parses mappings of .env-style file
+func parseEnvFile(r io.Reader, path string) ([]envMapping, error) {
+	var mappings []envMapping
+	scanner := bufio.NewScanner(r)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		if name, value, ok := strings.Cut(line, "="); ok {
+			value = strings.TrimSpace(value)
+			if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+				value = value[1 : len(value)-1]
+			}
+			line = name + "=" + value
+		}
+		mapping, err := parseEnvMapping(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, lineNumber, err)
+		}
+		mappings = append(mappings, mapping)
+	}
+	return mappings, scanner.Err()
+}
@@ -0,0 +1,422 @@
+// Package: cli
+// in this file we have items of command line client and their fields
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/h2p2f/dedicated-vault/internal/client/models"
+)
+
+// dataTypes - types of items in order they are listed
+func dataTypes() []models.FolderDataType {
+	schemas := models.Schemas()
+	types := make([]models.FolderDataType, 0, len(schemas))
+	for _, s := range schemas {
+		types = append(types, s.Type)
+	}
+	return types
+}
+
+// typesHelp - types accepted in args for usage
+func typesHelp() string {
+	var types []string
+	for _, s := range models.Schemas() {
+		types = append(types, fmt.Sprintf("%s (%s)", s.Type, s.Name))
+	}
+	return strings.Join(types, ", ")
+}
+
+// ErrItemNotFound - there is no item with the given uuid or meta
+var ErrItemNotFound = errors.New("item not found")
+
+// ErrAmbiguousItem - several items have the given meta
+var ErrAmbiguousItem = errors.New("several items have this meta, use uuid")
+
+// parseType parses type name, type is given by its code or name
+func parseType(name string) (models.FolderDataType, error) {
+	for _, s := range models.Schemas() {
+		if strings.EqualFold(name, string(s.Type)) || strings.EqualFold(name, s.Name) {
+			return s.Type, nil
+		}
+	}
+	return "", usageErrorf("unknown type %q", name)
+}
+
+// hasContent - items of type have binary content
+func hasContent(dataType models.FolderDataType) bool {
+	s, ok := models.SchemaOf(dataType)
+	return ok && s.Content
+}
+
+// itemSummary - item without secret values
+type itemSummary struct {
+	UUID    string `json:"uuid"`
+	Meta    string `json:"meta"`
+	Type    string `json:"type"`
+	Version int64  `json:"version"`
+}
+
+// newItemSummary creates summary of data
+func newItemSummary(d models.Data) itemSummary {
+	return itemSummary{
+		UUID:    d.UUID,
+		Meta:    d.Meta,
+		Type:    string(d.DataType),
+		Version: d.Version,
+	}
+}
+
+// item - item with fields of its type, URLs, notes and custom fields
+type item struct {
+	itemSummary
+	Data   map[string]string    `json:"data"`
+	URLs   []string             `json:"urls,omitempty"`
+	Notes  string               `json:"notes,omitempty"`
+	Custom []models.CustomField `json:"custom,omitempty"`
+}
+
+// newItem creates item of data
+func newItem(d models.Data) item {
+	return item{
+		itemSummary: newItemSummary(d),
+		Data:        typeFields(d),
+		URLs:        d.Folder.URLs,
+		Notes:       d.Folder.Notes,
+		Custom:      d.Folder.Custom,
+	}
+}
+
+// typeFields - fields of data by schema of its type, content of binary is written to file only
+func typeFields(d models.Data) map[string]string {
+	s, ok := models.SchemaOf(d.DataType)
+	if !ok {
+		return map[string]string{}
+	}
+	return s.Values(&d.Folder)
+}
+
+// itemFields - fields of data by their names in JSON output
+// notes, URLs (one per line) and custom fields are added if item has them, fields of type are not replaced by them
+func itemFields(d models.Data) map[string]string {
+	fields := typeFields(d)
+	extras := make(map[string]string, len(d.Folder.Custom)+2)
+	for _, custom := range d.Folder.Custom {
+		extras[custom.Name] = custom.Value
+	}
+	if d.Folder.Notes != "" {
+		extras["notes"] = d.Folder.Notes
+	}
+	if len(d.Folder.URLs) != 0 {
+		extras["urls"] = strings.Join(d.Folder.URLs, "\n")
+	}
+	for name, value := range extras {
+		if _, ok := fields[name]; !ok {
+			fields[name] = value
+		}
+	}
+	fields["uuid"] = d.UUID
+	fields["meta"] = d.Meta
+	fields["type"] = string(d.DataType)
+	fields["version"] = fmt.Sprint(d.Version)
+	return fields
+}
+
+// fieldValue - value of field of data
+func fieldValue(d models.Data, field string) (string, error) {
+	fields := itemFields(d)
+	value, ok := fields[field]
+	if !ok {
+		names := make([]string, 0, len(fields))
+		for name := range fields {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return "", fmt.Errorf("item %q has no field %q, fields: %s", d.Meta, field, strings.Join(names, ", "))
+	}
+	return value, nil
+}
+
+// findItem finds item by uuid or meta, meta is compared case insensitive
+func findItem(data []models.Data, ref string) (models.Data, error) {
+	var found []models.Data
+	for _, d := range data {
+		if d.UUID == ref {
+			return d, nil
+		}
+		if strings.EqualFold(strings.TrimSpace(d.Meta), strings.TrimSpace(ref)) {
+			found = append(found, d)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return models.Data{}, fmt.Errorf("%w: %s", ErrItemNotFound, ref)
+	case 1:
+		return found[0], nil
+	}
+	return models.Data{}, fmt.Errorf("%w: %s", ErrAmbiguousItem, ref)
+}
+
+// fieldFlag - flag of field of items, fields of all types have flags and only flags of item type are accepted
+type fieldFlag struct {
+	value  string
+	isBool bool
+}
+
+// String returns value of flag
+func (f *fieldFlag) String() string {
+	if f == nil {
+		return ""
+	}
+	return f.value
+}
+
+// Set sets value of flag
+func (f *fieldFlag) Set(value string) error {
+	f.value = value
+	return nil
+}
+
+// IsBoolFlag allows -flag without value for bool fields
+func (f *fieldFlag) IsBoolFlag() bool {
+	return f.isBool
+}
+
+// fieldFlags - flags for fields of items
+// flag of field is its name with dashes, for example -name-on-card for name_on_card
+type fieldFlags struct {
+	fields    map[string]*fieldFlag
+	file      string
+	keyFile   string
+	secretFD  int
+	keyPassFD int
+	// URLs, notes and custom fields of item of any type
+	addURLs  repeatedFlag
+	rmURLs   repeatedFlag
+	notes    string
+	custom   repeatedFlag
+	rmCustom repeatedFlag
+}
+
+// flagName - name of flag of field
+func flagName(field string) string {
+	return strings.ReplaceAll(field, "_", "-")
+}
+
+// secretField - field of schema read by -secret-fd, it is the first secret field
+func secretField(s *models.Schema) (*models.Field, bool) {
+	for i := range s.Fields {
+		if s.Fields[i].Secret && !s.Fields[i].ReadOnly && s.Fields[i].Kind != models.FieldKinds.Bool {
+			return &s.Fields[i], true
+		}
+	}
+	return nil, false
+}
+
+// newFieldFlags defines flags for fields of items of all types in flag set
+func newFieldFlags(fs *flag.FlagSet) *fieldFlags {
+	f := &fieldFlags{fields: make(map[string]*fieldFlag)}
+	fs.StringVar(&f.file, "file", "", "file with content of binary item")
+	fs.StringVar(&f.keyFile, "private-key-file", "", "file with private key of ssh key item")
+	fs.IntVar(&f.keyPassFD, "key-passphrase-fd", -1, "file descriptor to read passphrase of encrypted private key from")
+	fs.Var(&f.addURLs, "add-url", "add url to item of any type, can be repeated")
+	fs.Var(&f.rmURLs, "rm-url", "remove url of item, can be repeated")
+	fs.StringVar(&f.notes, "notes", "", "notes of item of any type")
+	fs.Var(&f.custom, "custom", "name[:kind]=value, set custom field of item of any type, "+
+		"kind is text, hidden, date or bool, can be repeated")
+	fs.Var(&f.rmCustom, "rm-custom", "remove custom field of item, can be repeated")
+
+	// types of each field, field with the same name can be in several types
+	var names []string
+	fieldTypes := make(map[string][]string)
+	labels := make(map[string]string)
+	bools := make(map[string]bool)
+	var secrets []string
+	for _, s := range models.Schemas() {
+		if field, ok := secretField(s); ok {
+			secrets = append(secrets, field.Name)
+		}
+		for _, field := range s.Fields {
+			if field.ReadOnly {
+				continue
+			}
+			if _, ok := fieldTypes[field.Name]; !ok {
+				names = append(names, field.Name)
+				labels[field.Name] = strings.ToLower(field.Label)
+				bools[field.Name] = field.Kind == models.FieldKinds.Bool
+			}
+			fieldTypes[field.Name] = append(fieldTypes[field.Name], s.Name)
+		}
+	}
+	for _, name := range names {
+		// flags of command are not redefined by fields
+		if fs.Lookup(flagName(name)) != nil {
+			continue
+		}
+		fl := &fieldFlag{isBool: bools[name]}
+		f.fields[name] = fl
+		fs.Var(fl, flagName(name), fmt.Sprintf("%s of %s item", labels[name], strings.Join(fieldTypes[name], " or ")))
+	}
+	fs.IntVar(&f.secretFD, "secret-fd", -1,
+		"file descriptor to read secret field from: "+strings.Join(secrets, ", "))
+	return f
+}
+
+// apply sets fields given by flags to data, the other fields stay the same
+// fields are validated by schema of item type after they are set
+func (f *fieldFlags) apply(fs *flag.FlagSet, secrets *secretReader, d *models.Data) error {
+	s, ok := models.SchemaOf(d.DataType)
+	if !ok {
+		return usageErrorf("unknown type %q", d.DataType)
+	}
+	var err error
+	fs.Visit(func(fl *flag.Flag) {
+		if err != nil {
+			return
+		}
+		switch fl.Name {
+		case "meta", "add-url", "rm-url", "notes", "custom", "rm-custom":
+			return
+		case "file":
+			if !s.Content {
+				err = usageErrorf("flag -file is only for binary items")
+			}
+			return
+		case "private-key-file", "key-passphrase-fd":
+			if d.DataType != models.FolderData.SSHKey {
+				err = usageErrorf("flag -%s is only for ssh key items", fl.Name)
+				return
+			}
+			err = f.applySSHKey(fl.Name, secrets, s, &d.Folder)
+			return
+		case "secret-fd":
+			field, ok := secretField(s)
+			if !ok {
+				err = usageErrorf("flag -secret-fd is not supported for type %s", d.DataType)
+				return
+			}
+			var secret string
+			secret, err = secrets.all(f.secretFD)
+			if err != nil {
+				err = fmt.Errorf("read secret: %w", err)
+				return
+			}
+			err = s.Set(&d.Folder, field.Name, secret)
+			return
+		}
+		name := strings.ReplaceAll(fl.Name, "-", "_")
+		field, ok := s.Field(name)
+		if !ok || field.ReadOnly {
+			err = usageErrorf("flag -%s is not supported for type %s", fl.Name, d.DataType)
+			return
+		}
+		err = s.Set(&d.Folder, name, f.fields[name].value)
+	})
+	if err != nil {
+		return err
+	}
+	if err := f.applyExtras(fs, &d.Folder); err != nil {
+		return err
+	}
+	if s.Content && f.file != "" && d.Folder.Binary.Name == "" {
+		d.Folder.Binary.Name = filepath.Base(f.file)
+	}
+	return s.Prepare(&d.Folder)
+}
+
+// applySSHKey sets field of ssh key given by flag
+func (f *fieldFlags) applySSHKey(name string, secrets *secretReader, s *models.Schema, folder *models.Folder) error {
+	switch name {
+	case "private-key-file":
+		content, err := os.ReadFile(f.keyFile)
+		if err != nil {
+			return err
+		}
+		// public key of the new private key is set again
+		folder.SSHKey.PublicKey = ""
+		return s.Set(folder, "private_key", string(content))
+	case "key-passphrase-fd":
+		passphrase, err := secrets.line(f.keyPassFD)
+		if err != nil {
+			return fmt.Errorf("read key passphrase: %w", err)
+		}
+		return s.Set(folder, "key_passphrase", passphrase)
+	}
+	return nil
+}
+
+// applyExtras sets URLs, notes and custom fields given by flags, values are removed before they are added
+func (f *fieldFlags) applyExtras(fs *flag.FlagSet, folder *models.Folder) error {
+	// slices of found item are shared with data of processor, so they are copied before they are changed
+	folder.URLs = append([]string(nil), folder.URLs...)
+	folder.Custom = append([]models.CustomField(nil), folder.Custom...)
+	for _, address := range f.rmURLs {
+		i := indexOf(folder.URLs, address)
+		if i < 0 {
+			return usageErrorf("item has no url %q", address)
+		}
+		folder.URLs = append(folder.URLs[:i], folder.URLs[i+1:]...)
+	}
+	for _, address := range f.addURLs {
+		if indexOf(folder.URLs, address) < 0 {
+			folder.URLs = append(folder.URLs, address)
+		}
+	}
+	for _, name := range f.rmCustom {
+		if !folder.RemoveCustomField(name) {
+			return usageErrorf("item has no custom field %q", name)
+		}
+	}
+	for _, value := range f.custom {
+		field, err := parseCustomFlag(value, folder)
+		if err != nil {
+			return err
+		}
+		folder.SetCustomField(field)
+	}
+	fs.Visit(func(fl *flag.Flag) {
+		if fl.Name == "notes" {
+			folder.Notes = f.notes
+		}
+	})
+	return nil
+}
+
+// parseCustomFlag parses name[:kind]=value of custom field, field without kind keeps kind of existing field
+func parseCustomFlag(value string, folder *models.Folder) (models.CustomField, error) {
+	nameKind, fieldValue, ok := strings.Cut(value, "=")
+	if !ok || nameKind == "" {
+		return models.CustomField{}, usageErrorf("custom field must be name[:kind]=value, got %q", value)
+	}
+	field := models.CustomField{Name: nameKind, Value: fieldValue}
+	if i := strings.LastIndex(nameKind, ":"); i > 0 {
+		kind, err := models.ParseCustomFieldKind(nameKind[i+1:])
+		if err != nil {
+			return models.CustomField{}, err
+		}
+		field.Name, field.Kind = nameKind[:i], kind
+	}
+	if field.Kind == "" {
+		field.Kind = models.CustomFieldKinds.Text
+		if existing, ok := folder.CustomField(field.Name); ok {
+			field.Kind = existing.Kind
+		}
+	}
+	return field, nil
+}
+
+// indexOf returns index of value in values or -1
+func indexOf(values []string, value string) int {
+	for i, v := range values {
+		if v == value {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,108 @@
+// Package: cli
+// in this file we have one-time passwords of authenticators and credentials
+package cli
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/h2p2f/dedicated-vault/internal/client/models"
+)
+
+// otpCode - one-time password of item
+type otpCode struct {
+	UUID string `json:"uuid"`
+	Meta string `json:"meta"`
+	Type string `json:"type"`
+	Code string `json:"code"`
+	// ExpiresIn - seconds TOTP code is valid, HOTP code is valid until it is used
+	ExpiresIn int `json:"expires_in,omitempty"`
+}
+
+// otp prints one-time password of item, counter of HOTP is increased and saved
+func (c *CLI) otp(ctx context.Context, args []string) error {
+	fs := c.flagSet("otp", "<item>")
+	typeName := fs.String("type", "", "type of item, all types with one-time passwords if empty")
+	codeOnly := fs.Bool("code", false, "print only the code as is, without JSON")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return usageErrorf("item is required")
+	}
+	var schemas []*models.Schema
+	for _, s := range models.Schemas() {
+		if _, ok := s.OTPField(); ok {
+			schemas = append(schemas, s)
+		}
+	}
+	if *typeName != "" {
+		dataType, err := parseType(*typeName)
+		if err != nil {
+			return err
+		}
+		s, _ := models.SchemaOf(dataType)
+		if _, ok := s.OTPField(); !ok {
+			return usageErrorf("items of type %s have no one-time password", dataType)
+		}
+		schemas = []*models.Schema{s}
+	}
+	if err := c.unlock(ctx); err != nil {
+		return err
+	}
+	// only items with one-time password are found, so credentials without it don't make meta ambiguous
+	var data []models.Data
+	for _, s := range schemas {
+		d, err := c.dataByType(s.Type)
+		if err != nil {
+			return err
+		}
+		field, _ := s.OTPField()
+		for _, item := range d {
+			if field.Value(&item.Folder) != "" {
+				data = append(data, item)
+			}
+		}
+	}
+	item, err := findItem(data, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	s, _ := models.SchemaOf(item.DataType)
+	field, _ := s.OTPField()
+	o, err := models.ParseOTP(field.Value(&item.Folder))
+	if err != nil {
+		return err
+	}
+	now := c.now()
+	code, err := o.Code(now)
+	if err != nil {
+		return err
+	}
+	result := otpCode{
+		UUID:      item.UUID,
+		Meta:      item.Meta,
+		Type:      o.Type,
+		Code:      code,
+		ExpiresIn: int(o.Remaining(now).Seconds()),
+	}
+	if o.Type == models.OTPTypeHOTP {
+		// code of counter is used once, so the next counter is saved before code is shown
+		uri, err := o.NextCounter()
+		if err != nil {
+			return err
+		}
+		if err := s.Set(&item.Folder, field.Name, uri); err != nil {
+			return err
+		}
+		if err := c.processor.ChangeData(ctx, item); err != nil {
+			return err
+		}
+	}
+	if *codeOnly {
+		_, err = fmt.Fprintln(c.stdout, result.Code)
+		return err
+	}
+	return c.print(result)
+}
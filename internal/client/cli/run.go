@@ -0,0 +1,118 @@
+// Package: cli
+// in this file we have start of command line client
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/h2p2f/dedicated-vault/internal/client/agent"
+	"github.com/h2p2f/dedicated-vault/internal/client/config"
+	"github.com/h2p2f/dedicated-vault/internal/client/grpcclient"
+	"github.com/h2p2f/dedicated-vault/internal/client/storage"
+	"github.com/h2p2f/dedicated-vault/internal/client/tlsloader"
+	"github.com/h2p2f/dedicated-vault/internal/client/usecase"
+)
+
+// agentSocketEnv - environment variable with socket of unlock agent
+const agentSocketEnv = "VAULT_AGENT_SOCK"
+
+// replayInterval is how often local changes are pushed to server by agent
+const replayInterval = 30 * time.Second
+
+// Run launches command line client and returns exit code
+// gui is not linked into command line client, so it can be used without display
+// commands are run by unlock agent if it is running
+func Run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	conf := config.NewClientConfig()
+	if socket := os.Getenv(agentSocketEnv); socket != "" {
+		conf.AgentSocket = socket
+	}
+	opts, args, err := ParseFlags(conf, args, stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		return ExitUsage
+	}
+	if err != nil {
+		printError(stderr, err)
+		return ExitUsage
+	}
+	if args[0] == "agent" {
+		return runAgent(ctx, conf, opts, args[1:], stderr)
+	}
+	if !opts.NoAgent {
+		agentClient, err := agent.Dial(ctx, opts.AgentSocket, conf)
+		if err == nil {
+			return NewCLI(agentClient, opts, stdin, stdout, stderr).Execute(ctx, args)
+		}
+		if !errors.Is(err, agent.ErrNotRunning) {
+			printError(stderr, fmt.Errorf("agent: %w", err))
+			return ExitError
+		}
+	}
+	uc, err := newUseCase(conf, newLogger(stderr, zap.WarnLevel))
+	if err != nil {
+		printError(stderr, err)
+		return ExitError
+	}
+	return NewCLI(uc, opts, stdin, stdout, stderr).Execute(ctx, args)
+}
+
+// runAgent runs unlock agent until ctx is done
+func runAgent(ctx context.Context, conf *config.ClientConfig, opts *Options, args []string, stderr io.Writer) int {
+	fs := flag.NewFlagSet("agent", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.DurationVar(&conf.AgentIdleTimeout, "idle-timeout", conf.AgentIdleTimeout,
+		"lock vault after this time without requests, 0 to keep it unlocked")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return ExitUsage
+		}
+		printError(stderr, err)
+		return ExitUsage
+	}
+	logger := newLogger(stderr, zap.InfoLevel)
+	uc, err := newUseCase(conf, logger)
+	if err != nil {
+		printError(stderr, err)
+		return ExitError
+	}
+	// local changes made while server was unavailable are pushed by agent
+	go uc.RunReplayer(ctx, replayInterval)
+	server := agent.NewServer(uc, conf, logger)
+	logger.Info("agent is running", zap.String("socket", opts.AgentSocket))
+	err = server.Serve(ctx, opts.AgentSocket)
+	if err != nil {
+		printError(stderr, err)
+		return ExitError
+	}
+	return ExitOK
+}
+
+// newUseCase creates client logic working with local database and server
+func newUseCase(conf *config.ClientConfig, logger *zap.Logger) (*usecase.ClientUseCase, error) {
+	db := storage.NewClientStorage(logger, conf)
+	tlsConfig, err := tlsloader.LoadTLS(conf.ClientCA, conf.ClientCert, conf.ClientKey)
+	if err != nil {
+		return nil, fmt.Errorf("load tls: %w", err)
+	}
+	conf.TLSConfig = tlsConfig
+	tr := grpcclient.NewClient(conf, logger)
+	return usecase.NewClientUseCase(conf, db, tr), nil
+}
+
+// newLogger creates logger, stdout is for results only, so logs go to stderr
+func newLogger(stderr io.Writer, level zapcore.Level) *zap.Logger {
+	return zap.New(zapcore.NewCore(
+		zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig()),
+		zapcore.AddSync(stderr),
+		level,
+	))
+}
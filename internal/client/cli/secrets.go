@@ -0,0 +1,90 @@
+// Package: cli
+// in this file we have non-interactive reading of secrets
+package cli
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// secretReader reads secrets from stdin and other file descriptors
+// readers are kept per descriptor, so several secrets can be read from one descriptor line by line
+type secretReader struct {
+	stdin   io.Reader
+	readers map[int]*bufio.Reader
+}
+
+// newSecretReader creates a new secretReader, descriptor 0 is read from stdin
+func newSecretReader(stdin io.Reader) *secretReader {
+	return &secretReader{
+		stdin:   stdin,
+		readers: make(map[int]*bufio.Reader),
+	}
+}
+
+// reader returns reader of file descriptor
+func (s *secretReader) reader(fd int) (*bufio.Reader, error) {
+	if r, ok := s.readers[fd]; ok {
+		return r, nil
+	}
+	var r io.Reader
+	switch {
+	case fd == 0:
+		r = s.stdin
+	case fd > 2:
+		file := os.NewFile(uintptr(fd), fmt.Sprintf("fd%d", fd))
+		if file == nil {
+			return nil, fmt.Errorf("invalid file descriptor %d", fd)
+		}
+		r = file
+	default:
+		return nil, fmt.Errorf("can't read secret from file descriptor %d", fd)
+	}
+	s.readers[fd] = bufio.NewReader(r)
+	return s.readers[fd], nil
+}
+
+// line reads one line from file descriptor, line ending is not a part of secret
+func (s *secretReader) line(fd int) (string, error) {
+	r, err := s.reader(fd)
+	if err != nil {
+		return "", err
+	}
+	line, err := r.ReadString('\n')
+	if errors.Is(err, io.EOF) && line != "" {
+		err = nil
+	}
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return "", fmt.Errorf("no input on file descriptor %d", fd)
+		}
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// all reads the rest of file descriptor, the last line ending is not a part of secret
+func (s *secretReader) all(fd int) (string, error) {
+	r, err := s.reader(fd)
+	if err != nil {
+		return "", err
+	}
+	secret, err := io.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+	text := strings.TrimSuffix(string(secret), "\n")
+	return strings.TrimSuffix(text, "\r"), nil
+}
+
+// rest returns the rest of stdin after secrets read from it
+func (s *secretReader) rest() io.Reader {
+	if r, ok := s.readers[0]; ok {
+		return r
+	}
+	return s.stdin
+}
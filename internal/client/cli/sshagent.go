@@ -0,0 +1,60 @@
+// Package: cli
+// in this file we have ssh agent command, it serves ssh keys of vault to ssh clients
+package cli
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+
+	"go.uber.org/zap"
+
+	"github.com/h2p2f/dedicated-vault/internal/client/sshagent"
+)
+
+// askpassEnv - environment variable with program asking user to confirm use of key, as in OpenSSH
+const askpassEnv = "SSH_ASKPASS"
+
+// sshAgent serves ssh keys of vault on socket until ctx is done
+// with unlock agent keys are available while agent keeps vault unlocked,
+// without it vault is unlocked for the lifetime of ssh agent and locked on exit
+func (c *CLI) sshAgent(ctx context.Context, args []string) error {
+	fs := c.flagSet("ssh-agent", "")
+	socket := fs.String("socket", c.opts.SSHAgentSocket, "unix socket of ssh agent")
+	confirmAll := fs.Bool("confirm", false, "ask to confirm every use of every key, not only of keys with -confirm")
+	askpass := fs.String("askpass", os.Getenv(askpassEnv), "program asking to confirm use of key, exit code 0 allows it")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := c.unlock(ctx); err != nil {
+		return err
+	}
+	if l, ok := c.processor.(interface{ Lock() }); ok {
+		defer l.Lock()
+	}
+	var confirm sshagent.Confirmer
+	if *askpass != "" {
+		confirm = askpassConfirmer(ctx, *askpass)
+	}
+	a := sshagent.NewAgent(c.processor, confirm, *confirmAll, newLogger(c.stderr, zap.InfoLevel))
+	err := c.print(map[string]string{"ssh_auth_sock": *socket, "status": "running"})
+	if err != nil {
+		return err
+	}
+	return a.Serve(ctx, *socket)
+}
+
+// askpassConfirmer asks to confirm use of key with askpass program, like ssh-agent of OpenSSH does for ssh-add -c
+func askpassConfirmer(ctx context.Context, program string) sshagent.Confirmer {
+	return func(prompt string) (bool, error) {
+		cmd := exec.CommandContext(ctx, program, prompt)
+		cmd.Env = append(os.Environ(), "SSH_ASKPASS_PROMPT=confirm")
+		err := cmd.Run()
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return false, nil
+		}
+		return err == nil, err
+	}
+}
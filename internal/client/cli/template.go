@@ -0,0 +1,119 @@
+// Package: cli
+// in this file we have rendering of templates with fields of vault items
+package cli
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"text/template"
+
+	"github.com/h2p2f/dedicated-vault/internal/client/models"
+)
+
+// render renders Go text/template file with fields of items
+// in template, secret "meta" "field" is a field of item and item "meta" is a map of all its fields,
+// item is found by meta or uuid like in other commands
+func (c *CLI) render(ctx context.Context, args []string) error {
+	fs := c.flagSet("template", "<template file>")
+	out := fs.String("out", "", "output file, stdout if empty")
+	mode := fs.String("mode", "0600", "permissions of output file")
+	if len(args) == 0 {
+		fs.Usage()
+		return usageErrorf("template file is required")
+	}
+	path := args[0]
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return usageErrorf("unexpected arguments %v", fs.Args())
+	}
+	perm, err := strconv.ParseUint(*mode, 8, 32)
+	if err != nil || perm&^0o777 != 0 {
+		return usageErrorf("invalid mode %q", *mode)
+	}
+	text, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := c.unlock(ctx); err != nil {
+		return err
+	}
+	data, err := c.secretData()
+	if err != nil {
+		return err
+	}
+	// template is rendered to memory, so no partial output is written if it fails
+	rendered, err := renderTemplate(filepath.Base(path), string(text), data)
+	if err != nil {
+		return err
+	}
+	if *out == "" {
+		_, err = c.stdout.Write(rendered)
+		return err
+	}
+	err = writeFileAtomic(*out, rendered, os.FileMode(perm))
+	if err != nil {
+		return err
+	}
+	return c.print(map[string]string{"output": *out, "status": "rendered"})
+}
+
+// renderTemplate renders template with fields of data
+func renderTemplate(name, text string, data []models.Data) ([]byte, error) {
+	funcs := template.FuncMap{
+		"secret": func(ref, field string) (string, error) {
+			d, err := findItem(data, ref)
+			if err != nil {
+				return "", err
+			}
+			return fieldValue(d, field)
+		},
+		"item": func(ref string) (map[string]string, error) {
+			d, err := findItem(data, ref)
+			if err != nil {
+				return nil, err
+			}
+			return itemFields(d), nil
+		},
+	}
+	tmpl, err := template.New(name).Funcs(funcs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	err = tmpl.Execute(&buf, nil)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeFileAtomic writes file with permissions, file is replaced only after it is written completely
+// temporary file is created with owner only permissions, so secrets are never readable by others
+func writeFileAtomic(path string, content []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(content)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("write %s: %w", path, err)
+	}
+	err = os.Chmod(tmp.Name(), perm)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,87 @@
+// Package: cli
+// in this file we have two-factor authentication of user
+package cli
+
+import (
+	"context"
+	"fmt"
+)
+
+// twoFactor manages two-factor authentication of user, only password of user is read to enroll,
+// so user can enroll when server doesn't let users without it log in; disable needs login of user
+func (c *CLI) twoFactor(ctx context.Context, args []string) error {
+	fs := c.flagSet("2fa", "enroll | confirm <code> | disable <code>")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return usageErrorf("subcommand is required")
+	}
+	command, args := fs.Arg(0), fs.Args()[1:]
+	switch {
+	case command != "enroll" && command != "confirm" && command != "disable":
+		return usageErrorf("unknown subcommand %q", command)
+	case command == "enroll" && len(args) != 0:
+		return usageErrorf("unexpected arguments %v", args)
+	case command != "enroll" && len(args) != 1:
+		fs.Usage()
+		return usageErrorf("code is required")
+	}
+	if c.opts.User == "" {
+		return usageErrorf("flag -user is required")
+	}
+	var password string
+	var err error
+	if command == "disable" {
+		password, err = c.twoFactorLogin(ctx)
+	} else {
+		password, err = c.secrets.line(c.opts.PasswordFD)
+	}
+	if err != nil {
+		return err
+	}
+	switch command {
+	case "enroll":
+		uri, err := c.processor.EnrollTwoFactor(ctx, c.opts.User, password)
+		if err != nil {
+			return err
+		}
+		return c.print(map[string]string{"provisioning_uri": uri, "status": "add uri to authenticator app and confirm it with code"})
+	case "confirm":
+		recoveryCodes, err := c.processor.ConfirmTwoFactor(ctx, c.opts.User, password, args[0])
+		if err != nil {
+			return err
+		}
+		return c.print(map[string]any{"recovery_codes": recoveryCodes, "status": "enabled"})
+	default:
+		err := c.processor.DisableTwoFactor(ctx, c.opts.User, password, args[0])
+		if err != nil {
+			return err
+		}
+		return c.print(map[string]string{"status": "disabled"})
+	}
+}
+
+// twoFactorLogin logs user in before two-factor authentication is disabled and returns password of user,
+// vault of user unlocked by agent is used, otherwise login needs -2fa-code besides password and passphrase
+func (c *CLI) twoFactorLogin(ctx context.Context) (string, error) {
+	if s, ok := c.processor.(session); ok {
+		status, err := s.Status(ctx)
+		if err != nil {
+			return "", err
+		}
+		if status.Unlocked && status.User == c.opts.User {
+			password, err := c.secrets.line(c.opts.PasswordFD)
+			if err != nil {
+				return "", fmt.Errorf("read password: %w", err)
+			}
+			return password, nil
+		}
+	}
+	password, passphrase, err := c.credentials()
+	if err != nil {
+		return "", err
+	}
+	return password, c.processor.LoginUser(ctx, c.opts.User, password, passphrase, c.opts.TwoFactorCode)
+}
@@ -1,5 +1,83 @@
 package clienterrors
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
 
-var UserNotFound = errors.New("user not found")
+	"github.com/h2p2f/dedicated-vault/internal/client/models"
+)
+
+var (
+	UserNotFound      = errors.New("user not found")
+	VersionConflict   = errors.New("data was changed on another device")
+	ServerUnavailable = errors.New("server is unavailable")
+	DataAlreadyExists = errors.New("data already exists on server")
+	DataNotFound      = errors.New("data not found on server")
+	PendingChanges    = errors.New("there are local changes not pushed to server")
+	LocalDataNotFound = errors.New("data not found in local storage")
+	ConflictNotFound  = errors.New("conflict not found")
+	WrongPassphrase   = errors.New("wrong passphrase")
+	KeyMismatch       = errors.New("vault key on server does not match the key used on this device")
+	Undecryptable     = errors.New("some items can't be decrypted")
+
+	LegacyDataNotMigrated = errors.New("data in legacy format is not migrated yet, it can't be opened after passphrase change")
+
+	TwoFactorRequired           = errors.New("two-factor authentication code is required")
+	TwoFactorEnrollmentRequired = errors.New("server requires two-factor authentication, enable it to log in")
+	InvalidTwoFactorCode        = errors.New("invalid two-factor authentication code")
+	TwoFactorAlreadyEnabled     = errors.New("two-factor authentication is already enabled")
+	TwoFactorLocked             = errors.New("too many wrong two-factor authentication codes, try again later")
+	AnotherUserLoggedIn         = errors.New("another user is logged in, log out first")
+
+	WrongPassword        = errors.New("wrong login or password")
+	ServerProofMismatch  = errors.New("server failed to prove it knows password verifier")
+	WeakPasswordParams   = errors.New("server sent too weak parameters of password key")
+	CostlyPasswordParams = errors.New("server sent too costly parameters of password key")
+
+	PasswordUpgradeRequired = errors.New("wrong login or password, account registered before password verifiers logs in once with password upgrade")
+	PasswordUpgradeRefused  = errors.New("wrong login or password, account has a password verifier, password is not sent to server in clear")
+	TooManyHandshakes       = errors.New("too many password authentications in progress, try again later")
+)
+
+// UndecryptableError is returned with items which are decrypted, when other items can't be decrypted
+// Items are uuids of undecryptable items with reasons
+type UndecryptableError struct {
+	Items map[string]string
+}
+
+// Error returns error message with every undecryptable item
+func (e *UndecryptableError) Error() string {
+	uuids := make([]string, 0, len(e.Items))
+	for uuid := range e.Items {
+		uuids = append(uuids, uuid)
+	}
+	sort.Strings(uuids)
+	reasons := make([]string, 0, len(uuids))
+	for _, uuid := range uuids {
+		reasons = append(reasons, uuid+": "+e.Items[uuid])
+	}
+	return fmt.Sprintf("%s, they are skipped: %s", Undecryptable, strings.Join(reasons, "; "))
+}
+
+// Unwrap allows errors.Is(err, Undecryptable)
+func (e *UndecryptableError) Unwrap() error {
+	return Undecryptable
+}
+
+// ConflictError is returned when server has another version of data than the client expected
+// Server holds the current server copy of data
+type ConflictError struct {
+	Server models.StoredData
+}
+
+// Error returns error message
+func (e *ConflictError) Error() string {
+	return VersionConflict.Error()
+}
+
+// Unwrap allows errors.Is(err, VersionConflict)
+func (e *ConflictError) Unwrap() error {
+	return VersionConflict
+}
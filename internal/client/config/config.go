@@ -2,7 +2,12 @@
 package config
 
 import (
+	"fmt"
+	"os"
+	"path/filepath"
 	"runtime"
+	"sync"
+	"time"
 
 	"google.golang.org/grpc/credentials"
 )
@@ -19,7 +24,10 @@ var (
 
 // ClientConfig is a struct for client configuration
 // yaml tags currently not used
+// User, Passphrase, Token, RefreshToken, TokenExpires, ConflictPolicy and LastServerUpdated are changed
+// by login, token refresh and agent while replayer uses them, so they are accessed with methods of config under mu
 type ClientConfig struct {
+	mu                sync.RWMutex
 	StorageAddress    string `yaml:"storage_address"`
 	DBPath            string `yaml:"db_path"`
 	Secret            string `yaml:"secret"`
@@ -32,9 +40,24 @@ type ClientConfig struct {
 	CryptoKey         []byte `yaml:"crypto_key"`
 	IsLoggedIn        bool   `yaml:"is_logged_in"`
 	LastServerUpdated int64  `yaml:"last_server_updated"`
-	TLSConfig         credentials.TransportCredentials
-	Version           string `yaml:"version"`
-	BuildDate         string `yaml:"build_date"`
+	ConflictPolicy    string `yaml:"conflict_policy"`
+	// KDF* are Argon2id params, KDFMemory is in KiB, master key is not wrapped with less than 8 MiB
+	KDFTime    uint32 `yaml:"kdf_time"`
+	KDFMemory  uint32 `yaml:"kdf_memory"`
+	KDFThreads uint8  `yaml:"kdf_threads"`
+	// DeviceName is name of this device in list of sessions of user
+	DeviceName string `yaml:"device_name"`
+	// RefreshToken gets new access token of session, TokenExpires is unix time when access token expires
+	RefreshToken string `yaml:"refresh_token"`
+	TokenExpires int64  `yaml:"token_expires"`
+	// AgentSocket is unix socket of unlock agent, AgentIdleTimeout is how long agent keeps vault unlocked without use
+	AgentSocket      string        `yaml:"agent_socket"`
+	AgentIdleTimeout time.Duration `yaml:"agent_idle_timeout"`
+	// SSHAgentSocket is unix socket of ssh agent serving ssh keys of vault
+	SSHAgentSocket string `yaml:"ssh_agent_socket"`
+	TLSConfig      credentials.TransportCredentials
+	Version        string `yaml:"version"`
+	BuildDate      string `yaml:"build_date"`
 }
 
 // NewClientConfig - function of obtaining the client configuration
@@ -56,7 +79,102 @@ func NewClientConfig() *ClientConfig {
 		ClientCert:     cert,
 		ClientKey:      key,
 		TLSConfig:      nil,
-		Version:        version,
-		BuildDate:      buildDate,
+		ConflictPolicy: "ask",
+		// Argon2id parameters for new data, memory is in KiB
+		KDFTime:    3,
+		KDFMemory:  64 * 1024,
+		KDFThreads: 4,
+		// socket is in directory of user, so other users can't connect to it
+		AgentSocket:      filepath.Join(os.TempDir(), fmt.Sprintf("dedicated-vault-%d", os.Getuid()), "agent.sock"),
+		AgentIdleTimeout: 15 * time.Minute,
+		SSHAgentSocket:   filepath.Join(os.TempDir(), fmt.Sprintf("dedicated-vault-%d", os.Getuid()), "ssh-agent.sock"),
+		DeviceName:       deviceName(),
+		Version:          version,
+		BuildDate:        buildDate,
 	}
 }
+
+// deviceName - name of device is its host name
+func deviceName() string {
+	name, err := os.Hostname()
+	if err != nil || name == "" {
+		return runtime.GOOS
+	}
+	return name
+}
+
+// Session - user and tokens of logged in user, Token is empty while vault is locked
+type Session struct {
+	User         string
+	Passphrase   string
+	Token        string
+	RefreshToken string
+	TokenExpires int64
+}
+
+// Session returns user and tokens
+func (c *ClientConfig) Session() Session {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return Session{
+		User:         c.User,
+		Passphrase:   c.Passphrase,
+		Token:        c.Token,
+		RefreshToken: c.RefreshToken,
+		TokenExpires: c.TokenExpires,
+	}
+}
+
+// UpdateSession changes user and tokens with update at once
+func (c *ClientConfig) UpdateSession(update func(s *Session)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := Session{
+		User:         c.User,
+		Passphrase:   c.Passphrase,
+		Token:        c.Token,
+		RefreshToken: c.RefreshToken,
+		TokenExpires: c.TokenExpires,
+	}
+	update(&s)
+	c.User = s.User
+	c.Passphrase = s.Passphrase
+	c.Token = s.Token
+	c.RefreshToken = s.RefreshToken
+	c.TokenExpires = s.TokenExpires
+}
+
+// UserName returns name of user
+func (c *ClientConfig) UserName() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.User
+}
+
+// AccessToken returns access token, it is empty while vault is locked
+func (c *ClientConfig) AccessToken() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.Token
+}
+
+// ConflictPolicyOf returns policy of conflicts with server changes
+func (c *ClientConfig) ConflictPolicyOf() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.ConflictPolicy
+}
+
+// SetConflictPolicy sets policy of conflicts with server changes
+func (c *ClientConfig) SetConflictPolicy(policy string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ConflictPolicy = policy
+}
+
+// SetLastServerUpdated sets time of the last change on server
+func (c *ClientConfig) SetLastServerUpdated(updated int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.LastServerUpdated = updated
+}
@@ -4,21 +4,51 @@ package grpcclient
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
 
 	"go.uber.org/zap"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
+	"github.com/h2p2f/dedicated-vault/internal/client/clienterrors"
 	"github.com/h2p2f/dedicated-vault/internal/client/config"
 	"github.com/h2p2f/dedicated-vault/internal/client/grpcclient/middlewares"
+	"github.com/h2p2f/dedicated-vault/internal/client/models"
 	pb "github.com/h2p2f/dedicated-vault/proto"
 	//"google.golang.org/grpc/credentials"
 )
 
+// reasonErrors - errors recognized by reason of error details of server
+var reasonErrors = map[string]error{
+	"TWO_FACTOR_REQUIRED":            clienterrors.TwoFactorRequired,
+	"TWO_FACTOR_ENROLLMENT_REQUIRED": clienterrors.TwoFactorEnrollmentRequired,
+	"INVALID_TWO_FACTOR_CODE":        clienterrors.InvalidTwoFactorCode,
+	"TWO_FACTOR_ALREADY_ENABLED":     clienterrors.TwoFactorAlreadyEnabled,
+	"TWO_FACTOR_LOCKED":              clienterrors.TwoFactorLocked,
+	"WRONG_PASSWORD":                 clienterrors.WrongPassword,
+	"INVALID_PASSWORD_PROOF":         clienterrors.WrongPassword,
+	"TOO_MANY_HANDSHAKES":            clienterrors.TooManyHandshakes,
+}
+
+// syncLimit - the most changes requested by one SyncChanges
+const syncLimit = 200
+
+// refreshMargin - access token is refreshed before call if it expires earlier than in margin
+const refreshMargin = 30 * time.Second
+
 // Client is a struct for grpc client
+// it is used by replayer and user at once, so it keeps no connection, every call has its own one
 type Client struct {
-	pb.DedicatedVaultClient
 	config *config.ClientConfig
 	logger *zap.Logger
+	// tokenMu doesn't allow concurrent calls to refresh the same token twice, refresh token is rotated by server
+	tokenMu sync.Mutex
 }
 
 // NewClient creates a new Client
@@ -29,151 +59,581 @@ func NewClient(config *config.ClientConfig, logger *zap.Logger) *Client {
 	}
 }
 
-// Connect connects to the server
-func (c *Client) Connect() (*grpc.ClientConn, error) {
+// Connect connects to the server and returns client of the connection, the caller closes the connection
+func (c *Client) Connect() (pb.DedicatedVaultClient, *grpc.ClientConn, error) {
 
 	opts := []grpc.DialOption{
 		grpc.WithTransportCredentials(c.config.TLSConfig),
-		grpc.WithUnaryInterceptor(middlewares.JWTInjectorUnaryClientInterceptor(c.config.Token)),
+		grpc.WithUnaryInterceptor(middlewares.JWTInjectorUnaryClientInterceptor(c.accessToken, c.refreshRejected)),
+		grpc.WithStreamInterceptor(middlewares.JWTInjectorStreamClientInterceptor(c.accessToken)),
 	}
 	conn, err := grpc.Dial(c.config.StorageAddress, opts...)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	c.DedicatedVaultClient = pb.NewDedicatedVaultClient(conn)
-	return conn, nil
+	return pb.NewDedicatedVaultClient(conn), conn, nil
 }
 
-// Register registers a new user
+// closeConn closes connection of one call
+func (c *Client) closeConn(conn *grpc.ClientConn) {
+	if err := conn.Close(); err != nil {
+		c.logger.Error("failed to close connection", zap.Error(err))
+	}
+}
+
+// Register registers a new user, only verifier of password is sent to server
 func (c *Client) Register(ctx context.Context, user *pb.User) (string, error) {
-	conn, err := c.Connect()
+	verifier, err := newVerifier(user.Password)
+	if err != nil {
+		return "", err
+	}
+	client, conn, err := c.Connect()
 	if err != nil {
 		return "", err
 	}
+	defer c.closeConn(conn)
 
-	resp, err := c.DedicatedVaultClient.Register(ctx, &pb.RegisterRequest{
-		User: user,
+	resp, err := client.RegisterVerifier(ctx, &pb.RegisterVerifierRequest{
+		Name:       user.Name,
+		Verifier:   verifier,
+		DeviceName: c.config.DeviceName,
 	})
+	if err != nil {
+		return "", transportError(err)
+	}
+	c.setTokens(resp.Token, resp.RefreshToken, resp.ExpiresIn)
+	c.config.SetLastServerUpdated(resp.LastServerUpdated)
+	return resp.Token, nil
+}
+
+// Login logs in a user by proof of password, code is TOTP code or recovery code of user with two-factor authentication
+// password is never sent in clear here, user registered before verifiers gets WrongPassword like unknown login;
+// user who has to enroll two-factor authentication gets TwoFactorEnrollmentRequired, its tokens are saved,
+// they are accepted only to enroll
+func (c *Client) Login(ctx context.Context, user *pb.User, code string) (string, error) {
+	client, conn, err := c.Connect()
 	if err != nil {
 		return "", err
 	}
-	c.config.Token = resp.Token
-	c.config.LastServerUpdated = resp.LastServerUpdated
-	err = conn.Close()
+	defer c.closeConn(conn)
+	token, err := c.loginWithProof(ctx, client, user, code)
 	if err != nil {
 		return "", err
 	}
-	return resp.Token, nil
+	return token, nil
 }
 
-// Login logs in a user
-func (c *Client) Login(ctx context.Context, user *pb.User) (string, error) {
-	conn, err := c.Connect()
+// UpgradePassword logs in a user registered before verifiers by password sent in clear,
+// server replaces its bcrypt hash by verifier then, so it is done only once and only when user asks for it
+func (c *Client) UpgradePassword(ctx context.Context, user *pb.User, code string) (string, error) {
+	client, conn, err := c.Connect()
 	if err != nil {
 		return "", err
 	}
-	resp, err := c.DedicatedVaultClient.Login(ctx, &pb.LoginRequest{
-		User: user,
+	defer c.closeConn(conn)
+	token, err := c.loginWithPassword(ctx, client, user, code)
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// loginWithPassword logs in a user by password sent to server
+func (c *Client) loginWithPassword(ctx context.Context, client pb.DedicatedVaultClient, user *pb.User, code string) (string, error) {
+	resp, err := client.Login(ctx, &pb.LoginRequest{
+		User:       user,
+		DeviceName: c.config.DeviceName,
+		OtpCode:    code,
 	})
+	if err != nil {
+		return "", transportError(err)
+	}
+	c.setTokens(resp.Token, resp.RefreshToken, resp.ExpiresIn)
+	if resp.TwoFactorEnrollmentRequired {
+		return "", clienterrors.TwoFactorEnrollmentRequired
+	}
+	c.config.SetLastServerUpdated(resp.LastServerUpdated)
+	return resp.Token, nil
+}
+
+// loginWithProof logs in a user by proof of password, tokens are saved only if server proves it knows verifier
+func (c *Client) loginWithProof(ctx context.Context, client pb.DedicatedVaultClient, user *pb.User, code string) (string, error) {
+	proof, handshake, err := c.passwordProof(ctx, client, user)
 	if err != nil {
 		return "", err
 	}
-	c.config.Token = resp.Token
-	c.config.LastServerUpdated = resp.LastServerUpdated
-	err = conn.Close()
+	resp, err := client.FinishLogin(ctx, &pb.FinishLoginRequest{
+		Proof:      proof,
+		DeviceName: c.config.DeviceName,
+		OtpCode:    code,
+	})
 	if err != nil {
+		return "", transportError(err)
+	}
+	if err = c.verifyServer(handshake, resp.ServerProof); err != nil {
 		return "", err
 	}
+	c.setTokens(resp.Token, resp.RefreshToken, resp.ExpiresIn)
+	if resp.TwoFactorEnrollmentRequired {
+		return "", clienterrors.TwoFactorEnrollmentRequired
+	}
+	c.config.SetLastServerUpdated(resp.LastServerUpdated)
 	return resp.Token, nil
 }
 
-// ChangePassword changes password for a user
+// ChangePassword changes password for a user, proof of the current password and verifier of the new one are sent
+// server revokes all sessions of user, so tokens of the new session are saved, only if server proves it knows verifier
 func (c *Client) ChangePassword(ctx context.Context, user *pb.User, newPassword string) (string, error) {
-	resp, err := c.DedicatedVaultClient.ChangePassword(ctx, &pb.ChangePasswordRequest{
-		User:        user,
-		NewPassword: newPassword,
+	verifier, err := newVerifier(newPassword)
+	if err != nil {
+		return "", err
+	}
+	client, conn, err := c.Connect()
+	if err != nil {
+		return "", err
+	}
+	defer c.closeConn(conn)
+	proof, handshake, err := c.passwordProof(ctx, client, user)
+	if err != nil {
+		return "", err
+	}
+	resp, err := client.ChangeVerifier(ctx, &pb.ChangeVerifierRequest{
+		Proof:       proof,
+		NewVerifier: verifier,
+		DeviceName:  c.config.DeviceName,
 	})
 	if err != nil {
+		return "", transportError(err)
+	}
+	if err = c.verifyServer(handshake, resp.ServerProof); err != nil {
 		return "", err
 	}
-	c.config.Token = resp.Token
+	c.setTokens(resp.Token, resp.RefreshToken, resp.ExpiresIn)
 	return resp.Token, nil
 }
 
-// SaveSecret gets a secret
-func (c *Client) SaveSecret(ctx context.Context, data *pb.SecretData) error {
-	conn, err := c.Connect()
+// Logout revokes session of user on server, its access and refresh tokens can't be used anymore
+func (c *Client) Logout(ctx context.Context) error {
+	client, conn, err := c.Connect()
 	if err != nil {
 		return err
 	}
-	resp, err := c.DedicatedVaultClient.SaveSecret(ctx, &pb.SaveSecretRequest{
-		Data: data,
-	})
+	defer c.closeConn(conn)
+	_, err = client.Logout(ctx, &pb.LogoutRequest{})
 	if err != nil {
-		return err
+		return transportError(err)
 	}
-	c.config.LastServerUpdated = resp.LastServerUpdated
-	err = conn.Close()
+	c.setTokens("", "", 0)
+	return nil
+}
+
+// ListSessions gets sessions of user, session of this client is marked as current
+func (c *Client) ListSessions(ctx context.Context) ([]models.Session, error) {
+	client, conn, err := c.Connect()
+	if err != nil {
+		return nil, err
+	}
+	defer c.closeConn(conn)
+	resp, err := client.ListSessions(ctx, &pb.ListSessionsRequest{})
+	if err != nil {
+		return nil, transportError(err)
+	}
+	sessions := make([]models.Session, 0, len(resp.Sessions))
+	for _, session := range resp.Sessions {
+		sessions = append(sessions, models.Session{
+			ID:              session.Id,
+			DeviceName:      session.DeviceName,
+			CertFingerprint: session.CertFingerprint,
+			IP:              session.Ip,
+			Created:         session.Created,
+			LastSeen:        session.LastSeen,
+			Current:         session.Current,
+		})
+	}
+	return sessions, nil
+}
+
+// RevokeSession revokes session of user, its device has to log in again
+func (c *Client) RevokeSession(ctx context.Context, sessionID string) error {
+	client, conn, err := c.Connect()
 	if err != nil {
 		return err
 	}
+	defer c.closeConn(conn)
+	_, err = client.RevokeSession(ctx, &pb.RevokeSessionRequest{
+		Id: sessionID,
+	})
+	if err != nil {
+		return transportError(err)
+	}
 	return nil
 }
 
-// ChangeSecret gets a secret
-func (c *Client) ChangeSecret(ctx context.Context, data *pb.SecretData) error {
-	conn, err := c.Connect()
+// EnrollTwoFactor starts enrollment of two-factor authentication and returns provisioning URI of TOTP secret
+func (c *Client) EnrollTwoFactor(ctx context.Context, user *pb.User) (string, error) {
+	client, conn, err := c.Connect()
 	if err != nil {
-		return err
+		return "", err
 	}
-	resp, err := c.DedicatedVaultClient.ChangeSecret(ctx, &pb.ChangeSecretRequest{
-		Data: data,
+	defer c.closeConn(conn)
+	credentials, proof, handshake, err := c.authenticate(ctx, client, user)
+	if err != nil {
+		return "", err
+	}
+	resp, err := client.EnrollTwoFactor(ctx, &pb.EnrollTwoFactorRequest{
+		User:  credentials,
+		Proof: proof,
 	})
+	if err != nil {
+		return "", transportError(err)
+	}
+	if err = c.verifyServer(handshake, resp.ServerProof); err != nil {
+		return "", err
+	}
+	return resp.ProvisioningUri, nil
+}
+
+// ConfirmTwoFactor enables two-factor authentication with code of enrolled secret and returns recovery codes
+func (c *Client) ConfirmTwoFactor(ctx context.Context, user *pb.User, code string) ([]string, error) {
+	client, conn, err := c.Connect()
+	if err != nil {
+		return nil, err
+	}
+	defer c.closeConn(conn)
+	credentials, proof, handshake, err := c.authenticate(ctx, client, user)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.ConfirmTwoFactor(ctx, &pb.ConfirmTwoFactorRequest{
+		User:    credentials,
+		OtpCode: code,
+		Proof:   proof,
+	})
+	if err != nil {
+		return nil, transportError(err)
+	}
+	if err = c.verifyServer(handshake, resp.ServerProof); err != nil {
+		return nil, err
+	}
+	return resp.RecoveryCodes, nil
+}
+
+// DisableTwoFactor disables two-factor authentication, code is TOTP code or recovery code
+func (c *Client) DisableTwoFactor(ctx context.Context, user *pb.User, code string) error {
+	client, conn, err := c.Connect()
 	if err != nil {
 		return err
 	}
-	c.config.LastServerUpdated = resp.LastServerUpdated
-	err = conn.Close()
+	defer c.closeConn(conn)
+	credentials, proof, handshake, err := c.authenticate(ctx, client, user)
 	if err != nil {
 		return err
 	}
-	return nil
+	resp, err := client.DisableTwoFactor(ctx, &pb.DisableTwoFactorRequest{
+		User:    credentials,
+		OtpCode: code,
+		Proof:   proof,
+	})
+	if err != nil {
+		return transportError(err)
+	}
+	return c.verifyServer(handshake, resp.ServerProof)
 }
 
-// DeleteSecret gets a secret
-func (c *Client) DeleteSecret(ctx context.Context, uuid string) error {
-	conn, err := c.Connect()
+// setTokens saves tokens of session, expiresIn is lifetime of access token in seconds
+func (c *Client) setTokens(token, refreshToken string, expiresIn int64) {
+	c.tokenMu.Lock()
+	defer c.tokenMu.Unlock()
+	c.config.UpdateSession(func(s *config.Session) {
+		s.Token = token
+		s.RefreshToken = refreshToken
+		s.TokenExpires = 0
+		if expiresIn > 0 {
+			s.TokenExpires = time.Now().Unix() + expiresIn
+		}
+	})
+}
+
+// accessToken returns access token for call, it is refreshed if it expires soon
+// token without refresh token or expiration time is returned as is
+func (c *Client) accessToken(ctx context.Context, cc *grpc.ClientConn) (string, error) {
+	c.tokenMu.Lock()
+	defer c.tokenMu.Unlock()
+	session := c.config.Session()
+	if session.RefreshToken == "" || session.TokenExpires == 0 ||
+		time.Until(time.Unix(session.TokenExpires, 0)) > refreshMargin {
+		return session.Token, nil
+	}
+	return c.refresh(ctx, cc, session.RefreshToken)
+}
+
+// refreshRejected refreshes access token rejected by server
+// if token was already refreshed by another call, the new token is returned without refresh
+func (c *Client) refreshRejected(ctx context.Context, cc *grpc.ClientConn, rejected string) (string, error) {
+	c.tokenMu.Lock()
+	defer c.tokenMu.Unlock()
+	session := c.config.Session()
+	if session.Token != rejected {
+		return session.Token, nil
+	}
+	if session.RefreshToken == "" {
+		return "", errors.New("no refresh token")
+	}
+	return c.refresh(ctx, cc, session.RefreshToken)
+}
+
+// refresh gets new tokens of session by refresh token, tokenMu must be held
+// tokens are not set if session was locked or replaced while refresh was made
+func (c *Client) refresh(ctx context.Context, cc *grpc.ClientConn, refreshToken string) (string, error) {
+	resp, err := pb.NewDedicatedVaultClient(cc).RefreshToken(ctx, &pb.RefreshTokenRequest{
+		RefreshToken: refreshToken,
+	})
 	if err != nil {
-		return err
+		return "", err
 	}
-	resp, err := c.DedicatedVaultClient.DeleteSecret(ctx, &pb.DeleteSecretRequest{
-		Uuid: uuid,
+	c.config.UpdateSession(func(s *config.Session) {
+		if s.RefreshToken != refreshToken {
+			return
+		}
+		s.Token = resp.Token
+		s.RefreshToken = resp.RefreshToken
+		s.TokenExpires = time.Now().Unix() + resp.ExpiresIn
 	})
+	return resp.Token, nil
+}
+
+// SaveSecret gets a secret
+func (c *Client) SaveSecret(ctx context.Context, data *pb.SecretData) error {
+	client, conn, err := c.Connect()
 	if err != nil {
 		return err
 	}
-	c.config.LastServerUpdated = resp.LastServerUpdated
-	err = conn.Close()
+	defer c.closeConn(conn)
+	resp, err := client.SaveSecret(ctx, &pb.SaveSecretRequest{
+		Data: data,
+	})
+	if err != nil {
+		return transportError(err)
+	}
+	c.config.SetLastServerUpdated(resp.LastServerUpdated)
+	return nil
+}
+
+// ChangeSecret changes a secret if it still has the expected version on server
+// returns the new version of the secret
+func (c *Client) ChangeSecret(ctx context.Context, data *pb.SecretData, expectedVersion int64) (int64, error) {
+	client, conn, err := c.Connect()
+	if err != nil {
+		return 0, err
+	}
+	defer c.closeConn(conn)
+	resp, err := client.ChangeSecret(ctx, &pb.ChangeSecretRequest{
+		Data:            data,
+		ExpectedVersion: expectedVersion,
+	})
+	if err != nil {
+		return 0, transportError(err)
+	}
+	c.config.SetLastServerUpdated(resp.LastServerUpdated)
+	return resp.Version, nil
+}
+
+// DeleteSecret deletes a secret if it still has the expected version on server
+func (c *Client) DeleteSecret(ctx context.Context, uuid string, expectedVersion int64) error {
+	client, conn, err := c.Connect()
 	if err != nil {
 		return err
 	}
+	defer c.closeConn(conn)
+	resp, err := client.DeleteSecret(ctx, &pb.DeleteSecretRequest{
+		Uuid:            uuid,
+		ExpectedVersion: expectedVersion,
+	})
+	if err != nil {
+		return transportError(err)
+	}
+	c.config.SetLastServerUpdated(resp.LastServerUpdated)
 	return nil
 }
 
 // ListSecrets gets a secret
 func (c *Client) ListSecrets(ctx context.Context) ([]*pb.SecretData, error) {
-	conn, err := c.Connect()
+	client, conn, err := c.Connect()
 	if err != nil {
 		return nil, err
 	}
-	resp, err := c.DedicatedVaultClient.ListSecrets(ctx, &pb.ListSecretsRequest{})
+	defer c.closeConn(conn)
+	resp, err := client.ListSecrets(ctx, &pb.ListSecretsRequest{})
 	if err != nil {
 		return nil, err
 	}
-	c.config.LastServerUpdated = resp.LastServerUpdated
-	err = conn.Close()
+	c.config.SetLastServerUpdated(resp.LastServerUpdated)
+	return resp.Data, nil
+}
+
+// SyncChanges gets a page of secrets changed after the given revision and the newest revision among them
+// more tells that there are other changes after the revision
+func (c *Client) SyncChanges(ctx context.Context, sinceRevision int64) ([]*pb.SecretData, int64, bool, error) {
+	client, conn, err := c.Connect()
+	if err != nil {
+		return nil, 0, false, err
+	}
+	defer c.closeConn(conn)
+	resp, err := client.SyncChanges(ctx, &pb.SyncChangesRequest{
+		SinceRevision: sinceRevision,
+		Limit:         syncLimit,
+	})
+	if err != nil {
+		return nil, 0, false, transportError(err)
+	}
+	return resp.Data, resp.Revision, resp.More, nil
+}
+
+// GetMasterKey gets wrapped vault master key of user, version 0 means there is no key yet
+func (c *Client) GetMasterKey(ctx context.Context) (*models.WrappedKey, error) {
+	client, conn, err := c.Connect()
 	if err != nil {
 		return nil, err
 	}
-	return resp.Data, nil
+	defer c.closeConn(conn)
+	resp, err := client.GetMasterKey(ctx, &pb.GetMasterKeyRequest{})
+	if err != nil {
+		return nil, transportError(err)
+	}
+	return &models.WrappedKey{
+		Key:      resp.WrappedKey,
+		KeyCheck: resp.KeyCheck,
+		Version:  resp.Version,
+	}, nil
+}
+
+// SetMasterKey saves wrapped vault master key if it still has the expected version on server
+// returns the new version of the key
+func (c *Client) SetMasterKey(ctx context.Context, wrappedKey models.WrappedKey, expectedVersion int64) (int64, error) {
+	client, conn, err := c.Connect()
+	if err != nil {
+		return 0, err
+	}
+	defer c.closeConn(conn)
+	resp, err := client.SetMasterKey(ctx, &pb.SetMasterKeyRequest{
+		WrappedKey:      wrappedKey.Key,
+		KeyCheck:        wrappedKey.KeyCheck,
+		ExpectedVersion: expectedVersion,
+	})
+	if err != nil {
+		return 0, transportError(err)
+	}
+	return resp.Version, nil
+}
+
+// UploadSecret saves a secret with binary content streamed in chunks
+// next returns encrypted chunks one by one and io.EOF after the last one
+// returns the new version of the secret
+func (c *Client) UploadSecret(ctx context.Context, data *pb.SecretData, expectedVersion int64, next func() ([]byte, error)) (int64, error) {
+	client, conn, err := c.Connect()
+	if err != nil {
+		return 0, err
+	}
+	defer c.closeConn(conn)
+	stream, err := client.UploadSecret(ctx)
+	if err != nil {
+		return 0, transportError(err)
+	}
+	err = stream.Send(&pb.UploadSecretRequest{
+		Data:            data,
+		ExpectedVersion: expectedVersion,
+	})
+	for err == nil {
+		var chunk []byte
+		chunk, err = next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			// server drops chunks of upload which is not finished
+			_ = stream.CloseSend()
+			return 0, err
+		}
+		err = stream.Send(&pb.UploadSecretRequest{Chunk: chunk})
+	}
+	// error of Send is io.EOF if server has finished the stream, the reason is returned by CloseAndRecv
+	resp, err := stream.CloseAndRecv()
+	if err != nil {
+		return 0, transportError(err)
+	}
+	c.config.SetLastServerUpdated(resp.LastServerUpdated)
+	return resp.Version, nil
+}
+
+// DownloadSecret reads binary content of a secret with the given version and passes encrypted chunks to handle
+func (c *Client) DownloadSecret(ctx context.Context, uuid string, version int64, handle func(chunk []byte) error) error {
+	client, conn, err := c.Connect()
+	if err != nil {
+		return err
+	}
+	defer c.closeConn(conn)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := client.DownloadSecret(ctx, &pb.DownloadSecretRequest{
+		Uuid:    uuid,
+		Version: version,
+	})
+	if err != nil {
+		return transportError(err)
+	}
+	for {
+		resp, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return transportError(err)
+		}
+		err = handle(resp.Chunk)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// transportError converts grpc status to client errors, so usecase doesn't depend on grpc codes:
+// Unavailable and DeadlineExceeded - clienterrors.ServerUnavailable, the change can be retried later
+// AlreadyExists and NotFound - clienterrors.DataAlreadyExists and clienterrors.DataNotFound
+// Aborted with server copy of data in details - clienterrors.ConflictError, without it - clienterrors.VersionConflict
+// errors with known reason in details, e.g. of two-factor authentication, are recognized by reason first
+// other errors are returned as is
+func transportError(err error) error {
+	st, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok && reasonErrors[info.Reason] != nil {
+			return reasonErrors[info.Reason]
+		}
+	}
+	switch st.Code() {
+	case codes.Unavailable, codes.DeadlineExceeded:
+		return fmt.Errorf("%w: %s", clienterrors.ServerUnavailable, st.Message())
+	case codes.AlreadyExists:
+		return clienterrors.DataAlreadyExists
+	case codes.NotFound:
+		return clienterrors.DataNotFound
+	case codes.Aborted:
+		for _, detail := range st.Details() {
+			if secret, ok := detail.(*pb.SecretData); ok {
+				return &clienterrors.ConflictError{
+					Server: models.StoredData{
+						UUID:          secret.Uuid,
+						Meta:          secret.Meta,
+						DataType:      secret.Type,
+						Version:       secret.Version,
+						EncryptedData: secret.Value,
+					},
+				}
+			}
+		}
+		return fmt.Errorf("%w: %s", clienterrors.VersionConflict, st.Message())
+	}
+	return err
 }
@@ -6,22 +6,76 @@ import (
 	"context"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 )
 
+// TokenFunc returns access token for call, token which expires soon is refreshed before it is returned
+type TokenFunc func(ctx context.Context, cc *grpc.ClientConn) (string, error)
+
+// RefreshFunc refreshes access token rejected by server and returns the new one
+type RefreshFunc func(ctx context.Context, cc *grpc.ClientConn, rejected string) (string, error)
+
+// tokenlessMethods - methods are called without access token, they start or refresh session
+var tokenlessMethods = map[string]bool{
+	"/DedicatedVault/Register":     true,
+	"/DedicatedVault/Login":        true,
+	"/DedicatedVault/RefreshToken": true,
+	// password authentication by SRP-6a
+	"/DedicatedVault/RegisterVerifier": true,
+	"/DedicatedVault/StartAuth":        true,
+	"/DedicatedVault/FinishLogin":      true,
+}
+
+// withToken puts access token into metadata
+func withToken(ctx context.Context, token string) context.Context {
+	var md metadata.MD
+	// check if metadata exists
+	md, ok := metadata.FromOutgoingContext(ctx)
+	if !ok {
+		//if not, create new metadata
+		md = metadata.New(nil)
+	} else {
+		// metadata of context is shared with calls made before refresh
+		md = md.Copy()
+	}
+	md.Set("authorization", token)
+	return metadata.NewOutgoingContext(ctx, md)
+}
+
 // JWTInjectorUnaryClientInterceptor is a middleware for injecting jwt token into metadata
-func JWTInjectorUnaryClientInterceptor(token string) grpc.UnaryClientInterceptor {
+// call rejected as unauthenticated is retried once with refreshed token
+func JWTInjectorUnaryClientInterceptor(token TokenFunc, refresh RefreshFunc) grpc.UnaryClientInterceptor {
 	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if tokenlessMethods[method] {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+		accessToken, err := token(ctx, cc)
+		if err != nil {
+			return err
+		}
+		err = invoker(withToken(ctx, accessToken), method, req, reply, cc, opts...)
+		if status.Code(err) != codes.Unauthenticated {
+			return err
+		}
+		accessToken, refreshErr := refresh(ctx, cc, accessToken)
+		if refreshErr != nil {
+			// session can't be refreshed, so the error of call is returned
+			return err
+		}
+		return invoker(withToken(ctx, accessToken), method, req, reply, cc, opts...)
+	}
+}
 
-		var md metadata.MD
-		// check if metadata exists
-		md, ok := metadata.FromOutgoingContext(ctx)
-		if !ok {
-			//if not, create new metadata
-			md = metadata.New(nil)
+// JWTInjectorStreamClientInterceptor is a middleware for injecting jwt token into metadata of streaming calls
+// streams are not retried, content of stream may be already sent, so token is refreshed before it expires only
+func JWTInjectorStreamClientInterceptor(token TokenFunc) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		accessToken, err := token(ctx, cc)
+		if err != nil {
+			return nil, err
 		}
-		md.Set("authorization", token)
-		ctx = metadata.NewOutgoingContext(ctx, md)
-		return invoker(ctx, method, req, reply, cc, opts...)
+		return streamer(withToken(ctx, accessToken), desc, cc, method, opts...)
 	}
 }
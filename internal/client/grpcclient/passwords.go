@@ -0,0 +1,90 @@
+// Package: grpcclient
+// in this file we have password authentication by SRP-6a, password itself is never sent to server
+package grpcclient
+
+import (
+	"context"
+	"errors"
+	"math"
+
+	"go.uber.org/zap"
+
+	"github.com/h2p2f/dedicated-vault/internal/client/clienterrors"
+	"github.com/h2p2f/dedicated-vault/internal/srp"
+	pb "github.com/h2p2f/dedicated-vault/proto"
+)
+
+// newVerifier makes verifier of password with new salt
+func newVerifier(password string) (*pb.PasswordVerifier, error) {
+	salt, err := srp.NewSalt()
+	if err != nil {
+		return nil, err
+	}
+	return &pb.PasswordVerifier{
+		Salt:     salt,
+		Verifier: srp.Verifier(password, salt, srp.DefaultParams),
+		Params: &pb.KDFParams{
+			Time:    srp.DefaultParams.Time,
+			Memory:  srp.DefaultParams.Memory,
+			Threads: uint32(srp.DefaultParams.Threads),
+		},
+	}, nil
+}
+
+// passwordProof starts handshake of password authentication and makes proof of password of user
+// handshake is returned to check proof of server
+func (c *Client) passwordProof(ctx context.Context, client pb.DedicatedVaultClient, user *pb.User) (*pb.PasswordProof, *srp.Client, error) {
+	handshake, err := srp.NewClient(user.Name)
+	if err != nil {
+		return nil, nil, err
+	}
+	resp, err := client.StartAuth(ctx, &pb.StartAuthRequest{
+		Name:         user.Name,
+		ClientPublic: handshake.Public(),
+	})
+	if err != nil {
+		return nil, nil, transportError(err)
+	}
+	// proof made with weak params is cheap to brute-force for whoever gets it, so such params are not used
+	if resp.Params.GetThreads() > math.MaxUint8 {
+		return nil, nil, clienterrors.WeakPasswordParams
+	}
+	params := srp.Params{
+		Time:    resp.Params.GetTime(),
+		Memory:  resp.Params.GetMemory(),
+		Threads: uint8(resp.Params.GetThreads()),
+	}
+	// params are limited from above too, server could make client derive the key with all of its memory
+	if err = params.Valid(); errors.Is(err, srp.ErrCostlyParams) {
+		c.logger.Error("costly password params", zap.Any("params", params), zap.Error(err))
+		return nil, nil, clienterrors.CostlyPasswordParams
+	}
+	if err != nil {
+		c.logger.Error("weak password params", zap.Any("params", params), zap.Error(err))
+		return nil, nil, clienterrors.WeakPasswordParams
+	}
+	proof, err := handshake.Proof(user.Password, resp.Salt, params, resp.ServerPublic)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &pb.PasswordProof{HandshakeId: resp.HandshakeId, Proof: proof}, handshake, nil
+}
+
+// authenticate returns credentials of request authenticated by proof of password, password is left out of them
+// handshake is returned to check proof of server in response
+func (c *Client) authenticate(ctx context.Context, client pb.DedicatedVaultClient, user *pb.User) (*pb.User, *pb.PasswordProof, *srp.Client, error) {
+	proof, handshake, err := c.passwordProof(ctx, client, user)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return &pb.User{Name: user.Name}, proof, handshake, nil
+}
+
+// verifyServer checks proof of server of handshake, server that doesn't know verifier of password can't make it
+func (c *Client) verifyServer(handshake *srp.Client, serverProof []byte) error {
+	if err := handshake.VerifyServer(serverProof); err != nil {
+		c.logger.Error("server proof mismatch", zap.Error(err))
+		return clienterrors.ServerProofMismatch
+	}
+	return nil
+}
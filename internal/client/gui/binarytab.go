@@ -2,7 +2,7 @@ package gui
 
 import (
 	"context"
-	"io"
+	"os"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/container"
@@ -23,7 +23,10 @@ one of the solutions is to use some kind of dependency injection in the future
 // binaryTab - function for creating binary tab
 func (g *GraphicApp) binaryTab(ctx context.Context) (*widget.List, *fyne.Container) {
 	var err error
-	var binaryData []byte
+	// file selected on disk, its content is streamed to server on add or edit
+	var binaryFile fyne.URI
+	// selected binary, its content is streamed from server on save
+	var binaryItem models.Data
 
 	// declare binary area's widgets
 	binaryLabel := widget.NewLabel("Binary details:")
@@ -31,25 +34,58 @@ func (g *GraphicApp) binaryTab(ctx context.Context) (*widget.List, *fyne.Contain
 	binaryMetaEntry := widget.NewEntry()
 	binaryName := widget.NewLabel("Name:")
 	binaryNameEntry := widget.NewEntry()
+	extras := newExtrasEditor()
 	binaryUUIDLabel := widget.NewLabel("")
 	binaryUUIDLabel.Hide()
+	// version of selected binary, edit and remove are based on it
+	var binaryVersion int64
+	binaryProgress := widget.NewProgressBar()
+	binaryProgress.Hide()
+	progress := func(done, total int64) {
+		if total > 0 {
+			binaryProgress.SetValue(float64(done) / float64(total))
+		}
+	}
 
 	loadButton := widget.NewButton("Load from disk", func() {
 		dialog.ShowFileOpen(func(reader fyne.URIReadCloser, err error) {
+			if err != nil || reader == nil {
+				return
+			}
+			defer reader.Close()
 			binaryNameEntry.SetText(reader.URI().Name())
-			binaryData, err = io.ReadAll(reader)
+			binaryFile = reader.URI()
 		}, g.mainWindow)
 	})
 
 	saveButton := widget.NewButton("Save to disk", func() {
+		if binaryItem.UUID == "" {
+			g.lostData()
+			return
+		}
+		item := binaryItem
 		dialog.ShowFileSave(func(writer fyne.URIWriteCloser, err error) {
-			_, err = writer.Write(binaryData)
-			binaryNameEntry.SetText(writer.URI().Name())
+			if err != nil || writer == nil {
+				return
+			}
+			binaryProgress.SetValue(0)
+			binaryProgress.Show()
+			go func() {
+				defer binaryProgress.Hide()
+				err := g.processor.DownloadBinary(ctx, item, writer, progress)
+				closeErr := writer.Close()
+				if err == nil {
+					err = closeErr
+				}
+				if err != nil {
+					g.dialogErr(err)
+				}
+			}()
 		}, g.mainWindow)
 
 	})
 
-	listData, _ := g.processor.GetDataByType("bi")
+	listData, _ := g.processor.GetDataByType(string(models.FolderData.Binary))
 
 	// construct binary list
 	binaryList := widget.NewList(
@@ -68,58 +104,107 @@ func (g *GraphicApp) binaryTab(ctx context.Context) (*widget.List, *fyne.Contain
 		binaryMetaEntry.SetText(listData[id].Meta)
 		binaryNameEntry.SetText(listData[id].Folder.Binary.Name)
 		binaryUUIDLabel.SetText(listData[id].UUID)
-		binaryData = listData[id].Folder.Binary.Data
+		binaryVersion = listData[id].Version
+		binaryItem = listData[id]
+		binaryFile = nil
+		extras.set(listData[id].Folder)
+	}
+
+	// folder - folder of binary with name and extras of editors
+	folder := func() (models.Folder, error) {
+		f := models.Folder{Binary: models.BinaryData{Name: binaryNameEntry.Text}}
+		extras.get(&f)
+		return f, f.ValidateExtras()
 	}
 
 	refresh := func() {
 		g.notLoggedIn()
-		listData, err = g.processor.GetDataByType("bi")
+		listData, err = g.processor.GetDataByType(string(models.FolderData.Binary))
 		if err != nil {
 			g.dialogErr(err)
 		}
 		binaryList.Refresh()
 	}
 
+	// upload - stream selected file to server as content of data
+	upload := func(data models.Data) {
+		file, err := os.Open(binaryFile.Path())
+		if err != nil {
+			g.dialogErr(err)
+			return
+		}
+		info, err := file.Stat()
+		if err != nil {
+			file.Close()
+			g.dialogErr(err)
+			return
+		}
+		binaryProgress.SetValue(0)
+		binaryProgress.Show()
+		go func() {
+			defer binaryProgress.Hide()
+			defer file.Close()
+			err := g.processor.UploadBinary(ctx, data, file, info.Size(), progress)
+			if err != nil {
+				g.dialogErr(err)
+				return
+			}
+			binaryFile = nil
+			refresh()
+		}()
+	}
+
 	addButton := widget.NewButton("Add", func() {
-		if binaryMetaEntry.Text == "" || binaryNameEntry.Text == "" || binaryData == nil {
+		if binaryMetaEntry.Text == "" || binaryNameEntry.Text == "" || binaryFile == nil {
 			g.lostData()
+			return
 		}
-		if g.config.User == "" || g.config.Token == "" {
+		if g.config.UserName() == "" || g.config.AccessToken() == "" {
 			g.notLoggedIn()
+			return
 		}
-		folder := models.Folder{
-			Binary: models.BinaryData{
-				Name: binaryNameEntry.Text,
-				Data: binaryData,
-			},
-		}
-		data := models.Data{
-			Meta:     binaryMetaEntry.Text,
-			DataType: "bi",
-			Folder:   folder,
-		}
-		err := g.processor.SaveData(ctx, data)
+		f, err := folder()
 		if err != nil {
 			g.dialogErr(err)
 			return
 		}
-		r := refresh
-		r()
+		data := models.Data{
+			Meta:     binaryMetaEntry.Text,
+			DataType: models.FolderData.Binary,
+			Folder:   f,
+		}
+		upload(data)
 	})
 
 	editButton := widget.NewButton("Edit", func() {
-		if binaryMetaEntry.Text == "" || binaryNameEntry.Text == "" || binaryData == nil {
+		if binaryMetaEntry.Text == "" || binaryNameEntry.Text == "" || binaryUUIDLabel.Text == "" {
 			g.lostData()
+			return
 		}
-		if g.config.User == "" || g.config.Token == "" {
+		if g.config.UserName() == "" || g.config.AccessToken() == "" {
 			g.notLoggedIn()
+			return
+		}
+		f, err := folder()
+		if err != nil {
+			g.dialogErr(err)
+			return
 		}
 		data := models.Data{
-			UUID:   binaryUUIDLabel.Text,
-			Meta:   binaryMetaEntry.Text,
-			Folder: models.Folder{Binary: models.BinaryData{Name: binaryNameEntry.Text, Data: binaryData}},
+			UUID:     binaryUUIDLabel.Text,
+			Meta:     binaryMetaEntry.Text,
+			DataType: models.FolderData.Binary,
+			Version:  binaryVersion,
+			Folder:   f,
+		}
+		// without new file only details are changed, content stays the same
+		if binaryFile != nil {
+			upload(data)
+			return
 		}
-		err := g.processor.SaveData(ctx, data)
+		data.Folder.Binary = binaryItem.Folder.Binary
+		data.Folder.Binary.Name = binaryNameEntry.Text
+		err = g.processor.ChangeData(ctx, data)
 		if err != nil {
 			g.dialogErr(err)
 			return
@@ -132,13 +217,15 @@ func (g *GraphicApp) binaryTab(ctx context.Context) (*widget.List, *fyne.Contain
 		if binaryUUIDLabel.Text == "" {
 			g.lostData()
 		}
-		if g.config.User == "" || g.config.Token == "" {
+		if g.config.UserName() == "" || g.config.AccessToken() == "" {
 			g.notLoggedIn()
 		}
 		data := models.Data{
-			UUID:   binaryUUIDLabel.Text,
-			Meta:   binaryMetaEntry.Text,
-			Folder: models.Folder{Binary: models.BinaryData{Name: binaryNameEntry.Text, Data: binaryData}},
+			UUID:     binaryUUIDLabel.Text,
+			Meta:     binaryMetaEntry.Text,
+			DataType: models.FolderData.Binary,
+			Version:  binaryVersion,
+			Folder:   binaryItem.Folder,
 		}
 		err := g.processor.DeleteData(ctx, data)
 		if err != nil {
@@ -159,6 +246,8 @@ func (g *GraphicApp) binaryTab(ctx context.Context) (*widget.List, *fyne.Contain
 		binaryMeta, binaryMetaEntry,
 		binaryName, binaryNameEntry,
 		loadButton, saveButton,
+		binaryProgress,
+		extras.object,
 	)
 
 	return binaryList, binaryDetailBox
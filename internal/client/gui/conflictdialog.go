@@ -0,0 +1,67 @@
+package gui
+
+import (
+	"context"
+	"fmt"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/h2p2f/dedicated-vault/internal/client/models"
+)
+
+// showConflicts - function for resolving conflicts waiting for user decision one by one
+func (g *GraphicApp) showConflicts(ctx context.Context) {
+	conflicts, err := g.processor.Conflicts()
+	if err != nil {
+		dialog.ShowInformation("Error", err.Error(), g.mainWindow)
+		return
+	}
+	if len(conflicts) == 0 {
+		return
+	}
+	conflict := conflicts[0]
+
+	content := container.NewGridWithColumns(2,
+		container.NewVBox(widget.NewLabel("On this device:"), widget.NewLabel(describeData(conflict.Local))),
+		container.NewVBox(widget.NewLabel("On server:"), widget.NewLabel(describeData(conflict.Server))),
+	)
+	d := dialog.NewCustomWithoutButtons(fmt.Sprintf("Conflict (%d left)", len(conflicts)), content, g.mainWindow)
+
+	resolve := func(policy models.ConflictPolicy) func() {
+		return func() {
+			d.Hide()
+			err := g.processor.ResolveConflict(ctx, conflict.UUID, policy)
+			if err != nil {
+				dialog.ShowInformation("Error", err.Error(), g.mainWindow)
+				return
+			}
+			g.showConflicts(ctx)
+		}
+	}
+	d.SetButtons([]fyne.CanvasObject{
+		widget.NewButton("Keep mine", resolve(models.ConflictPolicies.ClientWins)),
+		widget.NewButton("Keep server", resolve(models.ConflictPolicies.ServerWins)),
+		widget.NewButton("Keep both", resolve(models.ConflictPolicies.KeepBoth)),
+		widget.NewButton("Later", d.Hide),
+	})
+	d.Show()
+}
+
+// describeData - function for short description of conflicting copy, secrets are not shown
+func describeData(data *models.Data) string {
+	if data == nil {
+		return "deleted"
+	}
+	description := fmt.Sprintf("Meta: %s\nVersion: %d\n", data.Meta, data.Version)
+	schema, ok := models.SchemaOf(data.DataType)
+	if !ok {
+		return description
+	}
+	if field, ok := schema.Summary(); ok {
+		description += field.Label + ": " + field.Value(&data.Folder)
+	}
+	return description
+}
@@ -0,0 +1,70 @@
+package gui
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/h2p2f/dedicated-vault/internal/client/models"
+)
+
+// showDevices - function for showing devices logged in as user, session of lost device can be revoked here
+func (g *GraphicApp) showDevices(ctx context.Context) {
+	sessions, err := g.processor.ListSessions(ctx)
+	if err != nil {
+		g.dialogErr(err)
+		return
+	}
+	list := container.NewVBox()
+	d := dialog.NewCustom("Devices", "Close", container.NewVScroll(list), g.mainWindow)
+	for _, session := range sessions {
+		session := session
+		var revokeButton fyne.CanvasObject = widget.NewLabel("this device")
+		if !session.Current {
+			revokeButton = widget.NewButton("Revoke", func() {
+				dialog.ShowConfirm("Revoke session",
+					fmt.Sprintf("%s will have to log in again. Revoke its session?", deviceTitle(session)),
+					func(ok bool) {
+						if !ok {
+							return
+						}
+						err := g.processor.RevokeSession(ctx, session.ID)
+						if err != nil {
+							g.dialogErr(err)
+							return
+						}
+						d.Hide()
+						g.showDevices(ctx)
+					}, g.mainWindow)
+			})
+		}
+		list.Add(container.NewBorder(nil, nil, nil, revokeButton, widget.NewLabel(describeSession(session))))
+	}
+	d.Resize(fyne.NewSize(520, 360))
+	d.Show()
+}
+
+// deviceTitle - name of device of session
+func deviceTitle(session models.Session) string {
+	if session.DeviceName == "" {
+		return "Unknown device"
+	}
+	return session.DeviceName
+}
+
+// describeSession - function for short description of session, only a prefix of certificate fingerprint is shown
+func describeSession(session models.Session) string {
+	fingerprint := session.CertFingerprint
+	if len(fingerprint) > 16 {
+		fingerprint = fingerprint[:16]
+	}
+	return fmt.Sprintf("%s\nIP: %s, certificate: %s\nLast seen: %s, logged in: %s",
+		deviceTitle(session), session.IP, fingerprint,
+		time.Unix(session.LastSeen, 0).Format(time.DateTime),
+		time.Unix(session.Created, 0).Format(time.DateTime))
+}
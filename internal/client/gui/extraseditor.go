@@ -0,0 +1,142 @@
+package gui
+
+import (
+	"strconv"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/h2p2f/dedicated-vault/internal/client/models"
+)
+
+// customFieldKinds - kinds of custom fields in order they are offered
+var customFieldKinds = []string{
+	string(models.CustomFieldKinds.Text),
+	string(models.CustomFieldKinds.Hidden),
+	string(models.CustomFieldKinds.Date),
+	string(models.CustomFieldKinds.Bool),
+}
+
+// customFieldRow - editor of custom field, value is edited by entry or by check for bool fields
+type customFieldRow struct {
+	name   *widget.Entry
+	kind   *widget.Select
+	value  *widget.Entry
+	check  *widget.Check
+	object fyne.CanvasObject
+}
+
+// extrasEditor - editor of URLs, notes and custom fields which item of any type can have
+type extrasEditor struct {
+	urls   *widget.Entry
+	notes  *widget.Entry
+	rows   []*customFieldRow
+	box    *fyne.Container
+	object fyne.CanvasObject
+}
+
+// newExtrasEditor creates editor of URLs, notes and custom fields
+func newExtrasEditor() *extrasEditor {
+	e := &extrasEditor{
+		urls:  widget.NewMultiLineEntry(),
+		notes: widget.NewMultiLineEntry(),
+		box:   container.NewVBox(),
+	}
+	e.urls.SetMinRowsVisible(2)
+	e.notes.Wrapping = fyne.TextWrapWord
+	e.notes.SetMinRowsVisible(3)
+	addButton := widget.NewButtonWithIcon("Add field", theme.ContentAddIcon(), func() {
+		e.addRow(models.CustomField{Kind: models.CustomFieldKinds.Text})
+	})
+	e.object = container.NewVBox(
+		widget.NewLabel("URLs (one per line):"), e.urls,
+		widget.NewLabel("Notes:"), e.notes,
+		widget.NewLabel("Custom fields:"), e.box, addButton,
+	)
+	return e
+}
+
+// addRow adds editor of custom field
+func (e *extrasEditor) addRow(field models.CustomField) {
+	row := &customFieldRow{
+		name:  widget.NewEntry(),
+		value: widget.NewEntry(),
+		check: widget.NewCheck("", nil),
+	}
+	row.name.SetPlaceHolder("Name")
+	row.name.SetText(field.Name)
+	// value is kept when kind is changed, so text typed as date is not lost
+	row.kind = widget.NewSelect(customFieldKinds, func(kind string) {
+		row.value.Password = kind == string(models.CustomFieldKinds.Hidden)
+		row.value.SetPlaceHolder("")
+		if kind == string(models.CustomFieldKinds.Date) {
+			row.value.SetPlaceHolder("YYYY-MM-DD")
+		}
+		if kind == string(models.CustomFieldKinds.Bool) {
+			checked, _ := strconv.ParseBool(row.value.Text)
+			row.check.SetChecked(checked)
+			row.value.Hide()
+			row.check.Show()
+			return
+		}
+		row.check.Hide()
+		row.value.Show()
+		row.value.Refresh()
+	})
+	row.value.SetText(field.Value)
+	row.kind.SetSelected(string(field.Kind))
+	removeButton := widget.NewButtonWithIcon("", theme.ContentRemoveIcon(), nil)
+	row.object = container.NewBorder(nil, nil, nil, removeButton,
+		container.NewGridWithColumns(3, row.name, row.kind, container.NewStack(row.value, row.check)))
+	removeButton.OnTapped = func() {
+		for i := range e.rows {
+			if e.rows[i] == row {
+				e.rows = append(e.rows[:i], e.rows[i+1:]...)
+				break
+			}
+		}
+		e.box.Remove(row.object)
+	}
+	e.rows = append(e.rows, row)
+	e.box.Add(row.object)
+}
+
+// set shows URLs, notes and custom fields of folder
+func (e *extrasEditor) set(folder models.Folder) {
+	e.urls.SetText(strings.Join(folder.URLs, "\n"))
+	e.notes.SetText(folder.Notes)
+	e.rows = nil
+	e.box.RemoveAll()
+	for _, field := range folder.Custom {
+		e.addRow(field)
+	}
+}
+
+// get sets URLs, notes and custom fields of folder, empty lines and rows are skipped
+func (e *extrasEditor) get(folder *models.Folder) {
+	folder.URLs = nil
+	for _, line := range strings.Split(e.urls.Text, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			folder.URLs = append(folder.URLs, line)
+		}
+	}
+	folder.Notes = e.notes.Text
+	folder.Custom = nil
+	for _, row := range e.rows {
+		field := models.CustomField{
+			Name:  strings.TrimSpace(row.name.Text),
+			Kind:  models.CustomFieldKind(row.kind.Selected),
+			Value: row.value.Text,
+		}
+		if field.Kind == models.CustomFieldKinds.Bool {
+			field.Value = strconv.FormatBool(row.check.Checked)
+		}
+		if field.Name == "" && field.Value == "" {
+			continue
+		}
+		folder.Custom = append(folder.Custom, field)
+	}
+}
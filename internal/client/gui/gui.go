@@ -4,6 +4,8 @@ package gui
 
 import (
 	"context"
+	"errors"
+	"io"
 	"time"
 
 	"fyne.io/fyne/v2"
@@ -15,6 +17,7 @@ import (
 	"fyne.io/fyne/v2/layout"
 	"fyne.io/fyne/v2/widget"
 
+	"github.com/h2p2f/dedicated-vault/internal/client/clienterrors"
 	"github.com/h2p2f/dedicated-vault/internal/client/config"
 	"github.com/h2p2f/dedicated-vault/internal/client/models"
 )
@@ -22,13 +25,28 @@ import (
 // Processor is an interface for processing data
 type Processor interface {
 	CreateUser(ctx context.Context, userName, password, passphrase string) error
-	LoginUser(ctx context.Context, userName, password, passphrase string) error
+	LoginUser(ctx context.Context, userName, password, passphrase, code string) error
+	UpgradePassword(ctx context.Context, userName, password, passphrase, code string) error
+	EnrollTwoFactor(ctx context.Context, userName, password string) (string, error)
+	ConfirmTwoFactor(ctx context.Context, userName, password, code string) ([]string, error)
+	DisableTwoFactor(ctx context.Context, userName, password, code string) error
 	ChangePassword(ctx context.Context, userName, password, newPassword string) error
+	ChangePassphrase(ctx context.Context, oldPassphrase, newPassphrase string) error
+	Logout(ctx context.Context) error
+	ListSessions(ctx context.Context) ([]models.Session, error)
+	RevokeSession(ctx context.Context, sessionID string) error
 	SaveData(ctx context.Context, data models.Data) error
 	ChangeData(ctx context.Context, data models.Data) error
 	DeleteData(ctx context.Context, data models.Data) error
+	UploadBinary(ctx context.Context, data models.Data, r io.Reader, size int64, progress func(done, total int64)) error
+	DownloadBinary(ctx context.Context, data models.Data, w io.Writer, progress func(done, total int64)) error
 	GetDataByType(dataType string) ([]models.Data, error)
+	Sync(ctx context.Context) error
 	FullSync(ctx context.Context) error
+	Flush(ctx context.Context) error
+	PendingChanges() ([]models.OutboxEntry, error)
+	Conflicts() ([]models.DataConflict, error)
+	ResolveConflict(ctx context.Context, dataUUID string, policy models.ConflictPolicy) error
 }
 
 // Updater is an interface for updating data
@@ -65,7 +83,7 @@ func (g *GraphicApp) Run(ctx context.Context) {
 	g.mainWindow.CenterOnScreen()
 
 	g.notLoggedIn = func() {
-		if g.config.User == "" {
+		if g.config.UserName() == "" {
 			dialog.ShowInformation("Error", "You are not logged in", g.mainWindow)
 		}
 	}
@@ -74,6 +92,11 @@ func (g *GraphicApp) Run(ctx context.Context) {
 	}
 
 	g.dialogErr = func(err error) {
+		// conflicts waiting for user decision are shown in resolution dialog instead of error
+		if errors.Is(err, clienterrors.VersionConflict) {
+			g.showConflicts(ctx)
+			return
+		}
 		dialog.ShowInformation("Error", err.Error(), g.mainWindow)
 	}
 
@@ -81,10 +104,6 @@ func (g *GraphicApp) Run(ctx context.Context) {
 	img.FillMode = canvas.ImageFillOriginal
 
 	userBox := g.settingsTab(ctx)
-	crList, crBox := g.credentialTab(ctx)
-	ccList, ccBox := g.creditCardTab(ctx)
-	txList, txBox := g.textTab(ctx)
-	biList, biBox := g.binaryTab(ctx)
 
 	settingsTabItem := container.NewTabItem("Settings", container.New(
 		layout.NewGridLayoutWithColumns(3),
@@ -93,30 +112,21 @@ func (g *GraphicApp) Run(ctx context.Context) {
 		container.New(layout.NewCenterLayout()),
 	))
 
-	credentialTabItem := container.NewTabItem("Credentials", container.NewGridWithColumns(2,
-		crList,
-		crBox,
-	))
-	creditCardTabItem := container.NewTabItem("Credit Cards", container.NewGridWithColumns(2,
-		ccList,
-		ccBox,
-	))
-	textTabItem := container.NewTabItem("Text Notes", container.NewGridWithColumns(2,
-		txList,
-		txBox,
-	))
-	binaryTabItem := container.NewTabItem("Binary Files", container.NewGridWithColumns(2,
-		biList,
-		biBox,
-	))
-
-	appTabs := container.NewAppTabs(
-		settingsTabItem,
-		credentialTabItem,
-		creditCardTabItem,
-		textTabItem,
-		binaryTabItem,
-	)
+	appTabs := container.NewAppTabs(settingsTabItem)
+	// tab of every item type is built from its schema, content of binary items is edited by binary tab
+	for _, schema := range models.Schemas() {
+		var list *widget.List
+		var box *fyne.Container
+		if schema.Content {
+			list, box = g.binaryTab(ctx)
+		} else {
+			list, box = g.schemaTab(ctx, schema)
+		}
+		appTabs.Append(container.NewTabItem(schema.Title, container.NewGridWithColumns(2,
+			list,
+			container.NewVScroll(box),
+		)))
+	}
 	appTabs.SetTabLocation(container.TabLocationLeading)
 
 	if drv, ok := guiApp.Driver().(desktop.Driver); ok {
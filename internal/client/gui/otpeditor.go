@@ -0,0 +1,91 @@
+package gui
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/h2p2f/dedicated-vault/internal/client/models"
+)
+
+// newOTPEditor creates editor of otpauth URI or seed, it shows code of it
+// TOTP code is shown with countdown, counter of HOTP is increased by "Next code" and the item is saved
+func (g *GraphicApp) newOTPEditor(ctx context.Context, field *models.Field, save func()) fieldEditor {
+	editor := fieldEditor{field: field}
+	entry := widget.NewPasswordEntry()
+	code := widget.NewLabel("")
+	countdown := widget.NewProgressBar()
+	countdown.TextFormatter = func() string {
+		return fmt.Sprintf("%.0fs", countdown.Value)
+	}
+	countdown.Hide()
+	nextButton := widget.NewButton("Next code", nil)
+	nextButton.Hide()
+
+	// update shows code of entered value, invalid value is reported on save
+	update := func() {
+		o, err := models.ParseOTP(entry.Text)
+		if entry.Text == "" || err != nil {
+			code.SetText("")
+			countdown.Hide()
+			nextButton.Hide()
+			return
+		}
+		now := time.Now()
+		value, err := o.Code(now)
+		if err != nil {
+			code.SetText("")
+			return
+		}
+		code.SetText("Code: " + value)
+		if o.Type == models.OTPTypeHOTP {
+			countdown.Hide()
+			nextButton.Show()
+			return
+		}
+		nextButton.Hide()
+		countdown.Max = float64(o.Period)
+		countdown.SetValue(o.Remaining(now).Seconds())
+		countdown.Show()
+	}
+	entry.OnChanged = func(string) { update() }
+	nextButton.OnTapped = func() {
+		o, err := models.ParseOTP(entry.Text)
+		if err != nil {
+			g.dialogErr(err)
+			return
+		}
+		uri, err := o.NextCounter()
+		if err != nil {
+			g.dialogErr(err)
+			return
+		}
+		entry.SetText(uri)
+		update()
+		save()
+	}
+	// countdown is updated while app is running
+	go func() {
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				update()
+			}
+		}
+	}()
+
+	editor.get = func() string { return entry.Text }
+	editor.set = func(value string) {
+		entry.SetText(value)
+		update()
+	}
+	editor.object = container.NewVBox(widget.NewLabel(field.Label+":"), entry, code, countdown, nextButton)
+	return editor
+}
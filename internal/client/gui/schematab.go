@@ -0,0 +1,270 @@
+package gui
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strconv"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/h2p2f/dedicated-vault/internal/client/clienterrors"
+	"github.com/h2p2f/dedicated-vault/internal/client/models"
+)
+
+// fieldEditor - editor of field of item
+type fieldEditor struct {
+	field  *models.Field
+	get    func() string
+	set    func(value string)
+	object fyne.CanvasObject
+}
+
+// newFieldEditor creates editor of field by its kind
+// multiline fields can be loaded from file, read only fields are shown only
+// save saves the edited item, editors which change the item by themselves call it
+func (g *GraphicApp) newFieldEditor(ctx context.Context, field *models.Field, save func()) fieldEditor {
+	editor := fieldEditor{field: field}
+	switch {
+	case field.Kind == models.FieldKinds.OTP:
+		return g.newOTPEditor(ctx, field, save)
+	case field.Kind == models.FieldKinds.Bool:
+		check := widget.NewCheck(field.Label, nil)
+		if field.ReadOnly {
+			check.Disable()
+		}
+		editor.get = func() string { return strconv.FormatBool(check.Checked) }
+		editor.set = func(value string) {
+			checked, _ := strconv.ParseBool(value)
+			check.SetChecked(checked)
+		}
+		editor.object = check
+		return editor
+	case field.Kind == models.FieldKinds.MultiLine:
+		entry := widget.NewMultiLineEntry()
+		entry.Wrapping = fyne.TextWrapBreak
+		entry.SetMinRowsVisible(6)
+		editor.get = func() string { return entry.Text }
+		editor.set = entry.SetText
+		if field.ReadOnly {
+			entry.Disable()
+			editor.object = container.NewVBox(widget.NewLabel(field.Label+":"), entry)
+			return editor
+		}
+		loadButton := widget.NewButton("Load from disk", func() {
+			dialog.ShowFileOpen(func(reader fyne.URIReadCloser, err error) {
+				if err != nil || reader == nil {
+					return
+				}
+				defer reader.Close()
+				content, err := io.ReadAll(reader)
+				if err != nil {
+					g.dialogErr(err)
+					return
+				}
+				entry.SetText(string(content))
+			}, g.mainWindow)
+		})
+		editor.object = container.NewVBox(widget.NewLabel(field.Label+":"), entry, loadButton)
+		return editor
+	}
+	entry := widget.NewEntry()
+	if field.Hidden {
+		entry = widget.NewPasswordEntry()
+	}
+	if field.Kind == models.FieldKinds.Date {
+		entry.SetPlaceHolder("YYYY-MM-DD")
+	}
+	if field.ReadOnly {
+		entry.Disable()
+	}
+	editor.get = func() string { return entry.Text }
+	editor.set = entry.SetText
+	editor.object = container.NewVBox(widget.NewLabel(field.Label+":"), entry)
+	return editor
+}
+
+// schemaTab - function for creating tab of item type, editors of fields are created from schema of type
+func (g *GraphicApp) schemaTab(ctx context.Context, schema *models.Schema) (*widget.List, *fyne.Container) {
+	var err error
+	// declare item area's widgets
+	label := widget.NewLabel(schema.Title + " details:")
+	meta := widget.NewLabel("Meta:")
+	metaEntry := widget.NewEntry()
+	// save is edit of selected item, it is set when edit button is created
+	var save func()
+	editors := make([]fieldEditor, 0, len(schema.Fields))
+	for i := range schema.Fields {
+		editors = append(editors, g.newFieldEditor(ctx, &schema.Fields[i], func() { save() }))
+	}
+	extras := newExtrasEditor()
+	uuidLabel := widget.NewLabel("")
+	uuidLabel.Hide()
+	// selected item, edit and delete are based on its version
+	// fields of other types of folder are kept, so schema of type may bind its fields to any of them
+	var selected models.Data
+
+	//get item list
+	//error not handled because it called on startup
+	//user may not be logged in
+	listData, _ := g.processor.GetDataByType(string(schema.Type))
+
+	// construct item list
+	list := widget.NewList(
+		func() int {
+			return len(listData)
+		},
+		func() fyne.CanvasObject {
+			return widget.NewLabel("template")
+		},
+		func(i widget.ListItemID, o fyne.CanvasObject) {
+			o.(*widget.Label).SetText(listData[i].Meta)
+		},
+	)
+	// add item list onSelected event
+	list.OnSelected = func(id widget.ListItemID) {
+		selected = listData[id]
+		metaEntry.SetText(selected.Meta)
+		for _, editor := range editors {
+			editor.set(editor.field.Value(&selected.Folder))
+		}
+		extras.set(selected.Folder)
+		uuidLabel.SetText(selected.UUID)
+	}
+
+	refresh := func() {
+		g.notLoggedIn()
+		listData, err = g.processor.GetDataByType(string(schema.Type))
+		if err != nil {
+			g.dialogErr(err)
+			// items which can't be decrypted are skipped, the others are shown
+			if !errors.Is(err, clienterrors.Undecryptable) {
+				return
+			}
+		}
+		list.Refresh()
+	}
+
+	// folder - folder of item with values of editors, computed fields are shown after it is prepared
+	folder := func(base models.Folder) (models.Folder, error) {
+		for _, editor := range editors {
+			if editor.field.ReadOnly {
+				continue
+			}
+			if err := schema.Set(&base, editor.field.Name, editor.get()); err != nil {
+				return base, err
+			}
+		}
+		extras.get(&base)
+		if err := schema.Prepare(&base); err != nil {
+			return base, err
+		}
+		for _, editor := range editors {
+			if editor.field.ReadOnly {
+				editor.set(editor.field.Value(&base))
+			}
+		}
+		return base, nil
+	}
+
+	addButton := widget.NewButton("Add", func() {
+		if metaEntry.Text == "" {
+			g.lostData()
+			return
+		}
+		if g.config.UserName() == "" || g.config.AccessToken() == "" {
+			g.notLoggedIn()
+		}
+		f, err := folder(models.Folder{})
+		if err != nil {
+			g.dialogErr(err)
+			return
+		}
+		data := models.Data{
+			Meta:     metaEntry.Text,
+			DataType: schema.Type,
+			Folder:   f,
+		}
+		err = g.processor.SaveData(ctx, data)
+		if err != nil {
+			g.dialogErr(err)
+			return
+		}
+		r := refresh
+		r()
+	})
+
+	editButton := widget.NewButton("Edit", func() {
+		if metaEntry.Text == "" || uuidLabel.Text == "" {
+			g.lostData()
+			return
+		}
+		if g.config.UserName() == "" || g.config.AccessToken() == "" {
+			g.notLoggedIn()
+		}
+		f, err := folder(selected.Folder)
+		if err != nil {
+			g.dialogErr(err)
+			return
+		}
+		data := models.Data{
+			UUID:     uuidLabel.Text,
+			Meta:     metaEntry.Text,
+			DataType: schema.Type,
+			Version:  selected.Version,
+			Folder:   f,
+		}
+		err = g.processor.ChangeData(ctx, data)
+		if err != nil {
+			g.dialogErr(err)
+			return
+		}
+		r := refresh
+		r()
+	})
+
+	deleteButton := widget.NewButton("Delete", func() {
+		if uuidLabel.Text == "" {
+			g.lostData()
+			return
+		}
+		if g.config.UserName() == "" || g.config.AccessToken() == "" {
+			g.notLoggedIn()
+		}
+		data := models.Data{
+			UUID:     uuidLabel.Text,
+			Meta:     metaEntry.Text,
+			DataType: schema.Type,
+			Version:  selected.Version,
+		}
+		err := g.processor.DeleteData(ctx, data)
+		if err != nil {
+			g.dialogErr(err)
+			return
+		}
+		r := refresh
+		r()
+	})
+
+	save = editButton.OnTapped
+
+	refreshButton := widget.NewButton("Refresh", refresh)
+
+	// construct item area
+	detailsBox := container.NewVBox(
+		refreshButton,
+		addButton, editButton, deleteButton,
+		label,
+		meta, metaEntry,
+	)
+	for _, editor := range editors {
+		detailsBox.Add(editor.object)
+	}
+	detailsBox.Add(extras.object)
+	detailsBox.Add(uuidLabel)
+
+	return list, detailsBox
+}
@@ -3,10 +3,17 @@ package gui
 import (
 	"context"
 	"errors"
+	"fmt"
+	"strings"
+	"time"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
 	"fyne.io/fyne/v2/widget"
+
+	"github.com/h2p2f/dedicated-vault/internal/client/clienterrors"
+	"github.com/h2p2f/dedicated-vault/internal/client/models"
 )
 
 /*
@@ -23,28 +30,181 @@ func (g *GraphicApp) settingsTab(ctx context.Context) *fyne.Container {
 	LoginLabel := widget.NewLabel("Login")
 	login := widget.NewEntry()
 	userLabel := widget.NewLabel("User")
-	if g.config.User == "" {
+	if g.config.UserName() == "" {
 		userLabel.Hide()
 	}
+	// pendingLabel shows how many local changes are not pushed to server yet
+	pendingLabel := widget.NewLabel("")
+	pendingLabel.Hide()
+	refreshPending := func() {
+		pending, err := g.processor.PendingChanges()
+		if err != nil {
+			g.dialogErr(err)
+			return
+		}
+		pendingLabel.SetText(fmt.Sprintf("Unsynced changes: %d", len(pending)))
+		pendingLabel.Show()
+	}
+	pushButton := widget.NewButton("Push changes", func() {
+		err := g.processor.Flush(ctx)
+		refreshPending()
+		if err != nil {
+			g.dialogErr(err)
+			return
+		}
+	})
+	if g.config.UserName() == "" {
+		pushButton.Hide()
+	}
+	showPendingButton := widget.NewButton("Show unsynced", func() {
+		pending, err := g.processor.PendingChanges()
+		if err != nil {
+			g.dialogErr(err)
+			return
+		}
+		if len(pending) == 0 {
+			dialog.ShowInformation("Unsynced changes", "All changes are pushed to server", g.mainWindow)
+			return
+		}
+		var lines []string
+		for _, p := range pending {
+			line := fmt.Sprintf("%s %s (%s)", p.Operation, p.Data.Meta, time.Unix(p.Created, 0).Format(time.DateTime))
+			if p.LastError != "" {
+				line += ": " + p.LastError
+			}
+			lines = append(lines, line)
+		}
+		dialog.ShowInformation("Unsynced changes", strings.Join(lines, "\n"), g.mainWindow)
+	})
+	if g.config.UserName() == "" {
+		showPendingButton.Hide()
+	}
+	// policySelect sets how conflicts between local and server changes are resolved
+	policySelect := widget.NewSelect([]string{
+		string(models.ConflictPolicies.Ask),
+		string(models.ConflictPolicies.ServerWins),
+		string(models.ConflictPolicies.ClientWins),
+		string(models.ConflictPolicies.KeepBoth),
+	}, func(s string) {
+		g.config.SetConflictPolicy(s)
+	})
+	policySelect.SetSelected(g.config.ConflictPolicyOf())
+	policyLabel := widget.NewLabel("On conflict")
+	conflictsButton := widget.NewButton("Resolve conflicts", func() {
+		g.showConflicts(ctx)
+	})
+	if g.config.UserName() == "" {
+		policyLabel.Hide()
+		policySelect.Hide()
+		conflictsButton.Hide()
+	}
+	syncButton := widget.NewButton("Sync", func() {
+		err := g.processor.Sync(ctx)
+		refreshPending()
+		if err != nil {
+			g.dialogErr(err)
+			return
+		}
+	})
+	if g.config.UserName() == "" {
+		syncButton.Hide()
+	}
 	fullSyncButton := widget.NewButton("Full sync", func() {
 		err := g.processor.FullSync(ctx)
+		refreshPending()
 		if err != nil {
 			g.dialogErr(err)
 			return
 		}
 	})
-	if g.config.User == "" {
+	if g.config.UserName() == "" {
 		fullSyncButton.Hide()
 	}
+	changePassphraseButton := widget.NewButton("Change passphrase", func() {
+		oldPassphrase := widget.NewPasswordEntry()
+		newPassphrase := widget.NewPasswordEntry()
+		repeatPassphrase := widget.NewPasswordEntry()
+		dialog.ShowForm("Change passphrase", "Change", "Cancel", []*widget.FormItem{
+			widget.NewFormItem("Current", oldPassphrase),
+			widget.NewFormItem("New", newPassphrase),
+			widget.NewFormItem("Repeat new", repeatPassphrase),
+		}, func(ok bool) {
+			if !ok {
+				return
+			}
+			if newPassphrase.Text == "" || newPassphrase.Text != repeatPassphrase.Text {
+				g.dialogErr(errors.New("new passphrases don't match"))
+				return
+			}
+			err := g.processor.ChangePassphrase(ctx, oldPassphrase.Text, newPassphrase.Text)
+			if err != nil {
+				g.dialogErr(err)
+				return
+			}
+			dialog.ShowInformation("Change passphrase", "Passphrase is changed, use it on other devices at the next login", g.mainWindow)
+		}, g.mainWindow)
+	})
+	if g.config.UserName() == "" {
+		changePassphraseButton.Hide()
+	}
+	// devicesButton shows devices logged in as user, so a lost device can be cut off
+	devicesButton := widget.NewButton("Devices", func() {
+		g.showDevices(ctx)
+	})
+	if g.config.UserName() == "" {
+		devicesButton.Hide()
+	}
 	passwordLabel := widget.NewLabel("Password")
 	password := widget.NewPasswordEntry()
 	passphraseLabel := widget.NewLabel("Passphrase")
 	passphrase := widget.NewPasswordEntry()
 
+	// logoutButton revokes session on server, so tokens of this device can't be used anymore
+	var logoutButton *widget.Button
+	logoutButton = widget.NewButton("Logout", func() {
+		err := g.processor.Logout(ctx)
+		// vault is locked even if session isn't revoked on server
+		userLabel.Hide()
+		syncButton.Hide()
+		fullSyncButton.Hide()
+		pushButton.Hide()
+		showPendingButton.Hide()
+		pendingLabel.Hide()
+		policyLabel.Hide()
+		policySelect.Hide()
+		conflictsButton.Hide()
+		changePassphraseButton.Hide()
+		devicesButton.Hide()
+		logoutButton.Hide()
+		password.SetText("")
+		passphrase.SetText("")
+		LoginLabel.Show()
+		login.Show()
+		passwordLabel.Show()
+		password.Show()
+		passphraseLabel.Show()
+		passphrase.Show()
+		if err != nil {
+			g.dialogErr(err)
+		}
+	})
+	if g.config.UserName() == "" {
+		logoutButton.Hide()
+	}
 	hideAndShow := func(s string) {
 		userLabel.SetText("User logged in: " + s)
 		userLabel.Show()
+		syncButton.Show()
 		fullSyncButton.Show()
+		pushButton.Show()
+		showPendingButton.Show()
+		policyLabel.Show()
+		policySelect.Show()
+		conflictsButton.Show()
+		changePassphraseButton.Show()
+		devicesButton.Show()
+		logoutButton.Show()
+		refreshPending()
 		LoginLabel.Hide()
 		login.Hide()
 		passwordLabel.Hide()
@@ -53,18 +213,43 @@ func (g *GraphicApp) settingsTab(ctx context.Context) *fyne.Container {
 		passphrase.Hide()
 	}
 
+	// upgrade lets password be sent in clear once, user agrees to it when server has no verifier of password yet
+	var upgrade bool
+	// logIn logs user in, code of two-factor authentication is asked when server requires it
+	var logIn func(code string)
+	logIn = func(code string) {
+		loginUser := g.processor.LoginUser
+		if upgrade {
+			loginUser = g.processor.UpgradePassword
+		}
+		err := loginUser(ctx, login.Text, password.Text, passphrase.Text, code)
+		// user is logged in even if sync after login failed
+		if g.config.AccessToken() != "" {
+			hideAndShow(login.Text)
+		}
+		switch {
+		case errors.Is(err, clienterrors.TwoFactorRequired):
+			g.askTwoFactorCode(logIn)
+		case errors.Is(err, clienterrors.InvalidTwoFactorCode):
+			dialog.ShowInformation("Two-factor authentication", "The code is wrong or already used, try the next one", g.mainWindow)
+		case errors.Is(err, clienterrors.TwoFactorEnrollmentRequired):
+			g.askEnrollment(ctx, login.Text)
+		case errors.Is(err, clienterrors.PasswordUpgradeRequired):
+			g.askPasswordUpgrade(func() {
+				upgrade = true
+				logIn(code)
+			})
+		case err != nil:
+			g.unlockErr(err, passphrase)
+		}
+	}
 	loginButton := widget.NewButton("Login", func() {
 		if login.Text == "" || password.Text == "" || passphrase.Text == "" {
 			g.dialogErr(errors.New("empty fields"))
 			return
 		}
-		err := g.processor.LoginUser(ctx, login.Text, password.Text, passphrase.Text)
-		if err != nil {
-			g.dialogErr(err)
-			return
-		}
-		hideAndShow(login.Text)
-
+		upgrade = false
+		logIn("")
 	})
 
 	registerButton := widget.NewButton("Register", func() {
@@ -73,12 +258,25 @@ func (g *GraphicApp) settingsTab(ctx context.Context) *fyne.Container {
 			return
 		}
 		err := g.processor.CreateUser(ctx, login.Text, password.Text, passphrase.Text)
+		// user is registered, but server lets it log in only with two-factor authentication
+		if errors.Is(err, clienterrors.TwoFactorEnrollmentRequired) {
+			g.askEnrollment(ctx, login.Text)
+			return
+		}
 		if err != nil {
-			g.dialogErr(err)
+			g.unlockErr(err, passphrase)
 			return
 		}
 		hideAndShow(login.Text)
 	})
+	// twoFactorButton enables or disables two-factor authentication, it is authenticated by password and session
+	twoFactorButton := widget.NewButton("Two-factor authentication", func() {
+		userName := login.Text
+		if g.config.UserName() != "" {
+			userName = g.config.UserName()
+		}
+		g.showTwoFactor(ctx, userName)
+	})
 	exitButton := widget.NewButton("Exit", func() {
 		g.mainWindow.Close()
 	})
@@ -89,10 +287,60 @@ func (g *GraphicApp) settingsTab(ctx context.Context) *fyne.Container {
 		passwordLabel, password,
 		passphraseLabel, passphrase,
 		loginButton, registerButton,
+		syncButton,
 		fullSyncButton,
+		pendingLabel,
+		pushButton, showPendingButton,
+		policyLabel, policySelect,
+		conflictsButton,
+		changePassphraseButton,
+		devicesButton,
+		twoFactorButton,
+		logoutButton,
 		exitButton,
 	)
 
 	return settingsContainer
 
 }
+
+// unlockErr shows error of login or registration
+// passphrase errors are explained, because nothing else tells user what is wrong with the vault
+func (g *GraphicApp) unlockErr(err error, passphrase *widget.Entry) {
+	switch {
+	case errors.Is(err, clienterrors.WrongPassphrase):
+		passphrase.SetText("")
+		dialog.ShowInformation("Wrong passphrase",
+			"The passphrase does not unlock your vault.\nNothing was decrypted or saved, check the passphrase and try again.", g.mainWindow)
+	case errors.Is(err, clienterrors.KeyMismatch):
+		dialog.ShowInformation("Vault key mismatch",
+			"The vault key on server is not the key this device has used.\nNothing was decrypted or saved, contact the server administrator.", g.mainWindow)
+	default:
+		g.dialogErr(err)
+	}
+}
+
+// askEnrollment - server requires two-factor authentication for login, user is offered to enable it
+func (g *GraphicApp) askEnrollment(ctx context.Context, userName string) {
+	dialog.ShowConfirm("Two-factor authentication required",
+		"The server lets users log in only with two-factor authentication.\nEnable it now and log in again?",
+		func(ok bool) {
+			if ok {
+				g.showTwoFactor(ctx, userName)
+			}
+		}, g.mainWindow)
+}
+
+// askPasswordUpgrade - server has no verifier of password of user registered before verifiers,
+// user is asked before password is sent to server in clear once
+func (g *GraphicApp) askPasswordUpgrade(upgrade func()) {
+	dialog.ShowConfirm("Password upgrade",
+		"The login or password is wrong, or the server has no password verifier of this account yet.\n"+
+			"Send the password to the server once, so it is replaced by a verifier?\n"+
+			"Agree only if this account was registered before password verifiers.",
+		func(ok bool) {
+			if ok {
+				upgrade()
+			}
+		}, g.mainWindow)
+}
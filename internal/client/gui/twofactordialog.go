@@ -0,0 +1,120 @@
+package gui
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// twoFactor actions
+const (
+	enableTwoFactor  = "Enable"
+	disableTwoFactor = "Disable"
+)
+
+// askTwoFactorCode - function for asking code of authenticator app or recovery code, login is retried with it
+func (g *GraphicApp) askTwoFactorCode(retry func(code string)) {
+	code := widget.NewEntry()
+	code.SetPlaceHolder("123456 or recovery code")
+	dialog.ShowForm("Two-factor authentication", "Log in", "Cancel", []*widget.FormItem{
+		widget.NewFormItem("Code", code),
+	}, func(ok bool) {
+		if !ok || strings.TrimSpace(code.Text) == "" {
+			return
+		}
+		retry(strings.TrimSpace(code.Text))
+	}, g.mainWindow)
+}
+
+// showTwoFactor - function for enabling or disabling two-factor authentication of user,
+// it is enabled with password, so it works before the first login as well; it is disabled only by logged in user
+func (g *GraphicApp) showTwoFactor(ctx context.Context, userName string) {
+	login := widget.NewEntry()
+	login.SetText(userName)
+	password := widget.NewPasswordEntry()
+	action := widget.NewRadioGroup([]string{enableTwoFactor, disableTwoFactor}, nil)
+	action.SetSelected(enableTwoFactor)
+	code := widget.NewEntry()
+	code.SetPlaceHolder("current code, only to disable")
+	dialog.ShowForm("Two-factor authentication", "Next", "Cancel", []*widget.FormItem{
+		widget.NewFormItem("Login", login),
+		widget.NewFormItem("Password", password),
+		widget.NewFormItem("Action", action),
+		widget.NewFormItem("Code", code),
+	}, func(ok bool) {
+		if !ok {
+			return
+		}
+		if login.Text == "" || password.Text == "" {
+			g.dialogErr(errors.New("empty fields"))
+			return
+		}
+		if action.Selected == enableTwoFactor {
+			g.enrollTwoFactor(ctx, login.Text, password.Text)
+			return
+		}
+		if g.config.AccessToken() == "" {
+			g.dialogErr(errors.New("log in to disable two-factor authentication"))
+			return
+		}
+		if strings.TrimSpace(code.Text) == "" {
+			g.dialogErr(errors.New("current code of authenticator app is required"))
+			return
+		}
+		err := g.processor.DisableTwoFactor(ctx, login.Text, password.Text, strings.TrimSpace(code.Text))
+		if err != nil {
+			g.dialogErr(err)
+			return
+		}
+		dialog.ShowInformation("Two-factor authentication", "Two-factor authentication is disabled", g.mainWindow)
+	}, g.mainWindow)
+}
+
+// enrollTwoFactor - function for enrollment: URI of secret is shown, then it is confirmed with code of authenticator app
+func (g *GraphicApp) enrollTwoFactor(ctx context.Context, userName, password string) {
+	uri, err := g.processor.EnrollTwoFactor(ctx, userName, password)
+	if err != nil {
+		g.dialogErr(err)
+		return
+	}
+	uriEntry := widget.NewMultiLineEntry()
+	uriEntry.Wrapping = fyne.TextWrapBreak
+	uriEntry.SetText(uri)
+	code := widget.NewEntry()
+	code.SetPlaceHolder("123456")
+	content := container.NewVBox(
+		widget.NewLabel("Add this URI to your authenticator app, then enter the code it shows"),
+		uriEntry,
+		code,
+	)
+	dialog.ShowCustomConfirm("Enable two-factor authentication", "Enable", "Cancel", content, func(ok bool) {
+		if !ok {
+			return
+		}
+		recoveryCodes, err := g.processor.ConfirmTwoFactor(ctx, userName, password, strings.TrimSpace(code.Text))
+		if err != nil {
+			g.dialogErr(err)
+			return
+		}
+		g.showRecoveryCodes(recoveryCodes)
+	}, g.mainWindow)
+}
+
+// showRecoveryCodes - function for showing recovery codes, they are shown only once
+func (g *GraphicApp) showRecoveryCodes(recoveryCodes []string) {
+	codesEntry := widget.NewMultiLineEntry()
+	codesEntry.SetText(strings.Join(recoveryCodes, "\n"))
+	codesEntry.SetMinRowsVisible(len(recoveryCodes))
+	content := container.NewVBox(
+		widget.NewLabel("Two-factor authentication is enabled.\n"+
+			"Save these recovery codes, each of them logs in once without authenticator app.\n"+
+			"They are not shown again."),
+		codesEntry,
+	)
+	dialog.ShowCustom("Recovery codes", "Saved", content, g.mainWindow)
+}
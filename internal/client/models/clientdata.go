@@ -17,15 +17,27 @@ type FolderDataType string
 
 // FolderData - types of data in folder
 var FolderData = struct {
-	CreditCard  FolderDataType
-	Credentials FolderDataType
-	Text        FolderDataType
-	Binary      FolderDataType
+	CreditCard    FolderDataType
+	Credentials   FolderDataType
+	Text          FolderDataType
+	Binary        FolderDataType
+	SSHKey        FolderDataType
+	Authenticator FolderDataType
+	APIToken      FolderDataType
+	Identity      FolderDataType
+	BankAccount   FolderDataType
+	WiFi          FolderDataType
 }{
-	CreditCard:  "cc",
-	Credentials: "cr",
-	Text:        "tx",
-	Binary:      "bn",
+	CreditCard:    "cc",
+	Credentials:   "cr",
+	Text:          "tx",
+	Binary:        "bi",
+	SSHKey:        "sk",
+	Authenticator: "ot",
+	APIToken:      "at",
+	Identity:      "id",
+	BankAccount:   "ba",
+	WiFi:          "wf",
 }
 
 // Data - general data struct
@@ -33,15 +45,23 @@ type Data struct {
 	UUID     string         `json:"uuid"`
 	Meta     string         `json:"meta"`
 	DataType FolderDataType `json:"data_type"`
+	Version  int64          `json:"version"`
 	Folder   Folder         `json:"data"`
 }
 
 // Folder - folder struct
+// fields of item types registered without typed struct are kept in Fields, see RegisterSchema
+// URLs, Notes and Custom fields can be attached to item of any type
 type Folder struct {
-	Card        CreditCard  `json:"card"`
-	Credentials Credentials `json:"credentials"`
-	Text        TextData    `json:"text"`
-	Binary      BinaryData  `json:"binary"`
+	Card        CreditCard        `json:"card"`
+	Credentials Credentials       `json:"credentials"`
+	Text        TextData          `json:"text"`
+	Binary      BinaryData        `json:"binary"`
+	SSHKey      SSHKey            `json:"ssh_key"`
+	Fields      map[string]string `json:"fields,omitempty"`
+	URLs        []string          `json:"urls,omitempty"`
+	Notes       string            `json:"notes,omitempty"`
+	Custom      []CustomField     `json:"custom,omitempty"`
 }
 
 // CreditCard - credit card struct
@@ -53,9 +73,13 @@ type CreditCard struct {
 }
 
 // Credentials - credentials struct
+// URL is address of service, git credential helper matches it by protocol, host, path and login
+// OTP is otpauth URI or seed of second factor of service
 type Credentials struct {
 	Login    string `json:"login"`
 	Password string `json:"password"`
+	URL      string `json:"url,omitempty"`
+	OTP      string `json:"otp,omitempty"`
 }
 
 // TextData - text data struct
@@ -64,19 +88,40 @@ type TextData struct {
 }
 
 // BinaryData - binary data struct
+// content of large binary is not in Data, it is streamed to server in chunks encrypted with StreamKey
 type BinaryData struct {
-	Name string `json:"name"`
-	Data []byte `json:"data"`
+	Name      string `json:"name"`
+	Data      []byte `json:"data"`
+	Size      int64  `json:"size,omitempty"`
+	StreamKey []byte `json:"stream_key,omitempty"`
 }
 
-// EncryptData - encrypt data
-func (d *Data) EncryptData(key []byte) (*StoredData, error) {
-	key32 := sha256.Sum256(key)
-	c, err := aes.NewCipher(key32[:])
+// IsStreamed - content of binary is streamed to server separately from data
+func (b *BinaryData) IsStreamed() bool {
+	return len(b.StreamKey) != 0
+}
+
+// sealedItem - payload of envelope version 3, meta is not sent to server in clear
+type sealedItem struct {
+	Meta   string `json:"meta"`
+	Folder Folder `json:"data"`
+}
+
+// EncryptData - encrypt meta and data with vault master key
+// the result is an envelope, so format of data can be changed later
+// Meta of the result is blinded search token of meta
+func (d *Data) EncryptData(keyring *Keyring) (*StoredData, error) {
+	if keyring == nil {
+		return nil, errors.New("keyring is not initialized")
+	}
+	if d.UUID == "" {
+		return nil, errors.New("data has no uuid")
+	}
+	masterKey, err := keyring.MasterKey()
 	if err != nil {
 		return nil, err
 	}
-	gcm, err := cipher.NewGCM(c)
+	gcm, err := newGCM(masterKey)
 	if err != nil {
 		return nil, err
 	}
@@ -84,53 +129,79 @@ func (d *Data) EncryptData(key []byte) (*StoredData, error) {
 	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
 		return nil, err
 	}
-	jsonFolder, err := json.Marshal(d.Folder)
+	jsonItem, err := json.Marshal(sealedItem{Meta: d.Meta, Folder: d.Folder})
+	if err != nil {
+		return nil, err
+	}
+	searchToken, err := keyring.SearchToken(d.Meta)
 	if err != nil {
 		return nil, err
 	}
 
-	encryptedData := gcm.Seal(nonce, nonce, jsonFolder, nil)
+	encryptedData := append([]byte(envelopeMagic), envelopeVersion4)
+	encryptedData = append(encryptedData, nonce...)
+	encryptedData = gcm.Seal(encryptedData, nonce, jsonItem, associatedData(keyring.user, d.UUID, string(d.DataType)))
 
 	return &StoredData{
 		UUID:          d.UUID,
-		Meta:          d.Meta,
+		Meta:          searchToken,
 		DataType:      string(d.DataType),
+		Version:       d.Version,
 		EncryptedData: encryptedData,
 	}, nil
 }
 
 // StoredData - stored data struct
+// Meta is blinded search token, meta of data encrypted before version 3 of envelope is in clear
 type StoredData struct {
 	UUID          string `json:"uuid"`
 	Meta          string `json:"meta"`
 	DataType      string `json:"data_type"`
+	Version       int64  `json:"version"`
 	EncryptedData []byte `json:"encrypted_data"`
 }
 
-// DecryptData - decrypt data
-func (s *StoredData) DecryptData(key []byte) (*Data, error) {
-	key32 := sha256.Sum256(key)
-	c, err := aes.NewCipher(key32[:])
-	if err != nil {
-		return nil, err
-	}
-	gcm, err := cipher.NewGCM(c)
-	if err != nil {
-		return nil, err
+// DecryptData - decrypt data encrypted with the current version of envelope
+// ErrContextMismatch is returned if data was encrypted for another user, uuid or type,
+// ErrLegacyEnvelope is returned for data encrypted before version 4 of envelope, it has no context
+func (s *StoredData) DecryptData(keyring *Keyring) (*Data, error) {
+	return s.decrypt(keyring, false)
+}
+
+// DecryptLegacyData - decrypt data in any format, data encrypted before version 4 of envelope is accepted,
+// it is used only to encrypt such data again
+func (s *StoredData) DecryptLegacyData(keyring *Keyring) (*Data, error) {
+	return s.decrypt(keyring, true)
+}
+
+// decrypt - decrypt data, data encrypted with master key, with key derived from passphrase
+// and in legacy format is accepted only if legacy is set
+func (s *StoredData) decrypt(keyring *Keyring, legacy bool) (*Data, error) {
+	if keyring == nil {
+		return nil, errors.New("keyring is not initialized")
 	}
-	nonceSize := gcm.NonceSize()
-	if len(s.EncryptedData) < nonceSize {
-		return nil, errors.New("encrypted data too short")
+	if !legacy && s.IsLegacy() {
+		return nil, ErrLegacyEnvelope
 	}
-	nonce, encryptedData := s.EncryptedData[:nonceSize], s.EncryptedData[nonceSize:]
-	decryptedData, err := gcm.Open(nil, nonce, encryptedData, nil)
+	version, decryptedData, err := s.open(keyring, legacy)
 	if err != nil {
 		return nil, err
 	}
 	var data Data
 	data.UUID = s.UUID
-	data.Meta = s.Meta
 	data.DataType = FolderDataType(s.DataType)
+	data.Version = s.Version
+	if version >= envelopeVersion3 {
+		var item sealedItem
+		err = json.Unmarshal(decryptedData, &item)
+		if err != nil {
+			return nil, err
+		}
+		data.Meta = item.Meta
+		data.Folder = item.Folder
+		return &data, nil
+	}
+	data.Meta = s.Meta
 	var folder Folder
 	err = json.Unmarshal(decryptedData, &folder)
 	if err != nil {
@@ -139,3 +210,66 @@ func (s *StoredData) DecryptData(key []byte) (*Data, error) {
 	data.Folder = folder
 	return &data, nil
 }
+
+// open - decrypt envelope or legacy data, version of envelope is 0 for legacy data
+// legacy data may start with envelope magic by chance, so it is tried if envelope can't be opened and legacy is set
+func (s *StoredData) open(keyring *Keyring, legacy bool) (byte, []byte, error) {
+	e, err := parseEnvelope(s.EncryptedData)
+	if err == nil {
+		var key []byte
+		if e.version == envelopeVersion1 {
+			key = keyring.key(e.params, e.salt)
+		} else {
+			key, err = keyring.MasterKey()
+		}
+		if err == nil {
+			var ad []byte
+			if e.version == envelopeVersion4 {
+				ad = associatedData(keyring.user, s.UUID, s.DataType)
+			}
+			var decryptedData []byte
+			decryptedData, err = openSealed(key, e.body, ad)
+			if err == nil {
+				return e.version, decryptedData, nil
+			}
+			if e.version == envelopeVersion4 {
+				err = ErrContextMismatch
+			}
+		}
+	}
+	if !legacy {
+		return 0, nil, err
+	}
+	legacyKey := sha256.Sum256(keyring.passphrase)
+	decryptedData, legacyErr := openSealed(legacyKey[:], s.EncryptedData, nil)
+	if legacyErr == nil {
+		return 0, decryptedData, nil
+	}
+	if errors.Is(err, ErrLegacyEnvelope) {
+		return 0, nil, legacyErr
+	}
+	return 0, nil, err
+}
+
+// newGCM - create AES-GCM cipher
+func newGCM(key []byte) (cipher.AEAD, error) {
+	c, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(c)
+}
+
+// openSealed - decrypt nonce | ciphertext, ad is associated data given at encryption
+func openSealed(key, sealed, ad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonceSize := gcm.NonceSize()
+	if len(sealed) < nonceSize {
+		return nil, errors.New("encrypted data too short")
+	}
+	nonce, encryptedData := sealed[:nonceSize], sealed[nonceSize:]
+	return gcm.Open(nil, nonce, encryptedData, ad)
+}
@@ -0,0 +1,37 @@
+// Package: models
+// in this file we have models for conflicts between local and server changes of the same data
+package models
+
+// ConflictPolicy - how conflict between local and server changes is resolved
+type ConflictPolicy string
+
+// ConflictPolicies - available conflict policies
+// Ask keeps the conflict until user chooses one of the other policies
+var ConflictPolicies = struct {
+	ServerWins ConflictPolicy
+	ClientWins ConflictPolicy
+	KeepBoth   ConflictPolicy
+	Ask        ConflictPolicy
+}{
+	ServerWins: "server-wins",
+	ClientWins: "client-wins",
+	KeepBoth:   "keep-both",
+	Ask:        "ask",
+}
+
+// Conflict - data changed both locally and on server since the last sync
+// Server is the current server copy, local copy is in local storage
+type Conflict struct {
+	UUID          string     `json:"uuid"`
+	Server        StoredData `json:"server"`
+	ServerDeleted bool       `json:"server_deleted"`
+	Detected      int64      `json:"detected"`
+}
+
+// DataConflict - decrypted copies of conflicting data for user
+// Local or Server is nil if data was deleted on that side
+type DataConflict struct {
+	UUID   string `json:"uuid"`
+	Local  *Data  `json:"local"`
+	Server *Data  `json:"server"`
+}
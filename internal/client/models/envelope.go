@@ -0,0 +1,380 @@
+// Package: models
+// in this file we have versioned envelope of encrypted data and key derivation
+package models
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// envelope header layout, all numbers are big endian:
+// version 1 - data sealed with key derived from passphrase, it is used for wrapping vault master key:
+// magic (3 bytes) | version (1) | kdf id (1) | time (4) | memory KiB (4) | threads (1) | salt length (1) | salt | nonce | ciphertext
+// version 2 - data sealed with vault master key:
+// magic (3 bytes) | version (1) | nonce | ciphertext
+// version 3 - the same as version 2, but meta is sealed together with data
+// version 4 - the same as version 3, but user, uuid and type of data are bound to ciphertext as associated data
+// version 5 - the same as version 1, but the header is bound to ciphertext as associated data,
+// so KDF params and salt of wrapped master key can't be changed; version 1 keys are still unwrapped
+// data encrypted before envelope was introduced is nonce | ciphertext with key sha256(passphrase)
+const (
+	envelopeMagic     = "DVE"
+	envelopeVersion1  = 1
+	envelopeVersion2  = 2
+	envelopeVersion3  = 3
+	envelopeVersion4  = 4
+	envelopeVersion5  = 5
+	envelopeFixedSize = len(envelopeMagic) + 1 + 1 + 4 + 4 + 1 + 1
+	// search token is sent to server instead of meta
+	searchKeyInfo     = "dedicated-vault search token"
+	searchTokenPrefix = "st1:"
+	searchTokenSize   = 16
+	// keyCheckCanary is sealed with master key to check that the key is right
+	keyCheckCanary = "dedicated-vault key check"
+	// SaltSize - size of random salt for key derivation
+	SaltSize = 16
+	keySize  = 32
+)
+
+// KDF ids in envelope header
+const (
+	kdfArgon2id byte = 1
+)
+
+// limits of KDF parameters accepted from envelope header, so a forged header can't exhaust client memory
+const (
+	maxKDFTime    = 16
+	maxKDFMemory  = 1024 * 1024
+	minSaltSize   = 8
+	maxSaltLength = 64
+)
+
+// minKDFMemory - master key is not wrapped or unwrapped with key derived with less memory (KiB),
+// so a forged header can't make client wrap it again with a key cheap to brute-force
+const minKDFMemory = 8 * 1024
+
+// ErrWeakKDFParams - KDF params of wrapped master key are below the floor
+var ErrWeakKDFParams = errors.New("kdf parameters of master key are too weak")
+
+// KDFParams - Argon2id parameters
+// Memory is in KiB
+type KDFParams struct {
+	Time    uint32 `json:"time"`
+	Memory  uint32 `json:"memory"`
+	Threads uint8  `json:"threads"`
+}
+
+// DefaultKDFParams - Argon2id parameters recommended by RFC 9106 for memory constrained environments
+var DefaultKDFParams = KDFParams{
+	Time:    3,
+	Memory:  64 * 1024,
+	Threads: 4,
+}
+
+// ErrLegacyEnvelope - data has no envelope header
+var ErrLegacyEnvelope = errors.New("data is encrypted in legacy format")
+
+// ErrContextMismatch - data can't be opened for its user, uuid and type, e.g. it was moved to another data
+var ErrContextMismatch = errors.New("encrypted data does not belong to this item")
+
+// ErrKeyCheckMismatch - key check value was sealed with another master key
+var ErrKeyCheckMismatch = errors.New("key check value does not match master key")
+
+// ErrVaultLocked - vault master key is not unwrapped yet
+var ErrVaultLocked = errors.New("vault is locked")
+
+// NewMasterKey - generate random vault master key
+func NewMasterKey() ([]byte, error) {
+	key := make([]byte, keySize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// NewSalt - generate random salt for key derivation
+func NewSalt() ([]byte, error) {
+	salt := make([]byte, SaltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, err
+	}
+	return salt, nil
+}
+
+// Keyring - keys derived from passphrase and vault master key
+// data is encrypted with master key, key derived from passphrase only wraps master key,
+// so passphrase can be changed without encrypting all data again
+// derived keys are cached, because Argon2id is slow by design
+type Keyring struct {
+	user       string
+	passphrase []byte
+	salt       []byte
+	params     KDFParams
+	mu         sync.Mutex
+	keys       map[string][]byte
+	masterKey  []byte
+}
+
+// NewKeyring - create keyring of user, new data is encrypted with key derived with the given salt and params
+func NewKeyring(user, passphrase string, salt []byte, params KDFParams) *Keyring {
+	return &Keyring{
+		user:       user,
+		passphrase: []byte(passphrase),
+		salt:       salt,
+		params:     params,
+		keys:       make(map[string][]byte),
+	}
+}
+
+// key - derive key with the given salt and params or take it from cache
+func (k *Keyring) key(params KDFParams, salt []byte) []byte {
+	cacheKey := fmt.Sprintf("%d:%d:%d:%x", params.Time, params.Memory, params.Threads, salt)
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if key, ok := k.keys[cacheKey]; ok {
+		return key
+	}
+	key := argon2.IDKey(k.passphrase, salt, params.Time, params.Memory, params.Threads, keySize)
+	k.keys[cacheKey] = key
+	return key
+}
+
+// SetMasterKey - set unwrapped vault master key
+func (k *Keyring) SetMasterKey(masterKey []byte) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.masterKey = masterKey
+}
+
+// MasterKey - unwrapped vault master key, ErrVaultLocked is returned if it is not set
+func (k *Keyring) MasterKey() ([]byte, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.masterKey == nil {
+		return nil, ErrVaultLocked
+	}
+	return k.masterKey, nil
+}
+
+// Wipe - overwrite master key and derived keys in memory, keyring can't be used after it
+func (k *Keyring) Wipe() {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	wipe := func(b []byte) {
+		for i := range b {
+			b[i] = 0
+		}
+	}
+	wipe(k.masterKey)
+	wipe(k.passphrase)
+	for cacheKey, key := range k.keys {
+		wipe(key)
+		delete(k.keys, cacheKey)
+	}
+	k.masterKey = nil
+}
+
+// WrappedKey - vault master key wrapped with key derived from passphrase and its key check value,
+// as they are saved on server, version 0 means there is no key yet
+type WrappedKey struct {
+	Key      []byte
+	KeyCheck []byte
+	Version  int64
+}
+
+// KeyCheck - canary sealed with master key
+// it is saved on server and locally, so master key can be checked before any data is encrypted with it
+func (k *Keyring) KeyCheck() ([]byte, error) {
+	masterKey, err := k.MasterKey()
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(masterKey)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	keyCheck := append([]byte(envelopeMagic), envelopeVersion4)
+	keyCheck = append(keyCheck, nonce...)
+	return gcm.Seal(keyCheck, nonce, []byte(keyCheckCanary), associatedData(k.user, keyCheckCanary, "")), nil
+}
+
+// VerifyKeyCheck - check that key check value was sealed with master key of keyring for its user
+func (k *Keyring) VerifyKeyCheck(keyCheck []byte) error {
+	masterKey, err := k.MasterKey()
+	if err != nil {
+		return err
+	}
+	e, err := parseEnvelope(keyCheck)
+	if err != nil || e.version != envelopeVersion4 {
+		return ErrKeyCheckMismatch
+	}
+	canary, err := openSealed(masterKey, e.body, associatedData(k.user, keyCheckCanary, ""))
+	if err != nil || string(canary) != keyCheckCanary {
+		return ErrKeyCheckMismatch
+	}
+	return nil
+}
+
+// SearchToken - blinded search token of meta
+// it is HMAC of normalized meta with key derived from master key, so the same meta gives the same token,
+// but server can't learn meta from it
+func (k *Keyring) SearchToken(meta string) (string, error) {
+	meta = strings.ToLower(strings.TrimSpace(meta))
+	if meta == "" {
+		return "", nil
+	}
+	masterKey, err := k.MasterKey()
+	if err != nil {
+		return "", err
+	}
+	keyMAC := hmac.New(sha256.New, masterKey)
+	keyMAC.Write([]byte(searchKeyInfo))
+	tokenMAC := hmac.New(sha256.New, keyMAC.Sum(nil))
+	tokenMAC.Write([]byte(meta))
+	return searchTokenPrefix + base64.RawURLEncoding.EncodeToString(tokenMAC.Sum(nil)[:searchTokenSize]), nil
+}
+
+// WrapKey - seal vault master key with key derived from passphrase, header is bound to it as associated data
+func (k *Keyring) WrapKey(masterKey []byte) ([]byte, error) {
+	if k.params.Memory < minKDFMemory {
+		return nil, ErrWeakKDFParams
+	}
+	gcm, err := newGCM(k.key(k.params, k.salt))
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	header := k.header(envelopeVersion5)
+	wrapped := append(header, nonce...)
+	return gcm.Seal(wrapped, nonce, masterKey, header), nil
+}
+
+// UnwrapKey - open vault master key sealed with key derived from passphrase
+// the salt and params are taken from the wrapped key, so it can be unwrapped on any device
+func (k *Keyring) UnwrapKey(wrapped []byte) ([]byte, error) {
+	e, err := parseEnvelope(wrapped)
+	if err != nil {
+		return nil, err
+	}
+	var ad []byte
+	switch e.version {
+	case envelopeVersion1:
+	case envelopeVersion5:
+		ad = e.header
+	default:
+		return nil, fmt.Errorf("unsupported wrapped key version %d", e.version)
+	}
+	if e.params.Memory < minKDFMemory {
+		return nil, ErrWeakKDFParams
+	}
+	masterKey, err := openSealed(k.key(e.params, e.salt), e.body, ad)
+	if err != nil {
+		return nil, err
+	}
+	if len(masterKey) != keySize {
+		return nil, errors.New("invalid master key size")
+	}
+	return masterKey, nil
+}
+
+// IsLegacyWrappedKey - master key is wrapped without its header bound to it and should be wrapped again
+func IsLegacyWrappedKey(wrapped []byte) bool {
+	e, err := parseEnvelope(wrapped)
+	return err == nil && e.version == envelopeVersion1
+}
+
+// envelope - parsed envelope header
+// params, salt and header are set only for versions 1 and 5
+type envelope struct {
+	version byte
+	params  KDFParams
+	salt    []byte
+	header  []byte
+	body    []byte
+}
+
+// header - envelope header of the given version for the keyring's salt and params
+func (k *Keyring) header(version byte) []byte {
+	header := make([]byte, 0, envelopeFixedSize+len(k.salt))
+	header = append(header, envelopeMagic...)
+	header = append(header, version, kdfArgon2id)
+	header = binary.BigEndian.AppendUint32(header, k.params.Time)
+	header = binary.BigEndian.AppendUint32(header, k.params.Memory)
+	header = append(header, k.params.Threads, byte(len(k.salt)))
+	return append(header, k.salt...)
+}
+
+// parseEnvelope - parse envelope header, ErrLegacyEnvelope is returned for data without it
+func parseEnvelope(data []byte) (*envelope, error) {
+	if len(data) < len(envelopeMagic)+1 || !bytes.HasPrefix(data, []byte(envelopeMagic)) {
+		return nil, ErrLegacyEnvelope
+	}
+	pos := len(envelopeMagic)
+	e := envelope{version: data[pos]}
+	switch e.version {
+	case envelopeVersion2, envelopeVersion3, envelopeVersion4:
+		e.body = data[pos+1:]
+		return &e, nil
+	case envelopeVersion1, envelopeVersion5:
+	default:
+		return nil, fmt.Errorf("unsupported envelope version %d", e.version)
+	}
+	if len(data) < envelopeFixedSize {
+		return nil, errors.New("envelope header too short")
+	}
+	if data[pos+1] != kdfArgon2id {
+		return nil, fmt.Errorf("unsupported kdf %d", data[pos+1])
+	}
+	pos += 2
+	e.params.Time = binary.BigEndian.Uint32(data[pos:])
+	e.params.Memory = binary.BigEndian.Uint32(data[pos+4:])
+	e.params.Threads = data[pos+8]
+	saltSize := int(data[pos+9])
+	pos += 10
+	if e.params.Time == 0 || e.params.Time > maxKDFTime || e.params.Memory > maxKDFMemory || e.params.Threads == 0 {
+		return nil, errors.New("invalid kdf parameters")
+	}
+	if saltSize < minSaltSize || saltSize > maxSaltLength || len(data) < pos+saltSize {
+		return nil, errors.New("invalid salt")
+	}
+	e.salt = data[pos : pos+saltSize]
+	e.header = data[:pos+saltSize]
+	e.body = data[pos+saltSize:]
+	return &e, nil
+}
+
+// IsLegacy - data is not encrypted in the current format and should be encrypted again
+func (s *StoredData) IsLegacy() bool {
+	e, err := parseEnvelope(s.EncryptedData)
+	return err != nil || e.version != envelopeVersion4
+}
+
+// associatedData - identity of data bound to its ciphertext,
+// so server can't move ciphertext to another data, type or user
+// every field is prefixed with its length, so fields can't be shifted
+func associatedData(user, uuid, dataType string) []byte {
+	ad := append([]byte(envelopeMagic), envelopeVersion4)
+	for _, field := range []string{user, uuid, dataType} {
+		ad = binary.BigEndian.AppendUint32(ad, uint32(len(field)))
+		ad = append(ad, field...)
+	}
+	return ad
+}
@@ -0,0 +1,256 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testParams - cheap KDF params, so tests are fast
+var testParams = KDFParams{Time: 1, Memory: minKDFMemory, Threads: 1}
+
+// testKeyring - keyring of test user with random master key
+func testKeyring(t *testing.T) *Keyring {
+	salt, err := NewSalt()
+	require.NoError(t, err)
+	masterKey, err := NewMasterKey()
+	require.NoError(t, err)
+	keyring := NewKeyring("testuser", "testpassphrase", salt, testParams)
+	keyring.SetMasterKey(masterKey)
+	return keyring
+}
+
+// seal - nonce | ciphertext of payload sealed with key, it is appended to prefix
+func seal(t *testing.T, key, prefix, payload, ad []byte) []byte {
+	gcm, err := newGCM(key)
+	require.NoError(t, err)
+	nonce := make([]byte, gcm.NonceSize())
+	_, err = io.ReadFull(rand.Reader, nonce)
+	require.NoError(t, err)
+	return gcm.Seal(append(append([]byte{}, prefix...), nonce...), nonce, payload, ad)
+}
+
+func TestStoredData_DecryptLegacyData(t *testing.T) {
+	keyring := testKeyring(t)
+	masterKey, err := keyring.MasterKey()
+	require.NoError(t, err)
+	folder, err := json.Marshal(Folder{Text: TextData{Text: "secret text"}})
+	require.NoError(t, err)
+	item, err := json.Marshal(sealedItem{Meta: "sealed meta", Folder: Folder{Text: TextData{Text: "secret text"}}})
+	require.NoError(t, err)
+	legacyKey := sha256.Sum256([]byte("testpassphrase"))
+	current, err := (&Data{UUID: "uuid1", Meta: "sealed meta", DataType: "tx", Version: 1,
+		Folder: Folder{Text: TextData{Text: "secret text"}}}).EncryptData(keyring)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		encrypted  []byte
+		wantLegacy bool
+		wantMeta   string
+	}{
+		{
+			name:       "Legacy data without envelope",
+			encrypted:  seal(t, legacyKey[:], nil, folder, nil),
+			wantLegacy: true,
+			wantMeta:   "clear meta",
+		},
+		{
+			name:       "Version 1, key derived from passphrase",
+			encrypted:  seal(t, keyring.key(keyring.params, keyring.salt), keyring.header(envelopeVersion1), folder, nil),
+			wantLegacy: true,
+			wantMeta:   "clear meta",
+		},
+		{
+			name:       "Version 2, master key",
+			encrypted:  seal(t, masterKey, append([]byte(envelopeMagic), envelopeVersion2), folder, nil),
+			wantLegacy: true,
+			wantMeta:   "clear meta",
+		},
+		{
+			name:       "Version 3, meta is sealed",
+			encrypted:  seal(t, masterKey, append([]byte(envelopeMagic), envelopeVersion3), item, nil),
+			wantLegacy: true,
+			wantMeta:   "sealed meta",
+		},
+		{
+			name:      "Version 4, context is bound",
+			encrypted: current.EncryptedData,
+			wantMeta:  "sealed meta",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stored := StoredData{UUID: "uuid1", Meta: "clear meta", DataType: "tx", Version: 1, EncryptedData: tt.encrypted}
+			assert.Equal(t, tt.wantLegacy, stored.IsLegacy())
+			data, err := stored.DecryptData(keyring)
+			if tt.wantLegacy {
+				// data without context is opened only to be encrypted again
+				assert.ErrorIs(t, err, ErrLegacyEnvelope)
+				data, err = stored.DecryptLegacyData(keyring)
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantMeta, data.Meta)
+			assert.Equal(t, "secret text", data.Folder.Text.Text)
+			assert.Equal(t, "uuid1", data.UUID)
+			assert.Equal(t, FolderDataType("tx"), data.DataType)
+		})
+	}
+}
+
+func TestStoredData_DecryptDataRejectsTampering(t *testing.T) {
+	keyring := testKeyring(t)
+	stored, err := (&Data{UUID: "uuid1", Meta: "meta", DataType: "tx", Version: 1,
+		Folder: Folder{Text: TextData{Text: "secret text"}}}).EncryptData(keyring)
+	require.NoError(t, err)
+	otherUser := NewKeyring("otheruser", "testpassphrase", keyring.salt, testParams)
+	masterKey, err := keyring.MasterKey()
+	require.NoError(t, err)
+	otherUser.SetMasterKey(masterKey)
+
+	tampered := func(pos int) []byte {
+		encrypted := append([]byte{}, stored.EncryptedData...)
+		encrypted[pos] ^= 1
+		return encrypted
+	}
+	tests := []struct {
+		name    string
+		stored  StoredData
+		keyring *Keyring
+		wantErr error
+	}{
+		{
+			name:    "Tampered magic",
+			stored:  StoredData{UUID: "uuid1", DataType: "tx", EncryptedData: tampered(0)},
+			keyring: keyring,
+		},
+		{
+			// version 3 has no associated data, so downgraded envelope can't be opened
+			name: "Version downgraded in header",
+			stored: StoredData{UUID: "uuid1", DataType: "tx",
+				EncryptedData: append(append([]byte(envelopeMagic), envelopeVersion3), stored.EncryptedData[len(envelopeMagic)+1:]...)},
+			keyring: keyring,
+		},
+		{
+			name:    "Unknown version in header",
+			stored:  StoredData{UUID: "uuid1", DataType: "tx", EncryptedData: tampered(len(envelopeMagic))},
+			keyring: keyring,
+		},
+		{
+			name:    "Tampered nonce",
+			stored:  StoredData{UUID: "uuid1", DataType: "tx", EncryptedData: tampered(len(envelopeMagic) + 1)},
+			keyring: keyring,
+			wantErr: ErrContextMismatch,
+		},
+		{
+			name:    "Tampered ciphertext",
+			stored:  StoredData{UUID: "uuid1", DataType: "tx", EncryptedData: tampered(len(stored.EncryptedData) - 1)},
+			keyring: keyring,
+			wantErr: ErrContextMismatch,
+		},
+		{
+			name:    "Associated data of another uuid",
+			stored:  StoredData{UUID: "uuid2", DataType: "tx", EncryptedData: stored.EncryptedData},
+			keyring: keyring,
+			wantErr: ErrContextMismatch,
+		},
+		{
+			name:    "Associated data of another type",
+			stored:  StoredData{UUID: "uuid1", DataType: "cr", EncryptedData: stored.EncryptedData},
+			keyring: keyring,
+			wantErr: ErrContextMismatch,
+		},
+		{
+			name:    "Associated data of another user",
+			stored:  StoredData{UUID: "uuid1", DataType: "tx", EncryptedData: stored.EncryptedData},
+			keyring: otherUser,
+			wantErr: ErrContextMismatch,
+		},
+		{
+			name:    "Wrong master key",
+			stored:  StoredData{UUID: "uuid1", DataType: "tx", EncryptedData: stored.EncryptedData},
+			keyring: testKeyring(t),
+			wantErr: ErrContextMismatch,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := tt.stored.DecryptData(tt.keyring)
+			assert.Error(t, err)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			}
+			assert.Nil(t, data)
+		})
+	}
+}
+
+func TestKeyring_WrapKey(t *testing.T) {
+	keyring := testKeyring(t)
+	masterKey, err := keyring.MasterKey()
+	require.NoError(t, err)
+	wrapped, err := keyring.WrapKey(masterKey)
+	require.NoError(t, err)
+
+	// salt and params are taken from header, so another device unwraps the key with passphrase only
+	unwrapped, err := NewKeyring("testuser", "testpassphrase", nil, DefaultKDFParams).UnwrapKey(wrapped)
+	require.NoError(t, err)
+	assert.Equal(t, masterKey, unwrapped)
+
+	_, err = NewKeyring("testuser", "wrongpassphrase", nil, DefaultKDFParams).UnwrapKey(wrapped)
+	assert.Error(t, err)
+
+	// memory of KDF is limited, so a forged header can't exhaust memory of client
+	forged := append([]byte{}, wrapped...)
+	forged[len(envelopeMagic)+2+4] = 0xff
+	_, err = keyring.UnwrapKey(forged)
+	assert.EqualError(t, err, "invalid kdf parameters")
+
+	// header is bound to wrapped key, so it can't be downgraded to version without associated data
+	forged = append([]byte{}, wrapped...)
+	forged[len(envelopeMagic)] = envelopeVersion1
+	_, err = keyring.UnwrapKey(forged)
+	assert.Error(t, err)
+
+	// key can't be unwrapped with params cheap to brute-force
+	forged = append([]byte{}, wrapped...)
+	binary.BigEndian.PutUint32(forged[len(envelopeMagic)+2+4:], minKDFMemory-1)
+	_, err = keyring.UnwrapKey(forged)
+	assert.ErrorIs(t, err, ErrWeakKDFParams)
+	_, err = NewKeyring("testuser", "testpassphrase", keyring.salt, KDFParams{Time: 1, Memory: 64, Threads: 1}).WrapKey(masterKey)
+	assert.ErrorIs(t, err, ErrWeakKDFParams)
+}
+
+func TestKeyring_UnwrapLegacyKey(t *testing.T) {
+	keyring := testKeyring(t)
+	masterKey, err := keyring.MasterKey()
+	require.NoError(t, err)
+	wrapped, err := keyring.WrapKey(masterKey)
+	require.NoError(t, err)
+	assert.False(t, IsLegacyWrappedKey(wrapped))
+
+	// key wrapped before its header was bound to it is still unwrapped
+	legacy := seal(t, keyring.key(keyring.params, keyring.salt), keyring.header(envelopeVersion1), masterKey, nil)
+	assert.True(t, IsLegacyWrappedKey(legacy))
+	unwrapped, err := NewKeyring("testuser", "testpassphrase", nil, DefaultKDFParams).UnwrapKey(legacy)
+	require.NoError(t, err)
+	assert.Equal(t, masterKey, unwrapped)
+}
+
+func TestKeyring_VerifyKeyCheck(t *testing.T) {
+	keyring := testKeyring(t)
+	keyCheck, err := keyring.KeyCheck()
+	require.NoError(t, err)
+	assert.NoError(t, keyring.VerifyKeyCheck(keyCheck))
+	assert.ErrorIs(t, testKeyring(t).VerifyKeyCheck(keyCheck), ErrKeyCheckMismatch)
+
+	tampered := append([]byte{}, keyCheck...)
+	tampered[len(tampered)-1] ^= 1
+	assert.ErrorIs(t, keyring.VerifyKeyCheck(tampered), ErrKeyCheckMismatch)
+}
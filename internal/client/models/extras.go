@@ -0,0 +1,112 @@
+// Package: models
+// in this file we have URLs, notes and user defined fields which any item can have
+package models
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CustomFieldKind - kind of user defined field
+type CustomFieldKind string
+
+// CustomFieldKinds - kinds of user defined fields
+var CustomFieldKinds = struct {
+	Text   CustomFieldKind
+	Hidden CustomFieldKind
+	Date   CustomFieldKind
+	Bool   CustomFieldKind
+}{
+	Text: "text",
+	// Hidden is text masked in editors
+	Hidden: "hidden",
+	// Date is date in format YYYY-MM-DD
+	Date: "date",
+	Bool: "bool",
+}
+
+// CustomField - user defined field of item, it is encrypted with the other fields of item
+type CustomField struct {
+	Name  string          `json:"name"`
+	Kind  CustomFieldKind `json:"kind"`
+	Value string          `json:"value"`
+}
+
+// ParseCustomFieldKind parses kind of user defined field, empty kind is text
+func ParseCustomFieldKind(kind string) (CustomFieldKind, error) {
+	switch k := CustomFieldKind(strings.ToLower(kind)); k {
+	case "":
+		return CustomFieldKinds.Text, nil
+	case CustomFieldKinds.Text, CustomFieldKinds.Hidden, CustomFieldKinds.Date, CustomFieldKinds.Bool:
+		return k, nil
+	}
+	return "", fmt.Errorf("%w: unknown kind %q of custom field, kinds: text, hidden, date, bool", ErrInvalidField, kind)
+}
+
+// CustomField returns user defined field by name
+func (f *Folder) CustomField(name string) (*CustomField, bool) {
+	for i := range f.Custom {
+		if f.Custom[i].Name == name {
+			return &f.Custom[i], true
+		}
+	}
+	return nil, false
+}
+
+// SetCustomField adds user defined field or changes field with the same name
+func (f *Folder) SetCustomField(field CustomField) {
+	if existing, ok := f.CustomField(field.Name); ok {
+		*existing = field
+		return
+	}
+	f.Custom = append(f.Custom, field)
+}
+
+// RemoveCustomField removes user defined field, false is returned if there is no such field
+func (f *Folder) RemoveCustomField(name string) bool {
+	for i := range f.Custom {
+		if f.Custom[i].Name == name {
+			f.Custom = append(f.Custom[:i], f.Custom[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateExtras validates URLs and user defined fields of item, it is called by Prepare of every schema
+func (f *Folder) ValidateExtras() error {
+	for _, u := range f.URLs {
+		if err := validateURL(u); err != nil {
+			return fmt.Errorf("%w: url %q %s", ErrInvalidField, u, err)
+		}
+	}
+	names := make(map[string]bool, len(f.Custom))
+	for _, field := range f.Custom {
+		if strings.TrimSpace(field.Name) == "" {
+			return fmt.Errorf("%w: custom field has no name", ErrInvalidField)
+		}
+		if names[field.Name] {
+			return fmt.Errorf("%w: custom field %s is declared twice", ErrInvalidField, field.Name)
+		}
+		names[field.Name] = true
+		if _, err := ParseCustomFieldKind(string(field.Kind)); err != nil {
+			return err
+		}
+		if field.Value == "" {
+			continue
+		}
+		switch field.Kind {
+		case CustomFieldKinds.Date:
+			if _, err := time.Parse(DateLayout, field.Value); err != nil {
+				return fmt.Errorf("%w: %s must be a date YYYY-MM-DD", ErrInvalidField, field.Name)
+			}
+		case CustomFieldKinds.Bool:
+			if _, err := strconv.ParseBool(field.Value); err != nil {
+				return fmt.Errorf("%w: %s must be true or false", ErrInvalidField, field.Name)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+// Package: models
+// in this file we have one-time passwords of authenticator items, TOTP (RFC 6238) and HOTP (RFC 4226)
+package models
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OTP types
+const (
+	OTPTypeTOTP = "totp"
+	OTPTypeHOTP = "hotp"
+)
+
+// ErrInvalidOTP - otpauth URI or seed can't be parsed
+var ErrInvalidOTP = errors.New("invalid otp")
+
+// OTP - parameters of one-time password generator
+// it is parsed from otpauth URI, a bare base32 seed is TOTP with default parameters
+type OTP struct {
+	Type      string
+	Label     string
+	Issuer    string
+	Secret    []byte
+	Algorithm string
+	Digits    int
+	Period    int
+	Counter   uint64
+	// params - parameters of URI, unknown ones are kept when URI is formatted again
+	params url.Values
+}
+
+// ParseOTP parses otpauth URI or base32 seed
+func ParseOTP(value string) (*OTP, error) {
+	value = strings.TrimSpace(value)
+	o := &OTP{Type: OTPTypeTOTP, Algorithm: "SHA1", Digits: 6, Period: 30, params: url.Values{}}
+	if !strings.HasPrefix(strings.ToLower(value), "otpauth://") {
+		secret, err := decodeSeed(value)
+		if err != nil {
+			return nil, err
+		}
+		o.Secret = secret
+		o.params.Set("secret", value)
+		return o, nil
+	}
+	u, err := url.Parse(value)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidOTP, err)
+	}
+	o.Type = strings.ToLower(u.Host)
+	if o.Type != OTPTypeTOTP && o.Type != OTPTypeHOTP {
+		return nil, fmt.Errorf("%w: unknown type %q", ErrInvalidOTP, u.Host)
+	}
+	o.Label = strings.TrimPrefix(u.Path, "/")
+	o.params = u.Query()
+	o.Issuer = o.params.Get("issuer")
+	if o.Issuer == "" {
+		if issuer, _, ok := strings.Cut(o.Label, ":"); ok {
+			o.Issuer = issuer
+		}
+	}
+	o.Secret, err = decodeSeed(o.params.Get("secret"))
+	if err != nil {
+		return nil, err
+	}
+	if algorithm := o.params.Get("algorithm"); algorithm != "" {
+		o.Algorithm = strings.ToUpper(algorithm)
+		if _, err := o.hash(); err != nil {
+			return nil, err
+		}
+	}
+	if digits := o.params.Get("digits"); digits != "" {
+		o.Digits, err = strconv.Atoi(digits)
+		if err != nil || o.Digits < 6 || o.Digits > 10 {
+			return nil, fmt.Errorf("%w: digits must be 6 to 10", ErrInvalidOTP)
+		}
+	}
+	if period := o.params.Get("period"); period != "" {
+		o.Period, err = strconv.Atoi(period)
+		if err != nil || o.Period <= 0 {
+			return nil, fmt.Errorf("%w: invalid period %q", ErrInvalidOTP, period)
+		}
+	}
+	if o.Type == OTPTypeHOTP {
+		o.Counter, err = strconv.ParseUint(o.params.Get("counter"), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: hotp needs counter", ErrInvalidOTP)
+		}
+	}
+	return o, nil
+}
+
+// decodeSeed decodes base32 seed, padding, spaces and case are ignored as authenticator apps do
+func decodeSeed(seed string) ([]byte, error) {
+	seed = strings.ToUpper(strings.NewReplacer(" ", "", "-", "", "=", "").Replace(seed))
+	if seed == "" {
+		return nil, fmt.Errorf("%w: secret is required", ErrInvalidOTP)
+	}
+	secret, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(seed)
+	if err != nil {
+		return nil, fmt.Errorf("%w: secret is not base32", ErrInvalidOTP)
+	}
+	return secret, nil
+}
+
+// hash returns hash function of algorithm
+func (o *OTP) hash() (func() hash.Hash, error) {
+	switch o.Algorithm {
+	case "SHA1":
+		return sha1.New, nil
+	case "SHA256":
+		return sha256.New, nil
+	case "SHA512":
+		return sha512.New, nil
+	}
+	return nil, fmt.Errorf("%w: unknown algorithm %q", ErrInvalidOTP, o.Algorithm)
+}
+
+// HOTP generates code for counter, RFC 4226
+func (o *OTP) HOTP(counter uint64) (string, error) {
+	h, err := o.hash()
+	if err != nil {
+		return "", err
+	}
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], counter)
+	mac := hmac.New(h, o.Secret)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+	// dynamic truncation
+	offset := sum[len(sum)-1] & 0x0f
+	code := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	mod := uint64(1)
+	for i := 0; i < o.Digits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", o.Digits, uint64(code)%mod), nil
+}
+
+// Code generates code, TOTP code is for time t (RFC 6238), HOTP code is for the current counter
+func (o *OTP) Code(t time.Time) (string, error) {
+	if o.Type == OTPTypeHOTP {
+		return o.HOTP(o.Counter)
+	}
+	return o.HOTP(uint64(t.Unix()) / uint64(o.Period))
+}
+
+// Remaining returns how long TOTP code for time t is valid, HOTP code is valid until it is used
+func (o *OTP) Remaining(t time.Time) time.Duration {
+	if o.Type == OTPTypeHOTP {
+		return 0
+	}
+	period := int64(o.Period)
+	return time.Duration(period-t.Unix()%period) * time.Second
+}
+
+// NextCounter returns otpauth URI with counter of HOTP increased, it is saved after code is used
+func (o *OTP) NextCounter() (string, error) {
+	if o.Type != OTPTypeHOTP {
+		return "", fmt.Errorf("%w: only hotp has counter", ErrInvalidOTP)
+	}
+	o.Counter++
+	o.params.Set("counter", strconv.FormatUint(o.Counter, 10))
+	u := url.URL{
+		Scheme:   "otpauth",
+		Host:     o.Type,
+		Path:     "/" + o.Label,
+		RawQuery: o.params.Encode(),
+	}
+	return u.String(), nil
+}
+
+// validateOTP checks otpauth URI or seed
+func validateOTP(value string) error {
+	_, err := ParseOTP(value)
+	if err != nil {
+		return errors.New(strings.TrimPrefix(err.Error(), ErrInvalidOTP.Error()+": "))
+	}
+	return nil
+}
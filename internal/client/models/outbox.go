@@ -0,0 +1,28 @@
+// Package: models
+// in this file we have models for local changes waiting to be pushed to server
+package models
+
+// OutboxOperation - type of change in outbox
+type OutboxOperation string
+
+// OutboxOperations - types of changes in outbox
+var OutboxOperations = struct {
+	Create OutboxOperation
+	Change OutboxOperation
+	Delete OutboxOperation
+}{
+	Create: "create",
+	Change: "change",
+	Delete: "delete",
+}
+
+// OutboxEntry - local change which is not applied on server yet
+type OutboxEntry struct {
+	ID              int64           `json:"id"`
+	Operation       OutboxOperation `json:"operation"`
+	Data            StoredData      `json:"data"`
+	ExpectedVersion int64           `json:"expected_version"`
+	Created         int64           `json:"created"`
+	Attempts        int64           `json:"attempts"`
+	LastError       string          `json:"last_error"`
+}
@@ -0,0 +1,224 @@
+// Package: models
+// in this file we have schema registry of item types
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// FieldKind - kind of field, it defines editor of field
+type FieldKind string
+
+// FieldKinds - kinds of fields
+var FieldKinds = struct {
+	Text      FieldKind
+	MultiLine FieldKind
+	Bool      FieldKind
+	Date      FieldKind
+	OTP       FieldKind
+}{
+	Text:      "text",
+	MultiLine: "multiline",
+	Bool:      "bool",
+	// Date is date in format YYYY-MM-DD
+	Date: "date",
+	// OTP is otpauth URI or seed, clients generate codes of it
+	OTP: "otp",
+}
+
+// DateLayout - layout of values of date fields
+const DateLayout = "2006-01-02"
+
+// ErrInvalidField - value of field doesn't pass validation of schema
+var ErrInvalidField = errors.New("invalid field")
+
+// Field - field of item type
+// Get and Set bind field to typed struct of Folder, values of fields without them are kept in Folder.Fields,
+// so a new item type is added by registering its schema only
+type Field struct {
+	// Name is name of field in JSON output, command line flags and templates
+	Name  string
+	Label string
+	Kind  FieldKind
+	// Secret - value is a secret, it isn't shown in descriptions and can be read by command line client from descriptor
+	Secret bool
+	// Hidden - value is masked in editor
+	Hidden   bool
+	Required bool
+	// ReadOnly - value is computed by Get, it isn't edited
+	ReadOnly bool
+	Validate func(value string) error
+	Get      func(f *Folder) string
+	Set      func(f *Folder, value string)
+}
+
+// Schema - item type and its fields
+type Schema struct {
+	Type FolderDataType
+	// Name is name of type in command line client, Title is title of its tab in gui
+	Name   string
+	Title  string
+	Fields []Field
+	// Content - item has binary content streamed apart from its fields
+	Content bool
+	// Complete derives computed values and checks item as a whole after fields are validated
+	Complete func(f *Folder) error
+}
+
+// registry - registered schemas in order of registration
+var registry = struct {
+	sync.RWMutex
+	schemas []*Schema
+}{}
+
+// RegisterSchema registers schema of item type, it panics if type is already registered
+// it is called from init, like registration of database drivers
+func RegisterSchema(s Schema) {
+	registry.Lock()
+	defer registry.Unlock()
+	for _, registered := range registry.schemas {
+		if registered.Type == s.Type || registered.Name == s.Name {
+			panic(fmt.Sprintf("models: schema of type %s is already registered", s.Type))
+		}
+	}
+	names := make(map[string]bool, len(s.Fields))
+	for _, field := range s.Fields {
+		if names[field.Name] {
+			panic(fmt.Sprintf("models: field %s of type %s is declared twice", field.Name, s.Type))
+		}
+		if field.ReadOnly && field.Get == nil {
+			panic(fmt.Sprintf("models: read only field %s of type %s has no Get", field.Name, s.Type))
+		}
+		names[field.Name] = true
+	}
+	registry.schemas = append(registry.schemas, &s)
+}
+
+// SchemaOf returns schema of item type
+func SchemaOf(dataType FolderDataType) (*Schema, bool) {
+	registry.RLock()
+	defer registry.RUnlock()
+	for _, s := range registry.schemas {
+		if s.Type == dataType {
+			return s, true
+		}
+	}
+	return nil, false
+}
+
+// Schemas returns registered schemas in order of registration
+func Schemas() []*Schema {
+	registry.RLock()
+	defer registry.RUnlock()
+	return append([]*Schema(nil), registry.schemas...)
+}
+
+// Field returns field by name
+func (s *Schema) Field(name string) (*Field, bool) {
+	for i := range s.Fields {
+		if s.Fields[i].Name == name {
+			return &s.Fields[i], true
+		}
+	}
+	return nil, false
+}
+
+// Value returns value of field of folder
+func (field *Field) Value(f *Folder) string {
+	if field.Get != nil {
+		return field.Get(f)
+	}
+	return f.Fields[field.Name]
+}
+
+// Values returns values of all fields of folder by their names
+func (s *Schema) Values(f *Folder) map[string]string {
+	values := make(map[string]string, len(s.Fields))
+	for i := range s.Fields {
+		values[s.Fields[i].Name] = s.Fields[i].Value(f)
+	}
+	return values
+}
+
+// Set sets value of field of folder, value isn't validated until Prepare
+func (s *Schema) Set(f *Folder, name, value string) error {
+	field, ok := s.Field(name)
+	if !ok {
+		return fmt.Errorf("%w: type %s has no field %s", ErrInvalidField, s.Name, name)
+	}
+	if field.ReadOnly {
+		return fmt.Errorf("%w: field %s is read only", ErrInvalidField, name)
+	}
+	if field.Set != nil {
+		field.Set(f, value)
+		return nil
+	}
+	if f.Fields == nil {
+		f.Fields = make(map[string]string)
+	}
+	f.Fields[name] = value
+	return nil
+}
+
+// Prepare validates fields of folder and derives computed values, it is called before item is saved
+func (s *Schema) Prepare(f *Folder) error {
+	for i := range s.Fields {
+		field := &s.Fields[i]
+		if field.ReadOnly {
+			continue
+		}
+		value := field.Value(f)
+		if value == "" {
+			if field.Required {
+				return fmt.Errorf("%w: %s is required", ErrInvalidField, field.Name)
+			}
+			continue
+		}
+		if field.Kind == FieldKinds.Bool {
+			if _, err := strconv.ParseBool(value); err != nil {
+				return fmt.Errorf("%w: %s must be true or false", ErrInvalidField, field.Name)
+			}
+		}
+		if field.Kind == FieldKinds.Date {
+			if _, err := time.Parse(DateLayout, value); err != nil {
+				return fmt.Errorf("%w: %s must be a date YYYY-MM-DD", ErrInvalidField, field.Name)
+			}
+		}
+		if field.Validate != nil {
+			if err := field.Validate(value); err != nil {
+				return fmt.Errorf("%w: %s %s", ErrInvalidField, field.Name, err)
+			}
+		}
+	}
+	if err := f.ValidateExtras(); err != nil {
+		return err
+	}
+	if s.Complete != nil {
+		return s.Complete(f)
+	}
+	return nil
+}
+
+// OTPField returns field with otpauth URI or seed
+func (s *Schema) OTPField() (*Field, bool) {
+	for i := range s.Fields {
+		if s.Fields[i].Kind == FieldKinds.OTP {
+			return &s.Fields[i], true
+		}
+	}
+	return nil, false
+}
+
+// Summary returns the first field which is not secret, it describes item without its secrets
+func (s *Schema) Summary() (*Field, bool) {
+	for i := range s.Fields {
+		if !s.Fields[i].Secret && !s.Fields[i].ReadOnly {
+			return &s.Fields[i], true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,134 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testSchema - schema of type which is not registered, its fields are kept in Folder.Fields
+var testSchema = Schema{
+	Type:  "zz",
+	Name:  "test",
+	Title: "Test",
+	Fields: []Field{
+		{Name: "name", Label: "Name", Kind: FieldKinds.Text, Required: true},
+		{Name: "active", Label: "Active", Kind: FieldKinds.Bool},
+		{Name: "expires", Label: "Expires", Kind: FieldKinds.Date},
+		{
+			Name: "expired", Label: "Expired", Kind: FieldKinds.Bool, ReadOnly: true,
+			Get: func(f *Folder) string { return "false" },
+		},
+	},
+}
+
+func TestRegisterSchema(t *testing.T) {
+	tests := []struct {
+		name      string
+		schema    Schema
+		wantPanic string
+	}{
+		{
+			name:      "Type is already registered",
+			schema:    Schema{Type: FolderData.Credentials, Name: "another"},
+			wantPanic: "models: schema of type cr is already registered",
+		},
+		{
+			name:      "Name is already registered",
+			schema:    Schema{Type: "zz", Name: "credentials"},
+			wantPanic: "models: schema of type zz is already registered",
+		},
+		{
+			name: "Field is declared twice",
+			schema: Schema{Type: "zz", Name: "test", Fields: []Field{
+				{Name: "name", Kind: FieldKinds.Text},
+				{Name: "name", Kind: FieldKinds.Text},
+			}},
+			wantPanic: "models: field name of type zz is declared twice",
+		},
+		{
+			name: "Read only field without Get",
+			schema: Schema{Type: "zz", Name: "test", Fields: []Field{
+				{Name: "expired", Kind: FieldKinds.Bool, ReadOnly: true},
+			}},
+			wantPanic: "models: read only field expired of type zz has no Get",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.PanicsWithValue(t, tt.wantPanic, func() { RegisterSchema(tt.schema) })
+			_, ok := SchemaOf("zz")
+			assert.False(t, ok)
+		})
+	}
+}
+
+func TestSchema_Prepare(t *testing.T) {
+	tests := []struct {
+		name    string
+		fields  map[string]string
+		wantErr string
+	}{
+		{
+			name:   "Valid fields",
+			fields: map[string]string{"name": "item", "active": "true", "expires": "2030-01-31"},
+		},
+		{
+			name:   "Optional fields are empty",
+			fields: map[string]string{"name": "item"},
+		},
+		{
+			name:    "Required field is empty",
+			fields:  map[string]string{"active": "true"},
+			wantErr: "invalid field: name is required",
+		},
+		{
+			name:    "Bool is not true or false",
+			fields:  map[string]string{"name": "item", "active": "yes"},
+			wantErr: "invalid field: active must be true or false",
+		},
+		{
+			name:    "Date is not YYYY-MM-DD",
+			fields:  map[string]string{"name": "item", "expires": "31.01.2030"},
+			wantErr: "invalid field: expires must be a date YYYY-MM-DD",
+		},
+		{
+			name:    "Date doesn't exist",
+			fields:  map[string]string{"name": "item", "expires": "2030-02-30"},
+			wantErr: "invalid field: expires must be a date YYYY-MM-DD",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := testSchema.Prepare(&Folder{Fields: tt.fields})
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, ErrInvalidField)
+			assert.EqualError(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestSchema_Set(t *testing.T) {
+	var folder Folder
+	require.NoError(t, testSchema.Set(&folder, "name", "item"))
+	assert.Equal(t, "item", folder.Fields["name"])
+
+	err := testSchema.Set(&folder, "expired", "true")
+	assert.ErrorIs(t, err, ErrInvalidField)
+	assert.EqualError(t, err, "invalid field: field expired is read only")
+	_, ok := folder.Fields["expired"]
+	assert.False(t, ok)
+
+	err = testSchema.Set(&folder, "unknown", "value")
+	assert.EqualError(t, err, "invalid field: type test has no field unknown")
+
+	// fields bound to typed struct are set there
+	credentials, ok := SchemaOf(FolderData.Credentials)
+	require.True(t, ok)
+	require.NoError(t, credentials.Set(&folder, "login", "admin"))
+	assert.Equal(t, "admin", folder.Credentials.Login)
+}
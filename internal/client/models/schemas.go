@@ -0,0 +1,443 @@
+// Package: models
+// in this file we have schemas of built-in item types
+package models
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// expireDate - expire date of card, MM/YY or MM/YYYY
+	expireDate = regexp.MustCompile(`^(0[1-9]|1[0-2])/([0-9]{2}|[0-9]{4})$`)
+	// countryCode - ISO 3166-1 alpha-2 code of country
+	countryCode = regexp.MustCompile(`^[A-Z]{2}$`)
+	// ibanFormat - country code, check digits and basic bank account number
+	ibanFormat = regexp.MustCompile(`^[A-Z]{2}[0-9]{2}[A-Z0-9]{11,30}$`)
+	// swiftFormat - bank, country, location and optional branch
+	swiftFormat = regexp.MustCompile(`^[A-Z]{4}[A-Z]{2}[A-Z0-9]{2}([A-Z0-9]{3})?$`)
+)
+
+func init() {
+	RegisterSchema(Schema{
+		Type:  FolderData.Credentials,
+		Name:  "credentials",
+		Title: "Credentials",
+		Fields: []Field{
+			{
+				Name: "login", Label: "Login", Kind: FieldKinds.Text, Required: true,
+				Get: func(f *Folder) string { return f.Credentials.Login },
+				Set: func(f *Folder, v string) { f.Credentials.Login = v },
+			},
+			{
+				Name: "password", Label: "Password", Kind: FieldKinds.Text, Secret: true, Hidden: true, Required: true,
+				Get: func(f *Folder) string { return f.Credentials.Password },
+				Set: func(f *Folder, v string) { f.Credentials.Password = v },
+			},
+			{
+				Name: "url", Label: "URL", Kind: FieldKinds.Text, Validate: validateURL,
+				Get: func(f *Folder) string { return f.Credentials.URL },
+				Set: func(f *Folder, v string) { f.Credentials.URL = v },
+			},
+			{
+				Name: "otp", Label: "One-time password (otpauth URI or seed)", Kind: FieldKinds.OTP,
+				Secret: true, Hidden: true, Validate: validateOTP,
+				Get: func(f *Folder) string { return f.Credentials.OTP },
+				Set: func(f *Folder, v string) { f.Credentials.OTP = v },
+			},
+		},
+	})
+	RegisterSchema(Schema{
+		Type:  FolderData.CreditCard,
+		Name:  "card",
+		Title: "Credit Cards",
+		Fields: []Field{
+			{
+				Name: "number", Label: "Number", Kind: FieldKinds.Text, Required: true, Validate: validateCardNumber,
+				Get: func(f *Folder) string { return f.Card.Number },
+				Set: func(f *Folder, v string) { f.Card.Number = v },
+			},
+			{
+				Name: "name_on_card", Label: "Owner", Kind: FieldKinds.Text, Required: true,
+				Get: func(f *Folder) string { return f.Card.NameOnCard },
+				Set: func(f *Folder, v string) { f.Card.NameOnCard = v },
+			},
+			{
+				Name: "expire_date", Label: "Expire", Kind: FieldKinds.Text, Required: true, Validate: validateExpireDate,
+				Get: func(f *Folder) string { return f.Card.ExpireDate },
+				Set: func(f *Folder, v string) { f.Card.ExpireDate = v },
+			},
+			{
+				Name: "cvv", Label: "CVV", Kind: FieldKinds.Text, Secret: true, Hidden: true, Required: true,
+				Validate: validateDigits(3, 4),
+				Get:      func(f *Folder) string { return f.Card.CVV },
+				Set:      func(f *Folder, v string) { f.Card.CVV = v },
+			},
+		},
+	})
+	RegisterSchema(Schema{
+		Type:  FolderData.Text,
+		Name:  "text",
+		Title: "Text Notes",
+		Fields: []Field{
+			{
+				Name: "text", Label: "Content", Kind: FieldKinds.MultiLine, Secret: true, Required: true,
+				Get: func(f *Folder) string { return f.Text.Text },
+				Set: func(f *Folder, v string) { f.Text.Text = v },
+			},
+		},
+	})
+	RegisterSchema(Schema{
+		Type:    FolderData.Binary,
+		Name:    "binary",
+		Title:   "Binary Files",
+		Content: true,
+		Fields: []Field{
+			{
+				Name: "name", Label: "File name", Kind: FieldKinds.Text, Required: true,
+				Get: func(f *Folder) string { return f.Binary.Name },
+				Set: func(f *Folder, v string) { f.Binary.Name = v },
+			},
+			{
+				Name: "size", Label: "Size", Kind: FieldKinds.Text, ReadOnly: true,
+				Get: func(f *Folder) string {
+					if f.Binary.IsStreamed() {
+						return strconv.FormatInt(f.Binary.Size, 10)
+					}
+					return strconv.Itoa(len(f.Binary.Data))
+				},
+			},
+		},
+	})
+	// authenticator has no typed struct in Folder, its fields are kept in Folder.Fields
+	RegisterSchema(Schema{
+		Type:  FolderData.Authenticator,
+		Name:  "otp",
+		Title: "Authenticators",
+		Fields: []Field{
+			{
+				Name: "uri", Label: "otpauth URI or seed", Kind: FieldKinds.OTP,
+				Secret: true, Hidden: true, Required: true, Validate: validateOTP,
+			},
+			{
+				Name: "issuer", Label: "Issuer", Kind: FieldKinds.Text, ReadOnly: true,
+				Get: func(f *Folder) string {
+					o, err := ParseOTP(f.Fields["uri"])
+					if err != nil {
+						return ""
+					}
+					return o.Issuer
+				},
+			},
+		},
+	})
+	RegisterSchema(Schema{
+		Type:  FolderData.SSHKey,
+		Name:  "ssh",
+		Title: "SSH Keys",
+		Fields: []Field{
+			{
+				Name: "private_key", Label: "Private key", Kind: FieldKinds.MultiLine, Secret: true, Required: true,
+				Get: func(f *Folder) string { return f.SSHKey.PrivateKey },
+				Set: func(f *Folder, v string) { f.SSHKey.PrivateKey = v },
+			},
+			{
+				Name: "key_passphrase", Label: "Passphrase of private key", Kind: FieldKinds.Text, Secret: true, Hidden: true,
+				Get: func(f *Folder) string { return f.SSHKey.Passphrase },
+				Set: func(f *Folder, v string) { f.SSHKey.Passphrase = v },
+			},
+			{
+				Name: "comment", Label: "Comment", Kind: FieldKinds.Text,
+				Get: func(f *Folder) string { return f.SSHKey.Comment },
+				Set: func(f *Folder, v string) { f.SSHKey.Comment = v },
+			},
+			{
+				Name: "confirm", Label: "Confirm every use", Kind: FieldKinds.Bool,
+				Get: func(f *Folder) string { return strconv.FormatBool(f.SSHKey.Confirm) },
+				Set: func(f *Folder, v string) { f.SSHKey.Confirm, _ = strconv.ParseBool(v) },
+			},
+			{
+				Name: "public_key", Label: "Public key", Kind: FieldKinds.MultiLine, ReadOnly: true,
+				Get: func(f *Folder) string { return f.SSHKey.PublicKey },
+			},
+			{
+				Name: "key_type", Label: "Key type", Kind: FieldKinds.Text, ReadOnly: true,
+				Get: func(f *Folder) string {
+					publicKey, err := f.SSHKey.PublicKeyOf()
+					if err != nil {
+						return ""
+					}
+					return publicKey.Type()
+				},
+			},
+			{
+				Name: "fingerprint", Label: "Fingerprint", Kind: FieldKinds.Text, ReadOnly: true,
+				Get: func(f *Folder) string {
+					// fingerprint of invalid key is empty, the key is still shown to be fixed
+					fingerprint, _ := f.SSHKey.Fingerprint()
+					return fingerprint
+				},
+			},
+		},
+		// public key is kept with private key, so ssh agent lists keys without parsing them
+		Complete: func(f *Folder) error {
+			if err := f.SSHKey.FillPublicKey(); err != nil {
+				return fmt.Errorf("invalid ssh key: %w", err)
+			}
+			return nil
+		},
+	})
+	// the next types have no typed structs in Folder, their fields are kept in Folder.Fields
+	RegisterSchema(Schema{
+		Type:  FolderData.APIToken,
+		Name:  "token",
+		Title: "API Tokens",
+		Fields: []Field{
+			{Name: "url", Label: "URL", Kind: FieldKinds.Text, Validate: validateURL},
+			{Name: "token", Label: "Token", Kind: FieldKinds.Text, Secret: true, Hidden: true, Required: true},
+			{Name: "scopes", Label: "Scopes", Kind: FieldKinds.Text},
+			{Name: "expires", Label: "Expires", Kind: FieldKinds.Date},
+			{
+				Name: "expired", Label: "Expired", Kind: FieldKinds.Bool, ReadOnly: true,
+				Get: func(f *Folder) string { return strconv.FormatBool(expired(f.Fields["expires"])) },
+			},
+		},
+	})
+	RegisterSchema(Schema{
+		Type:  FolderData.Identity,
+		Name:  "identity",
+		Title: "Identity Documents",
+		Fields: []Field{
+			{Name: "full_name", Label: "Full name", Kind: FieldKinds.Text, Required: true},
+			{Name: "document_type", Label: "Document (passport, id card, driver license...)", Kind: FieldKinds.Text, Required: true},
+			{Name: "number", Label: "Number", Kind: FieldKinds.Text, Secret: true, Required: true},
+			{Name: "country", Label: "Country (ISO 3166 code)", Kind: FieldKinds.Text, Validate: validateCountry},
+			{Name: "issued_by", Label: "Issued by", Kind: FieldKinds.Text},
+			{Name: "birth_date", Label: "Birth date", Kind: FieldKinds.Date},
+			{Name: "issue_date", Label: "Issue date", Kind: FieldKinds.Date},
+			{Name: "expire_date", Label: "Expire date", Kind: FieldKinds.Date},
+			{
+				Name: "expired", Label: "Expired", Kind: FieldKinds.Bool, ReadOnly: true,
+				Get: func(f *Folder) string { return strconv.FormatBool(expired(f.Fields["expire_date"])) },
+			},
+		},
+		Complete: func(f *Folder) error {
+			if country := f.Fields["country"]; country != "" {
+				f.Fields["country"] = strings.ToUpper(country)
+			}
+			// dates are already validated, layout of dates is sorted as strings
+			issued, expires := f.Fields["issue_date"], f.Fields["expire_date"]
+			if issued != "" && expires != "" && expires < issued {
+				return fmt.Errorf("%w: expire_date is before issue_date", ErrInvalidField)
+			}
+			return nil
+		},
+	})
+	RegisterSchema(Schema{
+		Type:  FolderData.BankAccount,
+		Name:  "bank",
+		Title: "Bank Accounts",
+		Fields: []Field{
+			{Name: "holder", Label: "Holder", Kind: FieldKinds.Text, Required: true},
+			{Name: "bank_name", Label: "Bank", Kind: FieldKinds.Text},
+			{Name: "iban", Label: "IBAN", Kind: FieldKinds.Text, Validate: validateIBAN},
+			{Name: "swift", Label: "SWIFT/BIC", Kind: FieldKinds.Text, Validate: validateSWIFT},
+			{Name: "account_number", Label: "Account number", Kind: FieldKinds.Text},
+			{Name: "routing_number", Label: "Routing number", Kind: FieldKinds.Text},
+			{Name: "pin", Label: "PIN", Kind: FieldKinds.Text, Secret: true, Hidden: true, Validate: validateDigits(4, 12)},
+		},
+		// accounts without IBAN have account number, IBAN and SWIFT are kept without spaces in upper case
+		Complete: func(f *Folder) error {
+			if f.Fields["iban"] == "" && f.Fields["account_number"] == "" {
+				return fmt.Errorf("%w: iban or account_number is required", ErrInvalidField)
+			}
+			for _, name := range []string{"iban", "swift"} {
+				if value := f.Fields[name]; value != "" {
+					f.Fields[name] = normalizeCode(value)
+				}
+			}
+			return nil
+		},
+	})
+	RegisterSchema(Schema{
+		Type:  FolderData.WiFi,
+		Name:  "wifi",
+		Title: "Wi-Fi Networks",
+		Fields: []Field{
+			{Name: "ssid", Label: "Network name (SSID)", Kind: FieldKinds.Text, Required: true, Validate: validateSSID},
+			{Name: "security", Label: "Security (WPA3, WPA2, WPA, WEP or none)", Kind: FieldKinds.Text, Validate: validateSecurity},
+			{Name: "password", Label: "Password", Kind: FieldKinds.Text, Secret: true, Hidden: true},
+			{Name: "hidden", Label: "Hidden network", Kind: FieldKinds.Bool},
+			{
+				// wifi_uri - network in format of QR codes, phones join network by it
+				Name: "wifi_uri", Label: "Wi-Fi QR code content", Kind: FieldKinds.Text, Secret: true, ReadOnly: true,
+				Get: wifiURI,
+			},
+		},
+		Complete: func(f *Folder) error {
+			security := strings.ToUpper(f.Fields["security"])
+			switch security {
+			case "":
+				security = "WPA2"
+			case "NONE":
+				security = "none"
+			}
+			f.Fields["security"] = security
+			return validateWiFiPassword(security, f.Fields["password"])
+		},
+	})
+}
+
+// validateURL checks that url has host, protocol may be omitted
+func validateURL(value string) error {
+	if strings.ContainsAny(value, " \t\n") {
+		return errors.New("must not contain spaces")
+	}
+	host := value
+	if _, rest, ok := strings.Cut(value, "://"); ok {
+		host = rest
+	}
+	if host == "" || strings.HasPrefix(host, "/") {
+		return errors.New("must have host")
+	}
+	return nil
+}
+
+// validateCardNumber checks that card number has 12 to 19 digits, spaces and dashes between them are allowed
+func validateCardNumber(value string) error {
+	digits := strings.NewReplacer(" ", "", "-", "").Replace(value)
+	return validateDigits(12, 19)(digits)
+}
+
+// validateExpireDate checks format of expire date
+func validateExpireDate(value string) error {
+	if !expireDate.MatchString(value) {
+		return errors.New("must be MM/YY or MM/YYYY")
+	}
+	return nil
+}
+
+// expired - date is before today, empty date never expires
+func expired(date string) bool {
+	return date != "" && date < time.Now().Format(DateLayout)
+}
+
+// validateCountry checks that country is ISO 3166-1 alpha-2 code
+func validateCountry(value string) error {
+	if !countryCode.MatchString(strings.ToUpper(value)) {
+		return errors.New("must be two letter code, for example DE")
+	}
+	return nil
+}
+
+// normalizeCode removes spaces of IBAN or SWIFT code and converts it to upper case
+func normalizeCode(value string) string {
+	return strings.ToUpper(strings.ReplaceAll(value, " ", ""))
+}
+
+// validateIBAN checks country, length and check digits of IBAN (ISO 13616)
+func validateIBAN(value string) error {
+	iban := normalizeCode(value)
+	if !ibanFormat.MatchString(iban) {
+		return errors.New("must be country code, check digits and up to 30 letters or digits")
+	}
+	// check digits are correct if number of IBAN with the first four characters moved to the end is 1 mod 97,
+	// letters are numbers from 10 to 35
+	rearranged := iban[4:] + iban[:4]
+	remainder := 0
+	for _, r := range rearranged {
+		digit := int(r - '0')
+		if r >= 'A' {
+			digit = int(r-'A') + 10
+			remainder = remainder * 10 % 97
+		}
+		remainder = (remainder*10 + digit) % 97
+	}
+	if remainder != 1 {
+		return errors.New("has wrong check digits")
+	}
+	return nil
+}
+
+// validateSWIFT checks format of SWIFT (BIC) code, bank, country, location and optional branch
+func validateSWIFT(value string) error {
+	if !swiftFormat.MatchString(normalizeCode(value)) {
+		return errors.New("must have 8 or 11 characters: bank, country, location and branch")
+	}
+	return nil
+}
+
+// validateSSID checks length of network name
+func validateSSID(value string) error {
+	if len(value) > 32 {
+		return errors.New("must be at most 32 bytes")
+	}
+	return nil
+}
+
+// validateSecurity checks security of Wi-Fi network
+func validateSecurity(value string) error {
+	switch strings.ToUpper(value) {
+	case "WPA3", "WPA2", "WPA", "WEP", "NONE":
+		return nil
+	}
+	return errors.New("must be WPA3, WPA2, WPA, WEP or none")
+}
+
+// validateWiFiPassword checks password of Wi-Fi network by its security
+func validateWiFiPassword(security, password string) error {
+	switch security {
+	case "none":
+		if password != "" {
+			return fmt.Errorf("%w: password of open network must be empty", ErrInvalidField)
+		}
+	case "WEP":
+		// WEP keys are 5 or 13 characters or 10 or 26 hex digits
+		hex := strings.Trim(strings.ToLower(password), "0123456789abcdef") == ""
+		if len(password) != 5 && len(password) != 13 && !(hex && (len(password) == 10 || len(password) == 26)) {
+			return fmt.Errorf("%w: password of WEP must have 5 or 13 characters or 10 or 26 hex digits", ErrInvalidField)
+		}
+	default:
+		if len(password) < 8 || len(password) > 63 {
+			return fmt.Errorf("%w: password of %s must have 8 to 63 characters", ErrInvalidField, security)
+		}
+	}
+	return nil
+}
+
+// wifiURI - content of QR code of Wi-Fi network, WIFI:T:WPA;S:ssid;P:password;H:true;;
+func wifiURI(f *Folder) string {
+	if f.Fields["ssid"] == "" {
+		return ""
+	}
+	escape := strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, `"`, `\"`, ":", `\:`).Replace
+	security := "WPA"
+	switch f.Fields["security"] {
+	case "WEP":
+		security = "WEP"
+	case "none":
+		security = "nopass"
+	}
+	uri := "WIFI:T:" + security + ";S:" + escape(f.Fields["ssid"]) + ";"
+	if security != "nopass" {
+		uri += "P:" + escape(f.Fields["password"]) + ";"
+	}
+	if hidden, _ := strconv.ParseBool(f.Fields["hidden"]); hidden {
+		uri += "H:true;"
+	}
+	return uri + ";"
+}
+
+// validateDigits returns validation of value with min to max digits
+func validateDigits(minDigits, maxDigits int) func(value string) error {
+	return func(value string) error {
+		if len(value) < minDigits || len(value) > maxDigits || strings.Trim(value, "0123456789") != "" {
+			return fmt.Errorf("must have %d to %d digits", minDigits, maxDigits)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,17 @@
+// Package: models
+// in this file we have sessions of user, every logged in device has its own session on server
+package models
+
+// Session - device logged in as user, its tokens are valid until session is revoked or expires
+// json tags are used by unlock agent
+type Session struct {
+	ID         string `json:"id"`
+	DeviceName string `json:"device_name"`
+	// CertFingerprint - SHA-256 of TLS client certificate of device
+	CertFingerprint string `json:"cert_fingerprint"`
+	IP              string `json:"ip"`
+	Created         int64  `json:"created"`
+	LastSeen        int64  `json:"last_seen"`
+	// Current - session of this client
+	Current bool `json:"current"`
+}
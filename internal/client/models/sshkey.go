@@ -0,0 +1,72 @@
+// Package: models
+// in this file we have ssh key item
+package models
+
+import (
+	"errors"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// SSHKey - ssh key struct
+// private key is in PEM or OpenSSH format, passphrase is set if private key is encrypted
+// public key is in authorized_keys format, so it can be listed without parsing of private key
+type SSHKey struct {
+	PrivateKey string `json:"private_key"`
+	Passphrase string `json:"passphrase,omitempty"`
+	PublicKey  string `json:"public_key"`
+	Comment    string `json:"comment"`
+	// Confirm - every use of key must be confirmed by user
+	Confirm bool `json:"confirm"`
+}
+
+// Signer parses private key
+func (k *SSHKey) Signer() (ssh.Signer, error) {
+	if k.PrivateKey == "" {
+		return nil, errors.New("ssh key has no private key")
+	}
+	if k.Passphrase != "" {
+		return ssh.ParsePrivateKeyWithPassphrase([]byte(k.PrivateKey), []byte(k.Passphrase))
+	}
+	return ssh.ParsePrivateKey([]byte(k.PrivateKey))
+}
+
+// PublicKeyOf returns public key, it is derived from private key if it is not set
+func (k *SSHKey) PublicKeyOf() (ssh.PublicKey, error) {
+	if k.PublicKey != "" {
+		key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(k.PublicKey))
+		return key, err
+	}
+	signer, err := k.Signer()
+	if err != nil {
+		return nil, err
+	}
+	return signer.PublicKey(), nil
+}
+
+// FillPublicKey checks private key and sets public key of it
+// comment of public key in authorized_keys format is used if comment is empty
+func (k *SSHKey) FillPublicKey() error {
+	signer, err := k.Signer()
+	if err != nil {
+		return err
+	}
+	if k.Comment == "" && k.PublicKey != "" {
+		_, comment, _, _, err := ssh.ParseAuthorizedKey([]byte(k.PublicKey))
+		if err == nil {
+			k.Comment = comment
+		}
+	}
+	k.PublicKey = strings.TrimSpace(string(ssh.MarshalAuthorizedKey(signer.PublicKey())))
+	return nil
+}
+
+// Fingerprint returns SHA256 fingerprint of public key as it is shown by ssh-keygen -l
+func (k *SSHKey) Fingerprint() (string, error) {
+	key, err := k.PublicKeyOf()
+	if err != nil {
+		return "", err
+	}
+	return ssh.FingerprintSHA256(key), nil
+}
@@ -0,0 +1,118 @@
+// Package: models
+// in this file we have chunked encryption of streamed binary content
+package models
+
+import (
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+)
+
+// StreamChunkSize - size of plain chunk of streamed binary content
+const StreamChunkSize = 64 * 1024
+
+// ErrStreamTruncated - stream of chunks has ended before the last chunk
+var ErrStreamTruncated = errors.New("binary content is truncated")
+
+// stream nonce layout: zeros (7 bytes) | chunk counter (4) | last chunk flag (1)
+// every upload has its own random key, so the counter never repeats for a key
+const (
+	streamCounterOffset = 7
+	streamLastOffset    = 11
+	maxStreamChunks     = 1<<32 - 1
+)
+
+// StreamSealer - encrypts binary content chunk by chunk, STREAM construction:
+// chunk number and the last chunk flag are in the nonce, so chunks can't be reordered, dropped or appended
+type StreamSealer struct {
+	aead    cipher.AEAD
+	ad      []byte
+	counter uint64
+	done    bool
+}
+
+// StreamOpener - decrypts binary content encrypted with StreamSealer
+type StreamOpener struct {
+	aead    cipher.AEAD
+	ad      []byte
+	counter uint64
+	done    bool
+}
+
+// NewStreamSealer - generate new stream key for binary content of data and create sealer with it
+// data should be encrypted after it, so the key is saved with data
+func (d *Data) NewStreamSealer(keyring *Keyring) (*StreamSealer, error) {
+	key, err := NewMasterKey()
+	if err != nil {
+		return nil, err
+	}
+	d.Folder.Binary.StreamKey = key
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	return &StreamSealer{aead: aead, ad: associatedData(keyring.user, d.UUID, string(d.DataType))}, nil
+}
+
+// NewStreamOpener - create opener of binary content of data with its stream key
+func (d *Data) NewStreamOpener(keyring *Keyring) (*StreamOpener, error) {
+	if !d.Folder.Binary.IsStreamed() {
+		return nil, errors.New("binary content is not streamed")
+	}
+	aead, err := newGCM(d.Folder.Binary.StreamKey)
+	if err != nil {
+		return nil, err
+	}
+	return &StreamOpener{aead: aead, ad: associatedData(keyring.user, d.UUID, string(d.DataType))}, nil
+}
+
+// streamNonce - nonce of chunk with the given number
+func streamNonce(size int, counter uint64, last bool) []byte {
+	nonce := make([]byte, size)
+	binary.BigEndian.PutUint32(nonce[streamCounterOffset:], uint32(counter))
+	if last {
+		nonce[streamLastOffset] = 1
+	}
+	return nonce
+}
+
+// Seal - encrypt the next chunk, last should be set for the last chunk only
+func (s *StreamSealer) Seal(chunk []byte, last bool) ([]byte, error) {
+	if s.done {
+		return nil, errors.New("stream is already finished")
+	}
+	if s.counter > maxStreamChunks {
+		return nil, errors.New("binary content is too large")
+	}
+	sealed := s.aead.Seal(nil, streamNonce(s.aead.NonceSize(), s.counter, last), chunk, s.ad)
+	s.counter++
+	s.done = last
+	return sealed, nil
+}
+
+// Open - decrypt the next chunk and report whether it is the last one
+func (o *StreamOpener) Open(sealed []byte) ([]byte, bool, error) {
+	if o.done {
+		return nil, false, errors.New("data after the last chunk")
+	}
+	if o.counter > maxStreamChunks {
+		return nil, false, errors.New("binary content is too large")
+	}
+	for _, last := range []bool{false, true} {
+		chunk, err := o.aead.Open(nil, streamNonce(o.aead.NonceSize(), o.counter, last), sealed, o.ad)
+		if err == nil {
+			o.counter++
+			o.done = last
+			return chunk, last, nil
+		}
+	}
+	return nil, false, ErrContextMismatch
+}
+
+// Finish - check that the last chunk was opened
+func (o *StreamOpener) Finish() error {
+	if !o.done {
+		return ErrStreamTruncated
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sealChunks - seal chunks of binary data, lastAt is index of chunk sealed as the last one
+func sealChunks(t *testing.T, data *Data, keyring *Keyring, chunks []string, lastAt int) [][]byte {
+	sealer, err := data.NewStreamSealer(keyring)
+	require.NoError(t, err)
+	sealed := make([][]byte, 0, len(chunks))
+	for i, chunk := range chunks {
+		s, err := sealer.Seal([]byte(chunk), i == lastAt)
+		require.NoError(t, err)
+		sealed = append(sealed, s)
+		if i == lastAt {
+			break
+		}
+	}
+	return sealed
+}
+
+// openChunks - open sealed chunks and finish the stream, error of the first failed step is returned
+func openChunks(data *Data, keyring *Keyring, sealed [][]byte) (string, error) {
+	opener, err := data.NewStreamOpener(keyring)
+	if err != nil {
+		return "", err
+	}
+	var content string
+	for _, s := range sealed {
+		chunk, _, err := opener.Open(s)
+		if err != nil {
+			return content, err
+		}
+		content += string(chunk)
+	}
+	return content, opener.Finish()
+}
+
+func TestStream(t *testing.T) {
+	keyring := testKeyring(t)
+	chunks := []string{"first ", "second ", "third"}
+
+	tests := []struct {
+		name        string
+		sealed      func(data *Data) [][]byte
+		openAs      Data
+		wantContent string
+		wantErr     error
+		wantErrMsg  string
+	}{
+		{
+			name: "Round trip",
+			sealed: func(data *Data) [][]byte {
+				return sealChunks(t, data, keyring, chunks, len(chunks)-1)
+			},
+			wantContent: "first second third",
+		},
+		{
+			name: "Truncated, final chunk is missing",
+			sealed: func(data *Data) [][]byte {
+				sealed := sealChunks(t, data, keyring, chunks, len(chunks)-1)
+				return sealed[:len(sealed)-1]
+			},
+			wantContent: "first second ",
+			wantErr:     ErrStreamTruncated,
+		},
+		{
+			name: "Reordered chunks",
+			sealed: func(data *Data) [][]byte {
+				sealed := sealChunks(t, data, keyring, chunks, len(chunks)-1)
+				sealed[0], sealed[1] = sealed[1], sealed[0]
+				return sealed
+			},
+			wantErr: ErrContextMismatch,
+		},
+		{
+			name: "Dropped chunk in the middle",
+			sealed: func(data *Data) [][]byte {
+				sealed := sealChunks(t, data, keyring, chunks, len(chunks)-1)
+				return [][]byte{sealed[0], sealed[2]}
+			},
+			wantContent: "first ",
+			wantErr:     ErrContextMismatch,
+		},
+		{
+			// content after chunk sealed as the last one is not accepted
+			name: "Last flag set early",
+			sealed: func(data *Data) [][]byte {
+				early := sealChunks(t, data, keyring, chunks, 0)
+				key := data.Folder.Binary.StreamKey
+				rest := sealChunks(t, data, keyring, chunks, len(chunks)-1)
+				data.Folder.Binary.StreamKey = key
+				return append(early, rest[1:]...)
+			},
+			wantContent: "first ",
+			wantErrMsg:  "data after the last chunk",
+		},
+		{
+			name: "Chunk of another item",
+			sealed: func(data *Data) [][]byte {
+				return sealChunks(t, data, keyring, chunks, len(chunks)-1)
+			},
+			openAs:  Data{UUID: "uuid2", DataType: "bn"},
+			wantErr: ErrContextMismatch,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := &Data{UUID: "uuid1", DataType: "bn"}
+			sealed := tt.sealed(data)
+			openAs := *data
+			if tt.openAs.UUID != "" {
+				openAs.UUID = tt.openAs.UUID
+			}
+			content, err := openChunks(&openAs, keyring, sealed)
+			switch {
+			case tt.wantErr != nil:
+				assert.ErrorIs(t, err, tt.wantErr)
+			case tt.wantErrMsg != "":
+				assert.EqualError(t, err, tt.wantErrMsg)
+			default:
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.wantContent, content)
+		})
+	}
+}
+
+func TestStreamSealer_SealAfterLast(t *testing.T) {
+	data := &Data{UUID: "uuid1", DataType: "bn"}
+	sealer, err := data.NewStreamSealer(testKeyring(t))
+	require.NoError(t, err)
+	_, err = sealer.Seal([]byte("content"), true)
+	require.NoError(t, err)
+	_, err = sealer.Seal([]byte("more"), false)
+	assert.Error(t, err)
+}
@@ -0,0 +1,255 @@
+// Package: sshagent
+// in this file we have ssh agent, it serves ssh keys of vault to ssh clients over SSH_AUTH_SOCK
+package sshagent
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+
+	"go.uber.org/zap"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+
+	unlockagent "github.com/h2p2f/dedicated-vault/internal/client/agent"
+	"github.com/h2p2f/dedicated-vault/internal/client/clienterrors"
+	"github.com/h2p2f/dedicated-vault/internal/client/models"
+)
+
+// ErrReadOnly - keys are managed in vault, they can't be added or removed by ssh-add
+var ErrReadOnly = errors.New("keys of ssh agent are stored in vault, manage them with vault client")
+
+// ErrKeyNotFound - vault has no ssh key for requested public key or vault is locked
+var ErrKeyNotFound = errors.New("ssh key not found")
+
+// ErrNotConfirmed - use of key is not confirmed by user
+var ErrNotConfirmed = errors.New("use of ssh key is not confirmed")
+
+// Keys is an interface of vault with ssh keys
+//
+//go:generate mockery --name Keys --output ./mocks --filename mocks_keys.go
+type Keys interface {
+	GetDataByType(dataType string) ([]models.Data, error)
+}
+
+// Agent implements ssh agent protocol with extensions
+var _ agent.ExtendedAgent = (*Agent)(nil)
+
+// Confirmer asks user to confirm use of key, prompt describes the key
+type Confirmer func(prompt string) (bool, error)
+
+// Agent is ssh agent serving ssh keys of vault
+// keys are read from vault on every request, so they are listed and used only while vault is unlocked,
+// private key is parsed only for signing and isn't kept between requests
+type Agent struct {
+	keys    Keys
+	confirm Confirmer
+	// confirmAll - every use of every key must be confirmed, not only of keys with Confirm
+	confirmAll bool
+	logger     *zap.Logger
+	// mu serializes requests to vault, local client logic is not safe for concurrent use
+	mu sync.Mutex
+}
+
+// NewAgent creates a new Agent
+// confirm may be nil, then keys which need confirmation can't be used
+func NewAgent(keys Keys, confirm Confirmer, confirmAll bool, logger *zap.Logger) *Agent {
+	return &Agent{
+		keys:       keys,
+		confirm:    confirm,
+		confirmAll: confirmAll,
+		logger:     logger,
+	}
+}
+
+// Serve serves ssh clients on socket until ctx is done
+func (a *Agent) Serve(ctx context.Context, socket string) error {
+	listener, err := unlockagent.Listen(socket)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(socket)
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+	}()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		go a.serveConn(conn)
+	}
+}
+
+// serveConn serves one ssh client
+func (a *Agent) serveConn(conn net.Conn) {
+	defer conn.Close()
+	err := agent.ServeAgent(a, conn)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+		a.logger.Debug("ssh client disconnected", zap.Error(err))
+	}
+}
+
+// vaultKey - ssh key of vault with its public key
+type vaultKey struct {
+	meta   string
+	key    models.SSHKey
+	public ssh.PublicKey
+}
+
+// vaultKeys returns ssh keys of vault, nothing is returned while vault is locked
+func (a *Agent) vaultKeys() []vaultKey {
+	data, err := a.keys.GetDataByType(string(models.FolderData.SSHKey))
+	if errors.Is(err, clienterrors.Undecryptable) {
+		a.logger.Warn("some ssh keys are skipped", zap.Error(err))
+		err = nil
+	}
+	if err != nil {
+		a.logger.Debug("ssh keys are not available", zap.Error(err))
+		return nil
+	}
+	keys := make([]vaultKey, 0, len(data))
+	for _, d := range data {
+		public, err := d.Folder.SSHKey.PublicKeyOf()
+		if err != nil {
+			a.logger.Warn("invalid ssh key", zap.String("uuid", d.UUID), zap.Error(err))
+			continue
+		}
+		keys = append(keys, vaultKey{meta: d.Meta, key: d.Folder.SSHKey, public: public})
+	}
+	return keys
+}
+
+// List returns public keys of vault
+func (a *Agent) List() ([]*agent.Key, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	keys := a.vaultKeys()
+	list := make([]*agent.Key, 0, len(keys))
+	for _, k := range keys {
+		comment := k.key.Comment
+		if comment == "" {
+			comment = k.meta
+		}
+		list = append(list, &agent.Key{
+			Format:  k.public.Type(),
+			Blob:    k.public.Marshal(),
+			Comment: comment,
+		})
+	}
+	return list, nil
+}
+
+// Sign signs data with private key of vault
+func (a *Agent) Sign(key ssh.PublicKey, data []byte) (*ssh.Signature, error) {
+	return a.SignWithFlags(key, data, 0)
+}
+
+// SignWithFlags signs data with private key of vault, flags select hash of RSA signature
+func (a *Agent) SignWithFlags(key ssh.PublicKey, data []byte, flags agent.SignatureFlags) (*ssh.Signature, error) {
+	a.mu.Lock()
+	k, err := a.find(key)
+	a.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	if a.confirmAll || k.key.Confirm {
+		err = a.confirmUse(k)
+		if err != nil {
+			return nil, err
+		}
+	}
+	signer, err := k.key.Signer()
+	if err != nil {
+		return nil, err
+	}
+	a.logger.Info("ssh key is used", zap.String("key", k.meta), zap.String("fingerprint", ssh.FingerprintSHA256(k.public)))
+
+	var algorithm string
+	switch {
+	case flags&agent.SignatureFlagRsaSha256 != 0:
+		algorithm = ssh.KeyAlgoRSASHA256
+	case flags&agent.SignatureFlagRsaSha512 != 0:
+		algorithm = ssh.KeyAlgoRSASHA512
+	default:
+		return signer.Sign(rand.Reader, data)
+	}
+	algorithmSigner, ok := signer.(ssh.AlgorithmSigner)
+	if !ok || signer.PublicKey().Type() != ssh.KeyAlgoRSA {
+		return nil, fmt.Errorf("signature algorithm %s is not supported by key %s", algorithm, k.meta)
+	}
+	return algorithmSigner.SignWithAlgorithm(rand.Reader, data, algorithm)
+}
+
+// find finds ssh key of vault by public key
+func (a *Agent) find(key ssh.PublicKey) (vaultKey, error) {
+	blob := key.Marshal()
+	for _, k := range a.vaultKeys() {
+		if string(k.public.Marshal()) == string(blob) {
+			return k, nil
+		}
+	}
+	return vaultKey{}, ErrKeyNotFound
+}
+
+// confirmUse asks user to confirm use of key
+func (a *Agent) confirmUse(k vaultKey) error {
+	if a.confirm == nil {
+		return fmt.Errorf("%w: confirmation is not available", ErrNotConfirmed)
+	}
+	prompt := fmt.Sprintf("Allow use of ssh key %q (%s)?", k.meta, ssh.FingerprintSHA256(k.public))
+	ok, err := a.confirm(prompt)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrNotConfirmed, err)
+	}
+	if !ok {
+		a.logger.Info("use of ssh key is declined", zap.String("key", k.meta))
+		return ErrNotConfirmed
+	}
+	return nil
+}
+
+// Signers isn't used by agent protocol, keys are not given out of agent
+func (a *Agent) Signers() ([]ssh.Signer, error) {
+	return nil, ErrReadOnly
+}
+
+// Add isn't supported, keys are added to vault
+func (a *Agent) Add(key agent.AddedKey) error {
+	return ErrReadOnly
+}
+
+// Remove isn't supported, keys are removed from vault
+func (a *Agent) Remove(key ssh.PublicKey) error {
+	return ErrReadOnly
+}
+
+// RemoveAll isn't supported, keys are removed from vault
+func (a *Agent) RemoveAll() error {
+	return ErrReadOnly
+}
+
+// Lock isn't supported, keys are not available while vault is locked
+func (a *Agent) Lock(passphrase []byte) error {
+	return errors.New("lock vault to lock ssh agent")
+}
+
+// Unlock isn't supported, keys are available after vault is unlocked
+func (a *Agent) Unlock(passphrase []byte) error {
+	return errors.New("unlock vault to unlock ssh agent")
+}
+
+// Extension isn't supported
+func (a *Agent) Extension(extensionType string, contents []byte) ([]byte, error) {
+	return nil, agent.ErrExtensionUnsupported
+}
@@ -0,0 +1,191 @@
+package sshagent
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+
+	"github.com/h2p2f/dedicated-vault/internal/client/models"
+	"github.com/h2p2f/dedicated-vault/internal/client/sshagent/mocks"
+)
+
+// pemKey encodes private key in PKCS #8 PEM format
+func pemKey(t *testing.T, private any) string {
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	assert.NoError(t, err)
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+}
+
+// testKey creates ssh key item
+func testKey(t *testing.T, meta string, confirm bool) models.Data {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	key := models.SSHKey{PrivateKey: pemKey(t, private), Comment: meta + "@host", Confirm: confirm}
+	assert.NoError(t, key.FillPublicKey())
+	return models.Data{UUID: meta, Meta: meta, DataType: models.FolderData.SSHKey, Folder: models.Folder{SSHKey: key}}
+}
+
+// startAgent starts ssh agent and returns its client
+func startAgent(t *testing.T, keys Keys, confirm Confirmer, confirmAll bool) agent.ExtendedAgent {
+	socket := filepath.Join(t.TempDir(), "ssh", "ssh-agent.sock")
+	ctx, cancel := context.WithCancel(context.Background())
+	a := NewAgent(keys, confirm, confirmAll, zap.NewNop())
+	done := make(chan error)
+	go func() {
+		done <- a.Serve(ctx, socket)
+	}()
+	var conn net.Conn
+	assert.Eventually(t, func() bool {
+		var err error
+		conn, err = net.Dial("unix", socket)
+		return err == nil
+	}, time.Second, 10*time.Millisecond)
+	t.Cleanup(func() {
+		conn.Close()
+		cancel()
+		assert.NoError(t, <-done)
+	})
+	return agent.NewClient(conn)
+}
+
+func TestAgent_ListAndSign(t *testing.T) {
+	deploy := testKey(t, "deploy", false)
+	keys := mocks.NewKeys(t)
+	keys.On("GetDataByType", "sk").Return([]models.Data{deploy}, nil)
+	client := startAgent(t, keys, nil, false)
+	public, err := deploy.Folder.SSHKey.PublicKeyOf()
+	assert.NoError(t, err)
+
+	list, err := client.List()
+	assert.NoError(t, err)
+	if assert.Len(t, list, 1) {
+		assert.Equal(t, "deploy@host", list[0].Comment)
+		assert.Equal(t, public.Marshal(), list[0].Blob)
+	}
+
+	signature, err := client.Sign(public, []byte("challenge"))
+	assert.NoError(t, err)
+	assert.NoError(t, public.Verify([]byte("challenge"), signature))
+
+	// keys are managed in vault only
+	assert.Error(t, client.RemoveAll())
+}
+
+func TestAgent_Locked(t *testing.T) {
+	deploy := testKey(t, "deploy", false)
+	keys := mocks.NewKeys(t)
+	keys.On("GetDataByType", "sk").Return(nil, errors.New("user not logged in"))
+	client := startAgent(t, keys, nil, false)
+
+	list, err := client.List()
+	assert.NoError(t, err)
+	assert.Empty(t, list)
+
+	public, err := deploy.Folder.SSHKey.PublicKeyOf()
+	assert.NoError(t, err)
+	_, err = client.Sign(public, []byte("challenge"))
+	assert.Error(t, err)
+}
+
+func TestAgent_Confirm(t *testing.T) {
+	deploy := testKey(t, "deploy", true)
+	other := testKey(t, "other", false)
+	tests := []struct {
+		name       string
+		key        models.Data
+		confirm    Confirmer
+		confirmAll bool
+		wantPrompt bool
+		wantErr    bool
+	}{
+		{
+			name:       "confirmed",
+			key:        deploy,
+			confirm:    func(prompt string) (bool, error) { return true, nil },
+			wantPrompt: true,
+		},
+		{
+			name:       "declined",
+			key:        deploy,
+			confirm:    func(prompt string) (bool, error) { return false, nil },
+			wantPrompt: true,
+			wantErr:    true,
+		},
+		{
+			name:    "no confirmer",
+			key:     deploy,
+			wantErr: true,
+		},
+		{
+			name:    "key without confirmation",
+			key:     other,
+			confirm: func(prompt string) (bool, error) { return false, nil },
+		},
+		{
+			name:       "confirmation of all keys",
+			key:        other,
+			confirm:    func(prompt string) (bool, error) { return false, nil },
+			confirmAll: true,
+			wantPrompt: true,
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys := mocks.NewKeys(t)
+			keys.On("GetDataByType", "sk").Return([]models.Data{deploy, other}, nil)
+			var prompts []string
+			var confirm Confirmer
+			if tt.confirm != nil {
+				confirm = func(prompt string) (bool, error) {
+					prompts = append(prompts, prompt)
+					return tt.confirm(prompt)
+				}
+			}
+			client := startAgent(t, keys, confirm, tt.confirmAll)
+
+			public, err := tt.key.Folder.SSHKey.PublicKeyOf()
+			assert.NoError(t, err)
+			_, err = client.Sign(public, []byte("challenge"))
+			assert.Equal(t, tt.wantErr, err != nil)
+			if tt.wantPrompt {
+				if assert.Len(t, prompts, 1) {
+					assert.Contains(t, prompts[0], tt.key.Meta)
+				}
+			} else {
+				assert.Empty(t, prompts)
+			}
+		})
+	}
+}
+
+func TestAgent_SignRSA(t *testing.T) {
+	private, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	key := models.SSHKey{PrivateKey: pemKey(t, private)}
+	assert.NoError(t, key.FillPublicKey())
+	keys := mocks.NewKeys(t)
+	keys.On("GetDataByType", "sk").
+		Return([]models.Data{{UUID: "rsa", Meta: "rsa", DataType: "sk", Folder: models.Folder{SSHKey: key}}}, nil)
+	client := startAgent(t, keys, nil, false)
+
+	public, err := key.PublicKeyOf()
+	assert.NoError(t, err)
+	signature, err := client.SignWithFlags(public, []byte("challenge"), agent.SignatureFlagRsaSha256)
+	assert.NoError(t, err)
+	assert.Equal(t, ssh.KeyAlgoRSASHA256, signature.Format)
+	assert.NoError(t, public.Verify([]byte("challenge"), signature))
+}
@@ -0,0 +1,77 @@
+// Package: storage
+// in this file we have conflicts waiting for user decision
+package storage
+
+import (
+	"database/sql"
+
+	"go.uber.org/zap"
+
+	"github.com/h2p2f/dedicated-vault/internal/client/models"
+)
+
+// SaveConflict saves conflict or replaces the server copy of already saved one
+func (s *ClientStorage) SaveConflict(user string, conflict models.Conflict) error {
+	id, err := s.GetUserID(user)
+	if err != nil || id == 0 {
+		s.logger.Error("failed to get user id", zap.Error(err))
+		return err
+	}
+	_, err = s.db.Exec(`INSERT INTO conflicts (user_id, uuid, meta, type, data, version, server_deleted, detected) VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (user_id, uuid) DO UPDATE SET meta = excluded.meta, type = excluded.type, data = excluded.data,
+		version = excluded.version, server_deleted = excluded.server_deleted`,
+		id, conflict.UUID, conflict.Server.Meta, conflict.Server.DataType, conflict.Server.EncryptedData, conflict.Server.Version,
+		conflict.ServerDeleted, conflict.Detected)
+	if err != nil {
+		s.logger.Error("failed to save conflict", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// GetConflicts gets all conflicts of user
+func (s *ClientStorage) GetConflicts(user string) ([]models.Conflict, error) {
+	id, err := s.GetUserID(user)
+	if err != nil || id == 0 {
+		s.logger.Error("failed to get user id", zap.Error(err))
+		return nil, err
+	}
+	rows, err := s.db.Query("SELECT uuid, meta, type, data, version, server_deleted, detected FROM conflicts WHERE user_id = ? ORDER BY id", id)
+	if err != nil {
+		s.logger.Error("failed to select conflicts", zap.Error(err))
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			s.logger.Error("failed to close rows", zap.Error(err))
+		}
+	}(rows)
+	var conflicts []models.Conflict
+	for rows.Next() {
+		var c models.Conflict
+		err := rows.Scan(&c.UUID, &c.Server.Meta, &c.Server.DataType, &c.Server.EncryptedData, &c.Server.Version, &c.ServerDeleted, &c.Detected)
+		if err != nil {
+			s.logger.Error("failed to scan conflict", zap.Error(err))
+			return nil, err
+		}
+		c.Server.UUID = c.UUID
+		conflicts = append(conflicts, c)
+	}
+	return conflicts, rows.Err()
+}
+
+// DeleteConflict deletes resolved conflict
+func (s *ClientStorage) DeleteConflict(user string, uuid string) error {
+	id, err := s.GetUserID(user)
+	if err != nil || id == 0 {
+		s.logger.Error("failed to get user id", zap.Error(err))
+		return err
+	}
+	_, err = s.db.Exec("DELETE FROM conflicts WHERE uuid = ? AND user_id = ?", uuid, id)
+	if err != nil {
+		s.logger.Error("failed to delete conflict", zap.Error(err))
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+// Package: storage
+// in this file we have outbox - local changes which are not pushed to server yet
+package storage
+
+import (
+	"database/sql"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/h2p2f/dedicated-vault/internal/client/models"
+)
+
+// AddToOutbox adds a local change to outbox
+func (s *ClientStorage) AddToOutbox(user string, entry models.OutboxEntry) error {
+	id, err := s.GetUserID(user)
+	if err != nil || id == 0 {
+		s.logger.Error("failed to get user id", zap.Error(err))
+		return err
+	}
+	_, err = s.db.Exec("INSERT INTO outbox (user_id, operation, uuid, meta, type, data, version, expected_version, created) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		id, entry.Operation, entry.Data.UUID, entry.Data.Meta, entry.Data.DataType, entry.Data.EncryptedData, entry.Data.Version, entry.ExpectedVersion, time.Now().Unix())
+	if err != nil {
+		s.logger.Error("failed to insert outbox entry", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// GetOutbox gets all local changes of user in order they were made
+func (s *ClientStorage) GetOutbox(user string) ([]models.OutboxEntry, error) {
+	id, err := s.GetUserID(user)
+	if err != nil || id == 0 {
+		s.logger.Error("failed to get user id", zap.Error(err))
+		return nil, err
+	}
+	rows, err := s.db.Query("SELECT id, operation, uuid, meta, type, data, version, expected_version, created, attempts, last_error FROM outbox WHERE user_id = ? ORDER BY id", id)
+	if err != nil {
+		s.logger.Error("failed to select outbox", zap.Error(err))
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			s.logger.Error("failed to close rows", zap.Error(err))
+		}
+	}(rows)
+	var entries []models.OutboxEntry
+	for rows.Next() {
+		var e models.OutboxEntry
+		err := rows.Scan(&e.ID, &e.Operation, &e.Data.UUID, &e.Data.Meta, &e.Data.DataType, &e.Data.EncryptedData,
+			&e.Data.Version, &e.ExpectedVersion, &e.Created, &e.Attempts, &e.LastError)
+		if err != nil {
+			s.logger.Error("failed to scan outbox entry", zap.Error(err))
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// DeleteFromOutbox deletes a change pushed to server
+func (s *ClientStorage) DeleteFromOutbox(user string, entryID int64) error {
+	id, err := s.GetUserID(user)
+	if err != nil || id == 0 {
+		s.logger.Error("failed to get user id", zap.Error(err))
+		return err
+	}
+	_, err = s.db.Exec("DELETE FROM outbox WHERE id = ? AND user_id = ?", entryID, id)
+	if err != nil {
+		s.logger.Error("failed to delete outbox entry", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// DeleteOutboxByUUID deletes all changes of one data
+func (s *ClientStorage) DeleteOutboxByUUID(user string, uuid string) error {
+	id, err := s.GetUserID(user)
+	if err != nil || id == 0 {
+		s.logger.Error("failed to get user id", zap.Error(err))
+		return err
+	}
+	_, err = s.db.Exec("DELETE FROM outbox WHERE uuid = ? AND user_id = ?", uuid, id)
+	if err != nil {
+		s.logger.Error("failed to delete outbox entries", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// UpdateOutboxError saves the error of the last push attempt
+func (s *ClientStorage) UpdateOutboxError(user string, entryID int64, lastError string) error {
+	id, err := s.GetUserID(user)
+	if err != nil || id == 0 {
+		s.logger.Error("failed to get user id", zap.Error(err))
+		return err
+	}
+	_, err = s.db.Exec("UPDATE outbox SET attempts = attempts + 1, last_error = ? WHERE id = ? AND user_id = ?", lastError, entryID, id)
+	if err != nil {
+		s.logger.Error("failed to update outbox entry", zap.Error(err))
+		return err
+	}
+	return nil
+}
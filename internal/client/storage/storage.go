@@ -5,6 +5,7 @@ package storage
 
 import (
 	"database/sql"
+	"errors"
 
 	"github.com/google/uuid"
 	_ "github.com/mattn/go-sqlite3"
@@ -25,16 +26,63 @@ CREATE TABLE IF NOT EXISTS data (
     	meta TEXT NOT NULL,
     	type TEXT NOT NULL,
     	data BLOB NOT NULL,
+    	version INTEGER NOT NULL DEFAULT 0,
     	FOREIGN KEY (user_id) REFERENCES users (id)
 	);
 CREATE TABLE IF NOT EXISTS users (
     	id INTEGER PRIMARY KEY AUTOINCREMENT,
     	username TEXT NOT NULL UNIQUE,
-    	last_updated INTEGER NOT NULL DEFAULT 0
-                                 
+    	last_revision INTEGER NOT NULL DEFAULT 0,
+    	kdf_salt BLOB,
+    	key_check BLOB,
+    	legacy_migrated INTEGER NOT NULL DEFAULT 0,
+    	uses_verifier INTEGER NOT NULL DEFAULT 0
     	);
+CREATE TABLE IF NOT EXISTS outbox (
+    	id INTEGER PRIMARY KEY AUTOINCREMENT,
+    	user_id INTEGER NOT NULL,
+    	operation TEXT NOT NULL,
+    	uuid TEXT NOT NULL,
+    	meta TEXT NOT NULL DEFAULT '',
+    	type TEXT NOT NULL DEFAULT '',
+    	data BLOB,
+    	version INTEGER NOT NULL DEFAULT 0,
+    	expected_version INTEGER NOT NULL DEFAULT 0,
+    	created INTEGER NOT NULL,
+    	attempts INTEGER NOT NULL DEFAULT 0,
+    	last_error TEXT NOT NULL DEFAULT '',
+    	FOREIGN KEY (user_id) REFERENCES users (id)
+	);
+CREATE TABLE IF NOT EXISTS conflicts (
+    	id INTEGER PRIMARY KEY AUTOINCREMENT,
+    	user_id INTEGER NOT NULL,
+    	uuid TEXT NOT NULL,
+    	meta TEXT NOT NULL DEFAULT '',
+    	type TEXT NOT NULL DEFAULT '',
+    	data BLOB,
+    	version INTEGER NOT NULL DEFAULT 0,
+    	server_deleted INTEGER NOT NULL DEFAULT 0,
+    	detected INTEGER NOT NULL,
+    	UNIQUE (user_id, uuid),
+    	FOREIGN KEY (user_id) REFERENCES users (id)
+	);
 `
 
+// migrations are columns added to tables after the first release,
+// CREATE TABLE IF NOT EXISTS does not add them to already existing databases
+var migrations = []struct {
+	table      string
+	column     string
+	definition string
+}{
+	{"users", "last_revision", "INTEGER NOT NULL DEFAULT 0"},
+	{"data", "version", "INTEGER NOT NULL DEFAULT 0"},
+	{"users", "kdf_salt", "BLOB"},
+	{"users", "key_check", "BLOB"},
+	{"users", "legacy_migrated", "INTEGER NOT NULL DEFAULT 0"},
+	{"users", "uses_verifier", "INTEGER NOT NULL DEFAULT 0"},
+}
+
 // ClientStorage is a struct for client storage
 type ClientStorage struct {
 	db     *sql.DB
@@ -51,11 +99,46 @@ func NewClientStorage(logger *zap.Logger, config *config.ClientConfig) *ClientSt
 	if err != nil {
 		logger.Fatal("failed to create table", zap.Error(err))
 	}
-
-	return &ClientStorage{
+	storage := &ClientStorage{
 		db:     db,
 		logger: logger,
 	}
+	for _, m := range migrations {
+		err = storage.addColumnIfNotExists(m.table, m.column, m.definition)
+		if err != nil {
+			logger.Fatal("failed to migrate table", zap.String("table", m.table), zap.Error(err))
+		}
+	}
+
+	return storage
+}
+
+// addColumnIfNotExists adds a column to the table if it is missing
+func (s *ClientStorage) addColumnIfNotExists(table, column, definition string) error {
+	rows, err := s.db.Query("SELECT name FROM pragma_table_info(?)", table)
+	if err != nil {
+		return err
+	}
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			s.logger.Error("failed to close rows", zap.Error(err))
+		}
+	}(rows)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return err
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	_, err = s.db.Exec("ALTER TABLE " + table + " ADD COLUMN " + column + " " + definition)
+	return err
 }
 
 // Close closes the database
@@ -63,23 +146,118 @@ func (s *ClientStorage) Close() error {
 	return s.db.Close()
 }
 
-// GetLastServerUpdated gets the last server update time
-func (s *ClientStorage) GetLastServerUpdated(username string) (int64, error) {
-	row := s.db.QueryRow("SELECT last_updated FROM users WHERE username = ?", username)
-	var lastUpdated int64
-	err := row.Scan(&lastUpdated)
+// GetLastRevision gets the last server revision applied to the local database
+func (s *ClientStorage) GetLastRevision(username string) (int64, error) {
+	row := s.db.QueryRow("SELECT last_revision FROM users WHERE username = ?", username)
+	var lastRevision int64
+	err := row.Scan(&lastRevision)
 	if err != nil {
-		s.logger.Error("failed to scan last server updated", zap.Error(err))
+		s.logger.Error("failed to scan last revision", zap.Error(err))
 		return 0, err
 	}
-	return lastUpdated, nil
+	return lastRevision, nil
 }
 
-// UpdateLastServerUpdated updates the last server update time
-func (s *ClientStorage) UpdateLastServerUpdated(username string, updateTime int64) error {
-	_, err := s.db.Exec("UPDATE users SET last_updated = ? WHERE username = ?", updateTime, username)
+// UpdateLastRevision updates the last server revision applied to the local database
+func (s *ClientStorage) UpdateLastRevision(username string, revision int64) error {
+	_, err := s.db.Exec("UPDATE users SET last_revision = ? WHERE username = ?", revision, username)
 	if err != nil {
-		s.logger.Error("failed to update last server updated", zap.Error(err))
+		s.logger.Error("failed to update last revision", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// GetUserSalt gets the salt for deriving encryption key of user
+// nil is returned if salt is not generated yet
+func (s *ClientStorage) GetUserSalt(userName string) ([]byte, error) {
+	row := s.db.QueryRow("SELECT kdf_salt FROM users WHERE username = ?", userName)
+	var salt []byte
+	err := row.Scan(&salt)
+	if err != nil {
+		s.logger.Error("failed to scan salt", zap.Error(err))
+		return nil, err
+	}
+	return salt, nil
+}
+
+// SetUserSalt sets the salt for deriving encryption key of user
+func (s *ClientStorage) SetUserSalt(userName string, salt []byte) error {
+	_, err := s.db.Exec("UPDATE users SET kdf_salt = ? WHERE username = ?", salt, userName)
+	if err != nil {
+		s.logger.Error("failed to update salt", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// GetUserKeyCheck gets the key check value of vault master key of user
+// nil is returned if the user has not logged in on this device yet
+func (s *ClientStorage) GetUserKeyCheck(userName string) ([]byte, error) {
+	row := s.db.QueryRow("SELECT key_check FROM users WHERE username = ?", userName)
+	var keyCheck []byte
+	err := row.Scan(&keyCheck)
+	if err != nil {
+		s.logger.Error("failed to scan key check", zap.Error(err))
+		return nil, err
+	}
+	return keyCheck, nil
+}
+
+// SetUserKeyCheck sets the key check value of vault master key of user
+func (s *ClientStorage) SetUserKeyCheck(userName string, keyCheck []byte) error {
+	_, err := s.db.Exec("UPDATE users SET key_check = ? WHERE username = ?", keyCheck, userName)
+	if err != nil {
+		s.logger.Error("failed to update key check", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// IsLegacyMigrated checks that all data of user in legacy format is migrated on this device
+func (s *ClientStorage) IsLegacyMigrated(userName string) (bool, error) {
+	row := s.db.QueryRow("SELECT legacy_migrated FROM users WHERE username = ?", userName)
+	var migrated bool
+	err := row.Scan(&migrated)
+	if err != nil {
+		s.logger.Error("failed to scan legacy migrated", zap.Error(err))
+		return false, err
+	}
+	return migrated, nil
+}
+
+// SetLegacyMigrated marks all data of user in legacy format as migrated on this device,
+// data in legacy format is not accepted from server after it
+func (s *ClientStorage) SetLegacyMigrated(userName string) error {
+	_, err := s.db.Exec("UPDATE users SET legacy_migrated = 1 WHERE username = ?", userName)
+	if err != nil {
+		s.logger.Error("failed to update legacy migrated", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// UsesVerifier checks that account of user has a password verifier on server,
+// password of such user is never sent to server in clear, unknown user has no mark yet
+func (s *ClientStorage) UsesVerifier(userName string) (bool, error) {
+	row := s.db.QueryRow("SELECT uses_verifier FROM users WHERE username = ?", userName)
+	var usesVerifier bool
+	err := row.Scan(&usesVerifier)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		s.logger.Error("failed to scan uses verifier", zap.Error(err))
+		return false, err
+	}
+	return usesVerifier, nil
+}
+
+// SetUsesVerifier marks that account of user has a password verifier on server
+func (s *ClientStorage) SetUsesVerifier(userName string) error {
+	_, err := s.db.Exec("UPDATE users SET uses_verifier = 1 WHERE username = ?", userName)
+	if err != nil {
+		s.logger.Error("failed to update uses verifier", zap.Error(err))
 		return err
 	}
 	return nil
@@ -87,7 +265,7 @@ func (s *ClientStorage) UpdateLastServerUpdated(username string, updateTime int6
 
 // CreateUser creates a new user
 func (s *ClientStorage) CreateUser(userName string) error {
-	_, err := s.db.Exec("INSERT INTO users (username, last_updated) VALUES (?, ?)", userName, 0)
+	_, err := s.db.Exec("INSERT INTO users (username, last_revision) VALUES (?, ?)", userName, 0)
 	if err != nil {
 		s.logger.Error("failed to insert user", zap.Error(err))
 		return err
@@ -110,14 +288,17 @@ func (s *ClientStorage) GetUserID(userName string) (int64, error) {
 }
 
 // CreateData creates new data
+// the uuid is generated only if data doesn't have it yet
 func (s *ClientStorage) CreateData(user string, data models.StoredData) error {
 	id, err := s.GetUserID(user)
 	if err != nil || id == 0 {
 		s.logger.Error("failed to get user id", zap.Error(err))
 		return err
 	}
-	data.UUID = uuid.New().String()
-	_, err = s.db.Exec("INSERT INTO data (user_id, uuid, meta, type, data) VALUES (?, ?, ?, ?, ?)", id, data.UUID, data.Meta, data.DataType, data.EncryptedData)
+	if data.UUID == "" {
+		data.UUID = uuid.New().String()
+	}
+	_, err = s.db.Exec("INSERT INTO data (user_id, uuid, meta, type, data, version) VALUES (?, ?, ?, ?, ?, ?)", id, data.UUID, data.Meta, data.DataType, data.EncryptedData, data.Version)
 
 	if err != nil {
 		s.logger.Error("failed to insert data", zap.Error(err))
@@ -133,10 +314,13 @@ func (s *ClientStorage) GetDataByUUID(user string, uuid string) (*models.StoredD
 		s.logger.Error("failed to get user id", zap.Error(err))
 		return nil, err
 	}
-	row := s.db.QueryRow("SELECT uuid, meta, type, data FROM data WHERE uuid = ? AND user_id = ?", uuid, id)
+	row := s.db.QueryRow("SELECT uuid, meta, type, data, version FROM data WHERE uuid = ? AND user_id = ?", uuid, id)
 
 	var data models.StoredData
-	err = row.Scan(&data.UUID, &data.Meta, &data.DataType, &data.EncryptedData)
+	err = row.Scan(&data.UUID, &data.Meta, &data.DataType, &data.EncryptedData, &data.Version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, clienterrors.LocalDataNotFound
+	}
 	if err != nil {
 		s.logger.Error("failed to scan data", zap.Error(err))
 		return nil, err
@@ -151,7 +335,7 @@ func (s *ClientStorage) GetData(user string) ([]models.StoredData, error) {
 		s.logger.Error("failed to get user id", zap.Error(err))
 		return nil, err
 	}
-	rows, err := s.db.Query("SELECT uuid, meta, type, data FROM data WHERE user_id = ?", id)
+	rows, err := s.db.Query("SELECT uuid, meta, type, data, version FROM data WHERE user_id = ?", id)
 	if err != nil {
 		s.logger.Error("failed to select data", zap.Error(err))
 		return nil, err
@@ -165,7 +349,7 @@ func (s *ClientStorage) GetData(user string) ([]models.StoredData, error) {
 	var data []models.StoredData
 	for rows.Next() {
 		var d models.StoredData
-		err := rows.Scan(&d.UUID, &d.Meta, &d.DataType, &d.EncryptedData)
+		err := rows.Scan(&d.UUID, &d.Meta, &d.DataType, &d.EncryptedData, &d.Version)
 		if err != nil {
 			s.logger.Error("failed to scan data", zap.Error(err))
 			return nil, err
@@ -182,12 +366,40 @@ func (s *ClientStorage) UpdateData(user string, data models.StoredData) error {
 		s.logger.Error("failed to get user id", zap.Error(err))
 		return err
 	}
-	_, err = s.db.Exec("UPDATE data SET meta = ?, data = ? WHERE uuid = ? AND user_id = ?", data.Meta, data.EncryptedData, data.UUID, id)
+	_, err = s.db.Exec("UPDATE data SET meta = ?, data = ?, version = ? WHERE uuid = ? AND user_id = ?", data.Meta, data.EncryptedData, data.Version, data.UUID, id)
+
+	if err != nil {
+		s.logger.Error("failed to update data", zap.Error(err))
+		return err
+	}
+	return nil
+}
 
+// UpsertData updates data if it exists, otherwise creates it
+func (s *ClientStorage) UpsertData(user string, data models.StoredData) error {
+	id, err := s.GetUserID(user)
+	if err != nil || id == 0 {
+		s.logger.Error("failed to get user id", zap.Error(err))
+		return err
+	}
+	result, err := s.db.Exec("UPDATE data SET meta = ?, type = ?, data = ?, version = ? WHERE uuid = ? AND user_id = ?", data.Meta, data.DataType, data.EncryptedData, data.Version, data.UUID, id)
 	if err != nil {
 		s.logger.Error("failed to update data", zap.Error(err))
 		return err
 	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		s.logger.Error("failed to get affected rows", zap.Error(err))
+		return err
+	}
+	if affected > 0 {
+		return nil
+	}
+	_, err = s.db.Exec("INSERT INTO data (user_id, uuid, meta, type, data, version) VALUES (?, ?, ?, ?, ?, ?)", id, data.UUID, data.Meta, data.DataType, data.EncryptedData, data.Version)
+	if err != nil {
+		s.logger.Error("failed to insert data", zap.Error(err))
+		return err
+	}
 	return nil
 }
 
@@ -224,15 +436,14 @@ func (s *ClientStorage) DeleteAllData(user string) error {
 }
 
 // FindByMeta finds data by meta
-// this function wrote for feature "search"
-// Deprecated: currently not used
+// meta of data is blinded search token, so the token of searched meta should be given
 func (s *ClientStorage) FindByMeta(user string, meta string) ([]models.StoredData, error) {
 	id, err := s.GetUserID(user)
 	if err != nil || id == 0 {
 		s.logger.Error("failed to get user id", zap.Error(err))
 		return nil, err
 	}
-	rows, err := s.db.Query("SELECT uuid, meta,type, data FROM data WHERE meta = ? AND user_id = ?", meta, id)
+	rows, err := s.db.Query("SELECT uuid, meta, type, data, version FROM data WHERE meta = ? AND user_id = ?", meta, id)
 	if err != nil {
 		s.logger.Error("failed to select data", zap.Error(err))
 		return nil, err
@@ -246,7 +457,7 @@ func (s *ClientStorage) FindByMeta(user string, meta string) ([]models.StoredDat
 	var data []models.StoredData
 	for rows.Next() {
 		var d models.StoredData
-		err := rows.Scan(&d.UUID, &d.Meta, &d.EncryptedData)
+		err := rows.Scan(&d.UUID, &d.Meta, &d.DataType, &d.EncryptedData, &d.Version)
 		if err != nil {
 			s.logger.Error("failed to scan data", zap.Error(err))
 			return nil, err
@@ -0,0 +1,208 @@
+// Package: usecase
+// in this file we have resolution of conflicts between local and server changes of the same data
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/h2p2f/dedicated-vault/internal/client/clienterrors"
+	"github.com/h2p2f/dedicated-vault/internal/client/models"
+)
+
+// conflictedCopySuffix is added to meta of local copy saved as new data by keep-both policy
+const conflictedCopySuffix = " (conflicted copy)"
+
+// conflictPolicy returns configured conflict policy, unknown policy is treated as ask
+func (c *ClientUseCase) conflictPolicy() models.ConflictPolicy {
+	switch policy := models.ConflictPolicy(c.Config.ConflictPolicyOf()); policy {
+	case models.ConflictPolicies.ServerWins, models.ConflictPolicies.ClientWins, models.ConflictPolicies.KeepBoth:
+		return policy
+	}
+	return models.ConflictPolicies.Ask
+}
+
+// handleConflict resolves conflict with configured policy
+// with ask policy the conflict is saved for user decision, local changes of the data wait for it in outbox,
+// and ConflictError is returned, so caller can show it
+func (c *ClientUseCase) handleConflict(conflict models.Conflict) error {
+	policy := c.conflictPolicy()
+	if policy != models.ConflictPolicies.Ask {
+		return c.applyPolicy(conflict, policy)
+	}
+	conflict.Detected = time.Now().Unix()
+	err := c.Storage.SaveConflict(c.Config.UserName(), conflict)
+	if err != nil {
+		return err
+	}
+	return &clienterrors.ConflictError{Server: conflict.Server}
+}
+
+// applyPolicy makes local storage and outbox consistent with the chosen side of conflict
+// local changes which should reach server are queued again based on the current server version
+func (c *ClientUseCase) applyPolicy(conflict models.Conflict, policy models.ConflictPolicy) error {
+	local, err := c.Storage.GetDataByUUID(c.Config.UserName(), conflict.UUID)
+	if err != nil && !errors.Is(err, clienterrors.LocalDataNotFound) {
+		return err
+	}
+	// local is nil if data was deleted locally
+	err = c.Storage.DeleteOutboxByUUID(c.Config.UserName(), conflict.UUID)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case policy == models.ConflictPolicies.ServerWins,
+		policy == models.ConflictPolicies.KeepBoth && local == nil:
+		return c.takeServerCopy(conflict)
+
+	case policy == models.ConflictPolicies.KeepBoth:
+		err = c.createCopy(*local, conflictedCopySuffix)
+		if err != nil {
+			return err
+		}
+		return c.takeServerCopy(conflict)
+
+	case policy == models.ConflictPolicies.ClientWins && local == nil:
+		if conflict.ServerDeleted {
+			return nil
+		}
+		return c.Storage.AddToOutbox(c.Config.UserName(), models.OutboxEntry{
+			Operation:       models.OutboxOperations.Delete,
+			Data:            models.StoredData{UUID: conflict.UUID, DataType: conflict.Server.DataType},
+			ExpectedVersion: conflict.Server.Version,
+		})
+
+	case policy == models.ConflictPolicies.ClientWins && conflict.ServerDeleted:
+		// uuid of data deleted on server can't be used again, so local copy is created as new data
+		err = c.Storage.DeleteData(c.Config.UserName(), *local)
+		if err != nil {
+			return err
+		}
+		return c.createCopy(*local, "")
+
+	case policy == models.ConflictPolicies.ClientWins:
+		rebased := *local
+		rebased.Version = conflict.Server.Version + 1
+		err = c.Storage.UpdateData(c.Config.UserName(), rebased)
+		if err != nil {
+			return err
+		}
+		return c.Storage.AddToOutbox(c.Config.UserName(), models.OutboxEntry{
+			Operation:       models.OutboxOperations.Change,
+			Data:            rebased,
+			ExpectedVersion: conflict.Server.Version,
+		})
+	}
+	return fmt.Errorf("unknown conflict policy %q", policy)
+}
+
+// takeServerCopy replaces local copy of data with the server one
+func (c *ClientUseCase) takeServerCopy(conflict models.Conflict) error {
+	if conflict.ServerDeleted {
+		return c.Storage.DeleteData(c.Config.UserName(), models.StoredData{UUID: conflict.UUID})
+	}
+	return c.Storage.UpsertData(c.Config.UserName(), conflict.Server)
+}
+
+// createCopy saves copy of data as new data with metaSuffix added to its meta
+// ciphertext is bound to uuid and meta is encrypted, so the copy is encrypted again
+func (c *ClientUseCase) createCopy(data models.StoredData, metaSuffix string) error {
+	copied, err := data.DecryptData(c.keyring)
+	if err != nil {
+		return err
+	}
+	copied.UUID = uuid.New().String()
+	copied.Meta += metaSuffix
+	copied.Version = 1
+	storedCopy, err := copied.EncryptData(c.keyring)
+	if err != nil {
+		return err
+	}
+	return c.createLocally(*storedCopy)
+}
+
+// createLocally saves new data and queues it for server
+func (c *ClientUseCase) createLocally(data models.StoredData) error {
+	err := c.Storage.CreateData(c.Config.UserName(), data)
+	if err != nil {
+		return err
+	}
+	return c.Storage.AddToOutbox(c.Config.UserName(), models.OutboxEntry{
+		Operation: models.OutboxOperations.Create,
+		Data:      data,
+	})
+}
+
+// sameContent reports whether local change is already applied on server,
+// for example when the response to the previous push was lost
+func sameContent(local, server models.StoredData) bool {
+	return local.Meta == server.Meta && local.DataType == server.DataType && bytes.Equal(local.EncryptedData, server.EncryptedData)
+}
+
+// Conflicts returns conflicts waiting for user decision with decrypted local and server copies
+func (c *ClientUseCase) Conflicts() ([]models.DataConflict, error) {
+	if c.Config.AccessToken() == "" {
+		return nil, fmt.Errorf("user not logged in")
+	}
+	conflicts, err := c.Storage.GetConflicts(c.Config.UserName())
+	if err != nil {
+		return nil, err
+	}
+	result := make([]models.DataConflict, 0, len(conflicts))
+	for _, conflict := range conflicts {
+		dataConflict := models.DataConflict{UUID: conflict.UUID}
+		local, err := c.Storage.GetDataByUUID(c.Config.UserName(), conflict.UUID)
+		if err != nil && !errors.Is(err, clienterrors.LocalDataNotFound) {
+			return nil, err
+		}
+		if local != nil {
+			dataConflict.Local, err = local.DecryptData(c.keyring)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if !conflict.ServerDeleted {
+			dataConflict.Server, err = conflict.Server.DecryptData(c.keyring)
+			if err != nil {
+				return nil, err
+			}
+		}
+		result = append(result, dataConflict)
+	}
+	return result, nil
+}
+
+// ResolveConflict resolves conflict waiting for user decision and pushes the result to server
+func (c *ClientUseCase) ResolveConflict(ctx context.Context, dataUUID string, policy models.ConflictPolicy) error {
+	if c.Config.AccessToken() == "" {
+		return fmt.Errorf("user not logged in")
+	}
+	if policy == models.ConflictPolicies.Ask {
+		return fmt.Errorf("conflict can't be resolved with policy %q", policy)
+	}
+	conflicts, err := c.Storage.GetConflicts(c.Config.UserName())
+	if err != nil {
+		return err
+	}
+	for _, conflict := range conflicts {
+		if conflict.UUID != dataUUID {
+			continue
+		}
+		err = c.applyPolicy(conflict, policy)
+		if err != nil {
+			return err
+		}
+		err = c.Storage.DeleteConflict(c.Config.UserName(), dataUUID)
+		if err != nil {
+			return err
+		}
+		return c.push(ctx)
+	}
+	return clienterrors.ConflictNotFound
+}
@@ -0,0 +1,317 @@
+// Package: usecase
+// in this file we have encryption keys of user
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/h2p2f/dedicated-vault/internal/client/clienterrors"
+	"github.com/h2p2f/dedicated-vault/internal/client/config"
+	"github.com/h2p2f/dedicated-vault/internal/client/models"
+)
+
+// kdfParams returns configured Argon2id parameters, missing ones are taken from defaults
+func (c *ClientUseCase) kdfParams() models.KDFParams {
+	params := models.DefaultKDFParams
+	if c.Config.KDFTime != 0 {
+		params.Time = c.Config.KDFTime
+	}
+	if c.Config.KDFMemory != 0 {
+		params.Memory = c.Config.KDFMemory
+	}
+	if c.Config.KDFThreads != 0 {
+		params.Threads = c.Config.KDFThreads
+	}
+	return params
+}
+
+// unlock creates keyring of user and unwraps vault master key with it
+// random salt is generated for the first login on this device,
+// master key is generated and saved on server wrapped for the first login of user at all
+// clienterrors.WrongPassphrase is returned if master key can't be unwrapped with the passphrase
+func (c *ClientUseCase) unlock(ctx context.Context, userName, passphrase string) error {
+	salt, err := c.Storage.GetUserSalt(userName)
+	if err != nil {
+		return err
+	}
+	if len(salt) == 0 {
+		salt, err = models.NewSalt()
+		if err != nil {
+			return err
+		}
+		err = c.Storage.SetUserSalt(userName, salt)
+		if err != nil {
+			return err
+		}
+	}
+	keyring := models.NewKeyring(userName, passphrase, salt, c.kdfParams())
+	wrappedKey, err := c.Transporter.GetMasterKey(ctx)
+	if err != nil {
+		return err
+	}
+	if wrappedKey.Version == 0 {
+		err = c.createMasterKey(ctx, keyring)
+		if err == nil {
+			return c.checkLocalKey(userName, keyring)
+		}
+		if !errors.Is(err, clienterrors.VersionConflict) {
+			return err
+		}
+		// another device has created master key at the same time, its key is used
+		wrappedKey, err = c.Transporter.GetMasterKey(ctx)
+		if err != nil {
+			return err
+		}
+	}
+	masterKey, err := keyring.UnwrapKey(wrappedKey.Key)
+	if errors.Is(err, models.ErrWeakKDFParams) {
+		return err
+	}
+	if err != nil {
+		return clienterrors.WrongPassphrase
+	}
+	keyring.SetMasterKey(masterKey)
+	if len(wrappedKey.KeyCheck) != 0 && keyring.VerifyKeyCheck(wrappedKey.KeyCheck) != nil {
+		return clienterrors.KeyMismatch
+	}
+	if len(wrappedKey.KeyCheck) == 0 || models.IsLegacyWrappedKey(wrappedKey.Key) {
+		// key saved before key check value was introduced gets it now,
+		// key wrapped before its header was bound to it is wrapped again
+		if len(wrappedKey.KeyCheck) == 0 {
+			wrappedKey.KeyCheck, err = keyring.KeyCheck()
+			if err != nil {
+				return err
+			}
+		}
+		if models.IsLegacyWrappedKey(wrappedKey.Key) {
+			wrappedKey.Key, err = keyring.WrapKey(masterKey)
+			if err != nil {
+				return err
+			}
+		}
+		_, err = c.Transporter.SetMasterKey(ctx, *wrappedKey, wrappedKey.Version)
+		if err != nil && !errors.Is(err, clienterrors.VersionConflict) {
+			return err
+		}
+	}
+	return c.checkLocalKey(userName, keyring)
+}
+
+// checkLocalKey checks master key of keyring with key check value saved on this device,
+// so data is never encrypted with another key, e.g. if server has replaced wrapped key
+// key check value is saved for the first login on this device
+func (c *ClientUseCase) checkLocalKey(userName string, keyring *models.Keyring) error {
+	keyCheck, err := c.Storage.GetUserKeyCheck(userName)
+	if err != nil {
+		return err
+	}
+	if len(keyCheck) != 0 {
+		if keyring.VerifyKeyCheck(keyCheck) != nil {
+			return clienterrors.KeyMismatch
+		}
+		c.keyring = keyring
+		return nil
+	}
+	keyCheck, err = keyring.KeyCheck()
+	if err != nil {
+		return err
+	}
+	err = c.Storage.SetUserKeyCheck(userName, keyCheck)
+	if err != nil {
+		return err
+	}
+	c.keyring = keyring
+	return nil
+}
+
+// Lock forgets vault master key, tokens and passphrase, user has to log in again to use data
+func (c *ClientUseCase) Lock() {
+	c.sessionMu.Lock()
+	defer c.sessionMu.Unlock()
+	c.lock()
+}
+
+// lock locks vault, sessionMu must be held
+func (c *ClientUseCase) lock() {
+	if c.keyring != nil {
+		c.keyring.Wipe()
+	}
+	c.keyring = nil
+	c.Config.UpdateSession(func(s *config.Session) {
+		s.Token = ""
+		s.RefreshToken = ""
+		s.TokenExpires = 0
+		s.Passphrase = ""
+	})
+}
+
+// Logout revokes session of user on server and locks vault
+// vault is locked even if server is unavailable, session expires on server by itself then
+func (c *ClientUseCase) Logout(ctx context.Context) error {
+	if c.Config.AccessToken() == "" {
+		return fmt.Errorf("user not logged in")
+	}
+	c.sessionMu.Lock()
+	defer c.sessionMu.Unlock()
+	err := c.Transporter.Logout(ctx)
+	c.lock()
+	return err
+}
+
+// createMasterKey generates vault master key and saves it on server wrapped with keyring
+func (c *ClientUseCase) createMasterKey(ctx context.Context, keyring *models.Keyring) error {
+	masterKey, err := models.NewMasterKey()
+	if err != nil {
+		return err
+	}
+	keyring.SetMasterKey(masterKey)
+	wrappedKey := models.WrappedKey{}
+	wrappedKey.Key, err = keyring.WrapKey(masterKey)
+	if err != nil {
+		return err
+	}
+	wrappedKey.KeyCheck, err = keyring.KeyCheck()
+	if err != nil {
+		return err
+	}
+	_, err = c.Transporter.SetMasterKey(ctx, wrappedKey, 0)
+	return err
+}
+
+// ChangePassphrase wraps vault master key with the new passphrase
+// data is encrypted with master key, so it is not encrypted again
+// data in legacy format is encrypted with key of the old passphrase, so it is migrated before,
+// passphrase is not changed while such data is left locally or not pushed to server
+// other devices need the new passphrase at the next login
+func (c *ClientUseCase) ChangePassphrase(ctx context.Context, oldPassphrase, newPassphrase string) error {
+	c.sessionMu.Lock()
+	defer c.sessionMu.Unlock()
+	if c.Config.AccessToken() == "" {
+		return fmt.Errorf("user not logged in")
+	}
+	if newPassphrase == "" {
+		return fmt.Errorf("new passphrase is empty")
+	}
+	wrappedKey, err := c.Transporter.GetMasterKey(ctx)
+	if err != nil {
+		return err
+	}
+	masterKey, err := models.NewKeyring(c.Config.UserName(), oldPassphrase, nil, c.kdfParams()).UnwrapKey(wrappedKey.Key)
+	if err != nil {
+		return clienterrors.WrongPassphrase
+	}
+	salt, err := models.NewSalt()
+	if err != nil {
+		return err
+	}
+	keyring := models.NewKeyring(c.Config.UserName(), newPassphrase, salt, c.kdfParams())
+	keyring.SetMasterKey(masterKey)
+	// master key is checked before it is wrapped again, so another key can't replace it this way
+	err = c.keyring.VerifyKeyCheck(wrappedKey.KeyCheck)
+	if err == nil {
+		err = keyring.VerifyKeyCheck(wrappedKey.KeyCheck)
+	}
+	if err != nil {
+		return clienterrors.KeyMismatch
+	}
+	err = c.migrateBeforePassphraseChange(ctx)
+	if err != nil {
+		return err
+	}
+	expectedVersion := wrappedKey.Version
+	wrappedKey.Key, err = keyring.WrapKey(masterKey)
+	if err != nil {
+		return err
+	}
+	_, err = c.Transporter.SetMasterKey(ctx, *wrappedKey, expectedVersion)
+	if err != nil {
+		return err
+	}
+	err = c.Storage.SetUserSalt(c.Config.UserName(), salt)
+	if err != nil {
+		return err
+	}
+	c.keyring = keyring
+	c.Config.UpdateSession(func(s *config.Session) {
+		s.Passphrase = newPassphrase
+	})
+	return nil
+}
+
+// migrateBeforePassphraseChange migrates data in legacy format and checks that nothing is left to migrate
+// migrated data has to be on server, so other devices get it instead of data they can't open after the change
+func (c *ClientUseCase) migrateBeforePassphraseChange(ctx context.Context) error {
+	err := c.migrateLegacyData(ctx)
+	if err != nil {
+		return err
+	}
+	storedData, err := c.Storage.GetData(c.Config.UserName())
+	if err != nil {
+		return err
+	}
+	for _, d := range storedData {
+		if d.IsLegacy() {
+			return fmt.Errorf("%w: %s", clienterrors.LegacyDataNotMigrated, d.UUID)
+		}
+	}
+	entries, err := c.Storage.GetOutbox(c.Config.UserName())
+	if err != nil {
+		return err
+	}
+	if len(entries) != 0 {
+		return clienterrors.PendingChanges
+	}
+	return nil
+}
+
+// migrateLegacyData encrypts data saved in legacy format again and pushes it to server as usual change,
+// so legacy format disappears from all devices
+// local vault is marked as migrated when nothing is left, data in legacy format is rejected by sync after it
+func (c *ClientUseCase) migrateLegacyData(ctx context.Context) error {
+	storedData, err := c.Storage.GetData(c.Config.UserName())
+	if err != nil {
+		return err
+	}
+	migrated, left := 0, 0
+	for _, d := range storedData {
+		if !d.IsLegacy() {
+			continue
+		}
+		data, err := d.DecryptLegacyData(c.keyring)
+		if err != nil {
+			// data encrypted with another passphrase can't be migrated, it is left as is
+			left++
+			continue
+		}
+		reencrypted, err := data.EncryptData(c.keyring)
+		if err != nil {
+			return err
+		}
+		reencrypted.Version = d.Version + 1
+		err = c.Storage.UpdateData(c.Config.UserName(), *reencrypted)
+		if err != nil {
+			return err
+		}
+		err = c.Storage.AddToOutbox(c.Config.UserName(), models.OutboxEntry{
+			Operation:       models.OutboxOperations.Change,
+			Data:            *reencrypted,
+			ExpectedVersion: d.Version,
+		})
+		if err != nil {
+			return err
+		}
+		migrated++
+	}
+	if left == 0 {
+		err = c.Storage.SetLegacyMigrated(c.Config.UserName())
+		if err != nil {
+			return err
+		}
+	}
+	if migrated == 0 {
+		return nil
+	}
+	return c.push(ctx)
+}
@@ -12,6 +12,20 @@ type Storager struct {
 	mock.Mock
 }
 
+// AddToOutbox provides a mock function with given fields: user, entry
+func (_m *Storager) AddToOutbox(user string, entry models.OutboxEntry) error {
+	ret := _m.Called(user, entry)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, models.OutboxEntry) error); ok {
+		r0 = rf(user, entry)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // CreateData provides a mock function with given fields: user, data
 func (_m *Storager) CreateData(user string, data models.StoredData) error {
 	ret := _m.Called(user, data)
@@ -54,6 +68,20 @@ func (_m *Storager) DeleteAllData(user string) error {
 	return r0
 }
 
+// DeleteConflict provides a mock function with given fields: user, uuid
+func (_m *Storager) DeleteConflict(user string, uuid string) error {
+	ret := _m.Called(user, uuid)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, string) error); ok {
+		r0 = rf(user, uuid)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // DeleteData provides a mock function with given fields: user, data
 func (_m *Storager) DeleteData(user string, data models.StoredData) error {
 	ret := _m.Called(user, data)
@@ -68,6 +96,34 @@ func (_m *Storager) DeleteData(user string, data models.StoredData) error {
 	return r0
 }
 
+// DeleteFromOutbox provides a mock function with given fields: user, entryID
+func (_m *Storager) DeleteFromOutbox(user string, entryID int64) error {
+	ret := _m.Called(user, entryID)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, int64) error); ok {
+		r0 = rf(user, entryID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DeleteOutboxByUUID provides a mock function with given fields: user, uuid
+func (_m *Storager) DeleteOutboxByUUID(user string, uuid string) error {
+	ret := _m.Called(user, uuid)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, string) error); ok {
+		r0 = rf(user, uuid)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // FindByMeta provides a mock function with given fields: user, meta
 func (_m *Storager) FindByMeta(user string, meta string) ([]models.StoredData, error) {
 	ret := _m.Called(user, meta)
@@ -94,6 +150,32 @@ func (_m *Storager) FindByMeta(user string, meta string) ([]models.StoredData, e
 	return r0, r1
 }
 
+// GetConflicts provides a mock function with given fields: user
+func (_m *Storager) GetConflicts(user string) ([]models.Conflict, error) {
+	ret := _m.Called(user)
+
+	var r0 []models.Conflict
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string) ([]models.Conflict, error)); ok {
+		return rf(user)
+	}
+	if rf, ok := ret.Get(0).(func(string) []models.Conflict); ok {
+		r0 = rf(user)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.Conflict)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(user)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetData provides a mock function with given fields: user
 func (_m *Storager) GetData(user string) ([]models.StoredData, error) {
 	ret := _m.Called(user)
@@ -146,8 +228,8 @@ func (_m *Storager) GetDataByUUID(user string, uuid string) (*models.StoredData,
 	return r0, r1
 }
 
-// GetLastServerUpdated provides a mock function with given fields: username
-func (_m *Storager) GetLastServerUpdated(username string) (int64, error) {
+// GetLastRevision provides a mock function with given fields: username
+func (_m *Storager) GetLastRevision(username string) (int64, error) {
 	ret := _m.Called(username)
 
 	var r0 int64
@@ -170,6 +252,32 @@ func (_m *Storager) GetLastServerUpdated(username string) (int64, error) {
 	return r0, r1
 }
 
+// GetOutbox provides a mock function with given fields: user
+func (_m *Storager) GetOutbox(user string) ([]models.OutboxEntry, error) {
+	ret := _m.Called(user)
+
+	var r0 []models.OutboxEntry
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string) ([]models.OutboxEntry, error)); ok {
+		return rf(user)
+	}
+	if rf, ok := ret.Get(0).(func(string) []models.OutboxEntry); ok {
+		r0 = rf(user)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.OutboxEntry)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(user)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetUserID provides a mock function with given fields: userName
 func (_m *Storager) GetUserID(userName string) (int64, error) {
 	ret := _m.Called(userName)
@@ -194,6 +302,152 @@ func (_m *Storager) GetUserID(userName string) (int64, error) {
 	return r0, r1
 }
 
+// GetUserKeyCheck provides a mock function with given fields: userName
+func (_m *Storager) GetUserKeyCheck(userName string) ([]byte, error) {
+	ret := _m.Called(userName)
+
+	var r0 []byte
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string) ([]byte, error)); ok {
+		return rf(userName)
+	}
+	if rf, ok := ret.Get(0).(func(string) []byte); ok {
+		r0 = rf(userName)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]byte)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(userName)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetUserSalt provides a mock function with given fields: userName
+func (_m *Storager) GetUserSalt(userName string) ([]byte, error) {
+	ret := _m.Called(userName)
+
+	var r0 []byte
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string) ([]byte, error)); ok {
+		return rf(userName)
+	}
+	if rf, ok := ret.Get(0).(func(string) []byte); ok {
+		r0 = rf(userName)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]byte)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(userName)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// IsLegacyMigrated provides a mock function with given fields: userName
+func (_m *Storager) IsLegacyMigrated(userName string) (bool, error) {
+	ret := _m.Called(userName)
+
+	var r0 bool
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string) (bool, error)); ok {
+		return rf(userName)
+	}
+	if rf, ok := ret.Get(0).(func(string) bool); ok {
+		r0 = rf(userName)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(userName)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// SaveConflict provides a mock function with given fields: user, conflict
+func (_m *Storager) SaveConflict(user string, conflict models.Conflict) error {
+	ret := _m.Called(user, conflict)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, models.Conflict) error); ok {
+		r0 = rf(user, conflict)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SetLegacyMigrated provides a mock function with given fields: userName
+func (_m *Storager) SetLegacyMigrated(userName string) error {
+	ret := _m.Called(userName)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string) error); ok {
+		r0 = rf(userName)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SetUserKeyCheck provides a mock function with given fields: userName, keyCheck
+func (_m *Storager) SetUserKeyCheck(userName string, keyCheck []byte) error {
+	ret := _m.Called(userName, keyCheck)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, []byte) error); ok {
+		r0 = rf(userName, keyCheck)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SetUserSalt provides a mock function with given fields: userName, salt
+func (_m *Storager) SetUserSalt(userName string, salt []byte) error {
+	ret := _m.Called(userName, salt)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, []byte) error); ok {
+		r0 = rf(userName, salt)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SetUsesVerifier provides a mock function with given fields: userName
+func (_m *Storager) SetUsesVerifier(userName string) error {
+	ret := _m.Called(userName)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string) error); ok {
+		r0 = rf(userName)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // UpdateData provides a mock function with given fields: user, data
 func (_m *Storager) UpdateData(user string, data models.StoredData) error {
 	ret := _m.Called(user, data)
@@ -208,13 +462,27 @@ func (_m *Storager) UpdateData(user string, data models.StoredData) error {
 	return r0
 }
 
-// UpdateLastServerUpdated provides a mock function with given fields: username, updateTime
-func (_m *Storager) UpdateLastServerUpdated(username string, updateTime int64) error {
-	ret := _m.Called(username, updateTime)
+// UpdateLastRevision provides a mock function with given fields: username, revision
+func (_m *Storager) UpdateLastRevision(username string, revision int64) error {
+	ret := _m.Called(username, revision)
 
 	var r0 error
 	if rf, ok := ret.Get(0).(func(string, int64) error); ok {
-		r0 = rf(username, updateTime)
+		r0 = rf(username, revision)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UpdateOutboxError provides a mock function with given fields: user, entryID, lastError
+func (_m *Storager) UpdateOutboxError(user string, entryID int64, lastError string) error {
+	ret := _m.Called(user, entryID, lastError)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, int64, string) error); ok {
+		r0 = rf(user, entryID, lastError)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -222,6 +490,44 @@ func (_m *Storager) UpdateLastServerUpdated(username string, updateTime int64) e
 	return r0
 }
 
+// UpsertData provides a mock function with given fields: user, data
+func (_m *Storager) UpsertData(user string, data models.StoredData) error {
+	ret := _m.Called(user, data)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, models.StoredData) error); ok {
+		r0 = rf(user, data)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UsesVerifier provides a mock function with given fields: userName
+func (_m *Storager) UsesVerifier(userName string) (bool, error) {
+	ret := _m.Called(userName)
+
+	var r0 bool
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string) (bool, error)); ok {
+		return rf(userName)
+	}
+	if rf, ok := ret.Get(0).(func(string) bool); ok {
+		r0 = rf(userName)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(userName)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // NewStorager creates a new instance of Storager. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func NewStorager(t interface {
@@ -5,6 +5,7 @@ package mocks
 import (
 	context "context"
 
+	models "github.com/h2p2f/dedicated-vault/internal/client/models"
 	proto "github.com/h2p2f/dedicated-vault/proto"
 	mock "github.com/stretchr/testify/mock"
 )
@@ -38,13 +39,63 @@ func (_m *Transporter) ChangePassword(ctx context.Context, user *proto.User, new
 	return r0, r1
 }
 
-// ChangeSecret provides a mock function with given fields: ctx, data
-func (_m *Transporter) ChangeSecret(ctx context.Context, data *proto.SecretData) error {
-	ret := _m.Called(ctx, data)
+// ChangeSecret provides a mock function with given fields: ctx, data, expectedVersion
+func (_m *Transporter) ChangeSecret(ctx context.Context, data *proto.SecretData, expectedVersion int64) (int64, error) {
+	ret := _m.Called(ctx, data, expectedVersion)
+
+	var r0 int64
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, *proto.SecretData, int64) (int64, error)); ok {
+		return rf(ctx, data, expectedVersion)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *proto.SecretData, int64) int64); ok {
+		r0 = rf(ctx, data, expectedVersion)
+	} else {
+		r0 = ret.Get(0).(int64)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *proto.SecretData, int64) error); ok {
+		r1 = rf(ctx, data, expectedVersion)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ConfirmTwoFactor provides a mock function with given fields: ctx, user, code
+func (_m *Transporter) ConfirmTwoFactor(ctx context.Context, user *proto.User, code string) ([]string, error) {
+	ret := _m.Called(ctx, user, code)
+
+	var r0 []string
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, *proto.User, string) ([]string, error)); ok {
+		return rf(ctx, user, code)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *proto.User, string) []string); ok {
+		r0 = rf(ctx, user, code)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]string)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *proto.User, string) error); ok {
+		r1 = rf(ctx, user, code)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// DeleteSecret provides a mock function with given fields: ctx, uuid, expectedVersion
+func (_m *Transporter) DeleteSecret(ctx context.Context, uuid string, expectedVersion int64) error {
+	ret := _m.Called(ctx, uuid, expectedVersion)
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(context.Context, *proto.SecretData) error); ok {
-		r0 = rf(ctx, data)
+	if rf, ok := ret.Get(0).(func(context.Context, string, int64) error); ok {
+		r0 = rf(ctx, uuid, expectedVersion)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -52,13 +103,13 @@ func (_m *Transporter) ChangeSecret(ctx context.Context, data *proto.SecretData)
 	return r0
 }
 
-// DeleteSecret provides a mock function with given fields: ctx, uuid
-func (_m *Transporter) DeleteSecret(ctx context.Context, uuid string) error {
-	ret := _m.Called(ctx, uuid)
+// DisableTwoFactor provides a mock function with given fields: ctx, user, code
+func (_m *Transporter) DisableTwoFactor(ctx context.Context, user *proto.User, code string) error {
+	ret := _m.Called(ctx, user, code)
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
-		r0 = rf(ctx, uuid)
+	if rf, ok := ret.Get(0).(func(context.Context, *proto.User, string) error); ok {
+		r0 = rf(ctx, user, code)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -66,6 +117,70 @@ func (_m *Transporter) DeleteSecret(ctx context.Context, uuid string) error {
 	return r0
 }
 
+// DownloadSecret provides a mock function with given fields: ctx, uuid, version, handle
+func (_m *Transporter) DownloadSecret(ctx context.Context, uuid string, version int64, handle func([]byte) error) error {
+	ret := _m.Called(ctx, uuid, version, handle)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, int64, func([]byte) error) error); ok {
+		r0 = rf(ctx, uuid, version, handle)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// EnrollTwoFactor provides a mock function with given fields: ctx, user
+func (_m *Transporter) EnrollTwoFactor(ctx context.Context, user *proto.User) (string, error) {
+	ret := _m.Called(ctx, user)
+
+	var r0 string
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, *proto.User) (string, error)); ok {
+		return rf(ctx, user)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *proto.User) string); ok {
+		r0 = rf(ctx, user)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *proto.User) error); ok {
+		r1 = rf(ctx, user)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetMasterKey provides a mock function with given fields: ctx
+func (_m *Transporter) GetMasterKey(ctx context.Context) (*models.WrappedKey, error) {
+	ret := _m.Called(ctx)
+
+	var r0 *models.WrappedKey
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context) (*models.WrappedKey, error)); ok {
+		return rf(ctx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context) *models.WrappedKey); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*models.WrappedKey)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // ListSecrets provides a mock function with given fields: ctx
 func (_m *Transporter) ListSecrets(ctx context.Context) ([]*proto.SecretData, error) {
 	ret := _m.Called(ctx)
@@ -92,23 +207,49 @@ func (_m *Transporter) ListSecrets(ctx context.Context) ([]*proto.SecretData, er
 	return r0, r1
 }
 
-// Login provides a mock function with given fields: ctx, user
-func (_m *Transporter) Login(ctx context.Context, user *proto.User) (string, error) {
-	ret := _m.Called(ctx, user)
+// ListSessions provides a mock function with given fields: ctx
+func (_m *Transporter) ListSessions(ctx context.Context) ([]models.Session, error) {
+	ret := _m.Called(ctx)
+
+	var r0 []models.Session
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context) ([]models.Session, error)); ok {
+		return rf(ctx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context) []models.Session); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.Session)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Login provides a mock function with given fields: ctx, user, code
+func (_m *Transporter) Login(ctx context.Context, user *proto.User, code string) (string, error) {
+	ret := _m.Called(ctx, user, code)
 
 	var r0 string
 	var r1 error
-	if rf, ok := ret.Get(0).(func(context.Context, *proto.User) (string, error)); ok {
-		return rf(ctx, user)
+	if rf, ok := ret.Get(0).(func(context.Context, *proto.User, string) (string, error)); ok {
+		return rf(ctx, user, code)
 	}
-	if rf, ok := ret.Get(0).(func(context.Context, *proto.User) string); ok {
-		r0 = rf(ctx, user)
+	if rf, ok := ret.Get(0).(func(context.Context, *proto.User, string) string); ok {
+		r0 = rf(ctx, user, code)
 	} else {
 		r0 = ret.Get(0).(string)
 	}
 
-	if rf, ok := ret.Get(1).(func(context.Context, *proto.User) error); ok {
-		r1 = rf(ctx, user)
+	if rf, ok := ret.Get(1).(func(context.Context, *proto.User, string) error); ok {
+		r1 = rf(ctx, user, code)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -116,6 +257,20 @@ func (_m *Transporter) Login(ctx context.Context, user *proto.User) (string, err
 	return r0, r1
 }
 
+// Logout provides a mock function with given fields: ctx
+func (_m *Transporter) Logout(ctx context.Context) error {
+	ret := _m.Called(ctx)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context) error); ok {
+		r0 = rf(ctx)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // Register provides a mock function with given fields: ctx, user
 func (_m *Transporter) Register(ctx context.Context, user *proto.User) (string, error) {
 	ret := _m.Called(ctx, user)
@@ -140,6 +295,20 @@ func (_m *Transporter) Register(ctx context.Context, user *proto.User) (string,
 	return r0, r1
 }
 
+// RevokeSession provides a mock function with given fields: ctx, sessionID
+func (_m *Transporter) RevokeSession(ctx context.Context, sessionID string) error {
+	ret := _m.Called(ctx, sessionID)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, sessionID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // SaveSecret provides a mock function with given fields: ctx, data
 func (_m *Transporter) SaveSecret(ctx context.Context, data *proto.SecretData) error {
 	ret := _m.Called(ctx, data)
@@ -154,6 +323,118 @@ func (_m *Transporter) SaveSecret(ctx context.Context, data *proto.SecretData) e
 	return r0
 }
 
+// SetMasterKey provides a mock function with given fields: ctx, wrappedKey, expectedVersion
+func (_m *Transporter) SetMasterKey(ctx context.Context, wrappedKey models.WrappedKey, expectedVersion int64) (int64, error) {
+	ret := _m.Called(ctx, wrappedKey, expectedVersion)
+
+	var r0 int64
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, models.WrappedKey, int64) (int64, error)); ok {
+		return rf(ctx, wrappedKey, expectedVersion)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, models.WrappedKey, int64) int64); ok {
+		r0 = rf(ctx, wrappedKey, expectedVersion)
+	} else {
+		r0 = ret.Get(0).(int64)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, models.WrappedKey, int64) error); ok {
+		r1 = rf(ctx, wrappedKey, expectedVersion)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// SyncChanges provides a mock function with given fields: ctx, sinceRevision
+func (_m *Transporter) SyncChanges(ctx context.Context, sinceRevision int64) ([]*proto.SecretData, int64, bool, error) {
+	ret := _m.Called(ctx, sinceRevision)
+
+	var r0 []*proto.SecretData
+	var r1 int64
+	var r2 bool
+	var r3 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64) ([]*proto.SecretData, int64, bool, error)); ok {
+		return rf(ctx, sinceRevision)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int64) []*proto.SecretData); ok {
+		r0 = rf(ctx, sinceRevision)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*proto.SecretData)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int64) int64); ok {
+		r1 = rf(ctx, sinceRevision)
+	} else {
+		r1 = ret.Get(1).(int64)
+	}
+
+	if rf, ok := ret.Get(2).(func(context.Context, int64) bool); ok {
+		r2 = rf(ctx, sinceRevision)
+	} else {
+		r2 = ret.Get(2).(bool)
+	}
+
+	if rf, ok := ret.Get(3).(func(context.Context, int64) error); ok {
+		r3 = rf(ctx, sinceRevision)
+	} else {
+		r3 = ret.Error(3)
+	}
+
+	return r0, r1, r2, r3
+}
+
+// UpgradePassword provides a mock function with given fields: ctx, user, code
+func (_m *Transporter) UpgradePassword(ctx context.Context, user *proto.User, code string) (string, error) {
+	ret := _m.Called(ctx, user, code)
+
+	var r0 string
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, *proto.User, string) (string, error)); ok {
+		return rf(ctx, user, code)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *proto.User, string) string); ok {
+		r0 = rf(ctx, user, code)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *proto.User, string) error); ok {
+		r1 = rf(ctx, user, code)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UploadSecret provides a mock function with given fields: ctx, data, expectedVersion, next
+func (_m *Transporter) UploadSecret(ctx context.Context, data *proto.SecretData, expectedVersion int64, next func() ([]byte, error)) (int64, error) {
+	ret := _m.Called(ctx, data, expectedVersion, next)
+
+	var r0 int64
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, *proto.SecretData, int64, func() ([]byte, error)) (int64, error)); ok {
+		return rf(ctx, data, expectedVersion, next)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *proto.SecretData, int64, func() ([]byte, error)) int64); ok {
+		r0 = rf(ctx, data, expectedVersion, next)
+	} else {
+		r0 = ret.Get(0).(int64)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *proto.SecretData, int64, func() ([]byte, error)) error); ok {
+		r1 = rf(ctx, data, expectedVersion, next)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // NewTransporter creates a new instance of Transporter. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func NewTransporter(t interface {
@@ -0,0 +1,203 @@
+// Package: usecase
+// in this file we have outbox replay - local changes are pushed to server when it is reachable
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/h2p2f/dedicated-vault/internal/client/clienterrors"
+	"github.com/h2p2f/dedicated-vault/internal/client/models"
+	pb "github.com/h2p2f/dedicated-vault/proto"
+)
+
+// maxFlushPasses limits flushing of changes queued again while resolving conflicts during flush
+const maxFlushPasses = 2
+
+// Flush pushes local changes from outbox to server in order they were made
+// if server is unavailable, flushing stops and clienterrors.ServerUnavailable is returned,
+// all not pushed changes stay in outbox
+// conflicts with server changes are resolved by configured policy, changes of data with conflict
+// waiting for user decision are not pushed
+// other errors are saved in outbox entry and the rest changes of this data wait for the next flush
+// errors of all failed data are returned together
+func (c *ClientUseCase) Flush(ctx context.Context) error {
+	if c.Config.AccessToken() == "" {
+		return fmt.Errorf("user not logged in")
+	}
+	c.flushMu.Lock()
+	defer c.flushMu.Unlock()
+
+	var errs []error
+	for pass := 0; pass < maxFlushPasses; pass++ {
+		requeued, passErrs, err := c.flushPass(ctx)
+		if err != nil {
+			return err
+		}
+		errs = append(errs, passErrs...)
+		if !requeued {
+			break
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// flushPass pushes outbox entries once
+// requeued reports whether resolved conflicts queued new changes
+func (c *ClientUseCase) flushPass(ctx context.Context) (requeued bool, errs []error, err error) {
+	entries, err := c.Storage.GetOutbox(c.Config.UserName())
+	if err != nil {
+		return false, nil, err
+	}
+	conflicts, err := c.Storage.GetConflicts(c.Config.UserName())
+	if err != nil {
+		return false, nil, err
+	}
+	failed := make(map[string]bool, len(conflicts))
+	for _, conflict := range conflicts {
+		failed[conflict.UUID] = true
+	}
+	for _, entry := range entries {
+		if failed[entry.Data.UUID] {
+			continue
+		}
+		pushErr := c.pushEntry(ctx, entry)
+		var conflictErr *clienterrors.ConflictError
+		switch {
+		case pushErr == nil,
+			entry.Operation == models.OutboxOperations.Create && errors.Is(pushErr, clienterrors.DataAlreadyExists),
+			entry.Operation == models.OutboxOperations.Delete && errors.Is(pushErr, clienterrors.DataNotFound),
+			errors.As(pushErr, &conflictErr) && entry.Operation == models.OutboxOperations.Change && sameContent(entry.Data, conflictErr.Server):
+			// change was already applied on server, for example response was lost last time
+			err = c.Storage.DeleteFromOutbox(c.Config.UserName(), entry.ID)
+			if err != nil {
+				return false, nil, err
+			}
+		case errors.Is(pushErr, clienterrors.ServerUnavailable):
+			return false, nil, pushErr
+		case errors.As(pushErr, &conflictErr), errors.Is(pushErr, clienterrors.DataNotFound):
+			// next changes of this data are based on the rejected one, so they are resolved together
+			failed[entry.Data.UUID] = true
+			conflict := models.Conflict{UUID: entry.Data.UUID, ServerDeleted: true}
+			if conflictErr != nil {
+				conflict.Server = conflictErr.Server
+				conflict.ServerDeleted = false
+			}
+			err = c.handleConflict(conflict)
+			if errors.Is(err, clienterrors.VersionConflict) {
+				errs = append(errs, fmt.Errorf("%s %s: %w", entry.Operation, c.title(entry.Data), err))
+				continue
+			}
+			if err != nil {
+				return false, nil, err
+			}
+			requeued = true
+		default:
+			failed[entry.Data.UUID] = true
+			errs = append(errs, fmt.Errorf("%s %s: %w", entry.Operation, c.title(entry.Data), pushErr))
+			err = c.Storage.UpdateOutboxError(c.Config.UserName(), entry.ID, pushErr.Error())
+			if err != nil {
+				return false, nil, err
+			}
+		}
+	}
+	return requeued, errs, nil
+}
+
+// pushEntry sends one outbox entry to server
+func (c *ClientUseCase) pushEntry(ctx context.Context, entry models.OutboxEntry) error {
+	secretData := &pb.SecretData{
+		Uuid:  entry.Data.UUID,
+		Meta:  entry.Data.Meta,
+		Type:  entry.Data.DataType,
+		Value: entry.Data.EncryptedData,
+	}
+	switch entry.Operation {
+	case models.OutboxOperations.Create:
+		return c.Transporter.SaveSecret(ctx, secretData)
+	case models.OutboxOperations.Change:
+		_, err := c.Transporter.ChangeSecret(ctx, secretData, entry.ExpectedVersion)
+		return err
+	case models.OutboxOperations.Delete:
+		return c.Transporter.DeleteSecret(ctx, entry.Data.UUID, entry.ExpectedVersion)
+	}
+	return fmt.Errorf("unknown outbox operation %q", entry.Operation)
+}
+
+// push flushes outbox after a local change
+// unavailable server is not an error here - the change is saved locally and will be pushed later
+func (c *ClientUseCase) push(ctx context.Context) error {
+	err := c.Flush(ctx)
+	if errors.Is(err, clienterrors.ServerUnavailable) {
+		return nil
+	}
+	return err
+}
+
+// PendingChanges returns local changes not pushed to server yet
+// meta of data in returned entries is decrypted, so it can be shown to user
+func (c *ClientUseCase) PendingChanges() ([]models.OutboxEntry, error) {
+	if c.Config.AccessToken() == "" {
+		return nil, fmt.Errorf("user not logged in")
+	}
+	entries, err := c.Storage.GetOutbox(c.Config.UserName())
+	if err != nil {
+		return nil, err
+	}
+	for i := range entries {
+		entries[i].Data.Meta = c.title(entries[i].Data)
+	}
+	return entries, nil
+}
+
+// title returns decrypted meta of data to show it to user
+// uuid is returned for data without meta, e.g. deleted one
+func (c *ClientUseCase) title(storedData models.StoredData) string {
+	if len(storedData.EncryptedData) != 0 && c.keyring != nil {
+		data, err := storedData.DecryptData(c.keyring)
+		if err == nil && data.Meta != "" {
+			return data.Meta
+		}
+	}
+	return storedData.UUID
+}
+
+// pendingUUIDs returns uuids of data with not pushed changes
+func (c *ClientUseCase) pendingUUIDs() (map[string]bool, error) {
+	entries, err := c.Storage.GetOutbox(c.Config.UserName())
+	if err != nil {
+		return nil, err
+	}
+	pending := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		pending[entry.Data.UUID] = true
+	}
+	return pending, nil
+}
+
+// replay pushes outbox of logged in user, session is not changed meanwhile
+func (c *ClientUseCase) replay(ctx context.Context) {
+	c.sessionMu.Lock()
+	defer c.sessionMu.Unlock()
+	if c.Config.AccessToken() == "" {
+		return
+	}
+	_ = c.Flush(ctx)
+}
+
+// RunReplayer periodically pushes outbox to server until ctx is done
+// errors are not returned - they stay in outbox and are shown as pending changes
+func (c *ClientUseCase) RunReplayer(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.replay(ctx)
+		}
+	}
+}
@@ -0,0 +1,27 @@
+// Package: usecase
+// in this file we have sessions of user on other devices
+package usecase
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/h2p2f/dedicated-vault/internal/client/models"
+)
+
+// ListSessions returns sessions of user, every logged in device has its own session
+func (c *ClientUseCase) ListSessions(ctx context.Context) ([]models.Session, error) {
+	if c.Config.AccessToken() == "" {
+		return nil, fmt.Errorf("user not logged in")
+	}
+	return c.Transporter.ListSessions(ctx)
+}
+
+// RevokeSession revokes session of another device, so a lost device can't use the vault without changing password
+// session of this device is ended by Logout, which locks vault as well
+func (c *ClientUseCase) RevokeSession(ctx context.Context, sessionID string) error {
+	if c.Config.AccessToken() == "" {
+		return fmt.Errorf("user not logged in")
+	}
+	return c.Transporter.RevokeSession(ctx, sessionID)
+}
@@ -0,0 +1,132 @@
+// Package: usecase
+// in this file we have streaming of large binary content
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/google/uuid"
+
+	"github.com/h2p2f/dedicated-vault/internal/client/models"
+	pb "github.com/h2p2f/dedicated-vault/proto"
+)
+
+// UploadBinary saves binary data with content read from r, content is encrypted and sent to server in chunks,
+// so it is never held in memory as a whole
+// new data is created if data has no uuid, otherwise data with data.Version is replaced
+// upload needs server, it is not queued in outbox like other changes
+// progress is called with the number of bytes sent and size of content
+func (c *ClientUseCase) UploadBinary(ctx context.Context, data models.Data, r io.Reader, size int64, progress func(done, total int64)) error {
+	if c.Config.AccessToken() == "" {
+		return fmt.Errorf("user not logged in")
+	}
+	if size < 0 {
+		return fmt.Errorf("invalid size %d", size)
+	}
+	expectedVersion := data.Version
+	if data.UUID == "" {
+		data.UUID = uuid.New().String()
+		expectedVersion = 0
+	}
+	data.Folder.Binary.Data = nil
+	data.Folder.Binary.Size = size
+	sealer, err := data.NewStreamSealer(c.keyring)
+	if err != nil {
+		return err
+	}
+	storedData, err := data.EncryptData(c.keyring)
+	if err != nil {
+		return err
+	}
+
+	buf := make([]byte, models.StreamChunkSize)
+	var done int64
+	finished := false
+	next := func() ([]byte, error) {
+		if finished {
+			return nil, io.EOF
+		}
+		n := int64(len(buf))
+		if size-done < n {
+			n = size - done
+		}
+		_, err := io.ReadFull(r, buf[:n])
+		if err != nil {
+			return nil, err
+		}
+		done += n
+		finished = done == size
+		sealed, err := sealer.Seal(buf[:n], finished)
+		if err != nil {
+			return nil, err
+		}
+		if progress != nil {
+			progress(done, size)
+		}
+		return sealed, nil
+	}
+	version, err := c.Transporter.UploadSecret(ctx, &pb.SecretData{
+		Uuid:  storedData.UUID,
+		Meta:  storedData.Meta,
+		Type:  storedData.DataType,
+		Value: storedData.EncryptedData,
+	}, expectedVersion, next)
+	if err != nil {
+		return err
+	}
+	storedData.Version = version
+	return c.Storage.UpsertData(c.Config.UserName(), *storedData)
+}
+
+// DownloadBinary writes content of binary data to w
+// streamed content is read from server and decrypted chunk by chunk, content saved in data itself is written as is
+func (c *ClientUseCase) DownloadBinary(ctx context.Context, data models.Data, w io.Writer, progress func(done, total int64)) error {
+	if c.Config.AccessToken() == "" {
+		return fmt.Errorf("user not logged in")
+	}
+	if !data.Folder.Binary.IsStreamed() {
+		_, err := w.Write(data.Folder.Binary.Data)
+		if err != nil {
+			return err
+		}
+		if progress != nil {
+			size := int64(len(data.Folder.Binary.Data))
+			progress(size, size)
+		}
+		return nil
+	}
+	opener, err := data.NewStreamOpener(c.keyring)
+	if err != nil {
+		return err
+	}
+	var done int64
+	err = c.Transporter.DownloadSecret(ctx, data.UUID, data.Version, func(sealed []byte) error {
+		chunk, _, err := opener.Open(sealed)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(chunk)
+		if err != nil {
+			return err
+		}
+		done += int64(len(chunk))
+		if progress != nil {
+			progress(done, data.Folder.Binary.Size)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	err = opener.Finish()
+	if err != nil {
+		return err
+	}
+	if done != data.Folder.Binary.Size {
+		return errors.New("binary content has unexpected size")
+	}
+	return nil
+}
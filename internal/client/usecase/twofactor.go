@@ -0,0 +1,84 @@
+// Package: usecase
+// in this file we have two-factor authentication of user
+package usecase
+
+import (
+	"context"
+	"errors"
+
+	"github.com/h2p2f/dedicated-vault/internal/client/clienterrors"
+	"github.com/h2p2f/dedicated-vault/internal/client/config"
+	pb "github.com/h2p2f/dedicated-vault/proto"
+)
+
+// EnrollTwoFactor starts enrollment of two-factor authentication and returns provisioning URI of TOTP secret,
+// it is added to authenticator app; server needs session of user and password,
+// user who is not logged in gets a session by password for the call, so it can be done before the first login
+func (c *ClientUseCase) EnrollTwoFactor(ctx context.Context, userName, password string) (string, error) {
+	c.sessionMu.Lock()
+	defer c.sessionMu.Unlock()
+	end, err := c.twoFactorSession(ctx, userName, password)
+	if err != nil {
+		return "", err
+	}
+	defer end()
+	return c.Transporter.EnrollTwoFactor(ctx, &pb.User{Name: userName, Password: password})
+}
+
+// ConfirmTwoFactor enables two-factor authentication with code of authenticator app and returns recovery codes,
+// they are shown only once
+func (c *ClientUseCase) ConfirmTwoFactor(ctx context.Context, userName, password, code string) ([]string, error) {
+	c.sessionMu.Lock()
+	defer c.sessionMu.Unlock()
+	end, err := c.twoFactorSession(ctx, userName, password)
+	if err != nil {
+		return nil, err
+	}
+	defer end()
+	return c.Transporter.ConfirmTwoFactor(ctx, &pb.User{Name: userName, Password: password}, code)
+}
+
+// DisableTwoFactor disables two-factor authentication, code is a current TOTP code of authenticator app,
+// user has to be logged in for it, login of user with two-factor authentication needs a code itself
+func (c *ClientUseCase) DisableTwoFactor(ctx context.Context, userName, password, code string) error {
+	c.sessionMu.Lock()
+	defer c.sessionMu.Unlock()
+	end, err := c.twoFactorSession(ctx, userName, password)
+	if err != nil {
+		return err
+	}
+	defer end()
+	return c.Transporter.DisableTwoFactor(ctx, &pb.User{Name: userName, Password: password}, code)
+}
+
+// twoFactorSession makes sure calls of two-factor authentication are made with session of user,
+// session of logged in user is used, otherwise user logs in by password and the session is ended by returned function;
+// user who has to enroll two-factor authentication gets a session accepted only to enroll
+func (c *ClientUseCase) twoFactorSession(ctx context.Context, userName, password string) (func(), error) {
+	session := c.Config.Session()
+	if session.Token != "" {
+		if session.User != userName {
+			return nil, clienterrors.AnotherUserLoggedIn
+		}
+		return func() {}, nil
+	}
+	_, err := c.Transporter.Login(ctx, &pb.User{Name: userName, Password: password}, "")
+	if err != nil && !errors.Is(err, clienterrors.TwoFactorEnrollmentRequired) {
+		c.endSession(ctx)
+		return nil, err
+	}
+	return func() { c.endSession(ctx) }, nil
+}
+
+// endSession revokes session which was started without unlocking vault and forgets its tokens,
+// session is forgotten even if server is unavailable, it expires on server by itself then
+func (c *ClientUseCase) endSession(ctx context.Context) {
+	if c.Config.AccessToken() != "" {
+		_ = c.Transporter.Logout(ctx)
+	}
+	c.Config.UpdateSession(func(s *config.Session) {
+		s.Token = ""
+		s.RefreshToken = ""
+		s.TokenExpires = 0
+	})
+}
@@ -6,6 +6,9 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sync"
+
+	"github.com/google/uuid"
 
 	"github.com/h2p2f/dedicated-vault/internal/client/clienterrors"
 	"github.com/h2p2f/dedicated-vault/internal/client/config"
@@ -21,13 +24,30 @@ type Storager interface {
 	GetUserID(userName string) (int64, error)
 	CreateData(user string, data models.StoredData) error
 	UpdateData(user string, data models.StoredData) error
+	UpsertData(user string, data models.StoredData) error
 	DeleteData(user string, data models.StoredData) error
 	GetDataByUUID(user string, uuid string) (*models.StoredData, error)
 	GetData(user string) ([]models.StoredData, error)
 	FindByMeta(user string, meta string) ([]models.StoredData, error)
 	DeleteAllData(user string) error
-	UpdateLastServerUpdated(username string, updateTime int64) error
-	GetLastServerUpdated(username string) (int64, error)
+	UpdateLastRevision(username string, revision int64) error
+	GetLastRevision(username string) (int64, error)
+	GetUserSalt(userName string) ([]byte, error)
+	SetUserSalt(userName string, salt []byte) error
+	GetUserKeyCheck(userName string) ([]byte, error)
+	SetUserKeyCheck(userName string, keyCheck []byte) error
+	IsLegacyMigrated(userName string) (bool, error)
+	SetLegacyMigrated(userName string) error
+	UsesVerifier(userName string) (bool, error)
+	SetUsesVerifier(userName string) error
+	AddToOutbox(user string, entry models.OutboxEntry) error
+	GetOutbox(user string) ([]models.OutboxEntry, error)
+	DeleteFromOutbox(user string, entryID int64) error
+	DeleteOutboxByUUID(user string, uuid string) error
+	UpdateOutboxError(user string, entryID int64, lastError string) error
+	SaveConflict(user string, conflict models.Conflict) error
+	GetConflicts(user string) ([]models.Conflict, error)
+	DeleteConflict(user string, uuid string) error
 }
 
 // Transporter interface for working with transporter
@@ -35,12 +55,24 @@ type Storager interface {
 //go:generate mockery --name Transporter --output ./mocks --filename mocks_transporter.go
 type Transporter interface {
 	Register(ctx context.Context, user *pb.User) (string, error)
-	Login(ctx context.Context, user *pb.User) (string, error)
+	Login(ctx context.Context, user *pb.User, code string) (string, error)
+	UpgradePassword(ctx context.Context, user *pb.User, code string) (string, error)
 	ChangePassword(ctx context.Context, user *pb.User, newPassword string) (string, error)
+	Logout(ctx context.Context) error
+	ListSessions(ctx context.Context) ([]models.Session, error)
+	RevokeSession(ctx context.Context, sessionID string) error
+	EnrollTwoFactor(ctx context.Context, user *pb.User) (string, error)
+	ConfirmTwoFactor(ctx context.Context, user *pb.User, code string) ([]string, error)
+	DisableTwoFactor(ctx context.Context, user *pb.User, code string) error
 	SaveSecret(ctx context.Context, data *pb.SecretData) error
-	ChangeSecret(ctx context.Context, data *pb.SecretData) error
-	DeleteSecret(ctx context.Context, uuid string) error
+	ChangeSecret(ctx context.Context, data *pb.SecretData, expectedVersion int64) (int64, error)
+	DeleteSecret(ctx context.Context, uuid string, expectedVersion int64) error
 	ListSecrets(ctx context.Context) ([]*pb.SecretData, error)
+	SyncChanges(ctx context.Context, sinceRevision int64) ([]*pb.SecretData, int64, bool, error)
+	GetMasterKey(ctx context.Context) (*models.WrappedKey, error)
+	SetMasterKey(ctx context.Context, wrappedKey models.WrappedKey, expectedVersion int64) (int64, error)
+	UploadSecret(ctx context.Context, data *pb.SecretData, expectedVersion int64, next func() ([]byte, error)) (int64, error)
+	DownloadSecret(ctx context.Context, uuid string, version int64, handle func(chunk []byte) error) error
 }
 
 // ClientUseCase is a struct for client usecase
@@ -48,6 +80,13 @@ type ClientUseCase struct {
 	Storage     Storager
 	Transporter Transporter
 	Config      *config.ClientConfig
+	// flushMu doesn't allow GUI and replayer to push the same outbox entries twice
+	flushMu sync.Mutex
+	// sessionMu is held by login, lock and passphrase change and by replayer,
+	// so replayer never pushes outbox while user, token and keyring are being changed
+	sessionMu sync.Mutex
+	// keyring holds vault master key and keys derived from passphrase of logged in user
+	keyring *models.Keyring
 }
 
 // NewClientUseCase creates a new ClientUseCase
@@ -61,6 +100,8 @@ func NewClientUseCase(config *config.ClientConfig, storage Storager, transporter
 
 // CreateUser creates a new user
 func (c *ClientUseCase) CreateUser(ctx context.Context, userName, password, passphrase string) error {
+	c.sessionMu.Lock()
+	defer c.sessionMu.Unlock()
 	err := c.Storage.CreateUser(userName)
 	if err != nil {
 		return err
@@ -73,23 +114,67 @@ func (c *ClientUseCase) CreateUser(ctx context.Context, userName, password, pass
 	if err != nil {
 		return err
 	}
-	c.Config.Token = token
-	c.Config.User = userName
-	c.Config.Passphrase = passphrase
-	err = c.Storage.UpdateLastServerUpdated(userName, c.Config.LastServerUpdated)
+	err = c.Storage.SetUsesVerifier(userName)
+	if err != nil {
+		return err
+	}
+	err = c.unlock(ctx, userName, passphrase)
 	if err != nil {
 		return err
 	}
+	c.Config.UpdateSession(func(s *config.Session) {
+		s.User = userName
+		s.Passphrase = passphrase
+		s.Token = token
+	})
 	return nil
 }
 
 // LoginUser login user
-func (c *ClientUseCase) LoginUser(ctx context.Context, userName, password, passphrase string) error {
+// code is TOTP code or recovery code, it is needed only if user has enabled two-factor authentication
+// password is never sent to server in clear, server answers user registered before verifiers like a wrong password,
+// so wrong password of user not known to have a verifier gets PasswordUpgradeRequired
+func (c *ClientUseCase) LoginUser(ctx context.Context, userName, password, passphrase, code string) error {
+	return c.login(ctx, userName, password, passphrase, code, false)
+}
+
+// UpgradePassword logs in user like LoginUser, but user registered before verifiers sends password in clear once,
+// server replaces its bcrypt hash by verifier then; it is refused for user known to have a verifier,
+// so server can't get password in clear by pretending it has no verifier
+func (c *ClientUseCase) UpgradePassword(ctx context.Context, userName, password, passphrase, code string) error {
+	return c.login(ctx, userName, password, passphrase, code, true)
+}
+
+// login logs in user by proof of password, upgrade allows password in clear for user without verifier on server
+func (c *ClientUseCase) login(ctx context.Context, userName, password, passphrase, code string, upgrade bool) error {
+	c.sessionMu.Lock()
+	defer c.sessionMu.Unlock()
 	user := &pb.User{
 		Name:     userName,
 		Password: password,
 	}
-	token, err := c.Transporter.Login(ctx, user)
+	token, err := c.Transporter.Login(ctx, user, code)
+	if errors.Is(err, clienterrors.WrongPassword) {
+		// server doesn't tell user without verifier from a wrong password, so only this device knows it
+		usesVerifier, checkErr := c.Storage.UsesVerifier(userName)
+		if checkErr != nil {
+			return checkErr
+		}
+		switch {
+		case usesVerifier && upgrade:
+			return clienterrors.PasswordUpgradeRefused
+		case usesVerifier:
+			return err
+		case !upgrade:
+			return clienterrors.PasswordUpgradeRequired
+		}
+		token, err = c.Transporter.UpgradePassword(ctx, user, code)
+	}
+	if errors.Is(err, clienterrors.TwoFactorEnrollmentRequired) {
+		// session accepted only to enroll is not kept, enrollment starts its own one
+		c.endSession(ctx)
+		return err
+	}
 	if err != nil {
 		return err
 	}
@@ -101,28 +186,38 @@ func (c *ClientUseCase) LoginUser(ctx context.Context, userName, password, passp
 		if err != nil {
 			return err
 		}
+	} else if err != nil {
+		return err
 	}
-
-	c.Config.Passphrase = passphrase
-	c.Config.User = userName
-	c.Config.Token = token
-
-	dbLastServerUpdated, err := c.Storage.GetLastServerUpdated(userName)
+	// server has a verifier of user now, password of user is never sent in clear from this device again
+	err = c.Storage.SetUsesVerifier(userName)
 	if err != nil {
 		return err
 	}
-	if dbLastServerUpdated < c.Config.LastServerUpdated {
-		err = c.FullSync(ctx)
-		if err != nil {
-			return err
-		}
-	}
 
-	err = c.Storage.UpdateLastServerUpdated(userName, c.Config.LastServerUpdated)
+	err = c.unlock(ctx, userName, passphrase)
 	if err != nil {
+		// data can't be decrypted without the right passphrase, so user is not logged in
+		c.Config.UpdateSession(func(s *config.Session) {
+			s.Token = ""
+			s.RefreshToken = ""
+			s.TokenExpires = 0
+		})
 		return err
 	}
-	return nil
+
+	c.Config.UpdateSession(func(s *config.Session) {
+		s.User = userName
+		s.Passphrase = passphrase
+		s.Token = token
+	})
+
+	// items rejected by sync are skipped, they don't keep user from logging in
+	err = c.Sync(ctx)
+	if err != nil && !errors.Is(err, clienterrors.Undecryptable) {
+		return err
+	}
+	return c.migrateLegacyData(ctx)
 }
 
 // ChangePassword changes user password
@@ -143,145 +238,287 @@ func (c *ClientUseCase) ChangePassword(ctx context.Context, userName, password,
 	if err != nil {
 		return err
 	}
-	c.Config.User = userName
-	c.Config.Token = token
+	c.Config.UpdateSession(func(s *config.Session) {
+		s.User = userName
+		s.Token = token
+	})
 	return nil
 }
 
 // SaveData saves data
+// data is saved locally and pushed to server through outbox,
+// if server is unavailable the change stays in outbox and no error is returned
 func (c *ClientUseCase) SaveData(ctx context.Context, data models.Data) error {
-	if c.Config.Token == "" {
+	if c.Config.AccessToken() == "" {
 		return fmt.Errorf("user not logged in")
 	}
-
-	storedData, err := data.EncryptData([]byte(c.Config.Passphrase))
-	if err != nil {
-		return err
+	// uuid is generated on client, so local and remote copies of data have the same identity
+	if data.UUID == "" {
+		data.UUID = uuid.New().String()
 	}
-	err = c.Storage.CreateData(c.Config.User, *storedData)
+	// new data has the first version both locally and on server
+	data.Version = 1
+
+	storedData, err := data.EncryptData(c.keyring)
 	if err != nil {
 		return err
 	}
-	secretData := &pb.SecretData{
-		Uuid:  storedData.UUID,
-		Meta:  storedData.Meta,
-		Type:  storedData.DataType,
-		Value: storedData.EncryptedData,
-	}
-	err = c.Transporter.SaveSecret(ctx, secretData)
+	err = c.Storage.CreateData(c.Config.UserName(), *storedData)
 	if err != nil {
 		return err
 	}
-	err = c.Storage.UpdateLastServerUpdated(c.Config.User, c.Config.LastServerUpdated)
+	err = c.Storage.AddToOutbox(c.Config.UserName(), models.OutboxEntry{
+		Operation: models.OutboxOperations.Create,
+		Data:      *storedData,
+	})
 	if err != nil {
 		return err
 	}
-	return nil
+	return c.push(ctx)
 }
 
 // ChangeData changes data
+// data.Version is the version the change is based on, if data was changed on server since then,
+// the conflict is resolved by configured policy, with ask policy clienterrors.ConflictError is returned
+// and the conflict waits for user decision instead of overwriting server copy
 func (c *ClientUseCase) ChangeData(ctx context.Context, data models.Data) error {
-	if c.Config.Token == "" {
+	if c.Config.AccessToken() == "" {
 		return fmt.Errorf("user not logged in")
 	}
-	storedData, err := data.EncryptData([]byte(c.Config.Passphrase))
-	if err != nil {
-		return err
-	}
-	err = c.Storage.UpdateData(c.Config.User, *storedData)
+	storedData, err := data.EncryptData(c.keyring)
 	if err != nil {
 		return err
 	}
-	secretData := &pb.SecretData{
-		Uuid:  storedData.UUID,
-		Meta:  storedData.Meta,
-		Type:  storedData.DataType,
-		Value: storedData.EncryptedData,
-	}
-	err = c.Transporter.ChangeSecret(ctx, secretData)
+	// server increments version on every change, local copy gets the same version in advance
+	storedData.Version = data.Version + 1
+	err = c.Storage.UpdateData(c.Config.UserName(), *storedData)
 	if err != nil {
 		return err
 	}
-	err = c.Storage.UpdateLastServerUpdated(c.Config.User, c.Config.LastServerUpdated)
+	err = c.Storage.AddToOutbox(c.Config.UserName(), models.OutboxEntry{
+		Operation:       models.OutboxOperations.Change,
+		Data:            *storedData,
+		ExpectedVersion: data.Version,
+	})
 	if err != nil {
 		return err
 	}
-	return nil
+	return c.push(ctx)
 }
 
 // DeleteData deletes data
+// like ChangeData, data is not deleted on server if it was changed there after data.Version
 func (c *ClientUseCase) DeleteData(ctx context.Context, data models.Data) error {
-	if c.Config.Token == "" {
+	if c.Config.AccessToken() == "" {
 		return fmt.Errorf("user not logged in")
 	}
 	var storedData models.StoredData
 	storedData.UUID = data.UUID
-	err := c.Storage.DeleteData(c.Config.User, storedData)
+	storedData.DataType = string(data.DataType)
+	err := c.Storage.DeleteData(c.Config.UserName(), storedData)
 	if err != nil {
 		return err
 	}
-	err = c.Transporter.DeleteSecret(ctx, data.UUID)
+	err = c.Storage.AddToOutbox(c.Config.UserName(), models.OutboxEntry{
+		Operation:       models.OutboxOperations.Delete,
+		Data:            storedData,
+		ExpectedVersion: data.Version,
+	})
 	if err != nil {
 		return err
 	}
-	err = c.Storage.UpdateLastServerUpdated(c.Config.User, c.Config.LastServerUpdated)
-	if err != nil {
-		return err
-	}
-	return nil
+	return c.push(ctx)
 }
 
 // GetDataByType gets data by type
+// items which can't be decrypted are skipped, the others are returned with *clienterrors.UndecryptableError
 func (c *ClientUseCase) GetDataByType(dataType string) ([]models.Data, error) {
-	if c.Config.Token == "" {
+	if c.Config.AccessToken() == "" {
 		return nil, fmt.Errorf("user not logged in")
 	}
-	storedData, err := c.Storage.GetData(c.Config.User)
+	storedData, err := c.Storage.GetData(c.Config.UserName())
 	if err != nil {
 		return nil, err
 	}
 	var data []models.Data
+	undecryptable := make(map[string]string)
 	for _, d := range storedData {
 		if d.DataType == dataType {
-			decryptData, err := d.DecryptData([]byte(c.Config.Passphrase))
+			decryptData, err := d.DecryptData(c.keyring)
 			if err != nil {
-				return nil, err
+				undecryptable[d.UUID] = err.Error()
+				continue
 			}
 			data = append(data, *decryptData)
 		}
 	}
+	if len(undecryptable) > 0 {
+		return data, &clienterrors.UndecryptableError{Items: undecryptable}
+	}
 	return data, nil
 }
 
-// FullSync does full sync with remote server
-func (c *ClientUseCase) FullSync(ctx context.Context) error {
-	if c.Config.Token == "" {
+// FindByMeta finds data with the given meta
+// meta is compared by blinded search token, so search is case insensitive and meta is not decrypted for it
+func (c *ClientUseCase) FindByMeta(meta string) ([]models.Data, error) {
+	if c.Config.AccessToken() == "" {
+		return nil, fmt.Errorf("user not logged in")
+	}
+	searchToken, err := c.keyring.SearchToken(meta)
+	if err != nil {
+		return nil, err
+	}
+	storedData, err := c.Storage.FindByMeta(c.Config.UserName(), searchToken)
+	if err != nil {
+		return nil, err
+	}
+	var data []models.Data
+	for _, d := range storedData {
+		decryptData, err := d.DecryptData(c.keyring)
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, *decryptData)
+	}
+	return data, nil
+}
+
+// Sync is a two-way sync with remote server
+// local changes from outbox are pushed first, conflicts with server changes are resolved by configured policy,
+// then changes made on server after the last applied revision are applied locally
+// data in legacy format is rejected once local vault is migrated, it is reported with *clienterrors.UndecryptableError
+func (c *ClientUseCase) Sync(ctx context.Context) error {
+	if c.Config.AccessToken() == "" {
 		return fmt.Errorf("user not logged in")
 	}
-	err := c.Storage.DeleteAllData(c.Config.User)
+	// local changes are pushed first, otherwise they would be overwritten by server copies
+	flushErr := c.Flush(ctx)
+	if errors.Is(flushErr, clienterrors.ServerUnavailable) {
+		return flushErr
+	}
+	pending, err := c.pendingUUIDs()
+	if err != nil {
+		return err
+	}
+	conflicts, err := c.Storage.GetConflicts(c.Config.UserName())
+	if err != nil {
+		return err
+	}
+	waiting := make(map[string]bool, len(conflicts))
+	for _, conflict := range conflicts {
+		waiting[conflict.UUID] = true
+	}
+	lastRevision, err := c.Storage.GetLastRevision(c.Config.UserName())
 	if err != nil {
-		fmt.Println(err)
 		return err
 	}
-	secrets, err := c.Transporter.ListSecrets(ctx)
+	migrated, err := c.Storage.IsLegacyMigrated(c.Config.UserName())
 	if err != nil {
 		return err
 	}
+	rejected := make(map[string]string)
+	// changes come in pages, revision of each applied page is saved, so an interrupted sync goes on from it
+	for {
+		secrets, revision, more, err := c.Transporter.SyncChanges(ctx, lastRevision)
+		if err != nil {
+			return err
+		}
+		err = c.applyChanges(secrets, waiting, pending, migrated, rejected)
+		if err != nil {
+			return err
+		}
+		err = c.Storage.UpdateLastRevision(c.Config.UserName(), revision)
+		if err != nil {
+			return err
+		}
+		if !more || revision <= lastRevision {
+			break
+		}
+		lastRevision = revision
+	}
+	if len(rejected) > 0 {
+		return &clienterrors.UndecryptableError{Items: rejected}
+	}
+	return flushErr
+}
+
+// applyChanges applies changes made on server to local storage
+// waiting are uuids of conflicts waiting for user decision, pending are uuids of data with changes in outbox
+func (c *ClientUseCase) applyChanges(secrets []*pb.SecretData, waiting, pending map[string]bool,
+	migrated bool, rejected map[string]string) error {
+	var err error
 	for _, secret := range secrets {
 		storedData := models.StoredData{
 			UUID:          secret.Uuid,
 			Meta:          secret.Meta,
 			DataType:      secret.Type,
+			Version:       secret.Version,
 			EncryptedData: secret.Value,
 		}
-		err = c.Storage.CreateData(c.Config.User, storedData)
+		// data in legacy format has no context, so it is not accepted once local vault is migrated,
+		// local copy is kept
+		if migrated && !secret.Deleted && storedData.IsLegacy() {
+			rejected[secret.Uuid] = models.ErrLegacyEnvelope.Error()
+			continue
+		}
+		// conflict waiting for user decision is resolved against the newest server copy
+		if waiting[secret.Uuid] {
+			err = c.Storage.SaveConflict(c.Config.UserName(), models.Conflict{
+				UUID:          secret.Uuid,
+				Server:        storedData,
+				ServerDeleted: secret.Deleted,
+			})
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		// data with changes still waiting in outbox keeps its local copy,
+		// the conflict if any is detected by version when the changes are pushed
+		if pending[secret.Uuid] {
+			continue
+		}
+		if secret.Deleted {
+			err = c.Storage.DeleteData(c.Config.UserName(), storedData)
+		} else {
+			err = c.Storage.UpsertData(c.Config.UserName(), storedData)
+		}
 		if err != nil {
 			return err
 		}
 	}
-	err = c.Storage.UpdateLastServerUpdated(c.Config.User, c.Config.LastServerUpdated)
+	return nil
+}
+
+// FullSync does full sync with remote server
+// local data is dropped and downloaded again from the first revision,
+// so it is refused while some local changes are not pushed to server
+func (c *ClientUseCase) FullSync(ctx context.Context) error {
+	if c.Config.AccessToken() == "" {
+		return fmt.Errorf("user not logged in")
+	}
+	flushErr := c.Flush(ctx)
+	if errors.Is(flushErr, clienterrors.ServerUnavailable) {
+		return flushErr
+	}
+	pending, err := c.PendingChanges()
 	if err != nil {
 		return err
 	}
-	return nil
+	if len(pending) > 0 {
+		return clienterrors.PendingChanges
+	}
+	err = c.Storage.DeleteAllData(c.Config.UserName())
+	if err != nil {
+		return err
+	}
+	err = c.Storage.UpdateLastRevision(c.Config.UserName(), 0)
+	if err != nil {
+		return err
+	}
+	err = c.Sync(ctx)
+	if err != nil {
+		return err
+	}
+	return flushErr
 }
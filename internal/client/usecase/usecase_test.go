@@ -1,20 +1,49 @@
 package usecase
 
 import (
+	"bytes"
 	"context"
-	"database/sql"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
 	"errors"
+	"fmt"
+	"io"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 
+	"github.com/h2p2f/dedicated-vault/internal/client/clienterrors"
 	"github.com/h2p2f/dedicated-vault/internal/client/config"
 	"github.com/h2p2f/dedicated-vault/internal/client/models"
 	"github.com/h2p2f/dedicated-vault/internal/client/usecase/mocks"
 	pb "github.com/h2p2f/dedicated-vault/proto"
 )
 
+// testKeyring returns keyring with cheap key derivation, so tests are fast
+func testKeyring() *models.Keyring {
+	keyring := models.NewKeyring("testuser", "testpassphrase", []byte("testsalt12345678"), testKDFParams)
+	keyring.SetMasterKey(testMasterKey)
+	return keyring
+}
+
+// testWrappedKey returns test master key as it is saved on server
+func testWrappedKey(t *testing.T) *models.WrappedKey {
+	keyring := testKeyring()
+	key, err := keyring.WrapKey(testMasterKey)
+	assert.NoError(t, err)
+	keyCheck, err := keyring.KeyCheck()
+	assert.NoError(t, err)
+	return &models.WrappedKey{Key: key, KeyCheck: keyCheck, Version: 1}
+}
+
+var (
+	testKDFParams = models.KDFParams{Time: 1, Memory: 8 * 1024, Threads: 1}
+	testMasterKey = []byte("testmasterkey0123456789012345678")
+)
+
 func TestClientUseCase_CreateUser(t *testing.T) {
 
 	tests := []struct {
@@ -25,7 +54,6 @@ func TestClientUseCase_CreateUser(t *testing.T) {
 		createUserError          error
 		registerToken            string
 		registerError            error
-		updateLastServerErr      error
 		expectedConfigPassphrase string
 		expectedConfigToken      string
 		expectedConfigUser       string
@@ -41,7 +69,6 @@ func TestClientUseCase_CreateUser(t *testing.T) {
 			createUserError:          nil,
 			registerToken:            "testtoken",
 			registerError:            nil,
-			updateLastServerErr:      nil,
 			expectedConfigPassphrase: "testpassphrase",
 			expectedConfigToken:      "testtoken",
 			expectedConfigUser:       "testuser",
@@ -57,7 +84,6 @@ func TestClientUseCase_CreateUser(t *testing.T) {
 			createUserError:          errors.New("storage error"),
 			registerToken:            "",
 			registerError:            nil,
-			updateLastServerErr:      nil,
 			expectedConfigPassphrase: "",
 			expectedConfigToken:      "",
 			expectedConfigUser:       "",
@@ -73,7 +99,6 @@ func TestClientUseCase_CreateUser(t *testing.T) {
 			createUserError:          nil,
 			registerToken:            "",
 			registerError:            errors.New("transporter error"),
-			updateLastServerErr:      nil,
 			expectedConfigPassphrase: "",
 			expectedConfigToken:      "",
 			expectedConfigUser:       "",
@@ -81,22 +106,6 @@ func TestClientUseCase_CreateUser(t *testing.T) {
 			expectedTransporterErr:   errors.New("transporter error"),
 			expectedErr:              errors.New("transporter error"),
 		},
-		{
-			name:                     "Error updating last server updated time",
-			userName:                 "testuser",
-			password:                 "testpassword",
-			passphrase:               "testpassphrase",
-			createUserError:          nil,
-			registerToken:            "testtoken",
-			registerError:            nil,
-			updateLastServerErr:      errors.New("storage error"),
-			expectedConfigPassphrase: "testpassphrase",
-			expectedConfigToken:      "testtoken",
-			expectedConfigUser:       "testuser",
-			expectedStorageErr:       errors.New("storage error"),
-			expectedTransporterErr:   nil,
-			expectedErr:              errors.New("storage error"),
-		},
 	}
 
 	// Run test cases
@@ -105,9 +114,6 @@ func TestClientUseCase_CreateUser(t *testing.T) {
 			// Create mock objects
 			mockStorage := mocks.NewStorager(t)
 			mockStorage.On("CreateUser", tt.userName).Return(tt.createUserError)
-			if tt.createUserError == nil && tt.expectedTransporterErr == nil {
-				mockStorage.On("UpdateLastServerUpdated", tt.userName, int64(0)).Return(tt.updateLastServerErr)
-			}
 			mockTransport := mocks.NewTransporter(t)
 			if tt.createUserError == nil {
 				mockTransport.On("Register", context.Background(), &pb.User{
@@ -115,7 +121,21 @@ func TestClientUseCase_CreateUser(t *testing.T) {
 					Password: tt.password,
 				}).Return(tt.registerToken, tt.registerError)
 			}
+			if tt.createUserError == nil && tt.registerError == nil {
+				mockStorage.On("SetUsesVerifier", tt.userName).Return(nil)
+				mockStorage.On("GetUserSalt", tt.userName).Return(nil, nil)
+				mockStorage.On("SetUserSalt", tt.userName, mock.MatchedBy(func(salt []byte) bool {
+					return len(salt) == models.SaltSize
+				})).Return(nil)
+				mockTransport.On("GetMasterKey", context.Background()).Return(&models.WrappedKey{}, nil)
+				mockTransport.On("SetMasterKey", context.Background(), mock.MatchedBy(func(k models.WrappedKey) bool {
+					return len(k.Key) != 0 && len(k.KeyCheck) != 0
+				}), int64(0)).Return(int64(1), nil)
+				mockStorage.On("GetUserKeyCheck", tt.userName).Return(nil, nil)
+				mockStorage.On("SetUserKeyCheck", tt.userName, mock.Anything).Return(nil)
+			}
 			testConfig := config.NewClientConfig()
+			testConfig.KDFTime, testConfig.KDFMemory, testConfig.KDFThreads = testKDFParams.Time, testKDFParams.Memory, testKDFParams.Threads
 			// Create client use case
 			clientUseCase := &ClientUseCase{
 				Config:      testConfig,
@@ -131,7 +151,7 @@ func TestClientUseCase_CreateUser(t *testing.T) {
 			assert.Equal(t, tt.expectedConfigToken, clientUseCase.Config.Token)
 			assert.Equal(t, tt.expectedConfigUser, clientUseCase.Config.User)
 			assert.Equal(t, tt.expectedErr, err)
-			if tt.expectedStorageErr == nil && tt.updateLastServerErr == nil {
+			if tt.expectedStorageErr == nil {
 				_, transportError := clientUseCase.Transporter.Register(context.Background(), &pb.User{
 					Name:     tt.userName,
 					Password: tt.password,
@@ -145,159 +165,146 @@ func TestClientUseCase_CreateUser(t *testing.T) {
 func TestClientUseCase_LoginUser(t *testing.T) {
 
 	tests := []struct {
-		name                    string
-		userName                string
-		password                string
-		passphrase              string
-		getUserIDError          error
-		createUserError         error
-		loginToken              string
-		loginError              error
-		configLastServerUpdated int64
-		getLastServerUpdated    int64
-		fullSyncError           error
-		updateLastServerErr     error
-		expectedConfigPass      string
-		expectedConfigToken     string
-		expectedConfigUser      string
-		expectedStorageErr      error
-		expectedTransporterErr  error
+		name                  string
+		userName              string
+		password              string
+		passphrase            string
+		code                  string
+		getUserIDError        error
+		createUserError       error
+		loginToken            string
+		loginError            error
+		lastRevision          int64
+		syncError             error
+		updateLastRevisionErr error
+		replacedKey           bool
+		expectedConfigPass    string
+		expectedConfigToken   string
+		expectedConfigUser    string
+		expectedErr           error
 	}{
 		{
-			name:                    "Successful login user with blank user ID",
-			userName:                "testuser",
-			password:                "testpassword",
-			passphrase:              "testpassphrase",
-			getUserIDError:          sql.ErrNoRows,
-			createUserError:         nil,
-			loginToken:              "testtoken",
-			loginError:              nil,
-			configLastServerUpdated: 10,
-			getLastServerUpdated:    10,
-			fullSyncError:           nil,
-			updateLastServerErr:     nil,
-			expectedConfigPass:      "testpassphrase",
-			expectedConfigToken:     "testtoken",
-			expectedConfigUser:      "testuser",
-			expectedStorageErr:      nil,
-			expectedTransporterErr:  nil,
-		},
-		{
-			name:                    "Successful login user with existing user ID",
-			userName:                "testuser",
-			password:                "testpassword",
-			passphrase:              "testpassphrase",
-			getUserIDError:          nil,
-			createUserError:         nil,
-			loginToken:              "testtoken",
-			loginError:              nil,
-			configLastServerUpdated: 10,
-			getLastServerUpdated:    10,
-			fullSyncError:           nil,
-			updateLastServerErr:     nil,
-			expectedConfigPass:      "testpassphrase",
-			expectedConfigToken:     "testtoken",
-			expectedConfigUser:      "testuser",
-			expectedStorageErr:      nil,
-			expectedTransporterErr:  nil,
-		},
-		{
-			name:                    "Error logging in user with transporter",
-			userName:                "testuser",
-			password:                "testpassword",
-			passphrase:              "testpassphrase",
-			getUserIDError:          nil,
-			createUserError:         nil,
-			loginToken:              "",
-			loginError:              errors.New("transporter error"),
-			configLastServerUpdated: 10,
-			getLastServerUpdated:    10,
-			fullSyncError:           nil,
-			updateLastServerErr:     nil,
-			expectedConfigPass:      "",
-			expectedConfigToken:     "",
-			expectedConfigUser:      "",
-			expectedStorageErr:      errors.New("transporter error"),
-			expectedTransporterErr:  nil,
-		},
-		{
-			name:                    "Error creating user in storage",
-			userName:                "testuser",
-			password:                "testpassword",
-			passphrase:              "testpassphrase",
-			getUserIDError:          errors.New("storage error"),
-			createUserError:         errors.New("storage error"),
-			loginToken:              "",
-			loginError:              nil,
-			configLastServerUpdated: 10,
-			getLastServerUpdated:    10,
-			fullSyncError:           nil,
-			updateLastServerErr:     nil,
-			expectedConfigPass:      "",
-			expectedConfigToken:     "",
-			expectedConfigUser:      "",
-			expectedStorageErr:      errors.New("storage error"),
-			expectedTransporterErr:  nil,
-		},
-		{
-			name:                    "Error getting last server updated time from storage",
-			userName:                "testuser",
-			password:                "testpassword",
-			passphrase:              "testpassphrase",
-			getUserIDError:          sql.ErrNoRows,
-			createUserError:         nil,
-			loginToken:              "testtoken",
-			loginError:              nil,
-			configLastServerUpdated: 10,
-			getLastServerUpdated:    10,
-			fullSyncError:           nil,
-			updateLastServerErr:     errors.New("storage error"),
-			expectedConfigPass:      "testpassphrase",
-			expectedConfigToken:     "testtoken",
-			expectedConfigUser:      "testuser",
-			expectedStorageErr:      errors.New("storage error"),
-			expectedTransporterErr:  nil,
-		},
-		{
-			name:                    "Error during full sync",
-			userName:                "testuser",
-			password:                "testpassword",
-			passphrase:              "testpassphrase",
-			getUserIDError:          sql.ErrNoRows,
-			createUserError:         nil,
-			loginToken:              "testtoken",
-			loginError:              nil,
-			configLastServerUpdated: 10,
-			getLastServerUpdated:    0,
-			fullSyncError:           errors.New("sync error"),
-			updateLastServerErr:     nil,
-			expectedConfigPass:      "testpassphrase",
-			expectedConfigToken:     "testtoken",
-			expectedConfigUser:      "testuser",
-			expectedStorageErr:      errors.New("sync error"),
-			expectedTransporterErr:  nil,
-		},
-		{
-			name:                    "Error updating last server updated time",
-			userName:                "testuser",
-			password:                "testpassword",
-			passphrase:              "testpassphrase",
-			getUserIDError:          nil,
-			createUserError:         nil,
-			loginToken:              "testtoken",
-			loginError:              nil,
-			configLastServerUpdated: 10,
-			getLastServerUpdated:    10,
-			fullSyncError:           nil,
-			updateLastServerErr:     errors.New("storage error"),
-			expectedConfigPass:      "testpassphrase",
-			expectedConfigToken:     "testtoken",
-			expectedConfigUser:      "testuser",
-			expectedStorageErr:      errors.New("storage error"),
-			expectedTransporterErr:  nil,
+			name:                "Successful login user with blank user ID",
+			userName:            "testuser",
+			password:            "testpassword",
+			passphrase:          "testpassphrase",
+			getUserIDError:      clienterrors.UserNotFound,
+			loginToken:          "testtoken",
+			lastRevision:        0,
+			expectedConfigPass:  "testpassphrase",
+			expectedConfigToken: "testtoken",
+			expectedConfigUser:  "testuser",
+			expectedErr:         nil,
+		},
+		{
+			name:                "Successful login user with existing user ID",
+			userName:            "testuser",
+			password:            "testpassword",
+			passphrase:          "testpassphrase",
+			code:                "123456",
+			loginToken:          "testtoken",
+			lastRevision:        10,
+			expectedConfigPass:  "testpassphrase",
+			expectedConfigToken: "testtoken",
+			expectedConfigUser:  "testuser",
+			expectedErr:         nil,
+		},
+		{
+			name:        "Error logging in user with transporter",
+			userName:    "testuser",
+			password:    "testpassword",
+			passphrase:  "testpassphrase",
+			loginError:  errors.New("transporter error"),
+			expectedErr: errors.New("transporter error"),
+		},
+		{
+			name:        "Two-factor code is required",
+			userName:    "testuser",
+			password:    "testpassword",
+			passphrase:  "testpassphrase",
+			loginError:  clienterrors.TwoFactorRequired,
+			expectedErr: clienterrors.TwoFactorRequired,
+		},
+		{
+			name:        "Enrollment of two-factor authentication is required",
+			userName:    "testuser",
+			password:    "testpassword",
+			passphrase:  "testpassphrase",
+			loginError:  clienterrors.TwoFactorEnrollmentRequired,
+			expectedErr: clienterrors.TwoFactorEnrollmentRequired,
+		},
+		{
+			name:            "Error creating user in storage",
+			userName:        "testuser",
+			password:        "testpassword",
+			passphrase:      "testpassphrase",
+			getUserIDError:  clienterrors.UserNotFound,
+			createUserError: errors.New("storage error"),
+			loginToken:      "testtoken",
+			expectedErr:     errors.New("storage error"),
+		},
+		{
+			name:           "Error getting user ID from storage",
+			userName:       "testuser",
+			password:       "testpassword",
+			passphrase:     "testpassphrase",
+			getUserIDError: errors.New("storage error"),
+			loginToken:     "testtoken",
+			expectedErr:    errors.New("storage error"),
+		},
+		{
+			name:                "Error during sync",
+			userName:            "testuser",
+			password:            "testpassword",
+			passphrase:          "testpassphrase",
+			loginToken:          "testtoken",
+			lastRevision:        10,
+			syncError:           errors.New("sync error"),
+			expectedConfigPass:  "testpassphrase",
+			expectedConfigToken: "testtoken",
+			expectedConfigUser:  "testuser",
+			expectedErr:         errors.New("sync error"),
+		},
+		{
+			name:        "Wrong passphrase",
+			userName:    "testuser",
+			password:    "testpassword",
+			passphrase:  "wrongpassphrase",
+			loginToken:  "testtoken",
+			expectedErr: clienterrors.WrongPassphrase,
+		},
+		{
+			name:        "Master key on server differs from the key used on this device",
+			userName:    "testuser",
+			password:    "testpassword",
+			passphrase:  "testpassphrase",
+			loginToken:  "testtoken",
+			replacedKey: true,
+			expectedErr: clienterrors.KeyMismatch,
+		},
+		{
+			name:                  "Error updating last revision",
+			userName:              "testuser",
+			password:              "testpassword",
+			passphrase:            "testpassphrase",
+			loginToken:            "testtoken",
+			lastRevision:          10,
+			updateLastRevisionErr: errors.New("storage error"),
+			expectedConfigPass:    "testpassphrase",
+			expectedConfigToken:   "testtoken",
+			expectedConfigUser:    "testuser",
+			expectedErr:           errors.New("storage error"),
 		},
 	}
 
+	wrappedKey := testWrappedKey(t)
+	localKeyCheck, err := testKeyring().KeyCheck()
+	assert.NoError(t, err)
+	otherKeyring := testKeyring()
+	otherKeyring.SetMasterKey([]byte("othermasterkey012345678901234567"))
+	otherKeyCheck, err := otherKeyring.KeyCheck()
+	assert.NoError(t, err)
+
 	// Run test cases
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -310,43 +317,115 @@ func TestClientUseCase_LoginUser(t *testing.T) {
 				Storage:     mockStorage,
 				Transporter: mockTransport,
 			}
-			clientUseCase.Config.LastServerUpdated = tt.configLastServerUpdated
 
 			mockTransport.On("Login", context.Background(), &pb.User{
 				Name:     tt.userName,
 				Password: tt.password,
-			}).Return(tt.loginToken, tt.loginError)
+			}, tt.code).Return(tt.loginToken, tt.loginError)
 
 			if tt.loginError == nil {
-				mockStorage.On("GetUserID", tt.userName).Return(int64(0), tt.getUserIDError)
-				if tt.getUserIDError != nil {
-					if errors.Is(tt.getUserIDError, sql.ErrNoRows) {
-						mockStorage.On("CreateUser", tt.userName).Return(tt.createUserError)
+				mockStorage.On("GetUserID", tt.userName).Return(int64(1), tt.getUserIDError)
+				if errors.Is(tt.getUserIDError, clienterrors.UserNotFound) {
+					mockStorage.On("CreateUser", tt.userName).Return(tt.createUserError)
+				}
+				if (tt.getUserIDError == nil || errors.Is(tt.getUserIDError, clienterrors.UserNotFound)) && tt.createUserError == nil {
+					mockStorage.On("SetUsesVerifier", tt.userName).Return(nil)
+					mockStorage.On("GetUserSalt", tt.userName).Return([]byte("testsalt12345678"), nil)
+					mockTransport.On("GetMasterKey", context.Background()).Return(wrappedKey, nil)
+				}
+				if (tt.getUserIDError == nil || errors.Is(tt.getUserIDError, clienterrors.UserNotFound)) &&
+					tt.createUserError == nil && !errors.Is(tt.expectedErr, clienterrors.WrongPassphrase) {
+					if tt.replacedKey {
+						mockStorage.On("GetUserKeyCheck", tt.userName).Return(otherKeyCheck, nil)
+					} else {
+						mockStorage.On("GetUserKeyCheck", tt.userName).Return(localKeyCheck, nil)
 					}
 				}
-				if tt.createUserError == nil {
-					mockStorage.On("GetLastServerUpdated", tt.userName).Return(tt.getLastServerUpdated, nil)
-					if tt.getLastServerUpdated < clientUseCase.Config.LastServerUpdated {
-						mockStorage.On("DeleteAllData", tt.userName).Return(nil)
-						var protoData []*pb.SecretData
-						mockTransport.On("ListSecrets", context.Background()).Return(protoData, tt.fullSyncError)
-						if tt.fullSyncError == nil {
-							mockStorage.On("UpdateLastServerUpdated", tt.userName, int64(0)).Return(tt.updateLastServerErr)
-						}
+				if (tt.getUserIDError == nil || errors.Is(tt.getUserIDError, clienterrors.UserNotFound)) &&
+					tt.createUserError == nil && !errors.Is(tt.expectedErr, clienterrors.WrongPassphrase) && !tt.replacedKey {
+					mockStorage.On("GetOutbox", tt.userName).Return(nil, nil)
+					mockStorage.On("GetConflicts", tt.userName).Return(nil, nil)
+					mockStorage.On("GetLastRevision", tt.userName).Return(tt.lastRevision, nil)
+					mockStorage.On("IsLegacyMigrated", tt.userName).Return(false, nil)
+					var protoData []*pb.SecretData
+					mockTransport.On("SyncChanges", context.Background(), tt.lastRevision).Return(protoData, tt.lastRevision, false, tt.syncError)
+					if tt.syncError == nil {
+						mockStorage.On("UpdateLastRevision", tt.userName, tt.lastRevision).Return(tt.updateLastRevisionErr)
 					}
-					if tt.fullSyncError == nil {
-						mockStorage.On("UpdateLastServerUpdated", tt.userName, int64(10)).Return(tt.updateLastServerErr)
+					if tt.syncError == nil && tt.updateLastRevisionErr == nil {
+						mockStorage.On("GetData", tt.userName).Return([]models.StoredData{}, nil)
+						mockStorage.On("SetLegacyMigrated", tt.userName).Return(nil)
 					}
 				}
 			}
 			// Call function
-			err := clientUseCase.LoginUser(context.Background(), tt.userName, tt.password, tt.passphrase)
+			err := clientUseCase.LoginUser(context.Background(), tt.userName, tt.password, tt.passphrase, tt.code)
 
 			// Check output and error
 			assert.Equal(t, tt.expectedConfigPass, clientUseCase.Config.Passphrase)
 			assert.Equal(t, tt.expectedConfigToken, clientUseCase.Config.Token)
 			assert.Equal(t, tt.expectedConfigUser, clientUseCase.Config.User)
-			assert.Equal(t, tt.expectedStorageErr, err)
+			assert.Equal(t, tt.expectedErr, err)
+		})
+	}
+}
+
+func TestClientUseCase_UpgradePassword(t *testing.T) {
+	user := &pb.User{Name: "testuser", Password: "testpassword"}
+	tests := []struct {
+		name         string
+		upgrade      bool
+		usesVerifier bool
+		upgradeErr   error
+		wantUpgrade  bool
+		wantErr      error
+	}{
+		{
+			name:    "login doesn't send password in clear",
+			wantErr: clienterrors.PasswordUpgradeRequired,
+		},
+		{
+			name:         "wrong password of user with verifier",
+			usesVerifier: true,
+			wantErr:      clienterrors.WrongPassword,
+		},
+		{
+			name:         "upgrade is refused for user with verifier",
+			upgrade:      true,
+			usesVerifier: true,
+			wantErr:      clienterrors.PasswordUpgradeRefused,
+		},
+		{
+			name:        "upgrade of user without verifier",
+			upgrade:     true,
+			upgradeErr:  clienterrors.WrongPassword,
+			wantUpgrade: true,
+			wantErr:     clienterrors.WrongPassword,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStorage := mocks.NewStorager(t)
+			mockTransport := mocks.NewTransporter(t)
+			clientUseCase := &ClientUseCase{
+				Config:      config.NewClientConfig(),
+				Storage:     mockStorage,
+				Transporter: mockTransport,
+			}
+			mockTransport.On("Login", context.Background(), user, "").Return("", clienterrors.WrongPassword)
+			mockStorage.On("UsesVerifier", "testuser").Return(tt.usesVerifier, nil)
+			if tt.wantUpgrade {
+				mockTransport.On("UpgradePassword", context.Background(), user, "").Return("", tt.upgradeErr)
+			}
+
+			var err error
+			if tt.upgrade {
+				err = clientUseCase.UpgradePassword(context.Background(), "testuser", "testpassword", "testpassphrase", "")
+			} else {
+				err = clientUseCase.LoginUser(context.Background(), "testuser", "testpassword", "testpassphrase", "")
+			}
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.Empty(t, clientUseCase.Config.AccessToken())
 		})
 	}
 }
@@ -443,58 +522,59 @@ func TestClientUseCase_SaveData(t *testing.T) {
 	}
 
 	tests := []struct {
-		name                  string
-		user                  string
-		token                 string
-		createError           error
-		saveSecretError       error
-		updateLastServerError error
-		expectedError         error
+		name            string
+		user            string
+		token           string
+		createError     error
+		outboxError     error
+		saveSecretError error
+		expectedError   error
 	}{
 		{
-			name:                  "Successful save data",
-			user:                  "testuser",
-			token:                 "testtoken",
-			createError:           nil,
-			saveSecretError:       nil,
-			updateLastServerError: nil,
-			expectedError:         nil,
+			name:            "Successful save data",
+			user:            "testuser",
+			token:           "testtoken",
+			createError:     nil,
+			saveSecretError: nil,
+			expectedError:   nil,
+		},
+		{
+			name:            "Error creating data in storage",
+			user:            "testuser",
+			token:           "testtoken",
+			createError:     errors.New("storage error"),
+			saveSecretError: nil,
+			expectedError:   errors.New("storage error"),
 		},
 		{
-			name:                  "Error creating data in storage",
-			user:                  "testuser",
-			token:                 "testtoken",
-			createError:           errors.New("storage error"),
-			saveSecretError:       nil,
-			updateLastServerError: nil,
-			expectedError:         errors.New("storage error"),
+			name:          "Error adding change to outbox",
+			user:          "testuser",
+			token:         "testtoken",
+			outboxError:   errors.New("storage error"),
+			expectedError: errors.New("storage error"),
 		},
 		{
-			name:                  "Error saving secret with transporter",
-			user:                  "testuser",
-			token:                 "testtoken",
-			createError:           nil,
-			saveSecretError:       errors.New("transporter error"),
-			updateLastServerError: nil,
-			expectedError:         errors.New("transporter error"),
+			name:            "Server unavailable keeps change in outbox",
+			user:            "testuser",
+			token:           "testtoken",
+			saveSecretError: fmt.Errorf("%w: connection refused", clienterrors.ServerUnavailable),
+			expectedError:   nil,
 		},
 		{
-			name:                  "Error updating last server updated time",
-			user:                  "testuser",
-			token:                 "testtoken",
-			createError:           nil,
-			saveSecretError:       nil,
-			updateLastServerError: errors.New("storage error"),
-			expectedError:         errors.New("storage error"),
+			name:            "Error saving secret with transporter",
+			user:            "testuser",
+			token:           "testtoken",
+			createError:     nil,
+			saveSecretError: errors.New("transporter error"),
+			expectedError:   errors.New("create testmeta: transporter error"),
 		},
 		{
-			name:                  "Error user not logged in",
-			user:                  "",
-			token:                 "",
-			createError:           nil,
-			saveSecretError:       nil,
-			updateLastServerError: nil,
-			expectedError:         errors.New("user not logged in"),
+			name:            "Error user not logged in",
+			user:            "",
+			token:           "",
+			createError:     nil,
+			saveSecretError: nil,
+			expectedError:   errors.New("user not logged in"),
 		},
 	}
 
@@ -513,17 +593,40 @@ func TestClientUseCase_SaveData(t *testing.T) {
 			if tt.token != "" {
 				clientUseCase.Config.User = tt.user
 				clientUseCase.Config.Passphrase = "testpassphrase"
+				clientUseCase.keyring = testKeyring()
 				mockStorage.On("CreateData", tt.user, mock.Anything).Return(tt.createError)
 				if tt.createError == nil {
-					mockTransport.On("SaveSecret", context.Background(), mock.Anything).Return(tt.saveSecretError)
-					if tt.saveSecretError == nil {
-						mockStorage.On("UpdateLastServerUpdated", tt.user, int64(0)).Return(tt.updateLastServerError)
+					stored, err := data.EncryptData(testKeyring())
+					assert.NoError(t, err)
+					stored.Version = 1
+					entry := models.OutboxEntry{
+						ID:        1,
+						Operation: models.OutboxOperations.Create,
+						Data:      *stored,
+					}
+					mockStorage.On("AddToOutbox", tt.user, mock.MatchedBy(func(e models.OutboxEntry) bool {
+						return e.Operation == models.OutboxOperations.Create && e.Data.UUID == data.UUID && e.Data.Version == 1
+					})).Return(tt.outboxError)
+					if tt.outboxError == nil {
+						mockStorage.On("GetOutbox", tt.user).Return([]models.OutboxEntry{entry}, nil)
+						mockStorage.On("GetConflicts", tt.user).Return(nil, nil)
+						mockTransport.On("SaveSecret", context.Background(), mock.Anything).Return(tt.saveSecretError)
+					}
+					if tt.outboxError == nil && tt.saveSecretError == nil {
+						mockStorage.On("DeleteFromOutbox", tt.user, entry.ID).Return(nil)
+					}
+					if tt.saveSecretError != nil && !errors.Is(tt.saveSecretError, clienterrors.ServerUnavailable) {
+						mockStorage.On("UpdateOutboxError", tt.user, entry.ID, tt.saveSecretError.Error()).Return(nil)
 					}
 				}
 			}
 			err = clientUseCase.SaveData(context.Background(), data)
 
-			assert.Equal(t, tt.expectedError, err)
+			if tt.expectedError == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tt.expectedError.Error())
+			}
 
 		})
 	}
@@ -535,6 +638,7 @@ func TestClientUseCase_ChangeData(t *testing.T) {
 		UUID:     "testuuid",
 		Meta:     "testmeta",
 		DataType: "testdatatype",
+		Version:  2,
 		Folder: models.Folder{
 			Text: models.TextData{
 				Text: "",
@@ -542,59 +646,56 @@ func TestClientUseCase_ChangeData(t *testing.T) {
 		},
 	}
 
+	conflictErr := &clienterrors.ConflictError{
+		Server: models.StoredData{
+			UUID:          "testuuid",
+			Meta:          "servermeta",
+			DataType:      "testdatatype",
+			Version:       3,
+			EncryptedData: []byte("servervalue"),
+		},
+	}
+
 	tests := []struct {
-		name                  string
-		user                  string
-		token                 string
-		changeError           error
-		saveSecretError       error
-		updateLastServerError error
-		expectedError         error
+		name              string
+		user              string
+		token             string
+		changeError       error
+		changeSecretError error
+		expectedError     error
 	}{
 		{
-			name:                  "Successful change data",
-			user:                  "testuser",
-			token:                 "testtoken",
-			changeError:           nil,
-			saveSecretError:       nil,
-			updateLastServerError: nil,
-			expectedError:         nil,
+			name:          "Successful change data",
+			user:          "testuser",
+			token:         "testtoken",
+			expectedError: nil,
 		},
 		{
-			name:                  "Error changing data in storage",
-			user:                  "testuser",
-			token:                 "testtoken",
-			changeError:           errors.New("storage error"),
-			saveSecretError:       nil,
-			updateLastServerError: nil,
-			expectedError:         errors.New("storage error"),
+			name:          "Error changing data in storage",
+			user:          "testuser",
+			token:         "testtoken",
+			changeError:   errors.New("storage error"),
+			expectedError: errors.New("storage error"),
 		},
 		{
-			name:                  "Error saving secret with transporter",
-			user:                  "testuser",
-			token:                 "testtoken",
-			changeError:           nil,
-			saveSecretError:       errors.New("transporter error"),
-			updateLastServerError: nil,
-			expectedError:         errors.New("transporter error"),
+			name:              "Server unavailable keeps change in outbox",
+			user:              "testuser",
+			token:             "testtoken",
+			changeSecretError: fmt.Errorf("%w: connection refused", clienterrors.ServerUnavailable),
+			expectedError:     nil,
 		},
 		{
-			name:                  "Error updating last server updated time",
-			user:                  "testuser",
-			token:                 "testtoken",
-			changeError:           nil,
-			saveSecretError:       nil,
-			updateLastServerError: errors.New("storage error"),
-			expectedError:         errors.New("storage error"),
+			name:              "Error version conflict waits for user decision",
+			user:              "testuser",
+			token:             "testtoken",
+			changeSecretError: conflictErr,
+			expectedError:     errors.New("change testmeta: " + conflictErr.Error()),
 		},
 		{
-			name:                  "Error user not logged in",
-			user:                  "",
-			token:                 "",
-			changeError:           nil,
-			saveSecretError:       nil,
-			updateLastServerError: nil,
-			expectedError:         errors.New("user not logged in"),
+			name:          "Error user not logged in",
+			user:          "",
+			token:         "",
+			expectedError: errors.New("user not logged in"),
 		},
 	}
 
@@ -613,17 +714,46 @@ func TestClientUseCase_ChangeData(t *testing.T) {
 			if tt.token != "" {
 				clientUseCase.Config.User = tt.user
 				clientUseCase.Config.Passphrase = "testpassphrase"
-				mockStorage.On("UpdateData", tt.user, mock.Anything).Return(tt.changeError)
+				clientUseCase.keyring = testKeyring()
+				mockStorage.On("UpdateData", tt.user, mock.MatchedBy(func(d models.StoredData) bool {
+					return d.Version == data.Version+1
+				})).Return(tt.changeError)
 				if tt.changeError == nil {
-					mockTransport.On("ChangeSecret", context.Background(), mock.Anything).Return(tt.saveSecretError)
-					if tt.saveSecretError == nil {
-						mockStorage.On("UpdateLastServerUpdated", tt.user, int64(0)).Return(tt.updateLastServerError)
+					stored, err := data.EncryptData(testKeyring())
+					assert.NoError(t, err)
+					stored.Version = data.Version + 1
+					entry := models.OutboxEntry{
+						ID:              1,
+						Operation:       models.OutboxOperations.Change,
+						Data:            *stored,
+						ExpectedVersion: data.Version,
 					}
+					mockStorage.On("AddToOutbox", tt.user, mock.MatchedBy(func(e models.OutboxEntry) bool {
+						return e.Operation == models.OutboxOperations.Change && e.ExpectedVersion == data.Version
+					})).Return(nil)
+					mockStorage.On("GetOutbox", tt.user).Return([]models.OutboxEntry{entry}, nil)
+					mockStorage.On("GetConflicts", tt.user).Return(nil, nil)
+					mockTransport.On("ChangeSecret", context.Background(), mock.Anything, data.Version).Return(data.Version+1, tt.changeSecretError)
+					if tt.changeSecretError == nil {
+						mockStorage.On("DeleteFromOutbox", tt.user, entry.ID).Return(nil)
+					}
+				}
+				if conflict, ok := tt.changeSecretError.(*clienterrors.ConflictError); ok {
+					mockStorage.On("SaveConflict", tt.user, mock.MatchedBy(func(c models.Conflict) bool {
+						return c.UUID == data.UUID && c.Server.Version == conflict.Server.Version
+					})).Return(nil)
 				}
 			}
 			err = clientUseCase.ChangeData(context.Background(), data)
 
-			assert.Equal(t, tt.expectedError, err)
+			if tt.expectedError == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tt.expectedError.Error())
+			}
+			if tt.changeSecretError == conflictErr {
+				assert.ErrorIs(t, err, clienterrors.VersionConflict)
+			}
 
 		})
 	}
@@ -635,6 +765,7 @@ func TestClientUseCase_DeleteData(t *testing.T) {
 		UUID:     "testuuid",
 		Meta:     "testmeta",
 		DataType: "testdatatype",
+		Version:  2,
 		Folder: models.Folder{
 			Text: models.TextData{
 				Text: "",
@@ -642,59 +773,54 @@ func TestClientUseCase_DeleteData(t *testing.T) {
 		},
 	}
 
+	conflictErr := &clienterrors.ConflictError{
+		Server: models.StoredData{
+			UUID:     "testuuid",
+			Version:  3,
+			DataType: "testdatatype",
+		},
+	}
+
 	tests := []struct {
-		name                  string
-		user                  string
-		token                 string
-		deleteError           error
-		deleteSecretError     error
-		updateLastServerError error
-		expectedError         error
+		name              string
+		user              string
+		token             string
+		deleteError       error
+		deleteSecretError error
+		expectedError     error
 	}{
 		{
-			name:                  "Successful delete data",
-			user:                  "testuser",
-			token:                 "testtoken",
-			deleteError:           nil,
-			deleteSecretError:     nil,
-			updateLastServerError: nil,
-			expectedError:         nil,
+			name:          "Successful delete data",
+			user:          "testuser",
+			token:         "testtoken",
+			expectedError: nil,
 		},
 		{
-			name:                  "Error deleting data in storage",
-			user:                  "testuser",
-			token:                 "testtoken",
-			deleteError:           errors.New("storage error"),
-			deleteSecretError:     nil,
-			updateLastServerError: nil,
-			expectedError:         errors.New("storage error"),
+			name:          "Error deleting data in storage",
+			user:          "testuser",
+			token:         "testtoken",
+			deleteError:   errors.New("storage error"),
+			expectedError: errors.New("storage error"),
 		},
 		{
-			name:                  "Error deleting secret with transporter",
-			user:                  "testuser",
-			token:                 "testtoken",
-			deleteError:           nil,
-			deleteSecretError:     errors.New("transporter error"),
-			updateLastServerError: nil,
-			expectedError:         errors.New("transporter error"),
+			name:              "Data already deleted on server",
+			user:              "testuser",
+			token:             "testtoken",
+			deleteSecretError: clienterrors.DataNotFound,
+			expectedError:     nil,
 		},
 		{
-			name:                  "Error updating last server updated time",
-			user:                  "testuser",
-			token:                 "testtoken",
-			deleteError:           nil,
-			deleteSecretError:     nil,
-			updateLastServerError: errors.New("storage error"),
-			expectedError:         errors.New("storage error"),
+			name:              "Error version conflict waits for user decision",
+			user:              "testuser",
+			token:             "testtoken",
+			deleteSecretError: conflictErr,
+			expectedError:     errors.New("delete testuuid: " + conflictErr.Error()),
 		},
 		{
-			name:                  "Error user not logged in",
-			user:                  "",
-			token:                 "",
-			deleteError:           nil,
-			deleteSecretError:     nil,
-			updateLastServerError: nil,
-			expectedError:         errors.New("user not logged in"),
+			name:          "Error user not logged in",
+			user:          "",
+			token:         "",
+			expectedError: errors.New("user not logged in"),
 		},
 	}
 	for _, tt := range tests {
@@ -712,18 +838,135 @@ func TestClientUseCase_DeleteData(t *testing.T) {
 			if tt.token != "" {
 				clientUseCase.Config.User = tt.user
 				clientUseCase.Config.Passphrase = "testpassphrase"
-				mockStorage.On("DeleteData", tt.user, mock.Anything).Return(tt.deleteError)
+				clientUseCase.keyring = testKeyring()
+				mockStorage.On("DeleteData", tt.user, models.StoredData{UUID: data.UUID, DataType: string(data.DataType)}).Return(tt.deleteError)
 				if tt.deleteError == nil {
-					mockTransport.On("DeleteSecret", context.Background(), mock.Anything).Return(tt.deleteSecretError)
-					if tt.deleteSecretError == nil {
-						mockStorage.On("UpdateLastServerUpdated", tt.user, int64(0)).Return(tt.updateLastServerError)
+					entry := models.OutboxEntry{
+						ID:              1,
+						Operation:       models.OutboxOperations.Delete,
+						Data:            models.StoredData{UUID: data.UUID},
+						ExpectedVersion: data.Version,
+					}
+					mockStorage.On("AddToOutbox", tt.user, mock.MatchedBy(func(e models.OutboxEntry) bool {
+						return e.Operation == models.OutboxOperations.Delete && e.ExpectedVersion == data.Version
+					})).Return(nil)
+					mockStorage.On("GetOutbox", tt.user).Return([]models.OutboxEntry{entry}, nil)
+					mockStorage.On("GetConflicts", tt.user).Return(nil, nil)
+					mockTransport.On("DeleteSecret", context.Background(), data.UUID, data.Version).Return(tt.deleteSecretError)
+					if tt.deleteSecretError == nil || errors.Is(tt.deleteSecretError, clienterrors.DataNotFound) {
+						mockStorage.On("DeleteFromOutbox", tt.user, entry.ID).Return(nil)
 					}
 				}
+				if conflict, ok := tt.deleteSecretError.(*clienterrors.ConflictError); ok {
+					mockStorage.On("SaveConflict", tt.user, mock.MatchedBy(func(c models.Conflict) bool {
+						return c.UUID == data.UUID && c.Server.Version == conflict.Server.Version
+					})).Return(nil)
+				}
 			}
 			err = clientUseCase.DeleteData(context.Background(), data)
 
-			assert.Equal(t, tt.expectedError, err)
+			if tt.expectedError == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tt.expectedError.Error())
+			}
+
+		})
+	}
+}
+
+func TestClientUseCase_Flush(t *testing.T) {
+	create := models.OutboxEntry{
+		ID:        1,
+		Operation: models.OutboxOperations.Create,
+		Data:      models.StoredData{UUID: "uuid1", Meta: "meta1", Version: 1},
+	}
+	change := models.OutboxEntry{
+		ID:              2,
+		Operation:       models.OutboxOperations.Change,
+		Data:            models.StoredData{UUID: "uuid1", Meta: "meta1", Version: 2},
+		ExpectedVersion: 1,
+	}
+	other := models.OutboxEntry{
+		ID:              3,
+		Operation:       models.OutboxOperations.Delete,
+		Data:            models.StoredData{UUID: "uuid2"},
+		ExpectedVersion: 4,
+	}
+	conflictErr := &clienterrors.ConflictError{
+		Server: models.StoredData{UUID: "uuid1", Meta: "servermeta", Version: 5},
+	}
+
+	tests := []struct {
+		name            string
+		saveSecretError error
+		expectedError   error
+	}{
+		{
+			name: "Successful flush of all changes",
+		},
+		{
+			name:            "Create applied before is treated as pushed",
+			saveSecretError: clienterrors.DataAlreadyExists,
+		},
+		{
+			name:            "Server unavailable stops flush",
+			saveSecretError: clienterrors.ServerUnavailable,
+			expectedError:   clienterrors.ServerUnavailable,
+		},
+		{
+			name:            "Version conflict holds changes of the same data",
+			saveSecretError: conflictErr,
+			expectedError:   clienterrors.VersionConflict,
+		},
+		{
+			name:            "Error is saved and next changes of the same data wait",
+			saveSecretError: errors.New("transporter error"),
+			expectedError:   errors.New("transporter error"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStorage := mocks.NewStorager(t)
+			mockTransport := mocks.NewTransporter(t)
+			testConfig := config.NewClientConfig()
+			clientUseCase := &ClientUseCase{
+				Config:      testConfig,
+				Storage:     mockStorage,
+				Transporter: mockTransport,
+			}
+			clientUseCase.Config.Token = "testtoken"
+			clientUseCase.Config.User = "testuser"
 
+			mockStorage.On("GetOutbox", "testuser").Return([]models.OutboxEntry{create, change, other}, nil)
+			mockStorage.On("GetConflicts", "testuser").Return(nil, nil)
+			mockTransport.On("SaveSecret", context.Background(), mock.Anything).Return(tt.saveSecretError)
+			switch {
+			case tt.saveSecretError == nil || errors.Is(tt.saveSecretError, clienterrors.DataAlreadyExists):
+				mockStorage.On("DeleteFromOutbox", "testuser", create.ID).Return(nil)
+				mockTransport.On("ChangeSecret", context.Background(), mock.Anything, change.ExpectedVersion).Return(int64(2), nil)
+				mockStorage.On("DeleteFromOutbox", "testuser", change.ID).Return(nil)
+			case errors.Is(tt.saveSecretError, clienterrors.VersionConflict):
+				mockStorage.On("SaveConflict", "testuser", mock.MatchedBy(func(c models.Conflict) bool {
+					return c.UUID == "uuid1" && c.Server.Meta == "servermeta"
+				})).Return(nil)
+			case !errors.Is(tt.saveSecretError, clienterrors.ServerUnavailable):
+				mockStorage.On("UpdateOutboxError", "testuser", create.ID, tt.saveSecretError.Error()).Return(nil)
+			}
+			if !errors.Is(tt.saveSecretError, clienterrors.ServerUnavailable) {
+				mockTransport.On("DeleteSecret", context.Background(), "uuid2", other.ExpectedVersion).Return(nil)
+				mockStorage.On("DeleteFromOutbox", "testuser", other.ID).Return(nil)
+			}
+
+			err := clientUseCase.Flush(context.Background())
+			switch {
+			case tt.expectedError == nil:
+				assert.NoError(t, err)
+			case errors.Is(tt.expectedError, clienterrors.ServerUnavailable) || errors.Is(tt.expectedError, clienterrors.VersionConflict):
+				assert.ErrorIs(t, err, tt.expectedError)
+			default:
+				assert.ErrorContains(t, err, tt.expectedError.Error())
+			}
 		})
 	}
 }
@@ -769,6 +1012,7 @@ func TestClientUseCase_GetDataByType(t *testing.T) {
 			if tt.token != "" {
 				clientUseCase.Config.User = tt.user
 				clientUseCase.Config.Passphrase = "testpassphrase"
+				clientUseCase.keyring = testKeyring()
 				mockStorage.On("GetData", tt.user).Return([]models.StoredData{}, tt.getDataError)
 			}
 			_, err = clientUseCase.GetDataByType(tt.dataType)
@@ -779,60 +1023,244 @@ func TestClientUseCase_GetDataByType(t *testing.T) {
 	}
 }
 
+func TestClientUseCase_Sync(t *testing.T) {
+	changes := []*pb.SecretData{
+		{
+			Uuid:     "changeduuid",
+			Meta:     "testmeta",
+			Type:     "tx",
+			Value:    []byte("testvalue"),
+			Revision: 11,
+		},
+		{
+			Uuid:     "deleteduuid",
+			Revision: 12,
+			Deleted:  true,
+		},
+	}
+
+	tests := []struct {
+		name              string
+		user              string
+		token             string
+		pending           bool
+		lastRevisionError error
+		syncChangesError  error
+		upsertError       error
+		updateRevisionErr error
+		expectedError     error
+	}{
+		{
+			name:  "Successful sync",
+			user:  "testuser",
+			token: "testtoken",
+		},
+		{
+			name:          "Data with pending local change is not overwritten",
+			user:          "testuser",
+			token:         "testtoken",
+			pending:       true,
+			expectedError: errors.New("change changeduuid: transporter error"),
+		},
+		{
+			name:              "Error getting last revision from storage",
+			user:              "testuser",
+			token:             "testtoken",
+			lastRevisionError: errors.New("storage error"),
+			expectedError:     errors.New("storage error"),
+		},
+		{
+			name:             "Error syncing changes with transporter",
+			user:             "testuser",
+			token:            "testtoken",
+			syncChangesError: errors.New("transporter error"),
+			expectedError:    errors.New("transporter error"),
+		},
+		{
+			name:          "Error applying change to storage",
+			user:          "testuser",
+			token:         "testtoken",
+			upsertError:   errors.New("storage error"),
+			expectedError: errors.New("storage error"),
+		},
+		{
+			name:              "Error updating last revision",
+			user:              "testuser",
+			token:             "testtoken",
+			updateRevisionErr: errors.New("storage error"),
+			expectedError:     errors.New("storage error"),
+		},
+		{
+			name:          "Error user not logged in",
+			expectedError: errors.New("user not logged in"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStorage := mocks.NewStorager(t)
+			mockTransport := mocks.NewTransporter(t)
+			testConfig := config.NewClientConfig()
+			clientUseCase := &ClientUseCase{
+				Config:      testConfig,
+				Storage:     mockStorage,
+				Transporter: mockTransport,
+			}
+			clientUseCase.Config.Token = tt.token
+			if tt.token != "" {
+				clientUseCase.Config.User = tt.user
+				var outbox []models.OutboxEntry
+				if tt.pending {
+					outbox = []models.OutboxEntry{{
+						ID:              1,
+						Operation:       models.OutboxOperations.Change,
+						Data:            models.StoredData{UUID: "changeduuid", Meta: "testmeta"},
+						ExpectedVersion: 1,
+					}}
+					mockTransport.On("ChangeSecret", context.Background(), mock.Anything, int64(1)).Return(int64(0), errors.New("transporter error"))
+					mockStorage.On("UpdateOutboxError", tt.user, int64(1), "transporter error").Return(nil)
+				}
+				mockStorage.On("GetOutbox", tt.user).Return(outbox, nil)
+				mockStorage.On("GetConflicts", tt.user).Return(nil, nil)
+				mockStorage.On("GetLastRevision", tt.user).Return(int64(10), tt.lastRevisionError)
+				if tt.lastRevisionError == nil {
+					mockStorage.On("IsLegacyMigrated", tt.user).Return(false, nil)
+					mockTransport.On("SyncChanges", context.Background(), int64(10)).Return(changes, int64(12), false, tt.syncChangesError)
+				}
+				if tt.lastRevisionError == nil && tt.syncChangesError == nil {
+					if !tt.pending {
+						mockStorage.On("UpsertData", tt.user, models.StoredData{
+							UUID:          "changeduuid",
+							Meta:          "testmeta",
+							DataType:      "tx",
+							EncryptedData: []byte("testvalue"),
+						}).Return(tt.upsertError)
+					}
+					if tt.upsertError == nil {
+						mockStorage.On("DeleteData", tt.user, models.StoredData{UUID: "deleteduuid"}).Return(nil)
+						mockStorage.On("UpdateLastRevision", tt.user, int64(12)).Return(tt.updateRevisionErr)
+					}
+				}
+			}
+			err := clientUseCase.Sync(context.Background())
+			if tt.expectedError == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tt.expectedError.Error())
+			}
+		})
+	}
+}
+
+func TestClientUseCase_SyncPages(t *testing.T) {
+	mockStorage := mocks.NewStorager(t)
+	mockTransport := mocks.NewTransporter(t)
+	clientUseCase := &ClientUseCase{
+		Config:      config.NewClientConfig(),
+		Storage:     mockStorage,
+		Transporter: mockTransport,
+	}
+	clientUseCase.Config.User = "testuser"
+	clientUseCase.Config.Token = "testtoken"
+	mockStorage.On("GetOutbox", "testuser").Return(nil, nil)
+	mockStorage.On("GetConflicts", "testuser").Return(nil, nil)
+	mockStorage.On("GetLastRevision", "testuser").Return(int64(10), nil)
+	mockStorage.On("IsLegacyMigrated", "testuser").Return(false, nil)
+	mockTransport.On("SyncChanges", context.Background(), int64(10)).
+		Return([]*pb.SecretData{{Uuid: "first", Type: "tx", Value: []byte("first")}}, int64(11), true, nil)
+	mockTransport.On("SyncChanges", context.Background(), int64(11)).
+		Return([]*pb.SecretData{{Uuid: "second", Type: "tx", Value: []byte("second")}}, int64(15), false, nil)
+	mockStorage.On("UpsertData", "testuser", models.StoredData{UUID: "first", DataType: "tx", EncryptedData: []byte("first")}).Return(nil)
+	mockStorage.On("UpsertData", "testuser", models.StoredData{UUID: "second", DataType: "tx", EncryptedData: []byte("second")}).Return(nil)
+	mockStorage.On("UpdateLastRevision", "testuser", int64(11)).Return(nil)
+	mockStorage.On("UpdateLastRevision", "testuser", int64(15)).Return(nil)
+
+	assert.NoError(t, clientUseCase.Sync(context.Background()))
+}
+
+func TestClientUseCase_SyncRejectsLegacyData(t *testing.T) {
+	current, err := (&models.Data{UUID: "currentuuid", Meta: "current", DataType: "tx", Version: 2}).EncryptData(testKeyring())
+	assert.NoError(t, err)
+
+	mockStorage := mocks.NewStorager(t)
+	mockTransport := mocks.NewTransporter(t)
+	clientUseCase := &ClientUseCase{
+		Config:      config.NewClientConfig(),
+		Storage:     mockStorage,
+		Transporter: mockTransport,
+	}
+	clientUseCase.Config.User = "testuser"
+	clientUseCase.Config.Token = "testtoken"
+	mockStorage.On("GetOutbox", "testuser").Return(nil, nil)
+	mockStorage.On("GetConflicts", "testuser").Return(nil, nil)
+	mockStorage.On("GetLastRevision", "testuser").Return(int64(10), nil)
+	// local vault is migrated, so data without envelope of the current version is not accepted from server
+	mockStorage.On("IsLegacyMigrated", "testuser").Return(true, nil)
+	mockTransport.On("SyncChanges", context.Background(), int64(10)).Return([]*pb.SecretData{
+		{Uuid: "legacyuuid", Type: "tx", Version: 3, Value: []byte("legacy value")},
+		{Uuid: current.UUID, Type: current.DataType, Version: current.Version, Value: current.EncryptedData},
+		{Uuid: "deleteduuid", Deleted: true},
+	}, int64(12), false, nil)
+	mockStorage.On("UpsertData", "testuser", models.StoredData{UUID: current.UUID, DataType: current.DataType,
+		Version: current.Version, EncryptedData: current.EncryptedData}).Return(nil)
+	mockStorage.On("DeleteData", "testuser", models.StoredData{UUID: "deleteduuid"}).Return(nil)
+	mockStorage.On("UpdateLastRevision", "testuser", int64(12)).Return(nil)
+
+	err = clientUseCase.Sync(context.Background())
+	assert.ErrorIs(t, err, clienterrors.Undecryptable)
+	var rejected *clienterrors.UndecryptableError
+	if assert.ErrorAs(t, err, &rejected) {
+		assert.Equal(t, map[string]string{"legacyuuid": models.ErrLegacyEnvelope.Error()}, rejected.Items)
+	}
+}
+
 func TestClientUseCase_FullSync(t *testing.T) {
 	tests := []struct {
-		name                  string
-		user                  string
-		token                 string
-		deleteDataError       error
-		listSecretsError      error
-		updateLastServerError error
-		expectedError         error
+		name             string
+		user             string
+		token            string
+		pending          bool
+		deleteDataError  error
+		resetRevisionErr error
+		syncChangesError error
+		expectedError    error
 	}{
 		{
-			name:                  "Successful full sync",
-			user:                  "testuser",
-			token:                 "testtoken",
-			deleteDataError:       nil,
-			listSecretsError:      nil,
-			updateLastServerError: nil,
-			expectedError:         nil,
+			name:  "Successful full sync",
+			user:  "testuser",
+			token: "testtoken",
+		},
+		{
+			name:            "Error deleting data in storage",
+			user:            "testuser",
+			token:           "testtoken",
+			deleteDataError: errors.New("storage error"),
+			expectedError:   errors.New("storage error"),
 		},
 		{
-			name:                  "Error deleting data in storage",
-			user:                  "testuser",
-			token:                 "testtoken",
-			deleteDataError:       errors.New("storage error"),
-			listSecretsError:      nil,
-			updateLastServerError: nil,
-			expectedError:         errors.New("storage error"),
+			name:          "Error local changes are not pushed",
+			user:          "testuser",
+			token:         "testtoken",
+			pending:       true,
+			expectedError: clienterrors.PendingChanges,
 		},
 		{
-			name:                  "Error listing secrets with transporter",
-			user:                  "testuser",
-			token:                 "testtoken",
-			deleteDataError:       nil,
-			listSecretsError:      errors.New("transporter error"),
-			updateLastServerError: nil,
-			expectedError:         errors.New("transporter error"),
+			name:             "Error resetting last revision",
+			user:             "testuser",
+			token:            "testtoken",
+			resetRevisionErr: errors.New("storage error"),
+			expectedError:    errors.New("storage error"),
 		},
 		{
-			name:                  "Error updating last server updated time",
-			user:                  "testuser",
-			token:                 "testtoken",
-			deleteDataError:       nil,
-			listSecretsError:      nil,
-			updateLastServerError: errors.New("storage error"),
-			expectedError:         errors.New("storage error"),
+			name:             "Error syncing changes with transporter",
+			user:             "testuser",
+			token:            "testtoken",
+			syncChangesError: errors.New("transporter error"),
+			expectedError:    errors.New("transporter error"),
 		},
 		{
-			name:                  "Error user not logged in",
-			user:                  "",
-			token:                 "",
-			deleteDataError:       nil,
-			listSecretsError:      nil,
-			updateLastServerError: nil,
-			expectedError:         errors.New("user not logged in"),
+			name:          "Error user not logged in",
+			expectedError: errors.New("user not logged in"),
 		},
 	}
 
@@ -850,12 +1278,29 @@ func TestClientUseCase_FullSync(t *testing.T) {
 			clientUseCase.Config.Token = tt.token
 			if tt.token != "" {
 				clientUseCase.Config.User = tt.user
+				if tt.pending {
+					outbox := []models.OutboxEntry{{ID: 1, Operation: models.OutboxOperations.Create, Data: models.StoredData{UUID: "testuuid"}}}
+					mockStorage.On("GetOutbox", tt.user).Return(outbox, nil)
+					mockStorage.On("GetConflicts", tt.user).Return(nil, nil)
+					mockTransport.On("SaveSecret", context.Background(), mock.Anything).Return(errors.New("transporter error"))
+					mockStorage.On("UpdateOutboxError", tt.user, int64(1), "transporter error").Return(nil)
+					err = clientUseCase.FullSync(context.Background())
+					assert.ErrorIs(t, err, tt.expectedError)
+					return
+				}
+				mockStorage.On("GetOutbox", tt.user).Return(nil, nil)
+				mockStorage.On("GetConflicts", tt.user).Return(nil, nil)
 				mockStorage.On("DeleteAllData", tt.user).Return(tt.deleteDataError)
 				if tt.deleteDataError == nil {
+					mockStorage.On("UpdateLastRevision", tt.user, int64(0)).Return(tt.resetRevisionErr).Once()
+				}
+				if tt.deleteDataError == nil && tt.resetRevisionErr == nil {
 					var protoData []*pb.SecretData
-					mockTransport.On("ListSecrets", context.Background()).Return(protoData, tt.listSecretsError)
-					if tt.listSecretsError == nil {
-						mockStorage.On("UpdateLastServerUpdated", tt.user, int64(0)).Return(tt.updateLastServerError)
+					mockStorage.On("GetLastRevision", tt.user).Return(int64(0), nil)
+					mockStorage.On("IsLegacyMigrated", tt.user).Return(false, nil)
+					mockTransport.On("SyncChanges", context.Background(), int64(0)).Return(protoData, int64(5), false, tt.syncChangesError)
+					if tt.syncChangesError == nil {
+						mockStorage.On("UpdateLastRevision", tt.user, int64(5)).Return(nil)
 					}
 				}
 			}
@@ -865,3 +1310,756 @@ func TestClientUseCase_FullSync(t *testing.T) {
 		})
 	}
 }
+
+func TestClientUseCase_ResolveConflict(t *testing.T) {
+	storedLocal, err := (&models.Data{UUID: "testuuid", Meta: "localmeta", DataType: "tx", Version: 3}).EncryptData(testKeyring())
+	assert.NoError(t, err)
+	local := *storedLocal
+	conflict := models.Conflict{
+		UUID:   "testuuid",
+		Server: models.StoredData{UUID: "testuuid", Meta: "servermeta", DataType: "tx", Version: 4, EncryptedData: []byte("servervalue")},
+	}
+
+	tests := []struct {
+		name          string
+		uuid          string
+		policy        models.ConflictPolicy
+		localDeleted  bool
+		serverDeleted bool
+		expectedError error
+	}{
+		{
+			name:   "Server wins replaces local copy",
+			uuid:   "testuuid",
+			policy: models.ConflictPolicies.ServerWins,
+		},
+		{
+			name:   "Client wins rebases local change on server version",
+			uuid:   "testuuid",
+			policy: models.ConflictPolicies.ClientWins,
+		},
+		{
+			name:          "Client wins recreates data deleted on server",
+			uuid:          "testuuid",
+			policy:        models.ConflictPolicies.ClientWins,
+			serverDeleted: true,
+		},
+		{
+			name:         "Client wins deletes data changed on server",
+			uuid:         "testuuid",
+			policy:       models.ConflictPolicies.ClientWins,
+			localDeleted: true,
+		},
+		{
+			name:   "Keep both saves local copy as new data",
+			uuid:   "testuuid",
+			policy: models.ConflictPolicies.KeepBoth,
+		},
+		{
+			name:          "Error conflict not found",
+			uuid:          "otheruuid",
+			policy:        models.ConflictPolicies.ServerWins,
+			expectedError: clienterrors.ConflictNotFound,
+		},
+		{
+			name:          "Error ask is not a resolution",
+			uuid:          "testuuid",
+			policy:        models.ConflictPolicies.Ask,
+			expectedError: errors.New(`conflict can't be resolved with policy "ask"`),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStorage := mocks.NewStorager(t)
+			mockTransport := mocks.NewTransporter(t)
+			testConfig := config.NewClientConfig()
+			clientUseCase := &ClientUseCase{
+				Config:      testConfig,
+				Storage:     mockStorage,
+				Transporter: mockTransport,
+				keyring:     testKeyring(),
+			}
+			clientUseCase.Config.Token = "testtoken"
+			clientUseCase.Config.User = "testuser"
+
+			conflict := conflict
+			conflict.ServerDeleted = tt.serverDeleted
+			if tt.policy != models.ConflictPolicies.Ask {
+				mockStorage.On("GetConflicts", "testuser").Return([]models.Conflict{conflict}, nil)
+			}
+			if tt.expectedError == nil {
+				if tt.localDeleted {
+					mockStorage.On("GetDataByUUID", "testuser", "testuuid").Return(nil, clienterrors.LocalDataNotFound)
+				} else {
+					mockStorage.On("GetDataByUUID", "testuser", "testuuid").Return(&local, nil)
+				}
+				mockStorage.On("DeleteOutboxByUUID", "testuser", "testuuid").Return(nil)
+				mockStorage.On("DeleteConflict", "testuser", "testuuid").Return(nil)
+				mockStorage.On("GetOutbox", "testuser").Return(nil, nil)
+			}
+			switch {
+			case tt.expectedError != nil:
+			case tt.policy == models.ConflictPolicies.ServerWins:
+				mockStorage.On("UpsertData", "testuser", conflict.Server).Return(nil)
+			case tt.policy == models.ConflictPolicies.ClientWins && tt.localDeleted:
+				mockStorage.On("AddToOutbox", "testuser", models.OutboxEntry{
+					Operation:       models.OutboxOperations.Delete,
+					Data:            models.StoredData{UUID: "testuuid", DataType: "tx"},
+					ExpectedVersion: 4,
+				}).Return(nil)
+			case tt.policy == models.ConflictPolicies.ClientWins && tt.serverDeleted:
+				mockStorage.On("DeleteData", "testuser", local).Return(nil)
+				mockStorage.On("CreateData", "testuser", mock.MatchedBy(func(d models.StoredData) bool {
+					return d.UUID != local.UUID && d.Meta == local.Meta && d.Version == 1
+				})).Return(nil)
+				mockStorage.On("AddToOutbox", "testuser", mock.MatchedBy(func(e models.OutboxEntry) bool {
+					return e.Operation == models.OutboxOperations.Create && e.Data.UUID != local.UUID
+				})).Return(nil)
+			case tt.policy == models.ConflictPolicies.ClientWins:
+				rebased := local
+				rebased.Version = 5
+				mockStorage.On("UpdateData", "testuser", rebased).Return(nil)
+				mockStorage.On("AddToOutbox", "testuser", models.OutboxEntry{
+					Operation:       models.OutboxOperations.Change,
+					Data:            rebased,
+					ExpectedVersion: 4,
+				}).Return(nil)
+			case tt.policy == models.ConflictPolicies.KeepBoth:
+				mockStorage.On("CreateData", "testuser", mock.MatchedBy(func(d models.StoredData) bool {
+					copied, err := d.DecryptData(testKeyring())
+					return err == nil && d.UUID != local.UUID && copied.Meta == "localmeta (conflicted copy)" && d.Version == 1
+				})).Return(nil)
+				mockStorage.On("AddToOutbox", "testuser", mock.MatchedBy(func(e models.OutboxEntry) bool {
+					return e.Operation == models.OutboxOperations.Create && e.Data.UUID != local.UUID
+				})).Return(nil)
+				mockStorage.On("UpsertData", "testuser", conflict.Server).Return(nil)
+			}
+
+			err := clientUseCase.ResolveConflict(context.Background(), tt.uuid, tt.policy)
+			assert.Equal(t, tt.expectedError, err)
+		})
+	}
+}
+
+func TestClientUseCase_FlushWithConflictPolicy(t *testing.T) {
+	mockStorage := mocks.NewStorager(t)
+	mockTransport := mocks.NewTransporter(t)
+	testConfig := config.NewClientConfig()
+	clientUseCase := &ClientUseCase{
+		Config:      testConfig,
+		Storage:     mockStorage,
+		Transporter: mockTransport,
+	}
+	clientUseCase.Config.Token = "testtoken"
+	clientUseCase.Config.User = "testuser"
+	clientUseCase.Config.ConflictPolicy = string(models.ConflictPolicies.ClientWins)
+
+	local := models.StoredData{UUID: "testuuid", Meta: "localmeta", Version: 3, EncryptedData: []byte("localvalue")}
+	entry := models.OutboxEntry{ID: 1, Operation: models.OutboxOperations.Change, Data: local, ExpectedVersion: 2}
+	conflictErr := &clienterrors.ConflictError{
+		Server: models.StoredData{UUID: "testuuid", Meta: "servermeta", Version: 4, EncryptedData: []byte("servervalue")},
+	}
+	rebased := local
+	rebased.Version = 5
+	rebasedEntry := models.OutboxEntry{ID: 2, Operation: models.OutboxOperations.Change, Data: rebased, ExpectedVersion: 4}
+
+	// the first pass meets the conflict and queues the rebased change, the second pass pushes it
+	mockStorage.On("GetOutbox", "testuser").Return([]models.OutboxEntry{entry}, nil).Once()
+	mockStorage.On("GetOutbox", "testuser").Return([]models.OutboxEntry{rebasedEntry}, nil).Once()
+	mockStorage.On("GetConflicts", "testuser").Return(nil, nil)
+	mockTransport.On("ChangeSecret", context.Background(), mock.Anything, int64(2)).Return(int64(0), conflictErr)
+	mockStorage.On("GetDataByUUID", "testuser", "testuuid").Return(&local, nil)
+	mockStorage.On("DeleteOutboxByUUID", "testuser", "testuuid").Return(nil)
+	mockStorage.On("UpdateData", "testuser", rebased).Return(nil)
+	mockStorage.On("AddToOutbox", "testuser", models.OutboxEntry{
+		Operation:       models.OutboxOperations.Change,
+		Data:            rebased,
+		ExpectedVersion: 4,
+	}).Return(nil)
+	mockTransport.On("ChangeSecret", context.Background(), mock.Anything, int64(4)).Return(int64(5), nil)
+	mockStorage.On("DeleteFromOutbox", "testuser", int64(2)).Return(nil)
+
+	err := clientUseCase.Flush(context.Background())
+	assert.NoError(t, err)
+}
+
+func TestClientUseCase_MigrateLegacyData(t *testing.T) {
+	// legacy format is nonce | ciphertext sealed with sha256(passphrase)
+	key := sha256.Sum256([]byte("testpassphrase"))
+	block, err := aes.NewCipher(key[:])
+	assert.NoError(t, err)
+	gcm, err := cipher.NewGCM(block)
+	assert.NoError(t, err)
+	nonce := make([]byte, gcm.NonceSize())
+	legacy := models.StoredData{
+		UUID:          "legacyuuid",
+		Meta:          "legacymeta",
+		DataType:      "tx",
+		Version:       2,
+		EncryptedData: gcm.Seal(nonce, nonce, []byte(`{"text":{"text":"legacy text"}}`), nil),
+	}
+	current, err := (&models.Data{UUID: "currentuuid", Meta: "currentmeta", DataType: "tx", Version: 1}).EncryptData(testKeyring())
+	assert.NoError(t, err)
+	assert.True(t, legacy.IsLegacy())
+	assert.False(t, current.IsLegacy())
+
+	mockStorage := mocks.NewStorager(t)
+	mockTransport := mocks.NewTransporter(t)
+	clientUseCase := &ClientUseCase{
+		Config:      config.NewClientConfig(),
+		Storage:     mockStorage,
+		Transporter: mockTransport,
+		keyring:     testKeyring(),
+	}
+	clientUseCase.Config.Token = "testtoken"
+	clientUseCase.Config.User = "testuser"
+
+	var migrated models.StoredData
+	mockStorage.On("GetData", "testuser").Return([]models.StoredData{legacy, *current}, nil)
+	mockStorage.On("UpdateData", "testuser", mock.MatchedBy(func(d models.StoredData) bool {
+		migrated = d
+		return d.UUID == legacy.UUID && d.Version == legacy.Version+1 && !d.IsLegacy()
+	})).Return(nil)
+	mockStorage.On("AddToOutbox", "testuser", mock.MatchedBy(func(e models.OutboxEntry) bool {
+		return e.Operation == models.OutboxOperations.Change && e.Data.UUID == legacy.UUID && e.ExpectedVersion == legacy.Version
+	})).Return(nil)
+	mockStorage.On("SetLegacyMigrated", "testuser").Return(nil)
+	mockStorage.On("GetOutbox", "testuser").Return(nil, nil)
+	mockStorage.On("GetConflicts", "testuser").Return(nil, nil)
+
+	err = clientUseCase.migrateLegacyData(context.Background())
+	assert.NoError(t, err)
+
+	data, err := migrated.DecryptData(testKeyring())
+	assert.NoError(t, err)
+	assert.Equal(t, "legacy text", data.Folder.Text.Text)
+}
+
+func TestClientUseCase_ChangePassphrase(t *testing.T) {
+	wrappedKey := testWrappedKey(t)
+	stored, err := (&models.Data{UUID: "testuuid", Meta: "testmeta", DataType: "tx", Version: 1,
+		Folder: models.Folder{Text: models.TextData{Text: "test text"}}}).EncryptData(testKeyring())
+	assert.NoError(t, err)
+	// legacy data encrypted with another passphrase can't be migrated
+	key := sha256.Sum256([]byte("otherpassphrase"))
+	block, err := aes.NewCipher(key[:])
+	assert.NoError(t, err)
+	gcm, err := cipher.NewGCM(block)
+	assert.NoError(t, err)
+	nonce := make([]byte, gcm.NonceSize())
+	legacy := models.StoredData{UUID: "legacyuuid", DataType: "tx", Version: 2,
+		EncryptedData: gcm.Seal(nonce, nonce, []byte(`{"text":{"text":"legacy text"}}`), nil)}
+
+	tests := []struct {
+		name            string
+		oldPassphrase   string
+		storedData      []models.StoredData
+		outbox          []models.OutboxEntry
+		setMasterKey    bool
+		setMasterKeyErr error
+		expectedErr     error
+	}{
+		{
+			name:          "Successful change passphrase",
+			oldPassphrase: "testpassphrase",
+			storedData:    []models.StoredData{*stored},
+			setMasterKey:  true,
+		},
+		{
+			name:          "Wrong old passphrase",
+			oldPassphrase: "wrongpassphrase",
+			expectedErr:   clienterrors.WrongPassphrase,
+		},
+		{
+			name:            "Key changed on another device",
+			oldPassphrase:   "testpassphrase",
+			storedData:      []models.StoredData{*stored},
+			setMasterKey:    true,
+			setMasterKeyErr: clienterrors.VersionConflict,
+			expectedErr:     clienterrors.VersionConflict,
+		},
+		{
+			name:          "Legacy data is not migrated",
+			oldPassphrase: "testpassphrase",
+			storedData:    []models.StoredData{*stored, legacy},
+			expectedErr:   clienterrors.LegacyDataNotMigrated,
+		},
+		{
+			name:          "Migrated data is not pushed",
+			oldPassphrase: "testpassphrase",
+			storedData:    []models.StoredData{*stored},
+			outbox:        []models.OutboxEntry{{Operation: models.OutboxOperations.Change, Data: *stored}},
+			expectedErr:   clienterrors.PendingChanges,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStorage := mocks.NewStorager(t)
+			mockTransport := mocks.NewTransporter(t)
+			clientUseCase := &ClientUseCase{
+				Config:      config.NewClientConfig(),
+				Storage:     mockStorage,
+				Transporter: mockTransport,
+				keyring:     testKeyring(),
+			}
+			clientUseCase.Config.Token = "testtoken"
+			clientUseCase.Config.User = "testuser"
+			clientUseCase.Config.Passphrase = "testpassphrase"
+			clientUseCase.Config.KDFTime, clientUseCase.Config.KDFMemory, clientUseCase.Config.KDFThreads = testKDFParams.Time, testKDFParams.Memory, testKDFParams.Threads
+
+			var newWrappedKey []byte
+			mockTransport.On("GetMasterKey", context.Background()).Return(&models.WrappedKey{
+				Key: wrappedKey.Key, KeyCheck: wrappedKey.KeyCheck, Version: 3}, nil)
+			if tt.storedData != nil {
+				mockStorage.On("GetData", "testuser").Return(tt.storedData, nil)
+			}
+			if tt.storedData != nil && !tt.storedData[len(tt.storedData)-1].IsLegacy() {
+				mockStorage.On("SetLegacyMigrated", "testuser").Return(nil)
+				mockStorage.On("GetOutbox", "testuser").Return(tt.outbox, nil)
+			}
+			if tt.setMasterKey {
+				mockTransport.On("SetMasterKey", context.Background(), mock.MatchedBy(func(k models.WrappedKey) bool {
+					newWrappedKey = k.Key
+					return bytes.Equal(k.KeyCheck, wrappedKey.KeyCheck)
+				}), int64(3)).Return(int64(4), tt.setMasterKeyErr)
+			}
+			if tt.expectedErr == nil {
+				mockStorage.On("SetUserSalt", "testuser", mock.MatchedBy(func(salt []byte) bool {
+					return len(salt) == models.SaltSize
+				})).Return(nil)
+			}
+
+			err := clientUseCase.ChangePassphrase(context.Background(), tt.oldPassphrase, "newpassphrase")
+			assert.ErrorIs(t, err, tt.expectedErr)
+			if tt.expectedErr != nil {
+				assert.Equal(t, "testpassphrase", clientUseCase.Config.Passphrase)
+				return
+			}
+			assert.Equal(t, "newpassphrase", clientUseCase.Config.Passphrase)
+
+			// master key is not changed, so data is decrypted without encrypting it again
+			masterKey, err := models.NewKeyring("testuser", "newpassphrase", nil, testKDFParams).UnwrapKey(newWrappedKey)
+			assert.NoError(t, err)
+			assert.Equal(t, testMasterKey, masterKey)
+			_, err = models.NewKeyring("testuser", "testpassphrase", nil, testKDFParams).UnwrapKey(newWrappedKey)
+			assert.Error(t, err)
+			data, err := stored.DecryptData(clientUseCase.keyring)
+			assert.NoError(t, err)
+			assert.Equal(t, "test text", data.Folder.Text.Text)
+		})
+	}
+}
+
+func TestClientUseCase_FindByMeta(t *testing.T) {
+	stored, err := (&models.Data{UUID: "testuuid", Meta: "Prod DB root", DataType: "cr", Version: 1,
+		Folder: models.Folder{Credentials: models.Credentials{Login: "root"}}}).EncryptData(testKeyring())
+	assert.NoError(t, err)
+	// meta is sent to server and saved locally only as blinded search token
+	assert.NotContains(t, stored.Meta, "Prod")
+	assert.NotContains(t, string(stored.EncryptedData), "Prod")
+
+	mockStorage := mocks.NewStorager(t)
+	clientUseCase := &ClientUseCase{
+		Config:  config.NewClientConfig(),
+		Storage: mockStorage,
+		keyring: testKeyring(),
+	}
+	clientUseCase.Config.Token = "testtoken"
+	clientUseCase.Config.User = "testuser"
+	mockStorage.On("FindByMeta", "testuser", stored.Meta).Return([]models.StoredData{*stored}, nil)
+
+	data, err := clientUseCase.FindByMeta("  prod db ROOT ")
+	assert.NoError(t, err)
+	if assert.Len(t, data, 1) {
+		assert.Equal(t, "Prod DB root", data[0].Meta)
+		assert.Equal(t, "root", data[0].Folder.Credentials.Login)
+	}
+}
+
+func TestClientUseCase_GetDataByTypeRejectsMovedData(t *testing.T) {
+	card, err := (&models.Data{UUID: "carduuid", Meta: "card", DataType: "cc", Version: 1}).EncryptData(testKeyring())
+	assert.NoError(t, err)
+	text, err := (&models.Data{UUID: "textuuid", Meta: "text", DataType: "tx", Version: 1}).EncryptData(testKeyring())
+	assert.NoError(t, err)
+	// item which is decrypted is returned with the skipped one
+	good, err := (&models.Data{UUID: "gooduuid", Meta: "good", DataType: "tx", Version: 1}).EncryptData(testKeyring())
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		stored models.StoredData
+	}{
+		{
+			name:   "Ciphertext moved to another uuid",
+			stored: models.StoredData{UUID: text.UUID, DataType: "tx", Version: 1, EncryptedData: card.EncryptedData},
+		},
+		{
+			name:   "Ciphertext moved to another type",
+			stored: models.StoredData{UUID: card.UUID, DataType: "tx", Version: 1, EncryptedData: card.EncryptedData},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStorage := mocks.NewStorager(t)
+			clientUseCase := &ClientUseCase{
+				Config:  config.NewClientConfig(),
+				Storage: mockStorage,
+				keyring: testKeyring(),
+			}
+			clientUseCase.Config.Token = "testtoken"
+			clientUseCase.Config.User = "testuser"
+			mockStorage.On("GetData", "testuser").Return([]models.StoredData{tt.stored, *good}, nil)
+
+			data, err := clientUseCase.GetDataByType("tx")
+			assert.ErrorIs(t, err, clienterrors.Undecryptable)
+			var undecryptable *clienterrors.UndecryptableError
+			if assert.ErrorAs(t, err, &undecryptable) {
+				assert.Contains(t, undecryptable.Items[tt.stored.UUID], models.ErrContextMismatch.Error())
+			}
+			if assert.Len(t, data, 1) {
+				assert.Equal(t, "good", data[0].Meta)
+			}
+		})
+	}
+
+	// ciphertext of another user can't be opened even with the same master key
+	otherUser := models.NewKeyring("otheruser", "testpassphrase", nil, testKDFParams)
+	otherUser.SetMasterKey(testMasterKey)
+	_, err = text.DecryptData(otherUser)
+	assert.ErrorIs(t, err, models.ErrContextMismatch)
+}
+
+func TestClientUseCase_UploadDownloadBinary(t *testing.T) {
+	content := bytes.Repeat([]byte("binary content "), 10000)
+	data := models.Data{
+		Meta:     "large file",
+		DataType: "bi",
+		Folder:   models.Folder{Binary: models.BinaryData{Name: "file.bin"}},
+	}
+
+	mockStorage := mocks.NewStorager(t)
+	mockTransporter := mocks.NewTransporter(t)
+	clientUseCase := &ClientUseCase{
+		Config:      config.NewClientConfig(),
+		Storage:     mockStorage,
+		Transporter: mockTransporter,
+		keyring:     testKeyring(),
+	}
+	clientUseCase.Config.Token = "testtoken"
+	clientUseCase.Config.User = "testuser"
+
+	var chunks [][]byte
+	var stored models.StoredData
+	mockTransporter.On("UploadSecret", mock.Anything, mock.AnythingOfType("*proto.SecretData"), int64(0), mock.Anything).
+		Run(func(args mock.Arguments) {
+			next := args.Get(3).(func() ([]byte, error))
+			for {
+				chunk, err := next()
+				if err != nil {
+					assert.Equal(t, io.EOF, err)
+					return
+				}
+				chunks = append(chunks, chunk)
+			}
+		}).Return(int64(1), nil)
+	mockStorage.On("UpsertData", "testuser", mock.AnythingOfType("models.StoredData")).
+		Run(func(args mock.Arguments) {
+			stored = args.Get(1).(models.StoredData)
+		}).Return(nil)
+
+	var uploaded int64
+	err := clientUseCase.UploadBinary(context.Background(), data, bytes.NewReader(content), int64(len(content)), func(done, total int64) {
+		uploaded = done
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(content)), uploaded)
+	assert.Len(t, chunks, (len(content)+models.StreamChunkSize-1)/models.StreamChunkSize)
+	// content is not saved in data itself
+	assert.Less(t, len(stored.EncryptedData), models.StreamChunkSize)
+
+	header, err := stored.DecryptData(clientUseCase.keyring)
+	assert.NoError(t, err)
+	assert.True(t, header.Folder.Binary.IsStreamed())
+	assert.Equal(t, int64(1), header.Version)
+
+	tests := []struct {
+		name    string
+		chunks  [][]byte
+		wantErr error
+	}{
+		{
+			name:   "All chunks",
+			chunks: chunks,
+		},
+		{
+			name:    "Last chunk dropped",
+			chunks:  chunks[:len(chunks)-1],
+			wantErr: models.ErrStreamTruncated,
+		},
+		{
+			name:    "Chunks reordered",
+			chunks:  [][]byte{chunks[1], chunks[0], chunks[2]},
+			wantErr: models.ErrContextMismatch,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockTransporter.On("DownloadSecret", mock.Anything, header.UUID, int64(1), mock.Anything).
+				Return(func(_ context.Context, _ string, _ int64, handle func([]byte) error) error {
+					for _, chunk := range tt.chunks {
+						if err := handle(chunk); err != nil {
+							return err
+						}
+					}
+					return nil
+				}).Once()
+
+			var buf bytes.Buffer
+			err := clientUseCase.DownloadBinary(context.Background(), *header, &buf, nil)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, content, buf.Bytes())
+		})
+	}
+}
+
+func TestClientUseCase_Lock(t *testing.T) {
+	keyring := testKeyring()
+	clientUseCase := &ClientUseCase{
+		Config:  config.NewClientConfig(),
+		Storage: mocks.NewStorager(t),
+		keyring: keyring,
+	}
+	clientUseCase.Config.Token = "testtoken"
+	clientUseCase.Config.RefreshToken = "testrefreshtoken"
+	clientUseCase.Config.User = "testuser"
+	clientUseCase.Config.Passphrase = "testpassphrase"
+
+	clientUseCase.Lock()
+	assert.Empty(t, clientUseCase.Config.Token)
+	assert.Empty(t, clientUseCase.Config.RefreshToken)
+	assert.Empty(t, clientUseCase.Config.Passphrase)
+	// master key is wiped, so it can't be used by anyone who still holds keyring
+	_, err := keyring.MasterKey()
+	assert.ErrorIs(t, err, models.ErrVaultLocked)
+	_, err = clientUseCase.GetDataByType("cr")
+	assert.Error(t, err)
+}
+
+func TestClientUseCase_Logout(t *testing.T) {
+	tests := []struct {
+		name      string
+		token     string
+		logoutErr error
+		wantErr   bool
+	}{
+		{
+			name:  "valid",
+			token: "testtoken",
+		},
+		{
+			name:    "not logged in",
+			wantErr: true,
+		},
+		{
+			name:      "server unavailable",
+			token:     "testtoken",
+			logoutErr: clienterrors.ServerUnavailable,
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			mockTransporter := mocks.NewTransporter(t)
+			clientUseCase := &ClientUseCase{
+				Config:      config.NewClientConfig(),
+				Storage:     mocks.NewStorager(t),
+				Transporter: mockTransporter,
+				keyring:     testKeyring(),
+			}
+			clientUseCase.Config.Token = tt.token
+			clientUseCase.Config.RefreshToken = "testrefreshtoken"
+			if tt.token != "" {
+				mockTransporter.On("Logout", ctx).Return(tt.logoutErr)
+			}
+
+			err := clientUseCase.Logout(ctx)
+			assert.Equal(t, tt.wantErr, err != nil)
+			if tt.token != "" {
+				// vault is locked even if session isn't revoked on server
+				assert.Empty(t, clientUseCase.Config.Token)
+				assert.Empty(t, clientUseCase.Config.RefreshToken)
+				_, err = clientUseCase.GetDataByType("cr")
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestClientUseCase_Sessions(t *testing.T) {
+	ctx := context.Background()
+	mockTransporter := mocks.NewTransporter(t)
+	clientUseCase := &ClientUseCase{
+		Config:      config.NewClientConfig(),
+		Storage:     mocks.NewStorager(t),
+		Transporter: mockTransporter,
+	}
+	_, err := clientUseCase.ListSessions(ctx)
+	assert.Error(t, err)
+	assert.Error(t, clientUseCase.RevokeSession(ctx, "session1"))
+
+	clientUseCase.Config.Token = "testtoken"
+	sessions := []models.Session{{ID: "session1", DeviceName: "laptop"}, {ID: "session2", Current: true}}
+	mockTransporter.On("ListSessions", ctx).Return(sessions, nil)
+	mockTransporter.On("RevokeSession", ctx, "session1").Return(clienterrors.DataNotFound)
+	got, err := clientUseCase.ListSessions(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, sessions, got)
+	assert.ErrorIs(t, clientUseCase.RevokeSession(ctx, "session1"), clienterrors.DataNotFound)
+}
+
+func TestClientUseCase_TwoFactor(t *testing.T) {
+	ctx := context.Background()
+	mockTransporter := mocks.NewTransporter(t)
+	testConfig := config.NewClientConfig()
+	testConfig.UpdateSession(func(s *config.Session) {
+		s.User = "testuser"
+		s.Token = "testtoken"
+	})
+	clientUseCase := &ClientUseCase{
+		Config:      testConfig,
+		Storage:     mocks.NewStorager(t),
+		Transporter: mockTransporter,
+	}
+	user := &pb.User{Name: "testuser", Password: "testpassword"}
+	mockTransporter.On("EnrollTwoFactor", ctx, user).Return("otpauth://totp/uri", nil)
+	mockTransporter.On("ConfirmTwoFactor", ctx, user, "123456").Return([]string{"aaaa-bbbb-cccc-dddd"}, nil)
+	mockTransporter.On("DisableTwoFactor", ctx, user, "654321").Return(clienterrors.InvalidTwoFactorCode)
+
+	// session of logged in user is used
+	uri, err := clientUseCase.EnrollTwoFactor(ctx, "testuser", "testpassword")
+	assert.NoError(t, err)
+	assert.Equal(t, "otpauth://totp/uri", uri)
+	codes, err := clientUseCase.ConfirmTwoFactor(ctx, "testuser", "testpassword", "123456")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"aaaa-bbbb-cccc-dddd"}, codes)
+	err = clientUseCase.DisableTwoFactor(ctx, "testuser", "testpassword", "654321")
+	assert.ErrorIs(t, err, clienterrors.InvalidTwoFactorCode)
+	assert.Equal(t, "testtoken", testConfig.AccessToken())
+
+	_, err = clientUseCase.EnrollTwoFactor(ctx, "otheruser", "testpassword")
+	assert.ErrorIs(t, err, clienterrors.AnotherUserLoggedIn)
+}
+
+func TestClientUseCase_TwoFactorWithoutLogin(t *testing.T) {
+	ctx := context.Background()
+	user := &pb.User{Name: "testuser", Password: "testpassword"}
+	tests := []struct {
+		name      string
+		loginErr  error
+		wantErr   error
+		wantCalls bool
+	}{
+		{
+			name:      "session accepted only to enroll",
+			loginErr:  clienterrors.TwoFactorEnrollmentRequired,
+			wantCalls: true,
+		},
+		{
+			name:      "usual session",
+			wantCalls: true,
+		},
+		{
+			name:     "wrong password",
+			loginErr: clienterrors.WrongPassword,
+			wantErr:  clienterrors.WrongPassword,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockTransporter := mocks.NewTransporter(t)
+			testConfig := config.NewClientConfig()
+			clientUseCase := &ClientUseCase{
+				Config:      testConfig,
+				Storage:     mocks.NewStorager(t),
+				Transporter: mockTransporter,
+			}
+			// tokens of session are set by transport at login, as grpc client does
+			mockTransporter.On("Login", ctx, user, "").Run(func(args mock.Arguments) {
+				if tt.wantErr == nil {
+					testConfig.UpdateSession(func(s *config.Session) {
+						s.Token = "enrolltoken"
+					})
+				}
+			}).Return("", tt.loginErr)
+			if tt.wantCalls {
+				mockTransporter.On("EnrollTwoFactor", ctx, user).Return("otpauth://totp/uri", nil)
+				mockTransporter.On("Logout", ctx).Return(nil)
+			}
+
+			uri, err := clientUseCase.EnrollTwoFactor(ctx, "testuser", "testpassword")
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, "otpauth://totp/uri", uri)
+			}
+			// session started for the call is not kept
+			assert.Empty(t, testConfig.AccessToken())
+		})
+	}
+}
+
+// TestClientUseCase_LoginWhileReplaying runs login and lock while replayer pushes outbox,
+// it is meant to be run with -race
+func TestClientUseCase_LoginWhileReplaying(t *testing.T) {
+	wrappedKey := testWrappedKey(t)
+	localKeyCheck, err := testKeyring().KeyCheck()
+	assert.NoError(t, err)
+	mockStorage := mocks.NewStorager(t)
+	mockTransport := mocks.NewTransporter(t)
+	testConfig := config.NewClientConfig()
+	testConfig.KDFTime, testConfig.KDFMemory, testConfig.KDFThreads = testKDFParams.Time, testKDFParams.Memory, testKDFParams.Threads
+	clientUseCase := NewClientUseCase(testConfig, mockStorage, mockTransport)
+
+	// tokens of session are set by transport at login, as grpc client does
+	mockTransport.On("Login", mock.Anything, mock.Anything, "").Run(func(args mock.Arguments) {
+		testConfig.UpdateSession(func(s *config.Session) {
+			s.Token = "testtoken"
+			s.RefreshToken = "testrefresh"
+		})
+	}).Return("testtoken", nil)
+	mockStorage.On("GetUserID", "testuser").Return(int64(1), nil)
+	mockStorage.On("SetUsesVerifier", "testuser").Return(nil)
+	mockStorage.On("GetUserSalt", "testuser").Return([]byte("testsalt12345678"), nil)
+	mockTransport.On("GetMasterKey", mock.Anything).Return(wrappedKey, nil)
+	mockStorage.On("GetUserKeyCheck", "testuser").Return(localKeyCheck, nil)
+	mockStorage.On("GetOutbox", "testuser").Return(nil, nil)
+	mockStorage.On("GetConflicts", "testuser").Return(nil, nil)
+	mockStorage.On("GetLastRevision", "testuser").Return(int64(0), nil)
+	mockStorage.On("IsLegacyMigrated", "testuser").Return(true, nil)
+	mockTransport.On("SyncChanges", mock.Anything, int64(0)).Return([]*pb.SecretData(nil), int64(0), false, nil)
+	mockStorage.On("UpdateLastRevision", "testuser", int64(0)).Return(nil)
+	mockStorage.On("GetData", "testuser").Return(nil, nil)
+	mockStorage.On("SetLegacyMigrated", "testuser").Return(nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		clientUseCase.RunReplayer(ctx, time.Millisecond)
+		close(done)
+	}()
+	for i := 0; i < 20; i++ {
+		err = clientUseCase.LoginUser(context.Background(), "testuser", "password", "testpassphrase", "")
+		assert.NoError(t, err)
+		assert.Equal(t, "testuser", testConfig.UserName())
+		clientUseCase.Lock()
+		assert.Equal(t, "", testConfig.AccessToken())
+	}
+	cancel()
+	<-done
+}
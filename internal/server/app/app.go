@@ -4,6 +4,9 @@ package app
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io/fs"
 	"log"
 	"net"
 	"os"
@@ -16,6 +19,7 @@ import (
 	"github.com/h2p2f/dedicated-vault/internal/server/config"
 	"github.com/h2p2f/dedicated-vault/internal/server/grpcserver"
 	"github.com/h2p2f/dedicated-vault/internal/server/grpcserver/middlewares"
+	"github.com/h2p2f/dedicated-vault/internal/server/jwtprocessing"
 	"github.com/h2p2f/dedicated-vault/internal/server/storage"
 	"github.com/h2p2f/dedicated-vault/internal/server/tlsloader"
 	pb "github.com/h2p2f/dedicated-vault/proto"
@@ -35,8 +39,19 @@ func Run(ctx context.Context, sigint chan os.Signal, connectionsClosed chan<- st
 		zapcore.Lock(os.Stdout),
 		atom))
 	defer logger.Sync() //nolint:errcheck
+	// load keys of tokens
+	keys, err := jwtKeys(conf, logger)
+	if err != nil {
+		logger.Fatal("jwt keys", zap.Error(err))
+	}
+	if conf.TwoFactor == "" {
+		conf.TwoFactor = config.TwoFactorOptional
+	}
+	if conf.TwoFactor != config.TwoFactorOptional && conf.TwoFactor != config.TwoFactorRequired {
+		logger.Fatal("unknown two_factor policy", zap.String("two_factor", conf.TwoFactor))
+	}
 	// create storage
-	db := storage.NewStorage(ctx, conf, logger)
+	db := storage.NewStorage(ctx, conf, keys, logger)
 	// create grpc server
 	// load tls
 	tlsConf, err := tlsloader.LoadTLS(conf)
@@ -49,13 +64,27 @@ func Run(ctx context.Context, sigint chan os.Signal, connectionsClosed chan<- st
 	}
 	// add jwt middleware with unprotected methods
 	unprotectedMethods := map[string]bool{
-		"/DedicatedVault/Register": true,
-		"/DedicatedVault/Login":    true,
+		"/DedicatedVault/Register":     true,
+		"/DedicatedVault/Login":        true,
+		"/DedicatedVault/RefreshToken": true,
+		// password authentication by SRP-6a, password change by ChangeVerifier is protected as ChangePassword
+		"/DedicatedVault/RegisterVerifier": true,
+		"/DedicatedVault/StartAuth":        true,
+		"/DedicatedVault/FinishLogin":      true,
+	}
+	// login of user who has to enroll two-factor authentication gives session accepted only by these methods
+	enrollmentMethods := map[string]bool{
+		"/DedicatedVault/EnrollTwoFactor":  true,
+		"/DedicatedVault/ConfirmTwoFactor": true,
+		"/DedicatedVault/Logout":           true,
 	}
 	opts = append(
 		opts,
 		grpc.UnaryInterceptor(
-			middlewares.JWTCheckingUnaryServerInterceptor(conf.JWTKey, unprotectedMethods),
+			middlewares.JWTCheckingUnaryServerInterceptor(keys, db, unprotectedMethods, enrollmentMethods),
+		),
+		grpc.StreamInterceptor(
+			middlewares.JWTCheckingStreamServerInterceptor(keys, db, unprotectedMethods, enrollmentMethods),
 		))
 	// create listener
 	listener, err := net.Listen("tcp", ":8090")
@@ -75,6 +104,8 @@ func Run(ctx context.Context, sigint chan os.Signal, connectionsClosed chan<- st
 		zap.String("tls cert", conf.ServerCert),
 		zap.String("tls key", conf.ServerKey),
 		zap.String("storage address", conf.StorageAddress),
+		zap.String("jwt key id", keys.SigningKID()),
+		zap.String("two factor", conf.TwoFactor),
 		zap.String("log level", conf.LogLevel))
 	go func() {
 		if err := server.Serve(listener); err != nil {
@@ -89,3 +120,17 @@ func Run(ctx context.Context, sigint chan os.Signal, connectionsClosed chan<- st
 	close(sigint)
 	close(connectionsClosed)
 }
+
+// jwtKeys loads keys of tokens, shared secret is used only if signing key is not configured,
+// the signing key is never generated or shipped with the server, so a missing key stops the server
+func jwtKeys(conf *config.ServerConfig, logger *zap.Logger) (*jwtprocessing.Keys, error) {
+	if conf.JWTSigningKey != "" {
+		if _, err := os.Stat(conf.JWTSigningKey); errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("jwt signing key %s doesn't exist, generate it with: openssl genpkey -algorithm ed25519 -out %s",
+				conf.JWTSigningKey, conf.JWTSigningKey)
+		}
+		return jwtprocessing.LoadKeys(conf.JWTSigningKey, conf.JWTVerificationKeys)
+	}
+	logger.Warn("jwt_signing_key is not set, tokens are signed with shared secret jwt_key, anyone who reads it can issue tokens")
+	return jwtprocessing.NewSecretKeys(conf.JWTKey)
+}
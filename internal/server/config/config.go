@@ -18,6 +18,24 @@ type ServerConfig struct {
 	DBPassword     string `yaml:"db_password"`
 	ServerCert     string `yaml:"server_cert"`
 	ServerKey      string `yaml:"server_key"`
+	// JWTSigningKey is PEM file of Ed25519 or RSA private key new tokens are signed with,
+	// tokens are also accepted with JWTVerificationKeys during rotation, JWTKey is used only without signing key
+	JWTSigningKey       string   `yaml:"jwt_signing_key"`
+	JWTVerificationKeys []string `yaml:"jwt_verification_keys"`
+	// TwoFactor is policy of two-factor authentication: "optional" (default) lets users enable it,
+	// "required" doesn't let users without it log in until they enroll
+	TwoFactor string `yaml:"two_factor"`
+}
+
+// two-factor authentication policies
+const (
+	TwoFactorOptional = "optional"
+	TwoFactorRequired = "required"
+)
+
+// RequiresTwoFactor - whether two-factor authentication is required for login
+func (c *ServerConfig) RequiresTwoFactor() bool {
+	return c.TwoFactor == TwoFactorRequired
 }
 
 // NewServerConfig - function of obtaining the server configuration, processes the yaml file
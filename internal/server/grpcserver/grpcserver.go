@@ -4,13 +4,25 @@ package grpcserver
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"math"
+	"net"
 
+	"github.com/google/uuid"
 	"go.uber.org/zap"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/status"
 
 	"github.com/h2p2f/dedicated-vault/internal/server/models"
+	"github.com/h2p2f/dedicated-vault/internal/server/servererrors"
+	"github.com/h2p2f/dedicated-vault/internal/srp"
 	pb "github.com/h2p2f/dedicated-vault/proto"
 )
 
@@ -18,10 +30,22 @@ import (
 //
 //go:generate mockery --name UserHandler --output ./mocks --filename mocks_userhandler.go
 type UserHandler interface {
-	Register(ctx context.Context, user models.User) (string, int64, error)
-	Login(ctx context.Context, user models.User) (string, int64, error)
+	Register(ctx context.Context, user models.User, device models.Device) (models.Tokens, int64, error)
+	Login(ctx context.Context, user models.User, code string, device models.Device) (models.Tokens, int64, error)
 	GetUser(ctx context.Context, user string) (models.User, error)
-	ChangePassword(ctx context.Context, user models.User, newPassword string) (string, error)
+	ChangePassword(ctx context.Context, user models.User, newPassword string, device models.Device) (models.Tokens, error)
+	SetWrappedKey(ctx context.Context, user models.User, wrappedKey, keyCheck []byte, expectedVersion int64) (int64, error)
+	RefreshToken(ctx context.Context, refreshToken string) (models.Tokens, error)
+	Logout(ctx context.Context, user models.User, sessionID string) error
+	ListSessions(ctx context.Context, user models.User) ([]models.Session, error)
+	RevokeSession(ctx context.Context, user models.User, sessionID string) error
+	EnrollTwoFactor(ctx context.Context, user models.User, proof models.PasswordProof) (string, []byte, error)
+	ConfirmTwoFactor(ctx context.Context, user models.User, sessionID string, proof models.PasswordProof, code string) ([]string, []byte, error)
+	DisableTwoFactor(ctx context.Context, user models.User, proof models.PasswordProof, code string) ([]byte, error)
+	RegisterVerifier(ctx context.Context, user models.User, device models.Device) (models.Tokens, int64, error)
+	StartAuth(ctx context.Context, login, address string, clientPublic []byte) (models.Challenge, error)
+	FinishLogin(ctx context.Context, proof models.PasswordProof, code string, device models.Device) (models.Tokens, int64, []byte, error)
+	ChangeVerifier(ctx context.Context, user models.User, proof models.PasswordProof, device models.Device) (models.Tokens, []byte, error)
 }
 
 // DataHandler is an interface for data handling
@@ -30,10 +54,21 @@ type UserHandler interface {
 type DataHandler interface {
 	CreateData(ctx context.Context, user models.User, data models.VaultData) (string, int64, error)
 	ChangeData(ctx context.Context, user models.User, data models.VaultData) (int64, error)
+	GetData(ctx context.Context, user models.User, dataUUID string) (models.VaultData, error)
 	GetAllData(ctx context.Context, user models.User) ([]models.VaultData, error)
+	GetChangedData(ctx context.Context, user models.User, sinceRevision, limit int64) ([]models.VaultData, bool, error)
 	DeleteData(ctx context.Context, user models.User, data models.VaultData) (int64, error)
+	SaveChunk(ctx context.Context, user models.User, chunk models.VaultChunk) error
+	GetChunks(ctx context.Context, user models.User, dataUUID, chunksID string, send func(chunk []byte) error) error
+	DeleteChunks(ctx context.Context, user models.User, dataUUID, chunksID string) error
 }
 
+// maxChunkSize limits size of one chunk of streamed binary content
+const maxChunkSize = 1 << 20
+
+// maxSyncLimit limits number of changes returned by one SyncChanges
+const maxSyncLimit = 500
+
 // VaultServer is a struct for handling grpc requests
 type VaultServer struct {
 	pb.UnimplementedDedicatedVaultServer
@@ -54,73 +89,515 @@ func NewVaultServer(uh UserHandler, dh DataHandler, logger *zap.Logger) *VaultSe
 func (s *VaultServer) Register(ctx context.Context, req *pb.RegisterRequest) (*pb.RegisterResponse, error) {
 
 	if req.User.Name == "" || req.User.Password == "" {
-		s.logger.Error("login or password is empty", zap.Any("user", req.User))
+		s.logger.Error("login or password is empty", zap.String("user", req.User.GetName()))
 		return nil, status.Error(codes.InvalidArgument, "login or password is empty")
 	}
 	token, lastServerUpdated, err := s.userHandler.Register(ctx, models.User{
 		Login:    req.User.Name,
 		Password: req.User.Password,
-	})
+	}, deviceOf(ctx, req.DeviceName))
+	if errors.Is(err, servererrors.TwoFactorEnrollmentRequired) {
+		s.logger.Info("registered user without session, two-factor enrollment is required", zap.String("user", req.User.GetName()))
+		return nil, authError(err)
+	}
 	if err != nil {
-		s.logger.Error("error registering user", zap.Any("user", req.User), zap.Error(err))
+		s.logger.Error("error registering user", zap.String("user", req.User.GetName()), zap.Error(err))
 		return nil, status.Error(codes.Internal, err.Error())
 	}
 
 	response := pb.RegisterResponse{
-		Token:             token,
+		Token:             token.Access,
 		LastServerUpdated: lastServerUpdated,
+		RefreshToken:      token.Refresh,
+		ExpiresIn:         token.ExpiresIn,
 	}
-	s.logger.Info("registered user", zap.Any("user", req.User))
+	s.logger.Info("registered user", zap.String("user", req.User.GetName()))
 	return &response, nil
 }
 
 // Login handles grpc requests for logging in a user
 func (s *VaultServer) Login(ctx context.Context, req *pb.LoginRequest) (*pb.LoginResponse, error) {
 	if req.User.Name == "" || req.User.Password == "" {
-		s.logger.Error("login or password is empty", zap.Any("user", req.User))
+		s.logger.Error("login or password is empty", zap.String("user", req.User.GetName()))
 		return nil, status.Error(codes.InvalidArgument, "login or password is empty")
 	}
 
 	token, lastServerUpdated, err := s.userHandler.Login(ctx, models.User{
 		Login:    req.User.Name,
 		Password: req.User.Password,
-	})
-	if err != nil {
-		s.logger.Error("error logging in user", zap.Any("user", req.User), zap.Error(err))
+	}, req.OtpCode, deviceOf(ctx, req.DeviceName))
+	enrollmentOnly := enrollmentSession(err, token)
+	if err != nil && !enrollmentOnly {
+		s.logger.Error("error logging in user", zap.String("user", req.User.GetName()), zap.Error(err))
+		if st := authError(err); st != nil {
+			return nil, st
+		}
 		return nil, status.Error(codes.Internal, err.Error())
 	}
 
 	response := pb.LoginResponse{
-		Token:             token,
-		LastServerUpdated: lastServerUpdated,
+		Token:                       token.Access,
+		LastServerUpdated:           lastServerUpdated,
+		RefreshToken:                token.Refresh,
+		ExpiresIn:                   token.ExpiresIn,
+		TwoFactorEnrollmentRequired: enrollmentOnly,
 	}
-	s.logger.Info("logged in user", zap.Any("user", req.User))
+	s.logger.Info("logged in user", zap.String("user", req.User.GetName()), zap.Bool("enrollment only", enrollmentOnly))
 	return &response, nil
 }
 
 // ChangePassword handles grpc requests for changing a user's password
 func (s *VaultServer) ChangePassword(ctx context.Context, req *pb.ChangePasswordRequest) (*pb.ChangePasswordResponse, error) {
 	if req.User.Name == "" || req.User.Password == "" || req.NewPassword == "" {
-		s.logger.Error("login or password is empty", zap.Any("user", req.User))
+		s.logger.Error("login or password is empty", zap.String("user", req.User.GetName()))
 		return nil, status.Error(codes.InvalidArgument, "login or password is empty")
 	}
 
 	token, err := s.userHandler.ChangePassword(ctx, models.User{
 		Login:    req.User.Name,
 		Password: req.User.Password,
-	}, req.NewPassword)
+	}, req.NewPassword, deviceOf(ctx, req.DeviceName))
 	if err != nil {
-		s.logger.Error("error changing password", zap.Any("user", req.User), zap.Error(err))
+		s.logger.Error("error changing password", zap.String("user", req.User.GetName()), zap.Error(err))
+		if st := authError(err); st != nil {
+			return nil, st
+		}
 		return nil, status.Error(codes.Internal, err.Error())
 	}
 
 	response := pb.ChangePasswordResponse{
-		Token: token,
+		Token:        token.Access,
+		RefreshToken: token.Refresh,
+		ExpiresIn:    token.ExpiresIn,
+	}
+	s.logger.Info("changed password", zap.String("user", req.User.GetName()))
+	return &response, nil
+}
+
+// RefreshToken handles grpc requests for new tokens of session, refresh token is rotated
+func (s *VaultServer) RefreshToken(ctx context.Context, req *pb.RefreshTokenRequest) (*pb.RefreshTokenResponse, error) {
+	if req.RefreshToken == "" {
+		s.logger.Error("refresh token is empty")
+		return nil, status.Error(codes.InvalidArgument, "refresh token is empty")
+	}
+	token, err := s.userHandler.RefreshToken(ctx, req.RefreshToken)
+	if errors.Is(err, servererrors.SessionRevoked) {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	if err != nil {
+		s.logger.Error("error refreshing token", zap.Error(err))
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &pb.RefreshTokenResponse{
+		Token:        token.Access,
+		RefreshToken: token.Refresh,
+		ExpiresIn:    token.ExpiresIn,
+	}, nil
+}
+
+// Logout handles grpc requests for revoking session of the token
+func (s *VaultServer) Logout(ctx context.Context, req *pb.LogoutRequest) (*pb.LogoutResponse, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		s.logger.Error("metadata is empty")
+		return nil, status.Error(codes.InvalidArgument, "metadata is empty")
+	}
+	userFromContext := md.Get("user")
+	sessionFromContext := md.Get("session")
+	if len(userFromContext) == 0 || userFromContext[0] == "" || len(sessionFromContext) == 0 {
+		s.logger.Error("user or session is empty")
+		return nil, status.Error(codes.InvalidArgument, "user is empty")
+	}
+	err := s.userHandler.Logout(ctx, models.User{UUID: userFromContext[0]}, sessionFromContext[0])
+	if err != nil {
+		s.logger.Error("error logging out user", zap.Any("user", userFromContext[0]), zap.Error(err))
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	s.logger.Info("logged out user", zap.Any("user", userFromContext[0]))
+	return &pb.LogoutResponse{}, nil
+}
+
+// ListSessions handles grpc requests for sessions of user, session of the request is marked as current
+func (s *VaultServer) ListSessions(ctx context.Context, req *pb.ListSessionsRequest) (*pb.ListSessionsResponse, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		s.logger.Error("metadata is empty")
+		return nil, status.Error(codes.InvalidArgument, "metadata is empty")
+	}
+	userFromContext := md.Get("user")
+	if len(userFromContext) == 0 || userFromContext[0] == "" {
+		s.logger.Error("user is empty")
+		return nil, status.Error(codes.InvalidArgument, "user is empty")
+	}
+	var currentSession string
+	if sessionFromContext := md.Get("session"); len(sessionFromContext) > 0 {
+		currentSession = sessionFromContext[0]
+	}
+	sessions, err := s.userHandler.ListSessions(ctx, models.User{UUID: userFromContext[0]})
+	if err != nil {
+		s.logger.Error("error listing sessions", zap.Any("user", userFromContext[0]), zap.Error(err))
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	response := pb.ListSessionsResponse{}
+	for _, session := range sessions {
+		response.Sessions = append(response.Sessions, &pb.Session{
+			Id:              session.SessionID,
+			DeviceName:      session.Name,
+			CertFingerprint: session.CertFingerprint,
+			Ip:              session.IP,
+			Created:         session.Created,
+			LastSeen:        session.LastSeen,
+			Current:         session.SessionID == currentSession,
+		})
 	}
-	s.logger.Info("changed password", zap.Any("user", req.User))
 	return &response, nil
 }
 
+// RevokeSession handles grpc requests for revoking session of user by its id
+func (s *VaultServer) RevokeSession(ctx context.Context, req *pb.RevokeSessionRequest) (*pb.RevokeSessionResponse, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		s.logger.Error("metadata is empty")
+		return nil, status.Error(codes.InvalidArgument, "metadata is empty")
+	}
+	userFromContext := md.Get("user")
+	if len(userFromContext) == 0 || userFromContext[0] == "" {
+		s.logger.Error("user is empty")
+		return nil, status.Error(codes.InvalidArgument, "user is empty")
+	}
+	if req.Id == "" {
+		s.logger.Error("session id is empty")
+		return nil, status.Error(codes.InvalidArgument, "session id is empty")
+	}
+	err := s.userHandler.RevokeSession(ctx, models.User{UUID: userFromContext[0]}, req.Id)
+	if errors.Is(err, servererrors.RecordNotFound) {
+		return nil, status.Error(codes.NotFound, "session not found")
+	}
+	if err != nil {
+		s.logger.Error("error revoking session", zap.Any("user", userFromContext[0]), zap.Error(err))
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	s.logger.Info("revoked session", zap.Any("user", userFromContext[0]), zap.String("session", req.Id))
+	return &pb.RevokeSessionResponse{}, nil
+}
+
+// EnrollTwoFactor handles grpc requests for enrollment of two-factor authentication
+// user of token is authenticated again by proof of password or by password, session accepted only to enroll is enough
+func (s *VaultServer) EnrollTwoFactor(ctx context.Context, req *pb.EnrollTwoFactorRequest) (*pb.EnrollTwoFactorResponse, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		s.logger.Error("metadata is empty")
+		return nil, status.Error(codes.InvalidArgument, "metadata is empty")
+	}
+	userFromContext := md.Get("user")
+	if len(userFromContext) == 0 || userFromContext[0] == "" {
+		s.logger.Error("user is empty")
+		return nil, status.Error(codes.InvalidArgument, "user is empty")
+	}
+	if !hasPassword(req.User, req.Proof) {
+		s.logger.Error("login or password is empty")
+		return nil, status.Error(codes.InvalidArgument, "login or password is empty")
+	}
+	uri, serverProof, err := s.userHandler.EnrollTwoFactor(ctx, models.User{
+		UUID:     userFromContext[0],
+		Login:    req.User.GetName(),
+		Password: req.User.GetPassword(),
+	}, proofOf(req.Proof))
+	if err != nil {
+		s.logger.Error("error enrolling two-factor authentication", zap.String("user", userFromContext[0]), zap.Error(err))
+		if st := authError(err); st != nil {
+			return nil, st
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	s.logger.Info("started two-factor enrollment", zap.String("user", userFromContext[0]))
+	return &pb.EnrollTwoFactorResponse{ProvisioningUri: uri, ServerProof: serverProof}, nil
+}
+
+// ConfirmTwoFactor handles grpc requests for enabling enrolled two-factor authentication, recovery codes are returned
+// session of the request accepted only to enroll becomes a usual one
+func (s *VaultServer) ConfirmTwoFactor(ctx context.Context, req *pb.ConfirmTwoFactorRequest) (*pb.ConfirmTwoFactorResponse, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		s.logger.Error("metadata is empty")
+		return nil, status.Error(codes.InvalidArgument, "metadata is empty")
+	}
+	userFromContext := md.Get("user")
+	sessionFromContext := md.Get("session")
+	if len(userFromContext) == 0 || userFromContext[0] == "" || len(sessionFromContext) == 0 {
+		s.logger.Error("user or session is empty")
+		return nil, status.Error(codes.InvalidArgument, "user is empty")
+	}
+	if !hasPassword(req.User, req.Proof) || req.OtpCode == "" {
+		s.logger.Error("login, password or code is empty")
+		return nil, status.Error(codes.InvalidArgument, "login, password or code is empty")
+	}
+	recoveryCodes, serverProof, err := s.userHandler.ConfirmTwoFactor(ctx, models.User{
+		UUID:     userFromContext[0],
+		Login:    req.User.GetName(),
+		Password: req.User.GetPassword(),
+	}, sessionFromContext[0], proofOf(req.Proof), req.OtpCode)
+	if err != nil {
+		s.logger.Error("error confirming two-factor authentication", zap.String("user", userFromContext[0]), zap.Error(err))
+		if st := authError(err); st != nil {
+			return nil, st
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	s.logger.Info("enabled two-factor authentication", zap.String("user", userFromContext[0]))
+	return &pb.ConfirmTwoFactorResponse{RecoveryCodes: recoveryCodes, ServerProof: serverProof}, nil
+}
+
+// DisableTwoFactor handles grpc requests for disabling two-factor authentication, a current TOTP code is required
+func (s *VaultServer) DisableTwoFactor(ctx context.Context, req *pb.DisableTwoFactorRequest) (*pb.DisableTwoFactorResponse, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		s.logger.Error("metadata is empty")
+		return nil, status.Error(codes.InvalidArgument, "metadata is empty")
+	}
+	userFromContext := md.Get("user")
+	if len(userFromContext) == 0 || userFromContext[0] == "" {
+		s.logger.Error("user is empty")
+		return nil, status.Error(codes.InvalidArgument, "user is empty")
+	}
+	if !hasPassword(req.User, req.Proof) || req.OtpCode == "" {
+		s.logger.Error("login, password or code is empty")
+		return nil, status.Error(codes.InvalidArgument, "login, password or code is empty")
+	}
+	serverProof, err := s.userHandler.DisableTwoFactor(ctx, models.User{
+		UUID:     userFromContext[0],
+		Login:    req.User.GetName(),
+		Password: req.User.GetPassword(),
+	}, proofOf(req.Proof), req.OtpCode)
+	if err != nil {
+		s.logger.Error("error disabling two-factor authentication", zap.String("user", userFromContext[0]), zap.Error(err))
+		if st := authError(err); st != nil {
+			return nil, st
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	s.logger.Info("disabled two-factor authentication", zap.String("user", userFromContext[0]))
+	return &pb.DisableTwoFactorResponse{ServerProof: serverProof}, nil
+}
+
+// RegisterVerifier handles grpc requests for registering a user with verifier of password
+func (s *VaultServer) RegisterVerifier(ctx context.Context, req *pb.RegisterVerifierRequest) (*pb.RegisterResponse, error) {
+	if req.Name == "" {
+		s.logger.Error("login is empty")
+		return nil, status.Error(codes.InvalidArgument, "login is empty")
+	}
+	verifier, err := verifierOf(req.Verifier)
+	if err != nil {
+		s.logger.Error("invalid password verifier", zap.String("user", req.Name), zap.Error(err))
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	token, lastServerUpdated, err := s.userHandler.RegisterVerifier(ctx, models.User{
+		Login:            req.Name,
+		PasswordVerifier: verifier,
+	}, deviceOf(ctx, req.DeviceName))
+	if errors.Is(err, servererrors.TwoFactorEnrollmentRequired) {
+		s.logger.Info("registered user without session, two-factor enrollment is required", zap.String("user", req.Name))
+		return nil, authError(err)
+	}
+	if err != nil {
+		s.logger.Error("error registering user", zap.String("user", req.Name), zap.Error(err))
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	s.logger.Info("registered user", zap.String("user", req.Name))
+	return &pb.RegisterResponse{
+		Token:             token.Access,
+		LastServerUpdated: lastServerUpdated,
+		RefreshToken:      token.Refresh,
+		ExpiresIn:         token.ExpiresIn,
+	}, nil
+}
+
+// StartAuth handles grpc requests for starting password authentication, client gets salt and public value of server
+func (s *VaultServer) StartAuth(ctx context.Context, req *pb.StartAuthRequest) (*pb.StartAuthResponse, error) {
+	if req.Name == "" {
+		s.logger.Error("login is empty")
+		return nil, status.Error(codes.InvalidArgument, "login is empty")
+	}
+	if err := srp.ValidPublic(req.ClientPublic); err != nil {
+		s.logger.Error("invalid client public value", zap.String("user", req.Name), zap.Error(err))
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	challenge, err := s.userHandler.StartAuth(ctx, req.Name, deviceOf(ctx, "").IP, req.ClientPublic)
+	if err != nil {
+		s.logger.Error("error starting password authentication", zap.String("user", req.Name), zap.Error(err))
+		if st := authError(err); st != nil {
+			return nil, st
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &pb.StartAuthResponse{
+		HandshakeId:  challenge.HandshakeID,
+		Salt:         challenge.Salt,
+		Params:       paramsToPB(challenge.Params),
+		ServerPublic: challenge.ServerPublic,
+	}, nil
+}
+
+// FinishLogin handles grpc requests for logging in a user by proof of password
+func (s *VaultServer) FinishLogin(ctx context.Context, req *pb.FinishLoginRequest) (*pb.FinishLoginResponse, error) {
+	if req.Proof.GetHandshakeId() == "" {
+		s.logger.Error("password proof is empty")
+		return nil, status.Error(codes.InvalidArgument, "password proof is empty")
+	}
+	token, lastServerUpdated, serverProof, err := s.userHandler.FinishLogin(ctx, proofOf(req.Proof), req.OtpCode, deviceOf(ctx, req.DeviceName))
+	enrollmentOnly := enrollmentSession(err, token)
+	if err != nil && !enrollmentOnly {
+		s.logger.Error("error logging in user", zap.Error(err))
+		if st := authError(err); st != nil {
+			return nil, st
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	s.logger.Info("logged in user by password proof", zap.Bool("enrollment only", enrollmentOnly))
+	return &pb.FinishLoginResponse{
+		Token:                       token.Access,
+		LastServerUpdated:           lastServerUpdated,
+		RefreshToken:                token.Refresh,
+		ExpiresIn:                   token.ExpiresIn,
+		ServerProof:                 serverProof,
+		TwoFactorEnrollmentRequired: enrollmentOnly,
+	}, nil
+}
+
+// ChangeVerifier handles grpc requests for changing a user's password by proof of the current password
+func (s *VaultServer) ChangeVerifier(ctx context.Context, req *pb.ChangeVerifierRequest) (*pb.ChangePasswordResponse, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		s.logger.Error("metadata is empty")
+		return nil, status.Error(codes.InvalidArgument, "metadata is empty")
+	}
+	userFromContext := md.Get("user")
+	if len(userFromContext) == 0 || userFromContext[0] == "" {
+		s.logger.Error("user is empty")
+		return nil, status.Error(codes.InvalidArgument, "user is empty")
+	}
+	if req.Proof.GetHandshakeId() == "" {
+		s.logger.Error("password proof is empty")
+		return nil, status.Error(codes.InvalidArgument, "password proof is empty")
+	}
+	verifier, err := verifierOf(req.NewVerifier)
+	if err != nil {
+		s.logger.Error("invalid password verifier", zap.Any("user", userFromContext[0]), zap.Error(err))
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	token, serverProof, err := s.userHandler.ChangeVerifier(ctx, models.User{
+		UUID:             userFromContext[0],
+		PasswordVerifier: verifier,
+	}, proofOf(req.Proof), deviceOf(ctx, req.DeviceName))
+	if err != nil {
+		s.logger.Error("error changing password", zap.Any("user", userFromContext[0]), zap.Error(err))
+		if st := authError(err); st != nil {
+			return nil, st
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	s.logger.Info("changed password", zap.Any("user", userFromContext[0]))
+	return &pb.ChangePasswordResponse{
+		Token:        token.Access,
+		RefreshToken: token.Refresh,
+		ExpiresIn:    token.ExpiresIn,
+		ServerProof:  serverProof,
+	}, nil
+}
+
+// hasPassword - request is authenticated by proof of password or by login and password
+func hasPassword(user *pb.User, proof *pb.PasswordProof) bool {
+	return proof.GetHandshakeId() != "" || (user.GetName() != "" && user.GetPassword() != "")
+}
+
+// enrollmentSession - whether login gave tokens of session accepted only to enroll two-factor authentication
+func enrollmentSession(err error, token models.Tokens) bool {
+	return errors.Is(err, servererrors.TwoFactorEnrollmentRequired) && token.Access != ""
+}
+
+// proofOf - proof of password of request, it is empty if request has no proof
+func proofOf(proof *pb.PasswordProof) models.PasswordProof {
+	return models.PasswordProof{
+		HandshakeID: proof.GetHandshakeId(),
+		Proof:       proof.GetProof(),
+	}
+}
+
+// maxVerifierSize - verifier is a number modulo N of 2048-bit group
+const maxVerifierSize = 256
+
+// verifierOf - verifier of password of request, verifiers with short salt or weak params of password key are rejected
+func verifierOf(verifier *pb.PasswordVerifier) (models.PasswordVerifier, error) {
+	if len(verifier.GetSalt()) < srp.SaltSize {
+		return models.PasswordVerifier{}, errors.New("salt of password verifier is too short")
+	}
+	if len(verifier.GetVerifier()) == 0 || len(verifier.GetVerifier()) > maxVerifierSize {
+		return models.PasswordVerifier{}, errors.New("password verifier is empty or too long")
+	}
+	if verifier.Params.GetThreads() > math.MaxUint8 {
+		return models.PasswordVerifier{}, errors.New("too many threads in params of password verifier")
+	}
+	params := srp.Params{
+		Time:    verifier.Params.GetTime(),
+		Memory:  verifier.Params.GetMemory(),
+		Threads: uint8(verifier.Params.GetThreads()),
+	}
+	if err := params.Valid(); err != nil {
+		return models.PasswordVerifier{}, err
+	}
+	return models.PasswordVerifier{
+		Salt:     verifier.Salt,
+		Verifier: verifier.Verifier,
+		Params:   params,
+	}, nil
+}
+
+// paramsToPB - params of password key in grpc message
+func paramsToPB(params srp.Params) *pb.KDFParams {
+	return &pb.KDFParams{
+		Time:    params.Time,
+		Memory:  params.Memory,
+		Threads: uint32(params.Threads),
+	}
+}
+
+// errorDomain - domain of reasons of errors
+const errorDomain = "dedicated-vault"
+
+// authErrors - grpc codes and reasons of errors of password and two-factor authentication, clients recognize errors by reasons
+var authErrors = []struct {
+	err    error
+	code   codes.Code
+	reason string
+}{
+	{err: servererrors.TwoFactorRequired, code: codes.FailedPrecondition, reason: "TWO_FACTOR_REQUIRED"},
+	{err: servererrors.TwoFactorEnrollmentRequired, code: codes.FailedPrecondition, reason: "TWO_FACTOR_ENROLLMENT_REQUIRED"},
+	{err: servererrors.InvalidTwoFactorCode, code: codes.PermissionDenied, reason: "INVALID_TWO_FACTOR_CODE"},
+	{err: servererrors.TwoFactorAlreadyEnabled, code: codes.AlreadyExists, reason: "TWO_FACTOR_ALREADY_ENABLED"},
+	{err: servererrors.TwoFactorNotEnabled, code: codes.FailedPrecondition, reason: "TWO_FACTOR_NOT_ENABLED"},
+	{err: servererrors.TwoFactorEnforced, code: codes.FailedPrecondition, reason: "TWO_FACTOR_ENFORCED"},
+	{err: servererrors.TwoFactorLocked, code: codes.ResourceExhausted, reason: "TWO_FACTOR_LOCKED"},
+	{err: servererrors.WrongPassword, code: codes.PermissionDenied, reason: "WRONG_PASSWORD"},
+	{err: servererrors.InvalidPasswordProof, code: codes.PermissionDenied, reason: "INVALID_PASSWORD_PROOF"},
+	{err: servererrors.TooManyHandshakes, code: codes.ResourceExhausted, reason: "TOO_MANY_HANDSHAKES"},
+}
+
+// authError converts error of authentication to grpc status with its reason, nil is returned for other errors
+func authError(err error) error {
+	for _, known := range authErrors {
+		if !errors.Is(err, known.err) {
+			continue
+		}
+		st := status.New(known.code, known.err.Error())
+		withDetails, detailsErr := st.WithDetails(&errdetails.ErrorInfo{Reason: known.reason, Domain: errorDomain})
+		if detailsErr != nil {
+			return st.Err()
+		}
+		return withDetails.Err()
+	}
+	return nil
+}
+
 // SaveSecret handles grpc requests for saving a secret
 func (s *VaultServer) SaveSecret(ctx context.Context, req *pb.SaveSecretRequest) (*pb.SaveSecretResponse, error) {
 	md, ok := metadata.FromIncomingContext(ctx)
@@ -144,18 +621,20 @@ func (s *VaultServer) SaveSecret(ctx context.Context, req *pb.SaveSecretRequest)
 	}
 
 	dataUUID, created, err := s.dataHandler.CreateData(ctx, user, models.VaultData{
+		DataUUID: req.Data.Uuid,
 		Meta:     req.Data.Meta,
 		DataType: req.Data.Type,
 		Data:     req.Data.Value,
 	})
 	if err != nil {
 		s.logger.Error("error creating data", zap.Any("user", userFromContext[0]), zap.Error(err))
-		return nil, status.Error(codes.Internal, err.Error())
+		return nil, s.dataError(ctx, user, req.Data.Uuid, err)
 	}
 	response := pb.SaveSecretResponse{
 		Uuid:              dataUUID,
 		Created:           created,
 		LastServerUpdated: created,
+		Version:           1,
 	}
 	return &response, nil
 }
@@ -181,20 +660,21 @@ func (s *VaultServer) ChangeSecret(ctx context.Context, req *pb.ChangeSecretRequ
 		s.logger.Error("error getting user", zap.Any("user", userFromContext[0]), zap.Error(err))
 		return nil, status.Error(codes.Internal, err.Error())
 	}
-	_ = user
 	updated, err := s.dataHandler.ChangeData(ctx, user, models.VaultData{
 		DataUUID: req.Data.Uuid,
 		Meta:     req.Data.Meta,
 		DataType: req.Data.Type,
 		Data:     req.Data.Value,
+		Version:  req.ExpectedVersion,
 	})
 	if err != nil {
 		s.logger.Error("error changing data", zap.Any("user", userFromContext[0]), zap.Error(err))
-		return nil, status.Error(codes.Internal, err.Error())
+		return nil, s.dataError(ctx, user, req.Data.Uuid, err)
 	}
 	response := pb.ChangeSecretResponse{
 		Updated:           updated,
 		LastServerUpdated: updated,
+		Version:           req.ExpectedVersion + 1,
 	}
 	return &response, nil
 }
@@ -222,10 +702,11 @@ func (s *VaultServer) DeleteSecret(ctx context.Context, req *pb.DeleteSecretRequ
 	}
 	lastServerUpdated, err := s.dataHandler.DeleteData(ctx, user, models.VaultData{
 		DataUUID: req.Uuid,
+		Version:  req.ExpectedVersion,
 	})
 	if err != nil {
 		s.logger.Error("error deleting data", zap.Any("user", userFromContext[0]), zap.Error(err))
-		return nil, status.Error(codes.Internal, err.Error())
+		return nil, s.dataError(ctx, user, req.Uuid, err)
 	}
 	response := pb.DeleteSecretResponse{
 		Uuid:              req.Uuid,
@@ -265,12 +746,347 @@ func (s *VaultServer) ListSecrets(ctx context.Context, req *pb.ListSecretsReques
 	for _, d := range data {
 		s.logger.Info("data", zap.Any("data", d))
 		response.Data = append(response.Data, &pb.SecretData{
-			Uuid:  d.DataUUID,
-			Meta:  d.Meta,
-			Type:  d.DataType,
-			Value: d.Data,
+			Uuid:     d.DataUUID,
+			Meta:     d.Meta,
+			Type:     d.DataType,
+			Value:    d.Data,
+			Revision: d.Revision,
+			Version:  d.Version,
 		})
 	}
 	_ = req
 	return &response, nil
 }
+
+// SyncChanges handles grpc requests for secrets changed after the given revision
+func (s *VaultServer) SyncChanges(ctx context.Context, req *pb.SyncChangesRequest) (*pb.SyncChangesResponse, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		s.logger.Error("metadata is empty")
+		return nil, status.Error(codes.InvalidArgument, "metadata is empty")
+	}
+	userFromContext := md.Get("user")
+	if len(userFromContext) == 0 {
+		s.logger.Error("userFromContext is empty")
+		return nil, status.Error(codes.InvalidArgument, "user is empty")
+	}
+	if userFromContext[0] == "" {
+		s.logger.Error("user is empty")
+		return nil, status.Error(codes.InvalidArgument, "user is empty")
+	}
+	if req.SinceRevision < 0 {
+		s.logger.Error("since revision is negative", zap.Int64("since", req.SinceRevision))
+		return nil, status.Error(codes.InvalidArgument, "since revision is negative")
+	}
+	user, err := s.userHandler.GetUser(ctx, userFromContext[0])
+	if err != nil {
+		s.logger.Error("error getting user", zap.Any("user", userFromContext[0]), zap.Error(err))
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	limit := req.Limit
+	if limit <= 0 || limit > maxSyncLimit {
+		limit = maxSyncLimit
+	}
+	data, more, err := s.dataHandler.GetChangedData(ctx, user, req.SinceRevision, limit)
+	if err != nil {
+		s.logger.Error("error getting changed data", zap.Any("user", userFromContext[0]), zap.Error(err))
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	// the response revision is the newest one actually returned,
+	// so the client never skips a change that was not delivered to it
+	response := pb.SyncChangesResponse{
+		Revision: req.SinceRevision,
+		More:     more,
+	}
+	for _, d := range data {
+		response.Data = append(response.Data, &pb.SecretData{
+			Uuid:     d.DataUUID,
+			Meta:     d.Meta,
+			Type:     d.DataType,
+			Value:    d.Data,
+			Revision: d.Revision,
+			Deleted:  d.Deleted,
+			Version:  d.Version,
+		})
+		if d.Revision > response.Revision {
+			response.Revision = d.Revision
+		}
+	}
+	return &response, nil
+}
+
+// GetMasterKey handles grpc requests for the user's wrapped master key
+func (s *VaultServer) GetMasterKey(ctx context.Context, req *pb.GetMasterKeyRequest) (*pb.GetMasterKeyResponse, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		s.logger.Error("metadata is empty")
+		return nil, status.Error(codes.InvalidArgument, "metadata is empty")
+	}
+	userFromContext := md.Get("user")
+	if len(userFromContext) == 0 {
+		s.logger.Error("userFromContext is empty")
+		return nil, status.Error(codes.InvalidArgument, "user is empty")
+	}
+	if userFromContext[0] == "" {
+		s.logger.Error("user is empty")
+		return nil, status.Error(codes.InvalidArgument, "user is empty")
+	}
+	user, err := s.userHandler.GetUser(ctx, userFromContext[0])
+	if err != nil {
+		s.logger.Error("error getting user", zap.Any("user", userFromContext[0]), zap.Error(err))
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	response := pb.GetMasterKeyResponse{
+		WrappedKey: user.WrappedKey,
+		Version:    user.WrappedKeyVersion,
+		KeyCheck:   user.KeyCheck,
+	}
+	return &response, nil
+}
+
+// SetMasterKey handles grpc requests for replacing the user's wrapped master key
+func (s *VaultServer) SetMasterKey(ctx context.Context, req *pb.SetMasterKeyRequest) (*pb.SetMasterKeyResponse, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		s.logger.Error("metadata is empty")
+		return nil, status.Error(codes.InvalidArgument, "metadata is empty")
+	}
+	userFromContext := md.Get("user")
+	if len(userFromContext) == 0 {
+		s.logger.Error("userFromContext is empty")
+		return nil, status.Error(codes.InvalidArgument, "user is empty")
+	}
+	if userFromContext[0] == "" {
+		s.logger.Error("user is empty")
+		return nil, status.Error(codes.InvalidArgument, "user is empty")
+	}
+	if len(req.WrappedKey) == 0 {
+		s.logger.Error("wrapped key is empty")
+		return nil, status.Error(codes.InvalidArgument, "wrapped key is empty")
+	}
+	user, err := s.userHandler.GetUser(ctx, userFromContext[0])
+	if err != nil {
+		s.logger.Error("error getting user", zap.Any("user", userFromContext[0]), zap.Error(err))
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	version, err := s.userHandler.SetWrappedKey(ctx, user, req.WrappedKey, req.KeyCheck, req.ExpectedVersion)
+	if err != nil {
+		s.logger.Error("error setting wrapped key", zap.Any("user", userFromContext[0]), zap.Error(err))
+		if errors.Is(err, servererrors.VersionConflict) {
+			return nil, status.Error(codes.Aborted, err.Error())
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	response := pb.SetMasterKeyResponse{
+		Version: version,
+	}
+	return &response, nil
+}
+
+// UploadSecret handles grpc streams for saving a secret with binary content
+// the first message carries header of data, the next ones carry encrypted chunks of content,
+// chunks are saved under new id and header is created or changed only after all of them are received,
+// so a broken upload never replaces the previous content
+func (s *VaultServer) UploadSecret(stream pb.DedicatedVault_UploadSecretServer) error {
+	ctx := stream.Context()
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		s.logger.Error("metadata is empty")
+		return status.Error(codes.InvalidArgument, "metadata is empty")
+	}
+	userFromContext := md.Get("user")
+	if len(userFromContext) == 0 {
+		s.logger.Error("userFromContext is empty")
+		return status.Error(codes.InvalidArgument, "user is empty")
+	}
+	if userFromContext[0] == "" {
+		s.logger.Error("user is empty")
+		return status.Error(codes.InvalidArgument, "user is empty")
+	}
+	user, err := s.userHandler.GetUser(ctx, userFromContext[0])
+	if err != nil {
+		s.logger.Error("error getting user", zap.Any("user", userFromContext[0]), zap.Error(err))
+		return status.Error(codes.Internal, err.Error())
+	}
+	req, err := stream.Recv()
+	if err != nil || req.Data == nil || req.Data.Uuid == "" {
+		s.logger.Error("upload header is empty", zap.Any("user", userFromContext[0]))
+		return status.Error(codes.InvalidArgument, "upload header is empty")
+	}
+	header := req.Data
+	expectedVersion := req.ExpectedVersion
+	chunksID := uuid.New().String()
+	// chunks of failed upload are deleted even if client has gone away
+	cleanup := func() {
+		if err := s.dataHandler.DeleteChunks(context.Background(), user, header.Uuid, chunksID); err != nil {
+			s.logger.Error("error deleting chunks of failed upload", zap.String("uuid", header.Uuid), zap.Error(err))
+		}
+	}
+	for index := int64(0); ; index++ {
+		req, err = stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			s.logger.Error("error receiving chunk", zap.String("uuid", header.Uuid), zap.Error(err))
+			cleanup()
+			return err
+		}
+		if len(req.Chunk) > maxChunkSize {
+			cleanup()
+			return status.Error(codes.InvalidArgument, "chunk is too large")
+		}
+		err = s.dataHandler.SaveChunk(ctx, user, models.VaultChunk{
+			DataUUID: header.Uuid,
+			ChunksID: chunksID,
+			Index:    index,
+			Data:     req.Chunk,
+		})
+		if err != nil {
+			s.logger.Error("error saving chunk", zap.String("uuid", header.Uuid), zap.Error(err))
+			cleanup()
+			return status.Error(codes.Internal, err.Error())
+		}
+	}
+	data := models.VaultData{
+		DataUUID: header.Uuid,
+		Meta:     header.Meta,
+		DataType: header.Type,
+		Data:     header.Value,
+		ChunksID: chunksID,
+	}
+	var updated int64
+	var previousChunksID string
+	if expectedVersion == 0 {
+		_, updated, err = s.dataHandler.CreateData(ctx, user, data)
+	} else {
+		// chunks of the replaced upload are deleted after the change
+		var current models.VaultData
+		current, err = s.dataHandler.GetData(ctx, user, header.Uuid)
+		if err == nil {
+			previousChunksID = current.ChunksID
+			data.Version = expectedVersion
+			updated, err = s.dataHandler.ChangeData(ctx, user, data)
+		}
+	}
+	if err != nil {
+		s.logger.Error("error saving uploaded data", zap.Any("user", userFromContext[0]), zap.Error(err))
+		cleanup()
+		return s.dataError(ctx, user, header.Uuid, err)
+	}
+	if previousChunksID != "" && previousChunksID != chunksID {
+		err = s.dataHandler.DeleteChunks(ctx, user, header.Uuid, previousChunksID)
+		if err != nil {
+			s.logger.Error("error deleting replaced chunks", zap.String("uuid", header.Uuid), zap.Error(err))
+		}
+	}
+	return stream.SendAndClose(&pb.UploadSecretResponse{
+		LastServerUpdated: updated,
+		Version:           expectedVersion + 1,
+	})
+}
+
+// DownloadSecret handles grpc requests for reading binary content of a secret
+// chunks are sent as they are read from storage
+// if req.Version is set and data has another version, the current server copy is returned as conflict
+func (s *VaultServer) DownloadSecret(req *pb.DownloadSecretRequest, stream pb.DedicatedVault_DownloadSecretServer) error {
+	ctx := stream.Context()
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		s.logger.Error("metadata is empty")
+		return status.Error(codes.InvalidArgument, "metadata is empty")
+	}
+	userFromContext := md.Get("user")
+	if len(userFromContext) == 0 {
+		s.logger.Error("userFromContext is empty")
+		return status.Error(codes.InvalidArgument, "user is empty")
+	}
+	if userFromContext[0] == "" {
+		s.logger.Error("user is empty")
+		return status.Error(codes.InvalidArgument, "user is empty")
+	}
+	user, err := s.userHandler.GetUser(ctx, userFromContext[0])
+	if err != nil {
+		s.logger.Error("error getting user", zap.Any("user", userFromContext[0]), zap.Error(err))
+		return status.Error(codes.Internal, err.Error())
+	}
+	data, err := s.dataHandler.GetData(ctx, user, req.Uuid)
+	if err != nil {
+		s.logger.Error("error getting data", zap.Any("user", userFromContext[0]), zap.Error(err))
+		return s.dataError(ctx, user, req.Uuid, err)
+	}
+	if req.Version != 0 && data.Version != req.Version {
+		return s.dataError(ctx, user, req.Uuid, servererrors.VersionConflict)
+	}
+	if data.ChunksID == "" {
+		return status.Error(codes.FailedPrecondition, "data has no streamed content")
+	}
+	err = s.dataHandler.GetChunks(ctx, user, req.Uuid, data.ChunksID, func(chunk []byte) error {
+		return stream.Send(&pb.DownloadSecretResponse{Chunk: chunk})
+	})
+	if err != nil {
+		s.logger.Error("error sending chunks", zap.String("uuid", req.Uuid), zap.Error(err))
+		return status.Error(codes.Internal, err.Error())
+	}
+	return nil
+}
+
+// dataError converts data handling error to grpc status
+// on version conflict the current server copy of data is attached to status details,
+// so the client can show it instead of silently overwriting
+func (s *VaultServer) dataError(ctx context.Context, user models.User, dataUUID string, err error) error {
+	switch {
+	case errors.Is(err, servererrors.RecordNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, servererrors.DataAlreadyExists):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, servererrors.VersionConflict):
+		current, getErr := s.dataHandler.GetData(ctx, user, dataUUID)
+		if getErr != nil {
+			s.logger.Error("error getting conflicting data", zap.String("uuid", dataUUID), zap.Error(getErr))
+			return status.Error(codes.Internal, getErr.Error())
+		}
+		st, detailsErr := status.New(codes.Aborted, err.Error()).WithDetails(&pb.SecretData{
+			Uuid:     current.DataUUID,
+			Meta:     current.Meta,
+			Type:     current.DataType,
+			Value:    current.Data,
+			Revision: current.Revision,
+			Version:  current.Version,
+		})
+		if detailsErr != nil {
+			s.logger.Error("error attaching conflicting data", zap.String("uuid", dataUUID), zap.Error(detailsErr))
+			return status.Error(codes.Internal, detailsErr.Error())
+		}
+		return st.Err()
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
+
+// maxDeviceName limits length of device name given by client
+const maxDeviceName = 64
+
+// deviceOf returns device of request: name is given by client, address and certificate are taken from connection
+func deviceOf(ctx context.Context, name string) models.Device {
+	if runes := []rune(name); len(runes) > maxDeviceName {
+		name = string(runes[:maxDeviceName])
+	}
+	device := models.Device{Name: name}
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return device
+	}
+	if p.Addr != nil {
+		host, _, err := net.SplitHostPort(p.Addr.String())
+		if err != nil {
+			host = p.Addr.String()
+		}
+		device.IP = host
+	}
+	if tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo); ok && len(tlsInfo.State.PeerCertificates) > 0 {
+		hash := sha256.Sum256(tlsInfo.State.PeerCertificates[0].Raw)
+		device.CertFingerprint = hex.EncodeToString(hash[:])
+	}
+	return device
+}
@@ -2,18 +2,33 @@ package grpcserver
 
 import (
 	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
 	"errors"
+	"io"
+	"net"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/status"
 
 	"github.com/h2p2f/dedicated-vault/internal/server/grpcserver/mocks"
 	"github.com/h2p2f/dedicated-vault/internal/server/models"
+	"github.com/h2p2f/dedicated-vault/internal/server/servererrors"
+	"github.com/h2p2f/dedicated-vault/internal/srp"
 	pb "github.com/h2p2f/dedicated-vault/proto"
 )
 
@@ -50,8 +65,9 @@ func TestVaultServer_Register(t *testing.T) {
 		t.Run(tt.testname, func(t *testing.T) {
 			server := &VaultServer{
 				userHandler: &mocks.UserHandler{},
+				logger:      zap.NewNop(),
 			}
-			mockToken := "mocktoken"
+			mockToken := models.Tokens{Access: "mocktoken", Refresh: "mockrefresh", ExpiresIn: 900}
 			mockLastServerUpdated := time.Now().Unix()
 
 			if tt.wantCode == codes.OK {
@@ -59,14 +75,14 @@ func TestVaultServer_Register(t *testing.T) {
 				mockUserHandler.On("Register", mockCtx, models.User{
 					Login:    tt.name,
 					Password: tt.password,
-				}).Return(mockToken, mockLastServerUpdated, nil)
+				}, models.Device{}).Return(mockToken, mockLastServerUpdated, nil)
 				server.userHandler = mockUserHandler
 			} else {
 				mockUserHandler := &mocks.UserHandler{}
 				mockUserHandler.On("Register", mockCtx, models.User{
 					Login:    tt.name,
 					Password: tt.password,
-				}).Return("", int64(0), errors.New("error"))
+				}, models.Device{}).Return(models.Tokens{}, int64(0), errors.New("error"))
 				server.userHandler = mockUserHandler
 			}
 			req := &pb.RegisterRequest{
@@ -120,8 +136,9 @@ func TestVaultServer_Login(t *testing.T) {
 		t.Run(tt.testname, func(t *testing.T) {
 			server := &VaultServer{
 				userHandler: &mocks.UserHandler{},
+				logger:      zap.NewNop(),
 			}
-			mockToken := "mocktoken"
+			mockToken := models.Tokens{Access: "mocktoken", Refresh: "mockrefresh", ExpiresIn: 900}
 			mockLastServerUpdated := time.Now().Unix()
 
 			if tt.wantCode == codes.OK {
@@ -129,14 +146,14 @@ func TestVaultServer_Login(t *testing.T) {
 				mockUserHandler.On("Login", mockCtx, models.User{
 					Login:    tt.name,
 					Password: tt.password,
-				}).Return(mockToken, mockLastServerUpdated, nil)
+				}, "", models.Device{}).Return(mockToken, mockLastServerUpdated, nil)
 				server.userHandler = mockUserHandler
 			} else {
 				mockUserHandler := &mocks.UserHandler{}
 				mockUserHandler.On("Login", mockCtx, models.User{
 					Login:    tt.name,
 					Password: tt.password,
-				}).Return("", int64(0), errors.New("error"))
+				}, "", models.Device{}).Return(models.Tokens{}, int64(0), errors.New("error"))
 				server.userHandler = mockUserHandler
 			}
 			req := &pb.LoginRequest{
@@ -200,21 +217,22 @@ func TestVaultServer_ChangePassword(t *testing.T) {
 		t.Run(tt.testname, func(t *testing.T) {
 			server := &VaultServer{
 				userHandler: &mocks.UserHandler{},
+				logger:      zap.NewNop(),
 			}
-			mockToken := "mocktoken"
+			mockToken := models.Tokens{Access: "mocktoken", Refresh: "mockrefresh", ExpiresIn: 900}
 			if tt.wantCode == codes.OK {
 				mockUserHandler := &mocks.UserHandler{}
 				mockUserHandler.On("ChangePassword", mockCtx, models.User{
 					Login:    tt.name,
 					Password: tt.password,
-				}, tt.newPassword).Return(mockToken, nil)
+				}, tt.newPassword, models.Device{}).Return(mockToken, nil)
 				server.userHandler = mockUserHandler
 			} else {
 				mockUserHandler := &mocks.UserHandler{}
 				mockUserHandler.On("ChangePassword", mockCtx, models.User{
 					Login:    tt.name,
 					Password: tt.password,
-				}, tt.newPassword).Return("", errors.New("error"))
+				}, tt.newPassword, models.Device{}).Return(models.Tokens{}, errors.New("error"))
 				server.userHandler = mockUserHandler
 			}
 			req := &pb.ChangePasswordRequest{
@@ -263,6 +281,12 @@ func TestVaultServer_SaveSecret(t *testing.T) {
 			mdExists: false,
 			wantCode: codes.InvalidArgument,
 		},
+		{
+			testname: "already exists",
+			user:     "testuser",
+			mdExists: true,
+			wantCode: codes.AlreadyExists,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.testname, func(t *testing.T) {
@@ -282,6 +306,7 @@ func TestVaultServer_SaveSecret(t *testing.T) {
 			mockCreated := time.Now().Unix()
 			server := &VaultServer{
 				userHandler: &mocks.UserHandler{},
+				logger:      zap.NewNop(),
 				dataHandler: &mocks.DataHandler{},
 			}
 			if tt.mdExists {
@@ -291,7 +316,14 @@ func TestVaultServer_SaveSecret(t *testing.T) {
 			}
 			mockUserHandler := &mocks.UserHandler{}
 			mockDataHandler := &mocks.DataHandler{}
-			if tt.testname != "user not found" {
+			if tt.testname == "already exists" {
+				mockUserHandler.On("GetUser", mockCtx, tt.user).Return(mockUser, nil)
+				mockDataHandler.On("CreateData", mockCtx, mockUser, models.VaultData{
+					Meta:     mockReq.Data.Meta,
+					DataType: mockReq.Data.Type,
+					Data:     mockReq.Data.Value,
+				}).Return("", int64(0), servererrors.DataAlreadyExists)
+			} else if tt.testname != "user not found" {
 				mockUserHandler.On("GetUser", mockCtx, tt.user).Return(mockUser, nil)
 				mockDataHandler.On("CreateData", mockCtx, mockUser, models.VaultData{
 					Meta:     mockReq.Data.Meta,
@@ -362,6 +394,7 @@ func TestVaultServer_ChangeSecret(t *testing.T) {
 			mockCreated := time.Now().Unix()
 			server := &VaultServer{
 				userHandler: &mocks.UserHandler{},
+				logger:      zap.NewNop(),
 				dataHandler: &mocks.DataHandler{},
 			}
 			if tt.mdExists {
@@ -438,6 +471,7 @@ func TestVaultServer_DeleteSecret(t *testing.T) {
 			mockCreated := time.Now().Unix()
 			server := &VaultServer{
 				userHandler: &mocks.UserHandler{},
+				logger:      zap.NewNop(),
 				dataHandler: &mocks.DataHandler{},
 			}
 			if tt.mdExists {
@@ -510,6 +544,7 @@ func TestVaultServer_ListSecrets(t *testing.T) {
 
 			server := &VaultServer{
 				userHandler: &mocks.UserHandler{},
+				logger:      zap.NewNop(),
 				dataHandler: &mocks.DataHandler{},
 			}
 			if tt.mdExists {
@@ -534,3 +569,1247 @@ func TestVaultServer_ListSecrets(t *testing.T) {
 		})
 	}
 }
+
+func TestVaultServer_SyncChanges(t *testing.T) {
+	var mockCtx context.Context
+
+	tests := []struct {
+		testname      string
+		user          string
+		mdExists      bool
+		sinceRevision int64
+		limit         int64
+		wantLimit     int64
+		changedData   []models.VaultData
+		more          bool
+		wantRevision  int64
+		wantCode      codes.Code
+	}{
+		{
+			testname:      "valid",
+			user:          "testuser",
+			mdExists:      true,
+			sinceRevision: 3,
+			wantLimit:     maxSyncLimit,
+			changedData: []models.VaultData{
+				{DataUUID: uuid.New().String(), Revision: 4},
+				{DataUUID: uuid.New().String(), Revision: 6, Deleted: true},
+			},
+			wantRevision: 6,
+			wantCode:     codes.OK,
+		},
+		{
+			testname:      "page of changes",
+			user:          "testuser",
+			mdExists:      true,
+			sinceRevision: 3,
+			limit:         1,
+			wantLimit:     1,
+			changedData:   []models.VaultData{{DataUUID: uuid.New().String(), Revision: 4}},
+			more:          true,
+			wantRevision:  4,
+			wantCode:      codes.OK,
+		},
+		{
+			testname:      "limit is capped",
+			user:          "testuser",
+			mdExists:      true,
+			sinceRevision: 3,
+			limit:         maxSyncLimit + 1,
+			wantLimit:     maxSyncLimit,
+			changedData:   []models.VaultData{},
+			wantRevision:  3,
+			wantCode:      codes.OK,
+		},
+		{
+			testname:      "no changes",
+			user:          "testuser",
+			mdExists:      true,
+			sinceRevision: 3,
+			wantLimit:     maxSyncLimit,
+			changedData:   []models.VaultData{},
+			wantRevision:  3,
+			wantCode:      codes.OK,
+		},
+		{
+			testname:      "negative revision",
+			user:          "testuser",
+			mdExists:      true,
+			sinceRevision: -1,
+			wantCode:      codes.InvalidArgument,
+		},
+		{
+			testname: "empty user",
+			user:     "",
+			mdExists: true,
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			testname: "user not found",
+			user:     "testuser",
+			mdExists: true,
+			wantCode: codes.Internal,
+		},
+		{
+			testname: "no metadata in context",
+			user:     "testuser",
+			mdExists: false,
+			wantCode: codes.InvalidArgument,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.testname, func(t *testing.T) {
+			mockCtx = context.Background()
+			mockReq := &pb.SyncChangesRequest{SinceRevision: tt.sinceRevision, Limit: tt.limit}
+
+			mockUser := models.User{
+				UUID:  uuid.New().String(),
+				Login: tt.user}
+
+			if tt.mdExists {
+				md := make(map[string]string)
+				md["user"] = tt.user
+				mockCtx = metadata.NewIncomingContext(mockCtx, metadata.New(md))
+			}
+			mockUserHandler := &mocks.UserHandler{}
+			mockDataHandler := &mocks.DataHandler{}
+			if tt.testname != "user not found" {
+				mockUserHandler.On("GetUser", mockCtx, tt.user).Return(mockUser, nil)
+				mockDataHandler.On("GetChangedData", mockCtx, mockUser, tt.sinceRevision, tt.wantLimit).Return(tt.changedData, tt.more, nil)
+			} else {
+				mockUserHandler.On("GetUser", mockCtx, tt.user).Return(models.User{}, errors.New("error"))
+			}
+
+			server := &VaultServer{
+				userHandler: mockUserHandler,
+				dataHandler: mockDataHandler,
+				logger:      zap.NewNop(),
+			}
+
+			resp, err := server.SyncChanges(mockCtx, mockReq)
+			assert.Equal(t, tt.wantCode, status.Code(err))
+			if tt.wantCode == codes.OK {
+				assert.Equal(t, tt.wantRevision, resp.Revision)
+				assert.Equal(t, tt.more, resp.More)
+				assert.Len(t, resp.Data, len(tt.changedData))
+			}
+		})
+	}
+}
+
+func TestVaultServer_ChangeSecretConflict(t *testing.T) {
+	mockUser := models.User{
+		UUID:  uuid.New().String(),
+		Login: "testuser"}
+	mockReq := &pb.ChangeSecretRequest{
+		Data: &pb.SecretData{
+			Uuid:  uuid.New().String(),
+			Meta:  "testmeta",
+			Type:  "testtype",
+			Value: []byte("testvalue"),
+		},
+		ExpectedVersion: 2,
+	}
+	serverCopy := models.VaultData{
+		DataUUID: mockReq.Data.Uuid,
+		Meta:     "servermeta",
+		DataType: "testtype",
+		Data:     []byte("servervalue"),
+		Version:  3,
+	}
+	mockCtx := metadata.NewIncomingContext(context.Background(), metadata.New(map[string]string{"user": mockUser.Login}))
+
+	tests := []struct {
+		testname  string
+		changeErr error
+		wantCode  codes.Code
+	}{
+		{
+			testname:  "version conflict",
+			changeErr: servererrors.VersionConflict,
+			wantCode:  codes.Aborted,
+		},
+		{
+			testname:  "data not found",
+			changeErr: servererrors.RecordNotFound,
+			wantCode:  codes.NotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.testname, func(t *testing.T) {
+			mockUserHandler := &mocks.UserHandler{}
+			mockDataHandler := &mocks.DataHandler{}
+			mockUserHandler.On("GetUser", mockCtx, mockUser.Login).Return(mockUser, nil)
+			mockDataHandler.On("ChangeData", mockCtx, mockUser, models.VaultData{
+				DataUUID: mockReq.Data.Uuid,
+				Meta:     mockReq.Data.Meta,
+				DataType: mockReq.Data.Type,
+				Data:     mockReq.Data.Value,
+				Version:  mockReq.ExpectedVersion,
+			}).Return(int64(0), tt.changeErr)
+			mockDataHandler.On("GetData", mockCtx, mockUser, mockReq.Data.Uuid).Return(serverCopy, nil)
+
+			server := &VaultServer{
+				userHandler: mockUserHandler,
+				dataHandler: mockDataHandler,
+				logger:      zap.NewNop(),
+			}
+
+			_, err := server.ChangeSecret(mockCtx, mockReq)
+			st := status.Convert(err)
+			assert.Equal(t, tt.wantCode, st.Code())
+			if tt.wantCode == codes.Aborted {
+				details := st.Details()
+				assert.Len(t, details, 1)
+				current, ok := details[0].(*pb.SecretData)
+				assert.True(t, ok)
+				assert.Equal(t, serverCopy.Version, current.Version)
+				assert.Equal(t, serverCopy.Data, current.Value)
+			}
+		})
+	}
+}
+
+func TestVaultServer_SetMasterKey(t *testing.T) {
+	var mockCtx context.Context
+	tests := []struct {
+		testname   string
+		user       string
+		mdExists   bool
+		wrappedKey []byte
+		setErr     error
+		wantCode   codes.Code
+	}{
+		{
+			testname:   "valid",
+			user:       "testuser",
+			mdExists:   true,
+			wrappedKey: []byte("wrapped key"),
+			wantCode:   codes.OK,
+		},
+		{
+			testname:   "empty user",
+			user:       "",
+			mdExists:   true,
+			wrappedKey: []byte("wrapped key"),
+			wantCode:   codes.InvalidArgument,
+		},
+		{
+			testname: "empty key",
+			user:     "testuser",
+			mdExists: true,
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			testname:   "key changed on another device",
+			user:       "testuser",
+			mdExists:   true,
+			wrappedKey: []byte("wrapped key"),
+			setErr:     servererrors.VersionConflict,
+			wantCode:   codes.Aborted,
+		},
+		{
+			testname:   "no metadata in context",
+			user:       "testuser",
+			mdExists:   false,
+			wrappedKey: []byte("wrapped key"),
+			wantCode:   codes.InvalidArgument,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.testname, func(t *testing.T) {
+			mockCtx = context.Background()
+			mockReq := &pb.SetMasterKeyRequest{
+				WrappedKey:      tt.wrappedKey,
+				ExpectedVersion: 1,
+				KeyCheck:        []byte("key check"),
+			}
+			mockUser := models.User{
+				UUID:  uuid.New().String(),
+				Login: tt.user}
+			if tt.mdExists {
+				md := make(map[string]string)
+				md["user"] = tt.user
+				mockCtx = metadata.NewIncomingContext(mockCtx, metadata.New(md))
+			}
+			mockUserHandler := &mocks.UserHandler{}
+			mockUserHandler.On("GetUser", mockCtx, tt.user).Return(mockUser, nil)
+			mockUserHandler.On("SetWrappedKey", mockCtx, mockUser, tt.wrappedKey, []byte("key check"), int64(1)).Return(int64(2), tt.setErr)
+			server := &VaultServer{
+				userHandler: mockUserHandler,
+				logger:      zap.NewNop(),
+				dataHandler: &mocks.DataHandler{},
+			}
+
+			resp, err := server.SetMasterKey(mockCtx, mockReq)
+			assert.Equal(t, tt.wantCode, status.Code(err))
+			if tt.wantCode == codes.OK {
+				assert.Equal(t, int64(2), resp.Version)
+			}
+		})
+	}
+}
+
+func TestVaultServer_GetMasterKey(t *testing.T) {
+	mockUser := models.User{
+		UUID:              uuid.New().String(),
+		Login:             "testuser",
+		WrappedKey:        []byte("wrapped key"),
+		WrappedKeyVersion: 3,
+		KeyCheck:          []byte("key check"),
+	}
+	mockCtx := metadata.NewIncomingContext(context.Background(), metadata.New(map[string]string{"user": "testuser"}))
+	mockUserHandler := &mocks.UserHandler{}
+	mockUserHandler.On("GetUser", mockCtx, "testuser").Return(mockUser, nil)
+	server := &VaultServer{
+		userHandler: mockUserHandler,
+		logger:      zap.NewNop(),
+		dataHandler: &mocks.DataHandler{},
+	}
+
+	resp, err := server.GetMasterKey(mockCtx, &pb.GetMasterKeyRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, mockUser.WrappedKey, resp.WrappedKey)
+	assert.Equal(t, int64(3), resp.Version)
+	assert.Equal(t, mockUser.KeyCheck, resp.KeyCheck)
+}
+
+func TestVaultServer_RefreshToken(t *testing.T) {
+	mockCtx := context.Background()
+	tests := []struct {
+		testname     string
+		refreshToken string
+		refreshErr   error
+		wantCode     codes.Code
+	}{
+		{
+			testname:     "valid",
+			refreshToken: "refresh",
+			wantCode:     codes.OK,
+		},
+		{
+			testname: "empty refresh token",
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			testname:     "revoked session",
+			refreshToken: "refresh",
+			refreshErr:   servererrors.SessionRevoked,
+			wantCode:     codes.Unauthenticated,
+		},
+		{
+			testname:     "storage error",
+			refreshToken: "refresh",
+			refreshErr:   errors.New("error"),
+			wantCode:     codes.Internal,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.testname, func(t *testing.T) {
+			mockTokens := models.Tokens{Access: "access", Refresh: "new refresh", ExpiresIn: 900}
+			mockUserHandler := &mocks.UserHandler{}
+			mockUserHandler.On("RefreshToken", mockCtx, tt.refreshToken).Return(mockTokens, tt.refreshErr)
+			server := &VaultServer{
+				userHandler: mockUserHandler,
+				logger:      zap.NewNop(),
+				dataHandler: &mocks.DataHandler{},
+			}
+
+			resp, err := server.RefreshToken(mockCtx, &pb.RefreshTokenRequest{RefreshToken: tt.refreshToken})
+			assert.Equal(t, tt.wantCode, status.Code(err))
+			if tt.wantCode == codes.OK {
+				assert.Equal(t, "access", resp.Token)
+				assert.Equal(t, "new refresh", resp.RefreshToken)
+				assert.Equal(t, int64(900), resp.ExpiresIn)
+			}
+		})
+	}
+}
+
+func TestVaultServer_Logout(t *testing.T) {
+	tests := []struct {
+		testname string
+		user     string
+		session  string
+		mdExists bool
+		wantCode codes.Code
+	}{
+		{
+			testname: "valid",
+			user:     "user-uuid",
+			session:  "session",
+			mdExists: true,
+			wantCode: codes.OK,
+		},
+		{
+			testname: "empty user",
+			session:  "session",
+			mdExists: true,
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			testname: "no metadata in context",
+			mdExists: false,
+			wantCode: codes.InvalidArgument,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.testname, func(t *testing.T) {
+			mockCtx := context.Background()
+			if tt.mdExists {
+				mockCtx = metadata.NewIncomingContext(mockCtx, metadata.New(map[string]string{
+					"user":    tt.user,
+					"session": tt.session,
+				}))
+			}
+			mockUserHandler := &mocks.UserHandler{}
+			mockUserHandler.On("Logout", mockCtx, models.User{UUID: tt.user}, tt.session).Return(nil)
+			server := &VaultServer{
+				userHandler: mockUserHandler,
+				logger:      zap.NewNop(),
+				dataHandler: &mocks.DataHandler{},
+			}
+
+			_, err := server.Logout(mockCtx, &pb.LogoutRequest{})
+			assert.Equal(t, tt.wantCode, status.Code(err))
+			if tt.wantCode == codes.OK {
+				mockUserHandler.AssertCalled(t, "Logout", mockCtx, models.User{UUID: tt.user}, tt.session)
+			}
+		})
+	}
+}
+
+func TestVaultServer_ListSessions(t *testing.T) {
+	mockCtx := metadata.NewIncomingContext(context.Background(), metadata.New(map[string]string{
+		"user":    "user-uuid",
+		"session": "session2",
+	}))
+	mockUserHandler := &mocks.UserHandler{}
+	mockUserHandler.On("ListSessions", mockCtx, models.User{UUID: "user-uuid"}).Return([]models.Session{
+		{SessionID: "session1", Device: models.Device{Name: "laptop", IP: "10.0.0.1"}, Created: 1, LastSeen: 3},
+		{SessionID: "session2", Device: models.Device{Name: "desktop", CertFingerprint: "ab"}, Created: 2, LastSeen: 2},
+	}, nil)
+	server := &VaultServer{
+		userHandler: mockUserHandler,
+		logger:      zap.NewNop(),
+		dataHandler: &mocks.DataHandler{},
+	}
+
+	resp, err := server.ListSessions(mockCtx, &pb.ListSessionsRequest{})
+	assert.NoError(t, err)
+	if assert.Len(t, resp.Sessions, 2) {
+		assert.Equal(t, "laptop", resp.Sessions[0].DeviceName)
+		assert.Equal(t, "10.0.0.1", resp.Sessions[0].Ip)
+		assert.Equal(t, int64(3), resp.Sessions[0].LastSeen)
+		assert.False(t, resp.Sessions[0].Current)
+		assert.Equal(t, "ab", resp.Sessions[1].CertFingerprint)
+		assert.True(t, resp.Sessions[1].Current)
+	}
+}
+
+func TestVaultServer_RevokeSession(t *testing.T) {
+	tests := []struct {
+		testname  string
+		session   string
+		revokeErr error
+		wantCode  codes.Code
+	}{
+		{
+			testname: "valid",
+			session:  "session1",
+			wantCode: codes.OK,
+		},
+		{
+			testname: "empty session",
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			testname:  "session of another user",
+			session:   "session1",
+			revokeErr: servererrors.RecordNotFound,
+			wantCode:  codes.NotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.testname, func(t *testing.T) {
+			mockCtx := metadata.NewIncomingContext(context.Background(), metadata.New(map[string]string{"user": "user-uuid"}))
+			mockUserHandler := &mocks.UserHandler{}
+			mockUserHandler.On("RevokeSession", mockCtx, models.User{UUID: "user-uuid"}, tt.session).Return(tt.revokeErr)
+			server := &VaultServer{
+				userHandler: mockUserHandler,
+				logger:      zap.NewNop(),
+				dataHandler: &mocks.DataHandler{},
+			}
+
+			_, err := server.RevokeSession(mockCtx, &pb.RevokeSessionRequest{Id: tt.session})
+			assert.Equal(t, tt.wantCode, status.Code(err))
+		})
+	}
+}
+
+// errorReason - reason of error details of grpc status
+func errorReason(err error) string {
+	for _, detail := range status.Convert(err).Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok {
+			return info.Reason
+		}
+	}
+	return ""
+}
+
+func TestVaultServer_LoginTwoFactor(t *testing.T) {
+	tests := []struct {
+		testname       string
+		code           string
+		loginErr       error
+		wantCode       codes.Code
+		wantReason     string
+		wantEnrollment bool
+	}{
+		{
+			testname: "valid code",
+			code:     "123456",
+			wantCode: codes.OK,
+		},
+		{
+			testname:   "code is required",
+			loginErr:   servererrors.TwoFactorRequired,
+			wantCode:   codes.FailedPrecondition,
+			wantReason: "TWO_FACTOR_REQUIRED",
+		},
+		{
+			// tokens are of session accepted only to enroll
+			testname:       "enrollment is required",
+			loginErr:       servererrors.TwoFactorEnrollmentRequired,
+			wantCode:       codes.OK,
+			wantEnrollment: true,
+		},
+		{
+			testname:   "invalid code",
+			code:       "654321",
+			loginErr:   servererrors.InvalidTwoFactorCode,
+			wantCode:   codes.PermissionDenied,
+			wantReason: "INVALID_TWO_FACTOR_CODE",
+		},
+		{
+			testname:   "codes are locked out",
+			code:       "654321",
+			loginErr:   servererrors.TwoFactorLocked,
+			wantCode:   codes.ResourceExhausted,
+			wantReason: "TWO_FACTOR_LOCKED",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.testname, func(t *testing.T) {
+			mockCtx := context.Background()
+			mockUserHandler := mocks.NewUserHandler(t)
+			mockUserHandler.On("Login", mockCtx, models.User{Login: "testuser", Password: "testpassword"}, tt.code, models.Device{}).
+				Return(models.Tokens{Access: "mocktoken"}, int64(0), tt.loginErr)
+			server := &VaultServer{
+				userHandler: mockUserHandler,
+				logger:      zap.NewNop(),
+			}
+
+			resp, err := server.Login(mockCtx, &pb.LoginRequest{
+				User:    &pb.User{Name: "testuser", Password: "testpassword"},
+				OtpCode: tt.code,
+			})
+			assert.Equal(t, tt.wantCode, status.Code(err))
+			assert.Equal(t, tt.wantReason, errorReason(err))
+			if tt.wantCode == codes.OK {
+				assert.Equal(t, "mocktoken", resp.Token)
+				assert.Equal(t, tt.wantEnrollment, resp.TwoFactorEnrollmentRequired)
+			}
+		})
+	}
+}
+
+func TestVaultServer_RegisterTwoFactorEnrollment(t *testing.T) {
+	mockCtx := context.Background()
+	mockUserHandler := mocks.NewUserHandler(t)
+	mockUserHandler.On("Register", mockCtx, models.User{Login: "testuser", Password: "testpassword"}, models.Device{}).
+		Return(models.Tokens{}, int64(0), servererrors.TwoFactorEnrollmentRequired)
+	server := &VaultServer{
+		userHandler: mockUserHandler,
+		logger:      zap.NewNop(),
+	}
+
+	_, err := server.Register(mockCtx, &pb.RegisterRequest{User: &pb.User{Name: "testuser", Password: "testpassword"}})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	assert.Equal(t, "TWO_FACTOR_ENROLLMENT_REQUIRED", errorReason(err))
+}
+
+// twoFactorContext - context of request with token of user, the same as after check of token by middleware
+func twoFactorContext(user string) context.Context {
+	return metadata.NewIncomingContext(context.Background(), metadata.New(map[string]string{
+		"user":    user,
+		"session": "session1",
+	}))
+}
+
+func TestVaultServer_EnrollTwoFactor(t *testing.T) {
+	tests := []struct {
+		testname  string
+		tokenUser string
+		user      *pb.User
+		enrollErr error
+		wantCode  codes.Code
+	}{
+		{
+			testname:  "valid",
+			tokenUser: "user-uuid",
+			user:      &pb.User{Name: "testuser", Password: "testpassword"},
+			wantCode:  codes.OK,
+		},
+		{
+			testname: "without token",
+			user:     &pb.User{Name: "testuser", Password: "testpassword"},
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			testname:  "without user",
+			tokenUser: "user-uuid",
+			wantCode:  codes.InvalidArgument,
+		},
+		{
+			testname:  "already enabled",
+			tokenUser: "user-uuid",
+			user:      &pb.User{Name: "testuser", Password: "testpassword"},
+			enrollErr: servererrors.TwoFactorAlreadyEnabled,
+			wantCode:  codes.AlreadyExists,
+		},
+		{
+			testname:  "password of another user",
+			tokenUser: "user-uuid",
+			user:      &pb.User{Name: "testuser", Password: "testpassword"},
+			enrollErr: servererrors.WrongPassword,
+			wantCode:  codes.PermissionDenied,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.testname, func(t *testing.T) {
+			mockCtx := twoFactorContext(tt.tokenUser)
+			mockUserHandler := &mocks.UserHandler{}
+			mockUserHandler.On("EnrollTwoFactor", mockCtx,
+				models.User{UUID: "user-uuid", Login: "testuser", Password: "testpassword"}, models.PasswordProof{}).
+				Return("otpauth://totp/uri", []byte(nil), tt.enrollErr)
+			server := &VaultServer{
+				userHandler: mockUserHandler,
+				logger:      zap.NewNop(),
+			}
+
+			resp, err := server.EnrollTwoFactor(mockCtx, &pb.EnrollTwoFactorRequest{User: tt.user})
+			assert.Equal(t, tt.wantCode, status.Code(err))
+			if tt.wantCode == codes.OK {
+				assert.Equal(t, "otpauth://totp/uri", resp.ProvisioningUri)
+			}
+		})
+	}
+}
+
+func TestVaultServer_ConfirmTwoFactor(t *testing.T) {
+	tests := []struct {
+		testname   string
+		tokenUser  string
+		code       string
+		confirmErr error
+		wantCode   codes.Code
+		wantReason string
+	}{
+		{
+			testname:  "valid",
+			tokenUser: "user-uuid",
+			code:      "123456",
+			wantCode:  codes.OK,
+		},
+		{
+			testname: "without token",
+			code:     "123456",
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			testname:  "empty code",
+			tokenUser: "user-uuid",
+			wantCode:  codes.InvalidArgument,
+		},
+		{
+			testname:   "invalid code",
+			tokenUser:  "user-uuid",
+			code:       "654321",
+			confirmErr: servererrors.InvalidTwoFactorCode,
+			wantCode:   codes.PermissionDenied,
+			wantReason: "INVALID_TWO_FACTOR_CODE",
+		},
+		{
+			testname:   "codes are locked out",
+			tokenUser:  "user-uuid",
+			code:       "654321",
+			confirmErr: servererrors.TwoFactorLocked,
+			wantCode:   codes.ResourceExhausted,
+			wantReason: "TWO_FACTOR_LOCKED",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.testname, func(t *testing.T) {
+			mockCtx := twoFactorContext(tt.tokenUser)
+			mockUserHandler := &mocks.UserHandler{}
+			mockUserHandler.On("ConfirmTwoFactor", mockCtx,
+				models.User{UUID: "user-uuid", Login: "testuser", Password: "testpassword"}, "session1", models.PasswordProof{}, tt.code).
+				Return([]string{"aaaa-bbbb-cccc-dddd"}, []byte(nil), tt.confirmErr)
+			server := &VaultServer{
+				userHandler: mockUserHandler,
+				logger:      zap.NewNop(),
+			}
+
+			resp, err := server.ConfirmTwoFactor(mockCtx, &pb.ConfirmTwoFactorRequest{
+				User:    &pb.User{Name: "testuser", Password: "testpassword"},
+				OtpCode: tt.code,
+			})
+			assert.Equal(t, tt.wantCode, status.Code(err))
+			assert.Equal(t, tt.wantReason, errorReason(err))
+			if tt.wantCode == codes.OK {
+				assert.Equal(t, []string{"aaaa-bbbb-cccc-dddd"}, resp.RecoveryCodes)
+			}
+		})
+	}
+}
+
+func TestVaultServer_DisableTwoFactor(t *testing.T) {
+	tests := []struct {
+		testname   string
+		tokenUser  string
+		code       string
+		disableErr error
+		wantCode   codes.Code
+	}{
+		{
+			testname:  "valid",
+			tokenUser: "user-uuid",
+			code:      "123456",
+			wantCode:  codes.OK,
+		},
+		{
+			testname: "without token",
+			code:     "123456",
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			testname:  "empty code",
+			tokenUser: "user-uuid",
+			wantCode:  codes.InvalidArgument,
+		},
+		{
+			testname:   "recovery code",
+			tokenUser:  "user-uuid",
+			code:       "aaaa-bbbb-cccc-dddd",
+			disableErr: servererrors.InvalidTwoFactorCode,
+			wantCode:   codes.PermissionDenied,
+		},
+		{
+			testname:   "required by server",
+			tokenUser:  "user-uuid",
+			code:       "123456",
+			disableErr: servererrors.TwoFactorEnforced,
+			wantCode:   codes.FailedPrecondition,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.testname, func(t *testing.T) {
+			mockCtx := twoFactorContext(tt.tokenUser)
+			mockUserHandler := &mocks.UserHandler{}
+			mockUserHandler.On("DisableTwoFactor", mockCtx,
+				models.User{UUID: "user-uuid", Login: "testuser", Password: "testpassword"}, models.PasswordProof{}, tt.code).
+				Return([]byte(nil), tt.disableErr)
+			server := &VaultServer{
+				userHandler: mockUserHandler,
+				logger:      zap.NewNop(),
+			}
+
+			_, err := server.DisableTwoFactor(mockCtx, &pb.DisableTwoFactorRequest{
+				User:    &pb.User{Name: "testuser", Password: "testpassword"},
+				OtpCode: tt.code,
+			})
+			assert.Equal(t, tt.wantCode, status.Code(err))
+		})
+	}
+}
+
+func TestVaultServer_RegisterVerifier(t *testing.T) {
+	salt := make([]byte, srp.SaltSize)
+	params := &pb.KDFParams{Time: 3, Memory: 64 * 1024, Threads: 4}
+	tests := []struct {
+		testname    string
+		name        string
+		verifier    *pb.PasswordVerifier
+		registerErr error
+		wantCode    codes.Code
+		wantReason  string
+	}{
+		{
+			testname: "valid",
+			name:     "testuser",
+			verifier: &pb.PasswordVerifier{Salt: salt, Verifier: []byte("verifier"), Params: params},
+			wantCode: codes.OK,
+		},
+		{
+			testname: "empty login",
+			verifier: &pb.PasswordVerifier{Salt: salt, Verifier: []byte("verifier"), Params: params},
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			testname: "short salt",
+			name:     "testuser",
+			verifier: &pb.PasswordVerifier{Salt: salt[:4], Verifier: []byte("verifier"), Params: params},
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			testname: "weak params",
+			name:     "testuser",
+			verifier: &pb.PasswordVerifier{Salt: salt, Verifier: []byte("verifier"), Params: &pb.KDFParams{Time: 1, Memory: 64, Threads: 1}},
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			testname:    "enrollment is required",
+			name:        "testuser",
+			verifier:    &pb.PasswordVerifier{Salt: salt, Verifier: []byte("verifier"), Params: params},
+			registerErr: servererrors.TwoFactorEnrollmentRequired,
+			wantCode:    codes.FailedPrecondition,
+			wantReason:  "TWO_FACTOR_ENROLLMENT_REQUIRED",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.testname, func(t *testing.T) {
+			mockCtx := context.Background()
+			mockUserHandler := &mocks.UserHandler{}
+			mockUserHandler.On("RegisterVerifier", mockCtx, models.User{
+				Login: "testuser",
+				PasswordVerifier: models.PasswordVerifier{
+					Salt:     salt,
+					Verifier: []byte("verifier"),
+					Params:   srp.DefaultParams,
+				},
+			}, models.Device{}).Return(models.Tokens{Access: "mocktoken"}, int64(0), tt.registerErr)
+			server := &VaultServer{
+				userHandler: mockUserHandler,
+				logger:      zap.NewNop(),
+			}
+
+			resp, err := server.RegisterVerifier(mockCtx, &pb.RegisterVerifierRequest{Name: tt.name, Verifier: tt.verifier})
+			assert.Equal(t, tt.wantCode, status.Code(err))
+			assert.Equal(t, tt.wantReason, errorReason(err))
+			if tt.wantCode == codes.OK {
+				assert.Equal(t, "mocktoken", resp.Token)
+			}
+		})
+	}
+}
+
+func TestVaultServer_StartAuth(t *testing.T) {
+	tests := []struct {
+		testname   string
+		public     []byte
+		startErr   error
+		wantCode   codes.Code
+		wantReason string
+	}{
+		{
+			testname: "valid",
+			public:   []byte("client public"),
+			wantCode: codes.OK,
+		},
+		{
+			testname: "invalid public value",
+			public:   make([]byte, 256),
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			testname:   "too many handshakes",
+			public:     []byte("client public"),
+			startErr:   servererrors.TooManyHandshakes,
+			wantCode:   codes.ResourceExhausted,
+			wantReason: "TOO_MANY_HANDSHAKES",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.testname, func(t *testing.T) {
+			mockCtx := peer.NewContext(context.Background(), &peer.Peer{
+				Addr: &net.TCPAddr{IP: net.ParseIP("192.0.2.10"), Port: 50123},
+			})
+			mockUserHandler := &mocks.UserHandler{}
+			mockUserHandler.On("StartAuth", mockCtx, "testuser", "192.0.2.10", tt.public).Return(models.Challenge{
+				HandshakeID:  "handshake1",
+				Salt:         []byte("salt"),
+				Params:       srp.DefaultParams,
+				ServerPublic: []byte("server public"),
+			}, tt.startErr)
+			server := &VaultServer{
+				userHandler: mockUserHandler,
+				logger:      zap.NewNop(),
+			}
+
+			resp, err := server.StartAuth(mockCtx, &pb.StartAuthRequest{Name: "testuser", ClientPublic: tt.public})
+			assert.Equal(t, tt.wantCode, status.Code(err))
+			assert.Equal(t, tt.wantReason, errorReason(err))
+			if tt.wantCode == codes.OK {
+				assert.Equal(t, "handshake1", resp.HandshakeId)
+				assert.Equal(t, []byte("server public"), resp.ServerPublic)
+				assert.Equal(t, uint32(4), resp.Params.Threads)
+			}
+		})
+	}
+}
+
+func TestVaultServer_FinishLogin(t *testing.T) {
+	tests := []struct {
+		testname       string
+		proof          *pb.PasswordProof
+		code           string
+		finishErr      error
+		wantCode       codes.Code
+		wantReason     string
+		wantEnrollment bool
+	}{
+		{
+			testname: "valid",
+			proof:    &pb.PasswordProof{HandshakeId: "handshake1", Proof: []byte("proof")},
+			wantCode: codes.OK,
+		},
+		{
+			testname: "without proof",
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			testname:   "wrong proof",
+			proof:      &pb.PasswordProof{HandshakeId: "handshake1", Proof: []byte("proof")},
+			finishErr:  servererrors.InvalidPasswordProof,
+			wantCode:   codes.PermissionDenied,
+			wantReason: "INVALID_PASSWORD_PROOF",
+		},
+		{
+			testname:   "code is required",
+			proof:      &pb.PasswordProof{HandshakeId: "handshake1", Proof: []byte("proof")},
+			finishErr:  servererrors.TwoFactorRequired,
+			wantCode:   codes.FailedPrecondition,
+			wantReason: "TWO_FACTOR_REQUIRED",
+		},
+		{
+			// tokens are of session accepted only to enroll
+			testname:       "enrollment is required",
+			proof:          &pb.PasswordProof{HandshakeId: "handshake1", Proof: []byte("proof")},
+			finishErr:      servererrors.TwoFactorEnrollmentRequired,
+			wantCode:       codes.OK,
+			wantEnrollment: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.testname, func(t *testing.T) {
+			mockCtx := context.Background()
+			mockUserHandler := &mocks.UserHandler{}
+			mockUserHandler.On("FinishLogin", mockCtx, models.PasswordProof{HandshakeID: "handshake1", Proof: []byte("proof")}, tt.code, models.Device{}).
+				Return(models.Tokens{Access: "mocktoken"}, int64(0), []byte("server proof"), tt.finishErr)
+			server := &VaultServer{
+				userHandler: mockUserHandler,
+				logger:      zap.NewNop(),
+			}
+
+			resp, err := server.FinishLogin(mockCtx, &pb.FinishLoginRequest{Proof: tt.proof, OtpCode: tt.code})
+			assert.Equal(t, tt.wantCode, status.Code(err))
+			assert.Equal(t, tt.wantReason, errorReason(err))
+			if tt.wantCode == codes.OK {
+				assert.Equal(t, "mocktoken", resp.Token)
+				assert.Equal(t, []byte("server proof"), resp.ServerProof)
+				assert.Equal(t, tt.wantEnrollment, resp.TwoFactorEnrollmentRequired)
+			}
+		})
+	}
+}
+
+func TestVaultServer_ChangeVerifier(t *testing.T) {
+	salt := make([]byte, srp.SaltSize)
+	verifier := &pb.PasswordVerifier{Salt: salt, Verifier: []byte("verifier"), Params: &pb.KDFParams{Time: 3, Memory: 64 * 1024, Threads: 4}}
+	tests := []struct {
+		testname   string
+		verifier   *pb.PasswordVerifier
+		changeErr  error
+		wantCode   codes.Code
+		wantReason string
+	}{
+		{
+			testname: "valid",
+			verifier: verifier,
+			wantCode: codes.OK,
+		},
+		{
+			testname: "without verifier",
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			testname:   "proof of another user",
+			verifier:   verifier,
+			changeErr:  servererrors.InvalidPasswordProof,
+			wantCode:   codes.PermissionDenied,
+			wantReason: "INVALID_PASSWORD_PROOF",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.testname, func(t *testing.T) {
+			mockCtx := metadata.NewIncomingContext(context.Background(), metadata.New(map[string]string{"user": "user-uuid"}))
+			mockUserHandler := &mocks.UserHandler{}
+			mockUserHandler.On("ChangeVerifier", mockCtx, models.User{
+				UUID: "user-uuid",
+				PasswordVerifier: models.PasswordVerifier{
+					Salt:     salt,
+					Verifier: []byte("verifier"),
+					Params:   srp.DefaultParams,
+				},
+			}, models.PasswordProof{HandshakeID: "handshake1"}, models.Device{}).Return(models.Tokens{Access: "mocktoken"}, []byte("server proof"), tt.changeErr)
+			server := &VaultServer{
+				userHandler: mockUserHandler,
+				logger:      zap.NewNop(),
+			}
+
+			resp, err := server.ChangeVerifier(mockCtx, &pb.ChangeVerifierRequest{
+				Proof:       &pb.PasswordProof{HandshakeId: "handshake1"},
+				NewVerifier: tt.verifier,
+			})
+			assert.Equal(t, tt.wantCode, status.Code(err))
+			assert.Equal(t, tt.wantReason, errorReason(err))
+			if tt.wantCode == codes.OK {
+				assert.Equal(t, "mocktoken", resp.Token)
+				assert.Equal(t, []byte("server proof"), resp.ServerProof)
+			}
+		})
+	}
+}
+
+func TestDeviceOf(t *testing.T) {
+	cert := &x509.Certificate{Raw: []byte("certificate")}
+	ctx := peer.NewContext(context.Background(), &peer.Peer{
+		Addr: &net.TCPAddr{IP: net.ParseIP("192.0.2.10"), Port: 50123},
+		AuthInfo: credentials.TLSInfo{State: tls.ConnectionState{
+			PeerCertificates: []*x509.Certificate{cert},
+		}},
+	})
+	hash := sha256.Sum256(cert.Raw)
+
+	device := deviceOf(ctx, strings.Repeat("a", 100))
+	assert.Equal(t, strings.Repeat("a", maxDeviceName), device.Name)
+	assert.Equal(t, "192.0.2.10", device.IP)
+	assert.Equal(t, hex.EncodeToString(hash[:]), device.CertFingerprint)
+
+	assert.Equal(t, models.Device{Name: "laptop"}, deviceOf(context.Background(), "laptop"))
+}
+
+// uploadStream - client stream of UploadSecret with prepared requests
+type uploadStream struct {
+	grpc.ServerStream
+	ctx      context.Context
+	requests []*pb.UploadSecretRequest
+	response *pb.UploadSecretResponse
+}
+
+func (s *uploadStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *uploadStream) Recv() (*pb.UploadSecretRequest, error) {
+	if len(s.requests) == 0 {
+		return nil, io.EOF
+	}
+	req := s.requests[0]
+	s.requests = s.requests[1:]
+	return req, nil
+}
+
+func (s *uploadStream) SendAndClose(resp *pb.UploadSecretResponse) error {
+	s.response = resp
+	return nil
+}
+
+// downloadStream - server stream of DownloadSecret collecting sent chunks
+type downloadStream struct {
+	grpc.ServerStream
+	ctx    context.Context
+	chunks [][]byte
+}
+
+func (s *downloadStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *downloadStream) Send(resp *pb.DownloadSecretResponse) error {
+	s.chunks = append(s.chunks, resp.Chunk)
+	return nil
+}
+
+func TestVaultServer_UploadSecret(t *testing.T) {
+	mockUser := models.User{
+		UUID:  uuid.New().String(),
+		Login: "testuser"}
+	header := &pb.SecretData{
+		Uuid:  uuid.New().String(),
+		Meta:  "testmeta",
+		Type:  "bi",
+		Value: []byte("testvalue"),
+	}
+	mockCtx := metadata.NewIncomingContext(context.Background(), metadata.New(map[string]string{"user": mockUser.Login}))
+
+	tests := []struct {
+		testname        string
+		requests        []*pb.UploadSecretRequest
+		expectedVersion int64
+		saveErr         error
+		wantCode        codes.Code
+		wantVersion     int64
+		wantDeleted     bool
+	}{
+		{
+			testname: "new data",
+			requests: []*pb.UploadSecretRequest{
+				{Data: header},
+				{Chunk: []byte("chunk1")},
+				{Chunk: []byte("chunk2")},
+			},
+			wantCode:    codes.OK,
+			wantVersion: 1,
+		},
+		{
+			testname: "replace data",
+			requests: []*pb.UploadSecretRequest{
+				{Data: header, ExpectedVersion: 2},
+				{Chunk: []byte("chunk1")},
+				{Chunk: []byte("chunk2")},
+			},
+			expectedVersion: 2,
+			wantCode:        codes.OK,
+			wantVersion:     3,
+		},
+		{
+			testname: "replace data with stale version",
+			requests: []*pb.UploadSecretRequest{
+				{Data: header, ExpectedVersion: 2},
+				{Chunk: []byte("chunk1")},
+				{Chunk: []byte("chunk2")},
+			},
+			expectedVersion: 2,
+			saveErr:         servererrors.VersionConflict,
+			wantCode:        codes.Aborted,
+			wantDeleted:     true,
+		},
+		{
+			testname: "no header",
+			requests: []*pb.UploadSecretRequest{
+				{Chunk: []byte("chunk1")},
+			},
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			testname: "chunk is too large",
+			requests: []*pb.UploadSecretRequest{
+				{Data: header},
+				{Chunk: make([]byte, maxChunkSize+1)},
+			},
+			wantCode:    codes.InvalidArgument,
+			wantDeleted: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.testname, func(t *testing.T) {
+			mockUserHandler := &mocks.UserHandler{}
+			mockDataHandler := &mocks.DataHandler{}
+			mockUserHandler.On("GetUser", mockCtx, mockUser.Login).Return(mockUser, nil)
+			mockDataHandler.On("SaveChunk", mockCtx, mockUser, mock.AnythingOfType("models.VaultChunk")).Return(nil)
+			mockDataHandler.On("CreateData", mockCtx, mockUser, mock.AnythingOfType("models.VaultData")).
+				Return(header.Uuid, int64(100), tt.saveErr)
+			mockDataHandler.On("GetData", mockCtx, mockUser, header.Uuid).
+				Return(models.VaultData{DataUUID: header.Uuid, Version: 2, ChunksID: "previous"}, nil)
+			mockDataHandler.On("ChangeData", mockCtx, mockUser, mock.AnythingOfType("models.VaultData")).
+				Return(int64(100), tt.saveErr)
+			mockDataHandler.On("DeleteChunks", mock.Anything, mockUser, header.Uuid, mock.AnythingOfType("string")).Return(nil)
+
+			server := &VaultServer{
+				userHandler: mockUserHandler,
+				dataHandler: mockDataHandler,
+				logger:      zap.NewNop(),
+			}
+
+			stream := &uploadStream{ctx: mockCtx, requests: tt.requests}
+			err := server.UploadSecret(stream)
+			assert.Equal(t, tt.wantCode, status.Code(err))
+			if tt.wantCode == codes.OK {
+				assert.Equal(t, tt.wantVersion, stream.response.Version)
+				mockDataHandler.AssertNumberOfCalls(t, "SaveChunk", 2)
+				if tt.expectedVersion != 0 {
+					// replaced chunks are deleted, uploaded are kept
+					mockDataHandler.AssertCalled(t, "DeleteChunks", mockCtx, mockUser, header.Uuid, "previous")
+					mockDataHandler.AssertNumberOfCalls(t, "DeleteChunks", 1)
+				}
+			}
+			if tt.wantDeleted {
+				// chunks of failed upload are deleted
+				mockDataHandler.AssertCalled(t, "DeleteChunks", context.Background(), mockUser, header.Uuid, mock.AnythingOfType("string"))
+			}
+		})
+	}
+}
+
+func TestVaultServer_DownloadSecret(t *testing.T) {
+	mockUser := models.User{
+		UUID:  uuid.New().String(),
+		Login: "testuser"}
+	dataUUID := uuid.New().String()
+	mockCtx := metadata.NewIncomingContext(context.Background(), metadata.New(map[string]string{"user": mockUser.Login}))
+
+	tests := []struct {
+		testname   string
+		version    int64
+		data       models.VaultData
+		wantCode   codes.Code
+		wantChunks [][]byte
+	}{
+		{
+			testname:   "valid",
+			version:    2,
+			data:       models.VaultData{DataUUID: dataUUID, Version: 2, ChunksID: "chunks"},
+			wantCode:   codes.OK,
+			wantChunks: [][]byte{[]byte("chunk1"), []byte("chunk2")},
+		},
+		{
+			testname: "version changed",
+			version:  1,
+			data:     models.VaultData{DataUUID: dataUUID, Version: 2, ChunksID: "chunks"},
+			wantCode: codes.Aborted,
+		},
+		{
+			testname: "data without chunks",
+			version:  2,
+			data:     models.VaultData{DataUUID: dataUUID, Version: 2},
+			wantCode: codes.FailedPrecondition,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.testname, func(t *testing.T) {
+			mockUserHandler := &mocks.UserHandler{}
+			mockDataHandler := &mocks.DataHandler{}
+			mockUserHandler.On("GetUser", mockCtx, mockUser.Login).Return(mockUser, nil)
+			mockDataHandler.On("GetData", mockCtx, mockUser, dataUUID).Return(tt.data, nil)
+			mockDataHandler.On("GetChunks", mockCtx, mockUser, dataUUID, "chunks", mock.Anything).
+				Run(func(args mock.Arguments) {
+					send := args.Get(4).(func([]byte) error)
+					_ = send([]byte("chunk1"))
+					_ = send([]byte("chunk2"))
+				}).Return(nil)
+
+			server := &VaultServer{
+				userHandler: mockUserHandler,
+				dataHandler: mockDataHandler,
+				logger:      zap.NewNop(),
+			}
+
+			stream := &downloadStream{ctx: mockCtx}
+			err := server.DownloadSecret(&pb.DownloadSecretRequest{Uuid: dataUUID, Version: tt.version}, stream)
+			assert.Equal(t, tt.wantCode, status.Code(err))
+			assert.Equal(t, tt.wantChunks, stream.chunks)
+		})
+	}
+}
@@ -3,37 +3,112 @@ package middlewares
 
 import (
 	"context"
-	"fmt"
+	"errors"
 
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 
 	"github.com/h2p2f/dedicated-vault/internal/server/jwtprocessing"
+	"github.com/h2p2f/dedicated-vault/internal/server/servererrors"
 )
 
+// SessionChecker checks that session of token is not revoked
+// session accepted only to enroll two-factor authentication is reported by servererrors.TwoFactorEnrollmentRequired
+type SessionChecker interface {
+	CheckSession(ctx context.Context, userUUID, sessionID string) error
+}
+
+// errorDomain - domain of error details, the same as of errors of grpc server
+const errorDomain = "dedicated-vault"
+
 // JWTCheckingUnaryServerInterceptor is an interceptor for checking jwt token
-func JWTCheckingUnaryServerInterceptor(key string, fullAccessMethods map[string]bool) grpc.UnaryServerInterceptor {
+// token is accepted only while its session is not revoked by logout or change of password,
+// token of session accepted only to enroll two-factor authentication is accepted only by enrollmentMethods
+func JWTCheckingUnaryServerInterceptor(keys *jwtprocessing.Keys, sessions SessionChecker,
+	fullAccessMethods, enrollmentMethods map[string]bool) grpc.UnaryServerInterceptor {
 	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
-		fmt.Println("interceptor")
 		if fullAccessMethods[info.FullMethod] {
 			return handler(ctx, req)
 		}
-		md, ok := metadata.FromIncomingContext(ctx)
-		if !ok {
-			return nil, status.Errorf(codes.InvalidArgument, "metadata not found")
+		ctx, err := userContext(ctx, keys, sessions, enrollmentMethods[info.FullMethod])
+		if err != nil {
+			return nil, err
 		}
-		authValues := md.Get("authorization")
+		return handler(ctx, req)
+	}
+}
 
-		user, err := jwtprocessing.ParseToken(authValues[0], key)
+// JWTCheckingStreamServerInterceptor is an interceptor for checking jwt token of streaming methods
+func JWTCheckingStreamServerInterceptor(keys *jwtprocessing.Keys, sessions SessionChecker,
+	fullAccessMethods, enrollmentMethods map[string]bool) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if fullAccessMethods[info.FullMethod] {
+			return handler(srv, ss)
+		}
+		ctx, err := userContext(ss.Context(), keys, sessions, enrollmentMethods[info.FullMethod])
 		if err != nil {
-			return nil, status.Errorf(codes.Unauthenticated, "invalid token")
+			return err
+		}
+		return handler(srv, &userStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// userContext checks jwt token from metadata and its session, user and session from it are put to metadata
+// session accepted only to enroll two-factor authentication is accepted only if enrollment is set
+func userContext(ctx context.Context, keys *jwtprocessing.Keys, sessions SessionChecker, enrollment bool) (context.Context, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return nil, status.Errorf(codes.InvalidArgument, "metadata not found")
+	}
+	authValues := md.Get("authorization")
+	if len(authValues) == 0 {
+		return nil, status.Errorf(codes.Unauthenticated, "token not found")
+	}
+
+	claims, err := keys.ParseToken(authValues[0])
+	if err != nil {
+		return nil, status.Errorf(codes.Unauthenticated, "invalid token")
+	}
+	err = sessions.CheckSession(ctx, claims.Login, claims.Session)
+	if errors.Is(err, servererrors.TwoFactorEnrollmentRequired) {
+		if !enrollment {
+			return nil, enrollmentRequired()
 		}
+		err = nil
+	}
+	if errors.Is(err, servererrors.SessionRevoked) {
+		return nil, status.Errorf(codes.Unauthenticated, "session is revoked")
+	}
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "session can't be checked")
+	}
 
-		md.Set("user", user)
-		ctx = metadata.NewIncomingContext(ctx, md)
+	md.Set("user", claims.Login)
+	md.Set("session", claims.Session)
+	return metadata.NewIncomingContext(ctx, md), nil
+}
 
-		return handler(ctx, req)
+// enrollmentRequired - error of call with token of session accepted only to enroll two-factor authentication,
+// its reason is the same as of login of user who has to enroll it
+func enrollmentRequired() error {
+	st := status.New(codes.FailedPrecondition, servererrors.TwoFactorEnrollmentRequired.Error())
+	withDetails, err := st.WithDetails(&errdetails.ErrorInfo{Reason: "TWO_FACTOR_ENROLLMENT_REQUIRED", Domain: errorDomain})
+	if err != nil {
+		return st.Err()
 	}
+	return withDetails.Err()
+}
+
+// userStream is a server stream with context containing user
+type userStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+// Context returns context with user
+func (s *userStream) Context() context.Context {
+	return s.ctx
 }
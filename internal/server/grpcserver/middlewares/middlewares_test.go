@@ -0,0 +1,107 @@
+package middlewares
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/h2p2f/dedicated-vault/internal/server/jwtprocessing"
+	"github.com/h2p2f/dedicated-vault/internal/server/servererrors"
+)
+
+// sessionFunc - session checker of test
+type sessionFunc func(ctx context.Context, userUUID, sessionID string) error
+
+// CheckSession checks session by function
+func (f sessionFunc) CheckSession(ctx context.Context, userUUID, sessionID string) error {
+	return f(ctx, userUUID, sessionID)
+}
+
+func TestJWTCheckingUnaryServerInterceptor(t *testing.T) {
+	keys, err := jwtprocessing.NewSecretKeys("testsecret")
+	require.NoError(t, err)
+	token, err := keys.GenerateToken("user-uuid", "session1")
+	require.NoError(t, err)
+	fullAccessMethods := map[string]bool{"/DedicatedVault/Login": true}
+	enrollmentMethods := map[string]bool{"/DedicatedVault/EnrollTwoFactor": true}
+
+	tests := []struct {
+		name       string
+		method     string
+		token      string
+		sessionErr error
+		wantCode   codes.Code
+	}{
+		{
+			name:     "method without token",
+			method:   "/DedicatedVault/Login",
+			wantCode: codes.OK,
+		},
+		{
+			name:     "valid session",
+			method:   "/DedicatedVault/SaveSecret",
+			token:    token,
+			wantCode: codes.OK,
+		},
+		{
+			name:     "no token",
+			method:   "/DedicatedVault/SaveSecret",
+			wantCode: codes.Unauthenticated,
+		},
+		{
+			name:       "revoked session",
+			method:     "/DedicatedVault/SaveSecret",
+			token:      token,
+			sessionErr: servererrors.SessionRevoked,
+			wantCode:   codes.Unauthenticated,
+		},
+		{
+			name:       "enrollment session is accepted to enroll",
+			method:     "/DedicatedVault/EnrollTwoFactor",
+			token:      token,
+			sessionErr: servererrors.TwoFactorEnrollmentRequired,
+			wantCode:   codes.OK,
+		},
+		{
+			name:       "enrollment session is not accepted for data",
+			method:     "/DedicatedVault/SaveSecret",
+			token:      token,
+			sessionErr: servererrors.TwoFactorEnrollmentRequired,
+			wantCode:   codes.FailedPrecondition,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sessions := sessionFunc(func(ctx context.Context, userUUID, sessionID string) error {
+				assert.Equal(t, "user-uuid", userUUID)
+				assert.Equal(t, "session1", sessionID)
+				return tt.sessionErr
+			})
+			interceptor := JWTCheckingUnaryServerInterceptor(keys, sessions, fullAccessMethods, enrollmentMethods)
+			md := metadata.New(nil)
+			if tt.token != "" {
+				md.Set("authorization", tt.token)
+			}
+			ctx := metadata.NewIncomingContext(context.Background(), md)
+			var user string
+			_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: tt.method},
+				func(ctx context.Context, req any) (any, error) {
+					md, _ := metadata.FromIncomingContext(ctx)
+					if values := md.Get("user"); len(values) > 0 {
+						user = values[0]
+					}
+					return nil, nil
+				})
+			assert.Equal(t, tt.wantCode, status.Code(err))
+			if tt.wantCode == codes.OK && tt.token != "" {
+				assert.Equal(t, "user-uuid", user)
+			}
+		})
+	}
+}
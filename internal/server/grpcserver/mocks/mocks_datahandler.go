@@ -70,6 +70,20 @@ func (_m *DataHandler) CreateData(ctx context.Context, user models.User, data mo
 	return r0, r1, r2
 }
 
+// DeleteChunks provides a mock function with given fields: ctx, user, dataUUID, chunksID
+func (_m *DataHandler) DeleteChunks(ctx context.Context, user models.User, dataUUID string, chunksID string) error {
+	ret := _m.Called(ctx, user, dataUUID, chunksID)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, models.User, string, string) error); ok {
+		r0 = rf(ctx, user, dataUUID, chunksID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // DeleteData provides a mock function with given fields: ctx, user, data
 func (_m *DataHandler) DeleteData(ctx context.Context, user models.User, data models.VaultData) (int64, error) {
 	ret := _m.Called(ctx, user, data)
@@ -120,6 +134,91 @@ func (_m *DataHandler) GetAllData(ctx context.Context, user models.User) ([]mode
 	return r0, r1
 }
 
+// GetChangedData provides a mock function with given fields: ctx, user, sinceRevision, limit
+func (_m *DataHandler) GetChangedData(ctx context.Context, user models.User, sinceRevision int64, limit int64) ([]models.VaultData, bool, error) {
+	ret := _m.Called(ctx, user, sinceRevision, limit)
+
+	var r0 []models.VaultData
+	var r1 bool
+	var r2 error
+	if rf, ok := ret.Get(0).(func(context.Context, models.User, int64, int64) ([]models.VaultData, bool, error)); ok {
+		return rf(ctx, user, sinceRevision, limit)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, models.User, int64, int64) []models.VaultData); ok {
+		r0 = rf(ctx, user, sinceRevision, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.VaultData)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, models.User, int64, int64) bool); ok {
+		r1 = rf(ctx, user, sinceRevision, limit)
+	} else {
+		r1 = ret.Get(1).(bool)
+	}
+
+	if rf, ok := ret.Get(2).(func(context.Context, models.User, int64, int64) error); ok {
+		r2 = rf(ctx, user, sinceRevision, limit)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// GetChunks provides a mock function with given fields: ctx, user, dataUUID, chunksID, send
+func (_m *DataHandler) GetChunks(ctx context.Context, user models.User, dataUUID string, chunksID string, send func([]byte) error) error {
+	ret := _m.Called(ctx, user, dataUUID, chunksID, send)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, models.User, string, string, func([]byte) error) error); ok {
+		r0 = rf(ctx, user, dataUUID, chunksID, send)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// GetData provides a mock function with given fields: ctx, user, dataUUID
+func (_m *DataHandler) GetData(ctx context.Context, user models.User, dataUUID string) (models.VaultData, error) {
+	ret := _m.Called(ctx, user, dataUUID)
+
+	var r0 models.VaultData
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, models.User, string) (models.VaultData, error)); ok {
+		return rf(ctx, user, dataUUID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, models.User, string) models.VaultData); ok {
+		r0 = rf(ctx, user, dataUUID)
+	} else {
+		r0 = ret.Get(0).(models.VaultData)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, models.User, string) error); ok {
+		r1 = rf(ctx, user, dataUUID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// SaveChunk provides a mock function with given fields: ctx, user, chunk
+func (_m *DataHandler) SaveChunk(ctx context.Context, user models.User, chunk models.VaultChunk) error {
+	ret := _m.Called(ctx, user, chunk)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, models.User, models.VaultChunk) error); ok {
+		r0 = rf(ctx, user, chunk)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // NewDataHandler creates a new instance of DataHandler. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func NewDataHandler(t interface {
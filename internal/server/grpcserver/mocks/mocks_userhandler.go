@@ -15,23 +15,23 @@ type UserHandler struct {
 	mock.Mock
 }
 
-// ChangePassword provides a mock function with given fields: ctx, user, newPassword
-func (_m *UserHandler) ChangePassword(ctx context.Context, user models.User, newPassword string) (string, error) {
-	ret := _m.Called(ctx, user, newPassword)
+// ChangePassword provides a mock function with given fields: ctx, user, newPassword, device
+func (_m *UserHandler) ChangePassword(ctx context.Context, user models.User, newPassword string, device models.Device) (models.Tokens, error) {
+	ret := _m.Called(ctx, user, newPassword, device)
 
-	var r0 string
+	var r0 models.Tokens
 	var r1 error
-	if rf, ok := ret.Get(0).(func(context.Context, models.User, string) (string, error)); ok {
-		return rf(ctx, user, newPassword)
+	if rf, ok := ret.Get(0).(func(context.Context, models.User, string, models.Device) (models.Tokens, error)); ok {
+		return rf(ctx, user, newPassword, device)
 	}
-	if rf, ok := ret.Get(0).(func(context.Context, models.User, string) string); ok {
-		r0 = rf(ctx, user, newPassword)
+	if rf, ok := ret.Get(0).(func(context.Context, models.User, string, models.Device) models.Tokens); ok {
+		r0 = rf(ctx, user, newPassword, device)
 	} else {
-		r0 = ret.Get(0).(string)
+		r0 = ret.Get(0).(models.Tokens)
 	}
 
-	if rf, ok := ret.Get(1).(func(context.Context, models.User, string) error); ok {
-		r1 = rf(ctx, user, newPassword)
+	if rf, ok := ret.Get(1).(func(context.Context, models.User, string, models.Device) error); ok {
+		r1 = rf(ctx, user, newPassword, device)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -39,18 +39,171 @@ func (_m *UserHandler) ChangePassword(ctx context.Context, user models.User, new
 	return r0, r1
 }
 
-// DeleteUser provides a mock function with given fields: ctx, user
-func (_m *UserHandler) DeleteUser(ctx context.Context, user models.User) error {
-	ret := _m.Called(ctx, user)
+// ChangeVerifier provides a mock function with given fields: ctx, user, proof, device
+func (_m *UserHandler) ChangeVerifier(ctx context.Context, user models.User, proof models.PasswordProof, device models.Device) (models.Tokens, []byte, error) {
+	ret := _m.Called(ctx, user, proof, device)
 
-	var r0 error
-	if rf, ok := ret.Get(0).(func(context.Context, models.User) error); ok {
-		r0 = rf(ctx, user)
+	var r0 models.Tokens
+	var r1 []byte
+	var r2 error
+	if rf, ok := ret.Get(0).(func(context.Context, models.User, models.PasswordProof, models.Device) (models.Tokens, []byte, error)); ok {
+		return rf(ctx, user, proof, device)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, models.User, models.PasswordProof, models.Device) models.Tokens); ok {
+		r0 = rf(ctx, user, proof, device)
 	} else {
-		r0 = ret.Error(0)
+		r0 = ret.Get(0).(models.Tokens)
 	}
 
-	return r0
+	if rf, ok := ret.Get(1).(func(context.Context, models.User, models.PasswordProof, models.Device) []byte); ok {
+		r1 = rf(ctx, user, proof, device)
+	} else {
+		if ret.Get(1) != nil {
+			r1 = ret.Get(1).([]byte)
+		}
+	}
+
+	if rf, ok := ret.Get(2).(func(context.Context, models.User, models.PasswordProof, models.Device) error); ok {
+		r2 = rf(ctx, user, proof, device)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// ConfirmTwoFactor provides a mock function with given fields: ctx, user, sessionID, proof, code
+func (_m *UserHandler) ConfirmTwoFactor(ctx context.Context, user models.User, sessionID string, proof models.PasswordProof, code string) ([]string, []byte, error) {
+	ret := _m.Called(ctx, user, sessionID, proof, code)
+
+	var r0 []string
+	var r1 []byte
+	var r2 error
+	if rf, ok := ret.Get(0).(func(context.Context, models.User, string, models.PasswordProof, string) ([]string, []byte, error)); ok {
+		return rf(ctx, user, sessionID, proof, code)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, models.User, string, models.PasswordProof, string) []string); ok {
+		r0 = rf(ctx, user, sessionID, proof, code)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]string)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, models.User, string, models.PasswordProof, string) []byte); ok {
+		r1 = rf(ctx, user, sessionID, proof, code)
+	} else {
+		if ret.Get(1) != nil {
+			r1 = ret.Get(1).([]byte)
+		}
+	}
+
+	if rf, ok := ret.Get(2).(func(context.Context, models.User, string, models.PasswordProof, string) error); ok {
+		r2 = rf(ctx, user, sessionID, proof, code)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// DisableTwoFactor provides a mock function with given fields: ctx, user, proof, code
+func (_m *UserHandler) DisableTwoFactor(ctx context.Context, user models.User, proof models.PasswordProof, code string) ([]byte, error) {
+	ret := _m.Called(ctx, user, proof, code)
+
+	var r0 []byte
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, models.User, models.PasswordProof, string) ([]byte, error)); ok {
+		return rf(ctx, user, proof, code)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, models.User, models.PasswordProof, string) []byte); ok {
+		r0 = rf(ctx, user, proof, code)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]byte)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, models.User, models.PasswordProof, string) error); ok {
+		r1 = rf(ctx, user, proof, code)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// EnrollTwoFactor provides a mock function with given fields: ctx, user, proof
+func (_m *UserHandler) EnrollTwoFactor(ctx context.Context, user models.User, proof models.PasswordProof) (string, []byte, error) {
+	ret := _m.Called(ctx, user, proof)
+
+	var r0 string
+	var r1 []byte
+	var r2 error
+	if rf, ok := ret.Get(0).(func(context.Context, models.User, models.PasswordProof) (string, []byte, error)); ok {
+		return rf(ctx, user, proof)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, models.User, models.PasswordProof) string); ok {
+		r0 = rf(ctx, user, proof)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, models.User, models.PasswordProof) []byte); ok {
+		r1 = rf(ctx, user, proof)
+	} else {
+		if ret.Get(1) != nil {
+			r1 = ret.Get(1).([]byte)
+		}
+	}
+
+	if rf, ok := ret.Get(2).(func(context.Context, models.User, models.PasswordProof) error); ok {
+		r2 = rf(ctx, user, proof)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// FinishLogin provides a mock function with given fields: ctx, proof, code, device
+func (_m *UserHandler) FinishLogin(ctx context.Context, proof models.PasswordProof, code string, device models.Device) (models.Tokens, int64, []byte, error) {
+	ret := _m.Called(ctx, proof, code, device)
+
+	var r0 models.Tokens
+	var r1 int64
+	var r2 []byte
+	var r3 error
+	if rf, ok := ret.Get(0).(func(context.Context, models.PasswordProof, string, models.Device) (models.Tokens, int64, []byte, error)); ok {
+		return rf(ctx, proof, code, device)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, models.PasswordProof, string, models.Device) models.Tokens); ok {
+		r0 = rf(ctx, proof, code, device)
+	} else {
+		r0 = ret.Get(0).(models.Tokens)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, models.PasswordProof, string, models.Device) int64); ok {
+		r1 = rf(ctx, proof, code, device)
+	} else {
+		r1 = ret.Get(1).(int64)
+	}
+
+	if rf, ok := ret.Get(2).(func(context.Context, models.PasswordProof, string, models.Device) []byte); ok {
+		r2 = rf(ctx, proof, code, device)
+	} else {
+		if ret.Get(2) != nil {
+			r2 = ret.Get(2).([]byte)
+		}
+	}
+
+	if rf, ok := ret.Get(3).(func(context.Context, models.PasswordProof, string, models.Device) error); ok {
+		r3 = rf(ctx, proof, code, device)
+	} else {
+		r3 = ret.Error(3)
+	}
+
+	return r0, r1, r2, r3
 }
 
 // GetUser provides a mock function with given fields: ctx, user
@@ -77,30 +230,56 @@ func (_m *UserHandler) GetUser(ctx context.Context, user string) (models.User, e
 	return r0, r1
 }
 
-// Login provides a mock function with given fields: ctx, user
-func (_m *UserHandler) Login(ctx context.Context, user models.User) (string, int64, error) {
+// ListSessions provides a mock function with given fields: ctx, user
+func (_m *UserHandler) ListSessions(ctx context.Context, user models.User) ([]models.Session, error) {
 	ret := _m.Called(ctx, user)
 
-	var r0 string
-	var r1 int64
-	var r2 error
-	if rf, ok := ret.Get(0).(func(context.Context, models.User) (string, int64, error)); ok {
+	var r0 []models.Session
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, models.User) ([]models.Session, error)); ok {
 		return rf(ctx, user)
 	}
-	if rf, ok := ret.Get(0).(func(context.Context, models.User) string); ok {
+	if rf, ok := ret.Get(0).(func(context.Context, models.User) []models.Session); ok {
 		r0 = rf(ctx, user)
 	} else {
-		r0 = ret.Get(0).(string)
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.Session)
+		}
 	}
 
-	if rf, ok := ret.Get(1).(func(context.Context, models.User) int64); ok {
+	if rf, ok := ret.Get(1).(func(context.Context, models.User) error); ok {
 		r1 = rf(ctx, user)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Login provides a mock function with given fields: ctx, user, code, device
+func (_m *UserHandler) Login(ctx context.Context, user models.User, code string, device models.Device) (models.Tokens, int64, error) {
+	ret := _m.Called(ctx, user, code, device)
+
+	var r0 models.Tokens
+	var r1 int64
+	var r2 error
+	if rf, ok := ret.Get(0).(func(context.Context, models.User, string, models.Device) (models.Tokens, int64, error)); ok {
+		return rf(ctx, user, code, device)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, models.User, string, models.Device) models.Tokens); ok {
+		r0 = rf(ctx, user, code, device)
+	} else {
+		r0 = ret.Get(0).(models.Tokens)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, models.User, string, models.Device) int64); ok {
+		r1 = rf(ctx, user, code, device)
 	} else {
 		r1 = ret.Get(1).(int64)
 	}
 
-	if rf, ok := ret.Get(2).(func(context.Context, models.User) error); ok {
-		r2 = rf(ctx, user)
+	if rf, ok := ret.Get(2).(func(context.Context, models.User, string, models.Device) error); ok {
+		r2 = rf(ctx, user, code, device)
 	} else {
 		r2 = ret.Error(2)
 	}
@@ -108,30 +287,68 @@ func (_m *UserHandler) Login(ctx context.Context, user models.User) (string, int
 	return r0, r1, r2
 }
 
-// Register provides a mock function with given fields: ctx, user
-func (_m *UserHandler) Register(ctx context.Context, user models.User) (string, int64, error) {
-	ret := _m.Called(ctx, user)
+// Logout provides a mock function with given fields: ctx, user, sessionID
+func (_m *UserHandler) Logout(ctx context.Context, user models.User, sessionID string) error {
+	ret := _m.Called(ctx, user, sessionID)
 
-	var r0 string
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, models.User, string) error); ok {
+		r0 = rf(ctx, user, sessionID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RefreshToken provides a mock function with given fields: ctx, refreshToken
+func (_m *UserHandler) RefreshToken(ctx context.Context, refreshToken string) (models.Tokens, error) {
+	ret := _m.Called(ctx, refreshToken)
+
+	var r0 models.Tokens
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (models.Tokens, error)); ok {
+		return rf(ctx, refreshToken)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) models.Tokens); ok {
+		r0 = rf(ctx, refreshToken)
+	} else {
+		r0 = ret.Get(0).(models.Tokens)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, refreshToken)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Register provides a mock function with given fields: ctx, user, device
+func (_m *UserHandler) Register(ctx context.Context, user models.User, device models.Device) (models.Tokens, int64, error) {
+	ret := _m.Called(ctx, user, device)
+
+	var r0 models.Tokens
 	var r1 int64
 	var r2 error
-	if rf, ok := ret.Get(0).(func(context.Context, models.User) (string, int64, error)); ok {
-		return rf(ctx, user)
+	if rf, ok := ret.Get(0).(func(context.Context, models.User, models.Device) (models.Tokens, int64, error)); ok {
+		return rf(ctx, user, device)
 	}
-	if rf, ok := ret.Get(0).(func(context.Context, models.User) string); ok {
-		r0 = rf(ctx, user)
+	if rf, ok := ret.Get(0).(func(context.Context, models.User, models.Device) models.Tokens); ok {
+		r0 = rf(ctx, user, device)
 	} else {
-		r0 = ret.Get(0).(string)
+		r0 = ret.Get(0).(models.Tokens)
 	}
 
-	if rf, ok := ret.Get(1).(func(context.Context, models.User) int64); ok {
-		r1 = rf(ctx, user)
+	if rf, ok := ret.Get(1).(func(context.Context, models.User, models.Device) int64); ok {
+		r1 = rf(ctx, user, device)
 	} else {
 		r1 = ret.Get(1).(int64)
 	}
 
-	if rf, ok := ret.Get(2).(func(context.Context, models.User) error); ok {
-		r2 = rf(ctx, user)
+	if rf, ok := ret.Get(2).(func(context.Context, models.User, models.Device) error); ok {
+		r2 = rf(ctx, user, device)
 	} else {
 		r2 = ret.Error(2)
 	}
@@ -139,6 +356,99 @@ func (_m *UserHandler) Register(ctx context.Context, user models.User) (string,
 	return r0, r1, r2
 }
 
+// RegisterVerifier provides a mock function with given fields: ctx, user, device
+func (_m *UserHandler) RegisterVerifier(ctx context.Context, user models.User, device models.Device) (models.Tokens, int64, error) {
+	ret := _m.Called(ctx, user, device)
+
+	var r0 models.Tokens
+	var r1 int64
+	var r2 error
+	if rf, ok := ret.Get(0).(func(context.Context, models.User, models.Device) (models.Tokens, int64, error)); ok {
+		return rf(ctx, user, device)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, models.User, models.Device) models.Tokens); ok {
+		r0 = rf(ctx, user, device)
+	} else {
+		r0 = ret.Get(0).(models.Tokens)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, models.User, models.Device) int64); ok {
+		r1 = rf(ctx, user, device)
+	} else {
+		r1 = ret.Get(1).(int64)
+	}
+
+	if rf, ok := ret.Get(2).(func(context.Context, models.User, models.Device) error); ok {
+		r2 = rf(ctx, user, device)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// RevokeSession provides a mock function with given fields: ctx, user, sessionID
+func (_m *UserHandler) RevokeSession(ctx context.Context, user models.User, sessionID string) error {
+	ret := _m.Called(ctx, user, sessionID)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, models.User, string) error); ok {
+		r0 = rf(ctx, user, sessionID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SetWrappedKey provides a mock function with given fields: ctx, user, wrappedKey, keyCheck, expectedVersion
+func (_m *UserHandler) SetWrappedKey(ctx context.Context, user models.User, wrappedKey []byte, keyCheck []byte, expectedVersion int64) (int64, error) {
+	ret := _m.Called(ctx, user, wrappedKey, keyCheck, expectedVersion)
+
+	var r0 int64
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, models.User, []byte, []byte, int64) (int64, error)); ok {
+		return rf(ctx, user, wrappedKey, keyCheck, expectedVersion)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, models.User, []byte, []byte, int64) int64); ok {
+		r0 = rf(ctx, user, wrappedKey, keyCheck, expectedVersion)
+	} else {
+		r0 = ret.Get(0).(int64)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, models.User, []byte, []byte, int64) error); ok {
+		r1 = rf(ctx, user, wrappedKey, keyCheck, expectedVersion)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// StartAuth provides a mock function with given fields: ctx, login, address, clientPublic
+func (_m *UserHandler) StartAuth(ctx context.Context, login string, address string, clientPublic []byte) (models.Challenge, error) {
+	ret := _m.Called(ctx, login, address, clientPublic)
+
+	var r0 models.Challenge
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, []byte) (models.Challenge, error)); ok {
+		return rf(ctx, login, address, clientPublic)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, []byte) models.Challenge); ok {
+		r0 = rf(ctx, login, address, clientPublic)
+	} else {
+		r0 = ret.Get(0).(models.Challenge)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, string, []byte) error); ok {
+		r1 = rf(ctx, login, address, clientPublic)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // NewUserHandler creates a new instance of UserHandler. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func NewUserHandler(t interface {
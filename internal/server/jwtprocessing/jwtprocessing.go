@@ -3,47 +3,93 @@
 package jwtprocessing
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"time"
 
 	"github.com/golang-jwt/jwt/v4"
 )
 
 // Claims - jwt claims
+// Session is id of session of token, token is valid only while its session is not revoked
 type Claims struct {
 	jwt.RegisteredClaims
-	Login string
+	Login   string
+	Session string `json:"sid,omitempty"`
 }
 
-// TOKENEXPIRES - token expires time
+// token lifetimes
 const (
-	TOKENEXPIRES = 240 * time.Hour
+	// ACCESSTOKENEXPIRES - access token is short-lived, so it is refreshed by refresh token of its session
+	ACCESSTOKENEXPIRES = 15 * time.Minute
+	// REFRESHTOKENEXPIRES - session without refresh expires after this time
+	REFRESHTOKENEXPIRES = 30 * 24 * time.Hour
 )
 
-// GenerateToken - generate token
-func GenerateToken(login, key string) (string, error) {
+// GenerateToken - generate access token of session signed by signing key, kid of the key is put into header
+func (k *Keys) GenerateToken(login, session string) (string, error) {
 	claims := Claims{
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(TOKENEXPIRES)),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(ACCESSTOKENEXPIRES)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
-		Login: login,
+		Login:   login,
+		Session: session,
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, &claims)
-	return token.SignedString([]byte(key))
+	token := jwt.NewWithClaims(k.signingMethod, &claims)
+	if k.signingKID != "" {
+		token.Header["kid"] = k.signingKID
+	}
+	return token.SignedString(k.signingKey)
 }
 
-// ParseToken - parse token
-func ParseToken(tokenString, key string) (string, error) {
+// ParseToken - parse token, token is verified by key of its kid and only with algorithm of that key,
+// so public key can't be used as HMAC secret and tokens of removed keys are not accepted
+func (k *Keys) ParseToken(tokenString string) (*Claims, error) {
 	claims := &Claims{}
 	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-		return []byte(key), nil
-	})
-	if claims.Valid() != nil {
-		return "", claims.Valid()
+		kid, _ := token.Header["kid"].(string)
+		key, ok := k.verification[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown key id %q", kid)
+		}
+		if token.Method.Alg() != key.method.Alg() {
+			return nil, fmt.Errorf("unexpected signing method %s of key %q", token.Method.Alg(), kid)
+		}
+		return key.key, nil
+	}, jwt.WithValidMethods([]string{
+		jwt.SigningMethodEdDSA.Alg(), jwt.SigningMethodRS256.Alg(), jwt.SigningMethodHS256.Alg(),
+	}))
+	if err != nil {
+		return nil, err
+	}
+	if claims.Session == "" {
+		// tokens issued before sessions can't be revoked, so they are not accepted
+		return nil, errors.New("token has no session")
 	}
-	return claims.Login, err
+	return claims, nil
 }
 
 // Valid - check if token is valid
 func (c *Claims) Valid() error {
 	return c.RegisteredClaims.Valid()
 }
+
+// GenerateRefreshToken - generate random refresh token, it is opaque for client
+func GenerateRefreshToken() (string, error) {
+	token := make([]byte, 32)
+	if _, err := rand.Read(token); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(token), nil
+}
+
+// HashRefreshToken - hash of refresh token, server keeps only hashes of refresh tokens
+func HashRefreshToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
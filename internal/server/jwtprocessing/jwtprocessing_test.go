@@ -0,0 +1,139 @@
+package jwtprocessing
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeKey writes private key as PKCS #8 PEM file and returns its path
+func writeKey(t *testing.T, name string, key crypto.PrivateKey) string {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	file := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600))
+	return file
+}
+
+// writePublicKey writes public key as PEM file and returns its path
+func writePublicKey(t *testing.T, name string, key crypto.PublicKey) string {
+	der, err := x509.MarshalPKIXPublicKey(key)
+	require.NoError(t, err)
+	file := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0o600))
+	return file
+}
+
+func TestKeys_Rotation(t *testing.T) {
+	_, oldKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	_, newKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	oldFile := writeKey(t, "old.pem", oldKey)
+	newFile := writeKey(t, "new.pem", newKey)
+
+	oldKeys, err := LoadKeys(oldFile, nil)
+	require.NoError(t, err)
+	oldToken, err := oldKeys.GenerateToken("user-uuid", "session")
+	require.NoError(t, err)
+	parsed, _, err := jwt.NewParser().ParseUnverified(oldToken, &Claims{})
+	require.NoError(t, err)
+	assert.Equal(t, "EdDSA", parsed.Method.Alg())
+	assert.Equal(t, oldKeys.SigningKID(), parsed.Header["kid"])
+
+	// new key signs, public key of old key still verifies its tokens
+	rotatedKeys, err := LoadKeys(newFile, []string{writePublicKey(t, "old.pub.pem", oldKey.Public())})
+	require.NoError(t, err)
+	assert.NotEqual(t, oldKeys.SigningKID(), rotatedKeys.SigningKID())
+	claims, err := rotatedKeys.ParseToken(oldToken)
+	require.NoError(t, err)
+	assert.Equal(t, "user-uuid", claims.Login)
+	assert.Equal(t, "session", claims.Session)
+	newToken, err := rotatedKeys.GenerateToken("user-uuid", "session")
+	require.NoError(t, err)
+	_, err = oldKeys.ParseToken(newToken)
+	assert.Error(t, err)
+
+	// old key is removed after rotation
+	newKeys, err := LoadKeys(newFile, nil)
+	require.NoError(t, err)
+	_, err = newKeys.ParseToken(oldToken)
+	assert.Error(t, err)
+	_, err = newKeys.ParseToken(newToken)
+	assert.NoError(t, err)
+}
+
+func TestKeys_RSA(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	keys, err := LoadKeys(writeKey(t, "rsa.pem", key), nil)
+	require.NoError(t, err)
+	token, err := keys.GenerateToken("user-uuid", "session")
+	require.NoError(t, err)
+	parsed, _, err := jwt.NewParser().ParseUnverified(token, &Claims{})
+	require.NoError(t, err)
+	assert.Equal(t, "RS256", parsed.Method.Alg())
+	_, err = keys.ParseToken(token)
+	assert.NoError(t, err)
+
+	weakKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.NoError(t, err)
+	_, err = LoadKeys(writeKey(t, "weak.pem", weakKey), nil)
+	assert.Error(t, err)
+}
+
+func TestKeys_RejectedTokens(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	keys, err := LoadKeys(writeKey(t, "key.pem", privateKey), nil)
+	require.NoError(t, err)
+	claims := Claims{
+		RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute))},
+		Login:            "user-uuid",
+		Session:          "session",
+	}
+	sign := func(method jwt.SigningMethod, kid any, key any, claims Claims) string {
+		token := jwt.NewWithClaims(method, &claims)
+		if kid != nil {
+			token.Header["kid"] = kid
+		}
+		signed, err := token.SignedString(key)
+		require.NoError(t, err)
+		return signed
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(publicKey)
+	require.NoError(t, err)
+	noSession := claims
+	noSession.Session = ""
+	expired := claims
+	expired.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{name: "without kid", token: sign(jwt.SigningMethodEdDSA, nil, privateKey, claims)},
+		{name: "unknown kid", token: sign(jwt.SigningMethodEdDSA, "unknown", privateKey, claims)},
+		{name: "public key as hmac secret", token: sign(jwt.SigningMethodHS256, keys.SigningKID(), publicDER, claims)},
+		{name: "shared secret", token: sign(jwt.SigningMethodHS256, nil, []byte("secret"), claims)},
+		{name: "without session", token: sign(jwt.SigningMethodEdDSA, keys.SigningKID(), privateKey, noSession)},
+		{name: "expired", token: sign(jwt.SigningMethodEdDSA, keys.SigningKID(), privateKey, expired)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := keys.ParseToken(tt.token)
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,173 @@
+// Package: jwtprocessing
+// in this file we have keys of tokens: signing key and keys accepted for verification during rotation
+package jwtprocessing
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// minRSABits - RSA keys shorter than this are rejected
+const minRSABits = 2048
+
+// verificationKey - public key which verifies tokens with its kid
+type verificationKey struct {
+	method jwt.SigningMethod
+	key    crypto.PublicKey
+}
+
+// Keys - signing key of new tokens and keys tokens are verified with, they are found by kid header of token
+// only public keys are needed for verification, so keys of previous signing keys are kept without their private parts
+type Keys struct {
+	signingKID    string
+	signingMethod jwt.SigningMethod
+	signingKey    crypto.PrivateKey
+	verification  map[string]verificationKey
+}
+
+// NewSecretKeys - keys of shared secret, tokens are signed by HS256 without kid
+// anyone who knows the secret can issue tokens, so it is kept for configurations without signing key
+func NewSecretKeys(secret string) (*Keys, error) {
+	if secret == "" {
+		return nil, errors.New("jwt secret is empty")
+	}
+	return &Keys{
+		signingMethod: jwt.SigningMethodHS256,
+		signingKey:    []byte(secret),
+		verification: map[string]verificationKey{
+			"": {method: jwt.SigningMethodHS256, key: []byte(secret)},
+		},
+	}, nil
+}
+
+// LoadKeys loads signing key and verification keys from PEM files
+// signing key is Ed25519 (EdDSA) or RSA (RS256) private key, its public key is always accepted
+// verification keys are public or private keys of previous and next signing keys
+func LoadKeys(signingKeyFile string, verificationKeyFiles []string) (*Keys, error) {
+	signingKey, err := readPrivateKey(signingKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("jwt signing key %s: %w", signingKeyFile, err)
+	}
+	keys := &Keys{
+		signingKey:   signingKey,
+		verification: make(map[string]verificationKey),
+	}
+	keys.signingKID, err = keys.add(signingKey.(crypto.Signer).Public())
+	if err != nil {
+		return nil, fmt.Errorf("jwt signing key %s: %w", signingKeyFile, err)
+	}
+	keys.signingMethod = keys.verification[keys.signingKID].method
+	for _, file := range verificationKeyFiles {
+		publicKey, err := readPublicKey(file)
+		if err == nil {
+			_, err = keys.add(publicKey)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("jwt verification key %s: %w", file, err)
+		}
+	}
+	return keys, nil
+}
+
+// SigningKID - kid of signing key, it is put into header of new tokens
+func (k *Keys) SigningKID() string {
+	return k.signingKID
+}
+
+// add adds public key to verification keys and returns its kid
+func (k *Keys) add(publicKey crypto.PublicKey) (string, error) {
+	var method jwt.SigningMethod
+	switch key := publicKey.(type) {
+	case ed25519.PublicKey:
+		method = jwt.SigningMethodEdDSA
+	case *rsa.PublicKey:
+		if key.N.BitLen() < minRSABits {
+			return "", fmt.Errorf("rsa key must be at least %d bits", minRSABits)
+		}
+		method = jwt.SigningMethodRS256
+	default:
+		return "", fmt.Errorf("unsupported key type %T, only ed25519 and rsa keys are supported", publicKey)
+	}
+	kid, err := KeyID(publicKey)
+	if err != nil {
+		return "", err
+	}
+	k.verification[kid] = verificationKey{method: method, key: publicKey}
+	return kid, nil
+}
+
+// KeyID - kid of public key, it is SHA-256 of DER encoded public key, so all servers derive the same kid of a key
+func KeyID(publicKey crypto.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.Sum256(der)
+	return hex.EncodeToString(hash[:8]), nil
+}
+
+// readPEM reads the first PEM block of file
+func readPEM(file string) (*pem.Block, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+	return block, nil
+}
+
+// readPrivateKey reads PKCS #8 or PKCS #1 (RSA) private key
+func readPrivateKey(file string) (crypto.PrivateKey, error) {
+	block, err := readPEM(file)
+	if err != nil {
+		return nil, err
+	}
+	switch block.Type {
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		switch key.(type) {
+		case ed25519.PrivateKey, *rsa.PrivateKey:
+			return key, nil
+		}
+		return nil, fmt.Errorf("unsupported key type %T, only ed25519 and rsa keys are supported", key)
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	}
+	return nil, fmt.Errorf("unexpected PEM block %q, private key is expected", block.Type)
+}
+
+// readPublicKey reads public key, public key of private key is read as well,
+// so the old signing key file can be moved to verification keys as is
+func readPublicKey(file string) (crypto.PublicKey, error) {
+	block, err := readPEM(file)
+	if err != nil {
+		return nil, err
+	}
+	switch block.Type {
+	case "PUBLIC KEY":
+		return x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	}
+	privateKey, err := readPrivateKey(file)
+	if err != nil {
+		return nil, err
+	}
+	return privateKey.(crypto.Signer).Public(), nil
+}
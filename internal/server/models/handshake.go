@@ -0,0 +1,45 @@
+// Package: models
+// in this file we have models for password authentication with SRP-6a
+package models
+
+import (
+	"time"
+
+	"github.com/h2p2f/dedicated-vault/internal/srp"
+)
+
+// PasswordVerifier - salt and verifier of password, server checks proofs of password with it but can't recover password
+type PasswordVerifier struct {
+	Salt     []byte     `json:"salt" bson:"srpSalt"`
+	Verifier []byte     `json:"verifier" bson:"srpVerifier"`
+	Params   srp.Params `json:"params" bson:"srpParams"`
+}
+
+// PasswordProof - proof of password made by client with handshake
+type PasswordProof struct {
+	HandshakeID string
+	Proof       []byte
+}
+
+// Handshake - started password authentication, it lives until proof of password is checked or it expires
+// server secret is kept only here, so any server can check the proof
+// handshake of unknown login or of user without verifier has no user, its proof is always rejected
+// expired handshakes are removed by TTL index of ExpireAt
+type Handshake struct {
+	HandshakeID  string    `json:"handshake_id" bson:"handshakeID"`
+	Login        string    `json:"login" bson:"login"`
+	Address      string    `json:"address" bson:"address"`
+	UserUUID     string    `json:"user_uuid" bson:"userUUID"`
+	ClientPublic []byte    `json:"-" bson:"clientPublic"`
+	ServerSecret []byte    `json:"-" bson:"serverSecret"`
+	ServerPublic []byte    `json:"-" bson:"serverPublic"`
+	ExpireAt     time.Time `json:"expire_at" bson:"expireAt"`
+}
+
+// Challenge - what client needs to make proof of password
+type Challenge struct {
+	HandshakeID  string
+	Salt         []byte
+	Params       srp.Params
+	ServerPublic []byte
+}
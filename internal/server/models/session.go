@@ -0,0 +1,36 @@
+// Package: models
+// in this file we have models for sessions of users
+package models
+
+// Tokens - tokens of session given to client
+type Tokens struct {
+	Access  string
+	Refresh string
+	// ExpiresIn - lifetime of access token in seconds
+	ExpiresIn int64
+}
+
+// Device - client which started session, it is shown to user in list of sessions
+type Device struct {
+	Name string `json:"device_name" bson:"deviceName"`
+	// CertFingerprint - SHA-256 of TLS client certificate, hex encoded
+	CertFingerprint string `json:"cert_fingerprint" bson:"certFingerprint"`
+	IP              string `json:"ip" bson:"ip"`
+}
+
+// Session - session of user, it is created by login and lives while its refresh token is rotated
+// only hashes of refresh tokens are kept, hash of the previous token detects reuse of rotated token
+type Session struct {
+	SessionID    string `json:"session_id" bson:"sessionID"`
+	UserUUID     string `json:"user_uuid" bson:"userUUID"`
+	Device       `bson:",inline"`
+	RefreshHash  string `json:"-" bson:"refreshHash"`
+	PreviousHash string `json:"-" bson:"previousHash"`
+	Created      int64  `json:"created" bson:"created"`
+	// LastSeen - time of the last request with token of session
+	LastSeen int64 `json:"last_seen" bson:"lastSeen"`
+	Expires  int64 `json:"expires" bson:"expires"`
+	// EnrollmentOnly - session of user who has to enroll two-factor authentication to log in,
+	// it is accepted only to enroll and it is not refreshed
+	EnrollmentOnly bool `json:"-" bson:"enrollmentOnly,omitempty"`
+}
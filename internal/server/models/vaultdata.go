@@ -5,6 +5,8 @@ package models
 import "go.mongodb.org/mongo-driver/bson/primitive"
 
 // VaultData is a struct for data
+// Meta is opaque search token computed by client, server can only compare it
+// ChunksID identifies chunks of streamed binary content of data, it is empty for data without them
 type VaultData struct {
 	ID       primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
 	DataUUID string             `json:"data_uuid" bson:"dataUUID"`
@@ -14,4 +16,19 @@ type VaultData struct {
 	Data     []byte             `json:"data" bson:"data"`
 	Created  int64              `json:"created" bson:"created"`
 	Updated  int64              `json:"updated,omitempty" bson:"updated"`
+	Revision int64              `json:"revision" bson:"revision"`
+	Deleted  bool               `json:"deleted,omitempty" bson:"deleted"`
+	Version  int64              `json:"version" bson:"version"`
+	ChunksID string             `json:"chunks_id,omitempty" bson:"chunksID,omitempty"`
+}
+
+// VaultChunk is a chunk of streamed binary content of data
+// chunks of one upload share ChunksID, so a new upload doesn't mix with the previous one
+type VaultChunk struct {
+	ID       primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+	UserUUID string             `json:"user_uuid" bson:"userUUID"`
+	DataUUID string             `json:"data_uuid" bson:"dataUUID"`
+	ChunksID string             `json:"chunks_id" bson:"chunksID"`
+	Index    int64              `json:"index" bson:"index"`
+	Data     []byte             `json:"data" bson:"data"`
 }
@@ -7,11 +7,38 @@ import (
 )
 
 // User is a struct for user
+// Password is bcrypt hash of password of users registered before verifiers, it is replaced by verifier at their next login
 type User struct {
 	UUID              string `json:"uuid" bson:"UUID"`
 	Login             string `json:"login" bson:"login"`
-	Password          string `json:"password" bson:"password"`
+	Password          string `json:"password" bson:"password,omitempty"`
 	LastServerUpdated int64  `json:"last_server_updated" bson:"lastServerUpdated"`
+	Revision          int64  `json:"revision" bson:"revision"`
+	// PendingRevisions - revisions claimed by changes which are not written yet
+	PendingRevisions  []PendingRevision `json:"-" bson:"pendingRevisions,omitempty"`
+	WrappedKey        []byte            `json:"wrapped_key,omitempty" bson:"wrappedKey"`
+	WrappedKeyVersion int64             `json:"wrapped_key_version" bson:"wrappedKeyVersion"`
+	KeyCheck          []byte            `json:"key_check,omitempty" bson:"keyCheck"`
+	// PasswordVerifier - SRP-6a verifier of password
+	PasswordVerifier `json:"-" bson:",inline"`
+	// TOTPSecret - secret of two-factor authentication, it is set when enrollment is confirmed
+	// TOTPPending is secret of enrollment which is not confirmed yet
+	TOTPSecret  string `json:"-" bson:"totpSecret,omitempty"`
+	TOTPPending string `json:"-" bson:"totpPending,omitempty"`
+	// TOTPLastStep - time step of the last accepted code, codes are not accepted twice
+	TOTPLastStep int64 `json:"-" bson:"totpLastStep"`
+	// RecoveryCodes - hashes of unused recovery codes
+	RecoveryCodes []string `json:"-" bson:"recoveryCodes,omitempty"`
+	// TwoFactorFailures - wrong codes in a row, codes are not checked until TwoFactorLockedUntil (unix time) after too many
+	TwoFactorFailures    int64 `json:"-" bson:"twoFactorFailures"`
+	TwoFactorLockedUntil int64 `json:"-" bson:"twoFactorLockedUntil"`
+}
+
+// PendingRevision - revision claimed by change of data, it is removed when the change is written
+// Expires is unix time of deadline of the change, a claim after it is of a change which failed without removing it
+type PendingRevision struct {
+	Revision int64 `bson:"revision"`
+	Expires  int64 `bson:"expires"`
 }
 
 // FromPB converts pb.User to models.User
@@ -5,4 +5,19 @@ import "errors"
 var (
 	UserAlreadyExists = errors.New("user already exists")
 	RecordNotFound    = errors.New("record not found")
+	DataAlreadyExists = errors.New("data already exists")
+	VersionConflict   = errors.New("data version conflict")
+	SessionRevoked    = errors.New("session is revoked or expired")
+	WrongPassword     = errors.New("wrong login or password")
+
+	InvalidPasswordProof = errors.New("password proof is invalid or its handshake has expired")
+	TooManyHandshakes    = errors.New("too many password authentications in progress, try again later")
+
+	TwoFactorRequired           = errors.New("two-factor authentication code is required")
+	TwoFactorEnrollmentRequired = errors.New("two-factor authentication must be enabled to log in")
+	InvalidTwoFactorCode        = errors.New("invalid two-factor authentication code")
+	TwoFactorAlreadyEnabled     = errors.New("two-factor authentication is already enabled")
+	TwoFactorNotEnabled         = errors.New("two-factor authentication is not enabled")
+	TwoFactorEnforced           = errors.New("two-factor authentication is required by server")
+	TwoFactorLocked             = errors.New("too many wrong two-factor authentication codes, try again later")
 )
@@ -0,0 +1,262 @@
+// Package storage
+// in this file we have passwords of users, only SRP-6a verifiers of them are kept
+// users registered before verifiers have bcrypt hashes, they are replaced by verifiers at the next login with password
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/h2p2f/dedicated-vault/internal/server/models"
+	"github.com/h2p2f/dedicated-vault/internal/server/servererrors"
+	"github.com/h2p2f/dedicated-vault/internal/srp"
+)
+
+// handshakeExpires - lifetime of handshake, proof of password has to be sent before it expires
+const handshakeExpires = time.Minute
+
+// maxHandshakes - the most live handshakes started from one address, guessing passwords by many handshakes is slowed down;
+// they are counted by address, not by login, so nobody can lock out a user by starting handshakes of its login
+const maxHandshakes = 10
+
+// dummyHash - bcrypt hash compared when there is no hash of user, so failed login takes as long for any login
+var (
+	dummyHash     []byte
+	dummyHashOnce sync.Once
+)
+
+// compareDummy compares password with dummy hash, its result is not used
+func compareDummy(password string) {
+	dummyHashOnce.Do(func() {
+		dummyHash, _ = bcrypt.GenerateFromPassword([]byte("dummy password"), bcrypt.DefaultCost)
+	})
+	_ = bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+}
+
+// newVerifier makes verifier of password sent in clear by old clients
+func newVerifier(password string) (models.PasswordVerifier, error) {
+	salt, err := srp.NewSalt()
+	if err != nil {
+		return models.PasswordVerifier{}, err
+	}
+	return models.PasswordVerifier{
+		Salt:     salt,
+		Verifier: srp.Verifier(password, salt, srp.DefaultParams),
+		Params:   srp.DefaultParams,
+	}, nil
+}
+
+// setVerifier replaces password of user with verifier, bcrypt hash of old user is removed
+func (s *Storage) setVerifier(ctx context.Context, userUUID string, verifier models.PasswordVerifier) error {
+	_, err := s.users.UpdateOne(ctx,
+		bson.D{{"UUID", userUUID}},
+		bson.D{
+			{"$set", bson.D{
+				{"srpSalt", verifier.Salt},
+				{"srpVerifier", verifier.Verifier},
+				{"srpParams", verifier.Params}}},
+			{"$unset", bson.D{{"password", ""}}}})
+	if err != nil {
+		s.logger.Error("error while updating password verifier", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// checkPassword finds user and checks its password, proof of server is returned for proof of password
+// user is authenticated by proof of password, if it is given, otherwise by password sent in clear,
+// password in clear is accepted only from user without verifier, it is done once to replace bcrypt hash by verifier;
+// unknown login, user with verifier and wrong password get the same WrongPassword, so logins can't be found out
+func (s *Storage) checkPassword(ctx context.Context, user models.User, proof models.PasswordProof) (models.User, []byte, error) {
+	if proof.HandshakeID != "" {
+		return s.verifyProof(ctx, proof)
+	}
+	var checkUser models.User
+	err := s.users.FindOne(ctx, bson.D{{"login", user.Login}}).Decode(&checkUser)
+	switch {
+	case errors.Is(err, mongo.ErrNoDocuments):
+		compareDummy(user.Password)
+		return models.User{}, nil, servererrors.WrongPassword
+	case err != nil:
+		s.logger.Error("error while finding user", zap.Error(err))
+		return models.User{}, nil, err
+	case len(checkUser.Verifier) != 0:
+		s.logger.Error("password in clear of user with verifier", zap.String("user", checkUser.UUID))
+		compareDummy(user.Password)
+		return models.User{}, nil, servererrors.WrongPassword
+	}
+	err = bcrypt.CompareHashAndPassword([]byte(checkUser.Password), []byte(user.Password))
+	if err != nil {
+		s.logger.Error("error while comparing passwords", zap.Error(err))
+		return models.User{}, nil, servererrors.WrongPassword
+	}
+	// password is right, so bcrypt hash of old user is replaced by verifier
+	verifier, err := newVerifier(user.Password)
+	if err != nil {
+		s.logger.Error("error while making password verifier", zap.Error(err))
+		return models.User{}, nil, err
+	}
+	err = s.setVerifier(ctx, checkUser.UUID, verifier)
+	if err != nil {
+		return models.User{}, nil, err
+	}
+	checkUser.Password = ""
+	checkUser.PasswordVerifier = verifier
+	return checkUser, nil, nil
+}
+
+// RegisterVerifier registers a new user with verifier of password, password itself is never sent to server
+func (s *Storage) RegisterVerifier(ctx context.Context, user models.User, device models.Device) (models.Tokens, int64, error) {
+	return s.register(ctx, user.Login, user.PasswordVerifier, device)
+}
+
+// fakeVerifier - verifier of unknown login, it is the same for the same login, so it looks like verifier of real user
+func (s *Storage) fakeVerifier(login string) models.PasswordVerifier {
+	derive := func(purpose string) []byte {
+		mac := hmac.New(sha256.New, s.fakeKey)
+		mac.Write([]byte(purpose + ":" + login))
+		return mac.Sum(nil)
+	}
+	return models.PasswordVerifier{
+		Salt:     derive("salt")[:srp.SaltSize],
+		Verifier: srp.FakeVerifier(derive("verifier")),
+		Params:   srp.DefaultParams,
+	}
+}
+
+// StartAuth starts handshake of password authentication of user and returns what client needs for proof of password
+// unknown login and user without verifier get a challenge of fake verifier, so they are found out only by failed proof
+// like a wrong password; user without verifier has to log in with password once, its bcrypt hash is replaced then;
+// address is address of client, live handshakes are counted by it
+func (s *Storage) StartAuth(ctx context.Context, login, address string, clientPublic []byte) (models.Challenge, error) {
+	var checkUser models.User
+	err := s.users.FindOne(ctx, bson.D{{"login", login}}).Decode(&checkUser)
+	switch {
+	case errors.Is(err, mongo.ErrNoDocuments):
+		checkUser = models.User{Login: login, PasswordVerifier: s.fakeVerifier(login)}
+	case err != nil:
+		s.logger.Error("error while finding user", zap.Error(err))
+		return models.Challenge{}, err
+	case len(checkUser.Verifier) == 0:
+		checkUser = models.User{Login: login, PasswordVerifier: s.fakeVerifier(login)}
+	}
+	now := time.Now()
+	started, err := s.handshakes.CountDocuments(ctx, bson.D{{"address", address}, {"expireAt", bson.D{{"$gt", now}}}})
+	if err != nil {
+		s.logger.Error("error while counting handshakes", zap.Error(err))
+		return models.Challenge{}, err
+	}
+	if started >= maxHandshakes {
+		s.logger.Error("too many handshakes", zap.String("address", address))
+		return models.Challenge{}, servererrors.TooManyHandshakes
+	}
+	secret, public, err := srp.ServerChallenge(checkUser.Verifier)
+	if err != nil {
+		s.logger.Error("error while making server challenge", zap.Error(err))
+		return models.Challenge{}, err
+	}
+	handshake := models.Handshake{
+		HandshakeID:  uuid.New().String(),
+		Login:        login,
+		Address:      address,
+		UserUUID:     checkUser.UUID,
+		ClientPublic: clientPublic,
+		ServerSecret: secret,
+		ServerPublic: public,
+		ExpireAt:     now.Add(handshakeExpires),
+	}
+	_, err = s.handshakes.InsertOne(ctx, handshake)
+	if err != nil {
+		s.logger.Error("error while inserting handshake", zap.Error(err))
+		return models.Challenge{}, err
+	}
+	return models.Challenge{
+		HandshakeID:  handshake.HandshakeID,
+		Salt:         checkUser.Salt,
+		Params:       checkUser.Params,
+		ServerPublic: public,
+	}, nil
+}
+
+// verifyProof checks proof of password and returns user of handshake and proof of server
+// handshake is removed on the way, so the same proof can't be used twice
+func (s *Storage) verifyProof(ctx context.Context, proof models.PasswordProof) (models.User, []byte, error) {
+	var handshake models.Handshake
+	err := s.handshakes.FindOneAndDelete(ctx,
+		bson.D{{"handshakeID", proof.HandshakeID}, {"expireAt", bson.D{{"$gt", time.Now()}}}}).Decode(&handshake)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return models.User{}, nil, servererrors.InvalidPasswordProof
+		}
+		s.logger.Error("error while finding handshake", zap.Error(err))
+		return models.User{}, nil, err
+	}
+	// handshake of unknown login or of user without verifier
+	if handshake.UserUUID == "" {
+		return models.User{}, nil, servererrors.InvalidPasswordProof
+	}
+	checkUser, err := s.GetUser(ctx, handshake.UserUUID)
+	if err != nil {
+		return models.User{}, nil, err
+	}
+	serverProof, err := srp.VerifyClient(checkUser.Login, checkUser.Salt, checkUser.Verifier,
+		handshake.ClientPublic, handshake.ServerSecret, handshake.ServerPublic, proof.Proof)
+	if err != nil {
+		s.logger.Error("error while verifying password proof", zap.Error(err))
+		return models.User{}, nil, servererrors.InvalidPasswordProof
+	}
+	return checkUser, serverProof, nil
+}
+
+// FinishLogin logs in user by proof of password and returns proof of server with tokens of the new session
+// code is TOTP code or recovery code, it is checked only for users with two-factor authentication,
+// user who has to enroll it gets tokens of a session accepted only to enroll together with TwoFactorEnrollmentRequired
+func (s *Storage) FinishLogin(ctx context.Context, proof models.PasswordProof, code string, device models.Device) (models.Tokens, int64, []byte, error) {
+	checkUser, serverProof, err := s.verifyProof(ctx, proof)
+	if err != nil {
+		return models.Tokens{}, 0, nil, err
+	}
+	token, err := s.loginSession(ctx, checkUser, code, device)
+	if token.Access == "" {
+		return models.Tokens{}, checkUser.LastServerUpdated, nil, err
+	}
+	return token, checkUser.LastServerUpdated, serverProof, err
+}
+
+// ChangeVerifier replaces verifier of user, proof of the current password is required for it
+// all sessions of user are revoked, so stolen tokens stop working, and a new session is started;
+// proof of server is returned with its tokens
+func (s *Storage) ChangeVerifier(ctx context.Context, user models.User, proof models.PasswordProof, device models.Device) (models.Tokens, []byte, error) {
+	checkUser, serverProof, err := s.verifyProof(ctx, proof)
+	if err != nil {
+		return models.Tokens{}, nil, err
+	}
+	// proof of password of another user doesn't change password of user of token
+	if checkUser.UUID != user.UUID {
+		s.logger.Error("password proof is of another user", zap.String("user", user.UUID))
+		return models.Tokens{}, nil, servererrors.InvalidPasswordProof
+	}
+	err = s.setVerifier(ctx, checkUser.UUID, user.PasswordVerifier)
+	if err != nil {
+		return models.Tokens{}, nil, err
+	}
+	err = s.revokeSessions(ctx, checkUser.UUID)
+	if err != nil {
+		return models.Tokens{}, nil, err
+	}
+	token, err := s.newSession(ctx, checkUser.UUID, device, false)
+	if err != nil {
+		return models.Tokens{}, nil, err
+	}
+	return token, serverProof, nil
+}
@@ -0,0 +1,199 @@
+// Package storage
+// in this file we have sessions of users, they keep hashes of refresh tokens
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+
+	"github.com/h2p2f/dedicated-vault/internal/server/jwtprocessing"
+	"github.com/h2p2f/dedicated-vault/internal/server/models"
+	"github.com/h2p2f/dedicated-vault/internal/server/servererrors"
+)
+
+// enrollmentSessionExpires - lifetime of session accepted only to enroll two-factor authentication
+const enrollmentSessionExpires = 10 * time.Minute
+
+// newSession creates a new session of user on device and returns its tokens
+// expired sessions of user are removed on the way
+func (s *Storage) newSession(ctx context.Context, userUUID string, device models.Device, enrollmentOnly bool) (models.Tokens, error) {
+	now := time.Now()
+	_, err := s.sessions.DeleteMany(ctx, bson.D{{"userUUID", userUUID}, {"expires", bson.D{{"$lte", now.Unix()}}}})
+	if err != nil {
+		s.logger.Error("error while deleting expired sessions", zap.Error(err))
+		return models.Tokens{}, err
+	}
+	refreshToken, err := jwtprocessing.GenerateRefreshToken()
+	if err != nil {
+		s.logger.Error("error while generating refresh token", zap.Error(err))
+		return models.Tokens{}, err
+	}
+	session := models.Session{
+		SessionID:   uuid.New().String(),
+		UserUUID:    userUUID,
+		Device:      device,
+		RefreshHash: jwtprocessing.HashRefreshToken(refreshToken),
+		Created:     now.Unix(),
+		LastSeen:    now.Unix(),
+		Expires:     now.Add(jwtprocessing.REFRESHTOKENEXPIRES).Unix(),
+	}
+	if enrollmentOnly {
+		session.EnrollmentOnly = true
+		session.Expires = now.Add(enrollmentSessionExpires).Unix()
+	}
+	_, err = s.sessions.InsertOne(ctx, session)
+	if err != nil {
+		s.logger.Error("error while inserting session", zap.Error(err))
+		return models.Tokens{}, err
+	}
+	return s.tokens(session, refreshToken)
+}
+
+// tokens generates access token of session and returns it with refresh token
+func (s *Storage) tokens(session models.Session, refreshToken string) (models.Tokens, error) {
+	token, err := s.keys.GenerateToken(session.UserUUID, session.SessionID)
+	if err != nil {
+		s.logger.Error("error while generating token", zap.Error(err))
+		return models.Tokens{}, err
+	}
+	return models.Tokens{
+		Access:    token,
+		Refresh:   refreshToken,
+		ExpiresIn: int64(jwtprocessing.ACCESSTOKENEXPIRES.Seconds()),
+	}, nil
+}
+
+// RefreshToken rotates refresh token of session and returns new tokens
+// rotated token can't be used again: if it is presented, it was stolen or copied, so the whole session is revoked
+// session accepted only to enroll two-factor authentication is not refreshed
+func (s *Storage) RefreshToken(ctx context.Context, refreshToken string) (models.Tokens, error) {
+	now := time.Now()
+	hash := jwtprocessing.HashRefreshToken(refreshToken)
+	newToken, err := jwtprocessing.GenerateRefreshToken()
+	if err != nil {
+		s.logger.Error("error while generating refresh token", zap.Error(err))
+		return models.Tokens{}, err
+	}
+	var session models.Session
+	err = s.sessions.FindOneAndUpdate(ctx,
+		bson.D{{"refreshHash", hash}, {"expires", bson.D{{"$gt", now.Unix()}}}, {"enrollmentOnly", bson.D{{"$ne", true}}}},
+		bson.D{{"$set", bson.D{
+			{"previousHash", hash},
+			{"refreshHash", jwtprocessing.HashRefreshToken(newToken)},
+			{"lastSeen", now.Unix()},
+			{"expires", now.Add(jwtprocessing.REFRESHTOKENEXPIRES).Unix()}}}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&session)
+	if err == nil {
+		return s.tokens(session, newToken)
+	}
+	if !errors.Is(err, mongo.ErrNoDocuments) {
+		s.logger.Error("error while rotating refresh token", zap.Error(err))
+		return models.Tokens{}, err
+	}
+	result, err := s.sessions.DeleteOne(ctx, bson.D{{"previousHash", hash}})
+	if err != nil {
+		s.logger.Error("error while revoking session", zap.Error(err))
+		return models.Tokens{}, err
+	}
+	if result.DeletedCount > 0 {
+		s.logger.Warn("rotated refresh token is reused, session is revoked")
+	}
+	return models.Tokens{}, servererrors.SessionRevoked
+}
+
+// CheckSession checks that session of access token is not revoked or expired, last seen time of session is updated
+// session accepted only to enroll two-factor authentication is reported by TwoFactorEnrollmentRequired
+func (s *Storage) CheckSession(ctx context.Context, userUUID, sessionID string) error {
+	now := time.Now().Unix()
+	var session models.Session
+	err := s.sessions.FindOneAndUpdate(ctx, bson.D{
+		{"sessionID", sessionID},
+		{"userUUID", userUUID},
+		{"expires", bson.D{{"$gt", now}}}},
+		bson.D{{"$max", bson.D{{"lastSeen", now}}}}).Decode(&session)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return servererrors.SessionRevoked
+	}
+	if err != nil {
+		s.logger.Error("error while updating session", zap.Error(err))
+		return err
+	}
+	if session.EnrollmentOnly {
+		return servererrors.TwoFactorEnrollmentRequired
+	}
+	return nil
+}
+
+// completeEnrollment makes session accepted only to enroll two-factor authentication a usual one,
+// user has proved password and code of authenticator app in it
+func (s *Storage) completeEnrollment(ctx context.Context, userUUID, sessionID string) error {
+	_, err := s.sessions.UpdateOne(ctx,
+		bson.D{{"sessionID", sessionID}, {"userUUID", userUUID}, {"enrollmentOnly", true}},
+		bson.D{
+			{"$set", bson.D{{"expires", time.Now().Add(jwtprocessing.REFRESHTOKENEXPIRES).Unix()}}},
+			{"$unset", bson.D{{"enrollmentOnly", ""}}}})
+	if err != nil {
+		s.logger.Error("error while completing enrollment of session", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// ListSessions returns sessions of user which are not expired, recently used sessions go first
+func (s *Storage) ListSessions(ctx context.Context, user models.User) ([]models.Session, error) {
+	cursor, err := s.sessions.Find(ctx, bson.D{
+		{"userUUID", user.UUID},
+		{"expires", bson.D{{"$gt", time.Now().Unix()}}}},
+		options.Find().SetSort(bson.D{{"lastSeen", -1}}))
+	if err != nil {
+		s.logger.Error("error while finding sessions", zap.Error(err))
+		return nil, err
+	}
+	var sessions []models.Session
+	err = cursor.All(ctx, &sessions)
+	if err != nil {
+		s.logger.Error("error while decoding sessions", zap.Error(err))
+		return nil, err
+	}
+	return sessions, nil
+}
+
+// RevokeSession revokes session of user by its id, device of session has to log in again
+func (s *Storage) RevokeSession(ctx context.Context, user models.User, sessionID string) error {
+	result, err := s.sessions.DeleteOne(ctx, bson.D{{"userUUID", user.UUID}, {"sessionID", sessionID}})
+	if err != nil {
+		s.logger.Error("error while deleting session", zap.Error(err))
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return servererrors.RecordNotFound
+	}
+	return nil
+}
+
+// Logout revokes session of user, its access and refresh tokens are not accepted any more
+func (s *Storage) Logout(ctx context.Context, user models.User, sessionID string) error {
+	_, err := s.sessions.DeleteOne(ctx, bson.D{{"userUUID", user.UUID}, {"sessionID", sessionID}})
+	if err != nil {
+		s.logger.Error("error while deleting session", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// revokeSessions revokes all sessions of user, it is done when password is changed
+func (s *Storage) revokeSessions(ctx context.Context, userUUID string) error {
+	_, err := s.sessions.DeleteMany(ctx, bson.D{{"userUUID", userUUID}})
+	if err != nil {
+		s.logger.Error("error while deleting sessions", zap.Error(err))
+		return err
+	}
+	return nil
+}
@@ -5,8 +5,8 @@ package storage
 
 import (
 	"context"
+	"crypto/rand"
 	"errors"
-	"fmt"
 	"time"
 
 	"github.com/google/uuid"
@@ -14,7 +14,6 @@ import (
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"go.uber.org/zap"
-	"golang.org/x/crypto/bcrypt"
 
 	"github.com/h2p2f/dedicated-vault/internal/server/config"
 	"github.com/h2p2f/dedicated-vault/internal/server/jwtprocessing"
@@ -27,12 +26,21 @@ import (
 type Storage struct {
 	users  *mongo.Collection
 	data   *mongo.Collection
+	chunks *mongo.Collection
+	// sessions keep hashes of refresh tokens, access tokens are valid only while their session exists
+	sessions *mongo.Collection
+	// handshakes keep started password authentications until proof of password is checked
+	handshakes *mongo.Collection
+	// fakeKey derives fake verifiers of unknown logins, it is kept in database, so they don't change on restart
+	fakeKey []byte
+	// keys sign access tokens of sessions
+	keys   *jwtprocessing.Keys
 	config *config.ServerConfig
 	logger *zap.Logger
 }
 
 // NewStorage creates a new Storage
-func NewStorage(ctx context.Context, config *config.ServerConfig, logger *zap.Logger) *Storage {
+func NewStorage(ctx context.Context, config *config.ServerConfig, keys *jwtprocessing.Keys, logger *zap.Logger) *Storage {
 	var storage Storage
 
 	credential := options.Credential{
@@ -48,12 +56,75 @@ func NewStorage(ctx context.Context, config *config.ServerConfig, logger *zap.Lo
 	db := client.Database("vault")
 	storage.users = db.Collection("users")
 	storage.data = db.Collection("data")
+	storage.chunks = db.Collection("chunks")
+	storage.sessions = db.Collection("sessions")
+	storage.handshakes = db.Collection("handshakes")
 	storage.logger = logger
 	storage.config = config
+	storage.keys = keys
+
+	// changes are requested by user and revision, so index it;
+	// uuid of data is unique for user, so data created twice at once is inserted only once
+	_, err = storage.data.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{"userUUID", 1}, {"revision", 1}}},
+		{Keys: bson.D{{"userUUID", 1}, {"dataUUID", 1}}, Options: options.Index().SetUnique(true)},
+	})
+	if err != nil {
+		logger.Error("error while creating data index", zap.Error(err))
+	}
+	// chunks are read in order of upload
+	_, err = storage.chunks.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{"userUUID", 1}, {"dataUUID", 1}, {"chunksID", 1}, {"index", 1}},
+	})
+	if err != nil {
+		logger.Error("error while creating chunks index", zap.Error(err))
+	}
+	// sessions are found by refresh token on refresh and by user and id on every request
+	_, err = storage.sessions.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{"refreshHash", 1}}},
+		{Keys: bson.D{{"previousHash", 1}}},
+		{Keys: bson.D{{"userUUID", 1}, {"sessionID", 1}}},
+	})
+	if err != nil {
+		logger.Error("error while creating sessions index", zap.Error(err))
+	}
+	// handshakes are found by id on proof of password and counted by address of client on start,
+	// expired ones are removed by mongoDB
+	_, err = storage.handshakes.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{"handshakeID", 1}}},
+		{Keys: bson.D{{"address", 1}, {"expireAt", 1}}},
+		{Keys: bson.D{{"expireAt", 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	})
+	if err != nil {
+		logger.Error("error while creating handshakes index", zap.Error(err))
+	}
+	storage.fakeKey, err = storage.loadFakeKey(ctx, db.Collection("settings"))
+	if err != nil {
+		logger.Fatal("error while loading key of fake verifiers", zap.Error(err))
+	}
 
 	return &storage
 }
 
+// loadFakeKey loads key of fake verifiers, the first server makes it
+func (s *Storage) loadFakeKey(ctx context.Context, settings *mongo.Collection) ([]byte, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	var setting struct {
+		Key []byte `bson:"key"`
+	}
+	err := settings.FindOneAndUpdate(ctx,
+		bson.D{{"_id", "fakeVerifierKey"}},
+		bson.D{{"$setOnInsert", bson.D{{"key", key}}}},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)).Decode(&setting)
+	if err != nil {
+		return nil, err
+	}
+	return setting.Key, nil
+}
+
 // Close closes the connection to the database
 func (s *Storage) Close(ctx context.Context) error {
 	if err := s.users.Database().Client().Disconnect(ctx); err != nil {
@@ -75,12 +146,118 @@ func (s *Storage) UpdateLastServerUpdated(ctx context.Context, user models.User)
 	return nil
 }
 
-// Register registers a new user
-func (s *Storage) Register(ctx context.Context, user models.User) (string, int64, error) {
+// writeTimeout - deadline of change of data whose request has no deadline of its own
+const writeTimeout = time.Minute
+
+// releaseTimeout - the longest wait for release of revision, it is done even if request is canceled
+const releaseTimeout = 5 * time.Second
+
+// withWriteDeadline gives change of data a deadline, claim of its revision is stale only after it,
+// so a change which is still written is never taken for a failed one
+func withWriteDeadline(ctx context.Context) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, writeTimeout)
+}
+
+// detachedContext keeps values of its parent, but not its deadline and cancellation
+type detachedContext struct {
+	parent context.Context
+}
+
+// Deadline - detached context has no deadline
+func (c detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+
+// Done - detached context is never canceled
+func (c detachedContext) Done() <-chan struct{} { return nil }
+
+// Err - detached context is never canceled
+func (c detachedContext) Err() error { return nil }
+
+// Value returns value of parent context
+func (c detachedContext) Value(key any) any { return c.parent.Value(key) }
+
+// NextRevision increments the user's revision counter and returns the new value
+// every change of the user's data is stamped with its own revision,
+// so clients can request only the changes made after the revision they already have
+// the revision is claimed as pending in the same update, changes are synced only up to the lowest pending revision,
+// so a change written later with a lower revision is not skipped; caller releases it by releaseRevision
+// the claim expires with deadline of ctx, change can't be written after it
+func (s *Storage) NextRevision(ctx context.Context, user models.User) (int64, error) {
+	var updatedUser models.User
+	now := time.Now()
+	expires := now.Add(writeTimeout)
+	if deadline, ok := ctx.Deadline(); ok {
+		expires = deadline
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err := s.users.FindOneAndUpdate(ctx,
+		bson.D{{"UUID", user.UUID}},
+		mongo.Pipeline{
+			{{"$set", bson.D{{"revision", bson.D{{"$add", bson.A{
+				bson.D{{"$ifNull", bson.A{"$revision", int64(0)}}}, int64(1)}}}}}}},
+			{{"$set", bson.D{{"pendingRevisions", bson.D{{"$concatArrays", bson.A{
+				bson.D{{"$filter", bson.D{
+					{"input", bson.D{{"$ifNull", bson.A{"$pendingRevisions", bson.A{}}}}},
+					{"cond", bson.D{{"$gte", bson.A{"$$this.expires", now.Unix()}}}}}}},
+				bson.A{bson.D{{"revision", "$revision"}, {"expires", expires.Unix() + 1}}},
+			}}}}}}},
+		},
+		opts).Decode(&updatedUser)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			s.logger.Error("error while finding user", zap.Error(err))
+			return 0, servererrors.RecordNotFound
+		}
+		s.logger.Error("error while incrementing revision", zap.Error(err))
+		return 0, err
+	}
+	return updatedUser.Revision, nil
+}
+
+// releaseRevision removes pending claim of revision, change stamped with it is written or failed
+// it is done with a context detached from the request, so a canceled request doesn't leave its claim
+func (s *Storage) releaseRevision(ctx context.Context, user models.User, revision int64) {
+	ctx, cancel := context.WithTimeout(detachedContext{parent: ctx}, releaseTimeout)
+	defer cancel()
+	_, err := s.users.UpdateOne(ctx,
+		bson.D{{"UUID", user.UUID}},
+		bson.D{{"$pull", bson.D{{"pendingRevisions", bson.D{{"revision", revision}}}}}})
+	if err != nil {
+		s.logger.Error("error while releasing revision", zap.Int64("revision", revision), zap.Error(err))
+	}
+}
+
+// syncedRevision - the newest revision all changes up to which are written
+func syncedRevision(user models.User, now time.Time) int64 {
+	synced := user.Revision
+	for _, pending := range user.PendingRevisions {
+		if pending.Expires >= now.Unix() && pending.Revision <= synced {
+			synced = pending.Revision - 1
+		}
+	}
+	return synced
+}
+
+// Register registers a new user with password sent in clear and starts its session
+// only verifier of password is kept, as for users registered by RegisterVerifier
+func (s *Storage) Register(ctx context.Context, user models.User, device models.Device) (models.Tokens, int64, error) {
+	verifier, err := newVerifier(user.Password)
+	if err != nil {
+		s.logger.Error("error while making password verifier", zap.Error(err))
+		return models.Tokens{}, 0, err
+	}
+	return s.register(ctx, user.Login, verifier, device)
+}
+
+// register registers a new user with verifier of password and starts its session
+// if server requires two-factor authentication, user is registered without session, it has to enroll before login
+func (s *Storage) register(ctx context.Context, login string, verifier models.PasswordVerifier, device models.Device) (models.Tokens, int64, error) {
 	var checkUser models.User
-	var token string
+	var token models.Tokens
 	var lastServerUpdated int64
-	err := s.users.FindOne(ctx, bson.D{{"login", user.Login}}).Decode(&checkUser)
+	err := s.users.FindOne(ctx, bson.D{{"login", login}}).Decode(&checkUser)
 	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
 		s.logger.Error("error while finding user", zap.Error(err))
 		return token, lastServerUpdated, err
@@ -92,52 +269,40 @@ func (s *Storage) Register(ctx context.Context, user models.User) (string, int64
 
 	uuidUser := uuid.New()
 	lastServerUpdated = time.Now().Unix()
-	encryptedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
-	if err != nil {
-		s.logger.Error("error while encrypting password", zap.Error(err))
-		return token, lastServerUpdated, err
-	}
 	docUser := bson.D{
 		{"UUID", uuidUser.String()},
-		{"login", user.Login},
-		{"password", string(encryptedPassword)},
-		{"lastServerUpdated", lastServerUpdated}}
+		{"login", login},
+		{"srpSalt", verifier.Salt},
+		{"srpVerifier", verifier.Verifier},
+		{"srpParams", verifier.Params},
+		{"lastServerUpdated", lastServerUpdated},
+		{"revision", int64(0)}}
 	_, err = s.users.InsertOne(ctx, docUser)
 	if err != nil {
 		s.logger.Error("error while inserting user", zap.Error(err))
 		return token, lastServerUpdated, err
 	}
-	token, err = jwtprocessing.GenerateToken(uuidUser.String(), s.config.JWTKey)
-	fmt.Println(token)
+	if s.config.RequiresTwoFactor() {
+		return token, lastServerUpdated, servererrors.TwoFactorEnrollmentRequired
+	}
+	token, err = s.newSession(ctx, uuidUser.String(), device, false)
 	if err != nil {
-		s.logger.Error("error while generating token", zap.Error(err))
 		return token, lastServerUpdated, err
 	}
 	return token, lastServerUpdated, nil
 }
 
-// Login logs in a user
-func (s *Storage) Login(ctx context.Context, user models.User) (string, int64, error) {
-	var checkUser models.User
-	var token string
-	var lastServerUpdated int64
-	err := s.users.FindOne(ctx, bson.D{{"login", user.Login}}).Decode(&checkUser)
-	if errors.Is(err, mongo.ErrNoDocuments) {
-		s.logger.Error("error while finding user", zap.Error(err))
-		return token, lastServerUpdated, servererrors.RecordNotFound
-	}
-	lastServerUpdated = checkUser.LastServerUpdated
-	err = bcrypt.CompareHashAndPassword([]byte(checkUser.Password), []byte(user.Password))
+// Login logs in a user, every login starts a new session
+// code is TOTP code or recovery code, it is checked only for users with two-factor authentication,
+// user who has to enroll it gets tokens of a session accepted only to enroll together with TwoFactorEnrollmentRequired
+func (s *Storage) Login(ctx context.Context, user models.User, code string, device models.Device) (models.Tokens, int64, error) {
+	var token models.Tokens
+	checkUser, _, err := s.checkPassword(ctx, user, models.PasswordProof{})
 	if err != nil {
-		s.logger.Error("error while comparing passwords", zap.Error(err))
-		return token, lastServerUpdated, err
+		return token, 0, err
 	}
-	token, err = jwtprocessing.GenerateToken(checkUser.UUID, s.config.JWTKey)
-	if err != nil {
-		s.logger.Error("error while generating token", zap.Error(err))
-		return token, lastServerUpdated, err
-	}
-	return token, lastServerUpdated, nil
+	token, err = s.loginSession(ctx, checkUser, code, device)
+	return token, checkUser.LastServerUpdated, err
 }
 
 // GetUser gets a user
@@ -157,41 +322,55 @@ func (s *Storage) GetUser(ctx context.Context, user string) (models.User, error)
 }
 
 // ChangePassword changes a user's password
-func (s *Storage) ChangePassword(ctx context.Context, user models.User, newPassword string) (string, error) {
-	var checkUser models.User
-	err := s.users.FindOne(ctx, bson.D{{"login", user.Login}}).Decode(&checkUser)
+// all sessions of user are revoked, so stolen tokens stop working, and a new session is started
+func (s *Storage) ChangePassword(ctx context.Context, user models.User, newPassword string, device models.Device) (models.Tokens, error) {
+	checkUser, _, err := s.checkPassword(ctx, user, models.PasswordProof{})
 	if err != nil {
-		if errors.Is(err, mongo.ErrNoDocuments) {
-			s.logger.Error("error while finding user", zap.Error(err))
-			return "", servererrors.RecordNotFound
-		} else {
-			s.logger.Error("error while finding user", zap.Error(err))
-			return "", err
-		}
+		return models.Tokens{}, err
 	}
-	err = bcrypt.CompareHashAndPassword([]byte(checkUser.Password), []byte(user.Password))
+	verifier, err := newVerifier(newPassword)
 	if err != nil {
-		s.logger.Error("error while comparing passwords", zap.Error(err))
-		return "", err
+		s.logger.Error("error while making password verifier", zap.Error(err))
+		return models.Tokens{}, err
 	}
-	encryptedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	err = s.setVerifier(ctx, checkUser.UUID, verifier)
 	if err != nil {
-		s.logger.Error("error while encrypting password", zap.Error(err))
-		return "", err
+		return models.Tokens{}, err
 	}
-	_, err = s.users.UpdateOne(ctx,
-		bson.D{{"login", user.Login}},
-		bson.D{{"$set", bson.D{{"password", string(encryptedPassword)}}}})
+	err = s.revokeSessions(ctx, checkUser.UUID)
 	if err != nil {
-		s.logger.Error("error while updating password", zap.Error(err))
-		return "", err
+		return models.Tokens{}, err
 	}
-	token, err := jwtprocessing.GenerateToken(checkUser.UUID, s.config.JWTKey)
+	return s.newSession(ctx, checkUser.UUID, device, false)
+}
+
+// SetWrappedKey replaces the user's vault master key wrapped by passphrase derived key and its key check value
+// the key is replaced only if it still has the expected version, version 0 means the key is not set yet
+// the server never sees the master key itself
+func (s *Storage) SetWrappedKey(ctx context.Context, user models.User, wrappedKey, keyCheck []byte, expectedVersion int64) (int64, error) {
+	filter := bson.D{{"UUID", user.UUID}}
+	if expectedVersion == 0 {
+		filter = append(filter, bson.E{"wrappedKeyVersion", bson.D{{"$in", bson.A{int64(0), nil}}}})
+	} else {
+		filter = append(filter, bson.E{"wrappedKeyVersion", expectedVersion})
+	}
+	result, err := s.users.UpdateOne(ctx, filter,
+		bson.D{{"$set", bson.D{
+			{"wrappedKey", wrappedKey},
+			{"keyCheck", keyCheck},
+			{"wrappedKeyVersion", expectedVersion + 1}}}})
 	if err != nil {
-		s.logger.Error("error while generating token", zap.Error(err))
-		return "", err
+		s.logger.Error("error while updating wrapped key", zap.Error(err))
+		return 0, err
+	}
+	if result.MatchedCount == 0 {
+		if _, err := s.GetUser(ctx, user.UUID); err != nil {
+			return 0, err
+		}
+		s.logger.Error("wrapped key version conflict", zap.String("user", user.UUID), zap.Int64("expected", expectedVersion))
+		return 0, servererrors.VersionConflict
 	}
-	return token, nil
+	return expectedVersion + 1, nil
 }
 
 // DeleteUser deletes a user
@@ -206,16 +385,39 @@ func (s *Storage) DeleteUser(ctx context.Context, user models.User) error {
 }
 
 // CreateData creates secrets data
+// client may generate data uuid by itself, otherwise it is generated here; uuid already used by user gives DataAlreadyExists
 func (s *Storage) CreateData(ctx context.Context, user models.User, data models.VaultData) (string, int64, error) {
-	uuidData := uuid.New()
-	data.DataUUID = uuidData.String()
+	ctx, cancel := withWriteDeadline(ctx)
+	defer cancel()
+	if data.DataUUID == "" {
+		data.DataUUID = uuid.New().String()
+	} else {
+		if _, err := uuid.Parse(data.DataUUID); err != nil {
+			s.logger.Error("error while parsing data uuid", zap.Error(err))
+			return "", 0, err
+		}
+	}
+	revision, err := s.NextRevision(ctx, user)
+	if err != nil {
+		s.logger.Error("error while getting next revision", zap.Error(err))
+		return "", 0, err
+	}
+	defer s.releaseRevision(ctx, user, revision)
 	data.UserUUID = user.UUID
 	data.Created = time.Now().Unix()
+	data.Revision = revision
+	data.Deleted = false
+	data.Version = 1
 	doc, err := bson.Marshal(data)
 	if err != nil {
 		s.logger.Error("error while marshaling data", zap.Error(err))
+		return "", 0, err
 	}
 	_, err = s.data.InsertOne(ctx, doc)
+	if mongo.IsDuplicateKeyError(err) {
+		s.logger.Error("data already exists", zap.String("uuid", data.DataUUID))
+		return "", 0, servererrors.DataAlreadyExists
+	}
 	if err != nil {
 		s.logger.Error("error while inserting data", zap.Error(err))
 		return "", 0, err
@@ -230,15 +432,39 @@ func (s *Storage) CreateData(ctx context.Context, user models.User, data models.
 }
 
 // ChangeData changes secrets data
+// data.Version is the version the client expects on server,
+// if data was changed by someone else since then, servererrors.VersionConflict is returned
 func (s *Storage) ChangeData(ctx context.Context, user models.User, data models.VaultData) (int64, error) {
+	ctx, cancel := withWriteDeadline(ctx)
+	defer cancel()
+	revision, err := s.NextRevision(ctx, user)
+	if err != nil {
+		s.logger.Error("error while getting next revision", zap.Error(err))
+		return 0, err
+	}
+	defer s.releaseRevision(ctx, user, revision)
 	data.Updated = time.Now().Unix()
-	_, err := s.data.ReplaceOne(ctx,
-		bson.D{{"userUUID", user.UUID}, {"dataUUID", data.DataUUID}},
-		data)
+	update := bson.D{
+		{"meta", data.Meta},
+		{"dataType", data.DataType},
+		{"data", data.Data},
+		{"updated", data.Updated},
+		{"revision", revision},
+		{"version", data.Version + 1}}
+	// chunks of binary content are replaced only by upload, other changes keep them
+	if data.ChunksID != "" {
+		update = append(update, bson.E{"chunksID", data.ChunksID})
+	}
+	result, err := s.data.UpdateOne(ctx,
+		versionFilter(user, data),
+		bson.D{{"$set", update}})
 	if err != nil {
-		s.logger.Error("error while replacing data", zap.Error(err))
+		s.logger.Error("error while updating data", zap.Error(err))
 		return 0, err
 	}
+	if result.MatchedCount == 0 {
+		return 0, s.mismatchReason(ctx, user, data)
+	}
 	user.LastServerUpdated = data.Updated
 	err = s.UpdateLastServerUpdated(ctx, user)
 	if err != nil {
@@ -248,15 +474,88 @@ func (s *Storage) ChangeData(ctx context.Context, user models.User, data models.
 	return data.Updated, nil
 }
 
+// GetData gets secrets data by uuid
+func (s *Storage) GetData(ctx context.Context, user models.User, dataUUID string) (models.VaultData, error) {
+	var data models.VaultData
+	err := s.data.FindOne(ctx,
+		bson.D{{"userUUID", user.UUID}, {"dataUUID", dataUUID}, {"deleted", bson.D{{"$ne", true}}}}).Decode(&data)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			s.logger.Error("error while finding data", zap.Error(err))
+			return models.VaultData{}, servererrors.RecordNotFound
+		}
+		s.logger.Error("error while finding data", zap.Error(err))
+		return models.VaultData{}, err
+	}
+	return data, nil
+}
+
+// versionFilter is a filter for not deleted data with the expected version
+// data saved before versioning has no version field and is treated as version 0
+func versionFilter(user models.User, data models.VaultData) bson.D {
+	filter := bson.D{
+		{"userUUID", user.UUID},
+		{"dataUUID", data.DataUUID},
+		{"deleted", bson.D{{"$ne", true}}},
+	}
+	if data.Version == 0 {
+		return append(filter, bson.E{"version", bson.D{{"$in", bson.A{int64(0), nil}}}})
+	}
+	return append(filter, bson.E{"version", data.Version})
+}
+
+// mismatchReason explains why versionFilter matched nothing:
+// either data doesn't exist or it has another version
+func (s *Storage) mismatchReason(ctx context.Context, user models.User, data models.VaultData) error {
+	_, err := s.GetData(ctx, user, data.DataUUID)
+	if err != nil {
+		return err
+	}
+	s.logger.Error("data version conflict", zap.String("uuid", data.DataUUID), zap.Int64("expected", data.Version))
+	return servererrors.VersionConflict
+}
+
 // GetAllData gets all secrets data
 func (s *Storage) GetAllData(ctx context.Context, user models.User) ([]models.VaultData, error) {
-	var data []models.VaultData
+	filter := bson.D{
+		{"userUUID", user.UUID},
+		{"deleted", bson.D{{"$ne", true}}},
+	}
+	return s.findData(ctx, filter, options.Find())
+}
 
+// GetChangedData gets at most limit of secrets data changed after the given revision in order of revisions,
+// more tells that there are other changes after them
+// deleted data is returned as tombstones, so clients can remove it locally
+// changes are returned only up to the lowest pending revision, changes with lower revisions can be written yet
+func (s *Storage) GetChangedData(ctx context.Context, user models.User, sinceRevision, limit int64) ([]models.VaultData, bool, error) {
+	current, err := s.GetUser(ctx, user.UUID)
+	if err != nil {
+		return nil, false, err
+	}
 	filter := bson.D{
 		{"userUUID", user.UUID},
+		{"revision", bson.D{{"$gt", sinceRevision}, {"$lte", syncedRevision(current, time.Now())}}},
 	}
+	data, err := s.findData(ctx, filter, options.Find().SetSort(bson.D{{"revision", 1}}).SetLimit(limit+1))
+	if err != nil {
+		return nil, false, err
+	}
+	if int64(len(data)) > limit {
+		return data[:limit], true, nil
+	}
+	return data, false, nil
+}
+
+// findData finds secrets data by filter
+func (s *Storage) findData(ctx context.Context, filter bson.D, opts *options.FindOptions) ([]models.VaultData, error) {
+	var data []models.VaultData
 
-	cur, err := s.data.Find(ctx, filter)
+	cur, err := s.data.Find(ctx, filter, opts)
+	if err != nil {
+		s.logger.Error("error while finding data", zap.Error(err))
+		return nil, err
+	}
 	defer func(cur *mongo.Cursor, ctx context.Context) {
 		err := cur.Close(ctx)
 		if err != nil {
@@ -264,11 +563,6 @@ func (s *Storage) GetAllData(ctx context.Context, user models.User) ([]models.Va
 		}
 	}(cur, ctx)
 
-	if err != nil {
-		s.logger.Error("error while finding data", zap.Error(err))
-		return nil, err
-	}
-
 	for cur.Next(ctx) {
 		var elem models.VaultData
 		err := cur.Decode(&elem)
@@ -283,14 +577,101 @@ func (s *Storage) GetAllData(ctx context.Context, user models.User) ([]models.Va
 }
 
 // DeleteData deletes secrets data
+// the document is kept as a tombstone without payload, so deletion reaches other clients on sync
 func (s *Storage) DeleteData(ctx context.Context, user models.User, data models.VaultData) (int64, error) {
-
-	_, err := s.data.DeleteOne(ctx,
-		bson.D{{"user", user.UUID}, {"data.dataUUID", data.DataUUID}})
+	ctx, cancel := withWriteDeadline(ctx)
+	defer cancel()
+	revision, err := s.NextRevision(ctx, user)
+	if err != nil {
+		s.logger.Error("error while getting next revision", zap.Error(err))
+		return 0, err
+	}
+	defer s.releaseRevision(ctx, user, revision)
+	updated := time.Now().Unix()
+	result, err := s.data.UpdateOne(ctx,
+		versionFilter(user, data),
+		bson.D{
+			{"$set", bson.D{
+				{"meta", ""},
+				{"data", nil},
+				{"deleted", true},
+				{"updated", updated},
+				{"revision", revision},
+				{"version", data.Version + 1}}},
+			{"$unset", bson.D{{"chunksID", ""}}}})
 	if err != nil {
 		s.logger.Error("error while deleting data", zap.Error(err))
 		return 0, err
 	}
-	user.LastServerUpdated = time.Now().Unix()
+	if result.MatchedCount == 0 {
+		return 0, s.mismatchReason(ctx, user, data)
+	}
+	err = s.DeleteChunks(ctx, user, data.DataUUID, "")
+	if err != nil {
+		return 0, err
+	}
+	user.LastServerUpdated = updated
+	err = s.UpdateLastServerUpdated(ctx, user)
+	if err != nil {
+		s.logger.Error("error while updating lastServerUpdated", zap.Error(err))
+		return 0, err
+	}
 	return user.LastServerUpdated, nil
 }
+
+// SaveChunk saves a chunk of streamed binary content of data
+func (s *Storage) SaveChunk(ctx context.Context, user models.User, chunk models.VaultChunk) error {
+	chunk.UserUUID = user.UUID
+	_, err := s.chunks.InsertOne(ctx, chunk)
+	if err != nil {
+		s.logger.Error("error while inserting chunk", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// GetChunks reads chunks of one upload of data in order and passes them to send one by one,
+// so the whole content is never held in memory
+func (s *Storage) GetChunks(ctx context.Context, user models.User, dataUUID, chunksID string, send func(chunk []byte) error) error {
+	cur, err := s.chunks.Find(ctx,
+		bson.D{{"userUUID", user.UUID}, {"dataUUID", dataUUID}, {"chunksID", chunksID}},
+		options.Find().SetSort(bson.D{{"index", 1}}))
+	if err != nil {
+		s.logger.Error("error while finding chunks", zap.Error(err))
+		return err
+	}
+	defer func(cur *mongo.Cursor, ctx context.Context) {
+		err := cur.Close(ctx)
+		if err != nil {
+			s.logger.Error("error while closing cursor", zap.Error(err))
+		}
+	}(cur, ctx)
+
+	for cur.Next(ctx) {
+		var chunk models.VaultChunk
+		err := cur.Decode(&chunk)
+		if err != nil {
+			s.logger.Error("error while decoding chunk", zap.Error(err))
+			return err
+		}
+		err = send(chunk.Data)
+		if err != nil {
+			return err
+		}
+	}
+	return cur.Err()
+}
+
+// DeleteChunks deletes chunks of one upload of data, all chunks of data are deleted if chunksID is empty
+func (s *Storage) DeleteChunks(ctx context.Context, user models.User, dataUUID, chunksID string) error {
+	filter := bson.D{{"userUUID", user.UUID}, {"dataUUID", dataUUID}}
+	if chunksID != "" {
+		filter = append(filter, bson.E{"chunksID", chunksID})
+	}
+	_, err := s.chunks.DeleteMany(ctx, filter)
+	if err != nil {
+		s.logger.Error("error while deleting chunks", zap.Error(err))
+		return err
+	}
+	return nil
+}
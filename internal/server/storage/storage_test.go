@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/h2p2f/dedicated-vault/internal/server/models"
+	"github.com/h2p2f/dedicated-vault/internal/srp"
+)
+
+func TestSyncedRevision(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name string
+		user models.User
+		want int64
+	}{
+		{
+			name: "no pending revisions",
+			user: models.User{Revision: 7},
+			want: 7,
+		},
+		{
+			name: "lowest pending revision",
+			user: models.User{Revision: 7, PendingRevisions: []models.PendingRevision{
+				{Revision: 7, Expires: now.Add(writeTimeout).Unix()},
+				{Revision: 5, Expires: now.Unix()},
+			}},
+			want: 4,
+		},
+		{
+			name: "stale pending revision",
+			user: models.User{Revision: 7, PendingRevisions: []models.PendingRevision{
+				{Revision: 5, Expires: now.Add(-time.Second).Unix()},
+				{Revision: 6, Expires: now.Add(writeTimeout).Unix()},
+			}},
+			want: 5,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, syncedRevision(tt.user, now))
+		})
+	}
+}
+
+func TestStorage_withWriteDeadline(t *testing.T) {
+	ctx, cancel := withWriteDeadline(context.Background())
+	defer cancel()
+	deadline, ok := ctx.Deadline()
+	assert.True(t, ok)
+	assert.WithinDuration(t, time.Now().Add(writeTimeout), deadline, time.Second)
+
+	parent, parentCancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer parentCancel()
+	ctx, cancel = withWriteDeadline(parent)
+	defer cancel()
+	parentDeadline, _ := parent.Deadline()
+	deadline, _ = ctx.Deadline()
+	assert.Equal(t, parentDeadline, deadline)
+}
+
+func TestDetachedContext(t *testing.T) {
+	type key struct{}
+	parent, cancel := context.WithCancel(context.WithValue(context.Background(), key{}, "value"))
+	cancel()
+	ctx := detachedContext{parent: parent}
+	assert.NoError(t, ctx.Err())
+	assert.Equal(t, "value", ctx.Value(key{}))
+	_, ok := ctx.Deadline()
+	assert.False(t, ok)
+}
+
+func TestStorage_fakeVerifier(t *testing.T) {
+	s := &Storage{fakeKey: []byte("testkey")}
+	verifier := s.fakeVerifier("unknown")
+	assert.Equal(t, verifier, s.fakeVerifier("unknown"))
+	assert.Len(t, verifier.Salt, srp.SaltSize)
+	assert.Equal(t, srp.DefaultParams, verifier.Params)
+	assert.NotEqual(t, verifier.Salt, s.fakeVerifier("another").Salt)
+	assert.NotEqual(t, verifier.Salt, (&Storage{fakeKey: []byte("otherkey")}).fakeVerifier("unknown").Salt)
+}
@@ -0,0 +1,246 @@
+// Package storage
+// in this file we have two-factor authentication of users, TOTP secret and hashes of recovery codes are kept in user
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+
+	"github.com/h2p2f/dedicated-vault/internal/server/models"
+	"github.com/h2p2f/dedicated-vault/internal/server/servererrors"
+	"github.com/h2p2f/dedicated-vault/internal/server/twofactor"
+)
+
+// checkTwoFactor checks second factor of login, user without two-factor authentication can't log in if server requires it
+func (s *Storage) checkTwoFactor(ctx context.Context, user models.User, code string) error {
+	if user.TOTPSecret == "" {
+		if s.config.RequiresTwoFactor() {
+			return servererrors.TwoFactorEnrollmentRequired
+		}
+		return nil
+	}
+	if code == "" {
+		return servererrors.TwoFactorRequired
+	}
+	return s.useCode(ctx, user, code)
+}
+
+// loginSession checks second factor of login and starts session of user,
+// user who has to enroll two-factor authentication gets session accepted only to enroll with TwoFactorEnrollmentRequired
+func (s *Storage) loginSession(ctx context.Context, user models.User, code string, device models.Device) (models.Tokens, error) {
+	err := s.checkTwoFactor(ctx, user, code)
+	if errors.Is(err, servererrors.TwoFactorEnrollmentRequired) {
+		token, sessionErr := s.newSession(ctx, user.UUID, device, true)
+		if sessionErr != nil {
+			return models.Tokens{}, sessionErr
+		}
+		return token, err
+	}
+	if err != nil {
+		return models.Tokens{}, err
+	}
+	return s.newSession(ctx, user.UUID, device, false)
+}
+
+// useCode accepts TOTP code or recovery code of user
+// accepted TOTP code and codes of earlier time steps can't be used again, recovery code is removed
+// both are checked by the update itself, so a code used by concurrent request is not accepted twice
+func (s *Storage) useCode(ctx context.Context, user models.User, code string) error {
+	if err := checkLockout(user); err != nil {
+		return err
+	}
+	filter := bson.D{{"UUID", user.UUID}, {"totpSecret", user.TOTPSecret}}
+	var update bson.D
+	if twofactor.IsTOTPCode(code) {
+		step, ok := twofactor.Verify(user.TOTPSecret, code, time.Now(), user.TOTPLastStep)
+		if !ok {
+			return s.failCode(ctx, user.UUID)
+		}
+		filter = append(filter, bson.E{"totpLastStep", bson.D{{"$lt", step}}})
+		update = bson.D{{"$set", bson.D{{"totpLastStep", step}, {"twoFactorFailures", int64(0)}}}}
+	} else {
+		hash := twofactor.HashRecoveryCode(code)
+		filter = append(filter, bson.E{"recoveryCodes", hash})
+		update = bson.D{
+			{"$pull", bson.D{{"recoveryCodes", hash}}},
+			{"$set", bson.D{{"twoFactorFailures", int64(0)}}}}
+	}
+	result, err := s.users.UpdateOne(ctx, filter, update)
+	if err != nil {
+		s.logger.Error("error while using two-factor code", zap.Error(err))
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return s.failCode(ctx, user.UUID)
+	}
+	return nil
+}
+
+// checkLockout rejects codes of user locked out after too many wrong codes, they are not even checked
+func checkLockout(user models.User) error {
+	if user.TwoFactorLockedUntil > time.Now().Unix() {
+		return servererrors.TwoFactorLocked
+	}
+	return nil
+}
+
+// failCode counts wrong code of user and locks out codes of user after too many of them,
+// every next wrong code after lockout locks them out longer; InvalidTwoFactorCode is returned
+func (s *Storage) failCode(ctx context.Context, userUUID string) error {
+	var user models.User
+	err := s.users.FindOneAndUpdate(ctx,
+		bson.D{{"UUID", userUUID}},
+		bson.D{{"$inc", bson.D{{"twoFactorFailures", int64(1)}}}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&user)
+	if err != nil {
+		s.logger.Error("error while counting wrong two-factor code", zap.Error(err))
+		return err
+	}
+	lockout := twofactor.Lockout(user.TwoFactorFailures)
+	if lockout == 0 {
+		return servererrors.InvalidTwoFactorCode
+	}
+	s.logger.Warn("two-factor codes of user are locked out",
+		zap.String("user", userUUID), zap.Int64("failures", user.TwoFactorFailures), zap.Duration("lockout", lockout))
+	_, err = s.users.UpdateOne(ctx,
+		bson.D{{"UUID", userUUID}},
+		bson.D{{"$set", bson.D{{"twoFactorLockedUntil", time.Now().Add(lockout).Unix()}}}})
+	if err != nil {
+		s.logger.Error("error while locking out two-factor codes", zap.Error(err))
+		return err
+	}
+	return servererrors.InvalidTwoFactorCode
+}
+
+// checkUserPassword checks password or proof of password of user of token, password of another user is not accepted
+// proof of server is returned for proof of password
+func (s *Storage) checkUserPassword(ctx context.Context, user models.User, proof models.PasswordProof) (models.User, []byte, error) {
+	checkUser, serverProof, err := s.checkPassword(ctx, user, proof)
+	if err != nil {
+		return models.User{}, nil, err
+	}
+	if checkUser.UUID != user.UUID {
+		s.logger.Error("password is of another user", zap.String("user", user.UUID))
+		return models.User{}, nil, servererrors.WrongPassword
+	}
+	return checkUser, serverProof, nil
+}
+
+// EnrollTwoFactor starts enrollment of two-factor authentication and returns provisioning URI of the new secret
+// the secret is used for login only after ConfirmTwoFactor, enrollment started again replaces it
+// user of token is authenticated again by proof of password, if it is given, otherwise by password
+func (s *Storage) EnrollTwoFactor(ctx context.Context, user models.User, proof models.PasswordProof) (string, []byte, error) {
+	checkUser, serverProof, err := s.checkUserPassword(ctx, user, proof)
+	if err != nil {
+		return "", nil, err
+	}
+	if checkUser.TOTPSecret != "" {
+		return "", nil, servererrors.TwoFactorAlreadyEnabled
+	}
+	secret, err := twofactor.NewSecret()
+	if err != nil {
+		s.logger.Error("error while generating totp secret", zap.Error(err))
+		return "", nil, err
+	}
+	_, err = s.users.UpdateOne(ctx,
+		bson.D{{"UUID", checkUser.UUID}},
+		bson.D{{"$set", bson.D{{"totpPending", secret}}}})
+	if err != nil {
+		s.logger.Error("error while saving totp secret", zap.Error(err))
+		return "", nil, err
+	}
+	return twofactor.URI(checkUser.Login, secret), serverProof, nil
+}
+
+// ConfirmTwoFactor enables two-factor authentication when code of enrolled secret is right
+// recovery codes are returned once, only their hashes are kept;
+// session accepted only to enroll becomes a usual one, user has proved the second factor in it
+func (s *Storage) ConfirmTwoFactor(ctx context.Context, user models.User, sessionID string, proof models.PasswordProof, code string) ([]string, []byte, error) {
+	checkUser, serverProof, err := s.checkUserPassword(ctx, user, proof)
+	if err != nil {
+		return nil, nil, err
+	}
+	if checkUser.TOTPSecret != "" {
+		return nil, nil, servererrors.TwoFactorAlreadyEnabled
+	}
+	if checkUser.TOTPPending == "" {
+		return nil, nil, servererrors.InvalidTwoFactorCode
+	}
+	if err = checkLockout(checkUser); err != nil {
+		return nil, nil, err
+	}
+	step, ok := twofactor.Verify(checkUser.TOTPPending, code, time.Now(), 0)
+	if !ok {
+		return nil, nil, s.failCode(ctx, checkUser.UUID)
+	}
+	codes, err := twofactor.NewRecoveryCodes(twofactor.RecoveryCodes)
+	if err != nil {
+		s.logger.Error("error while generating recovery codes", zap.Error(err))
+		return nil, nil, err
+	}
+	hashes := make([]string, 0, len(codes))
+	for _, recoveryCode := range codes {
+		hashes = append(hashes, twofactor.HashRecoveryCode(recoveryCode))
+	}
+	result, err := s.users.UpdateOne(ctx,
+		bson.D{{"UUID", checkUser.UUID}, {"totpPending", checkUser.TOTPPending}},
+		bson.D{
+			{"$set", bson.D{
+				{"totpSecret", checkUser.TOTPPending},
+				{"totpLastStep", step},
+				{"recoveryCodes", hashes},
+				{"twoFactorFailures", int64(0)}}},
+			{"$unset", bson.D{{"totpPending", ""}}}})
+	if err != nil {
+		s.logger.Error("error while enabling two-factor authentication", zap.Error(err))
+		return nil, nil, err
+	}
+	// enrollment was started again meanwhile, code is of the replaced secret
+	if result.MatchedCount == 0 {
+		return nil, nil, servererrors.InvalidTwoFactorCode
+	}
+	err = s.completeEnrollment(ctx, checkUser.UUID, sessionID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return codes, serverProof, nil
+}
+
+// DisableTwoFactor disables two-factor authentication of user, a current TOTP code is required for it,
+// recovery codes don't disable it, they only let user log in without authenticator app
+func (s *Storage) DisableTwoFactor(ctx context.Context, user models.User, proof models.PasswordProof, code string) ([]byte, error) {
+	checkUser, serverProof, err := s.checkUserPassword(ctx, user, proof)
+	if err != nil {
+		return nil, err
+	}
+	if checkUser.TOTPSecret == "" {
+		return nil, servererrors.TwoFactorNotEnabled
+	}
+	if s.config.RequiresTwoFactor() {
+		return nil, servererrors.TwoFactorEnforced
+	}
+	if !twofactor.IsTOTPCode(code) {
+		return nil, servererrors.InvalidTwoFactorCode
+	}
+	err = s.useCode(ctx, checkUser, code)
+	if err != nil {
+		return nil, err
+	}
+	_, err = s.users.UpdateOne(ctx,
+		bson.D{{"UUID", checkUser.UUID}},
+		bson.D{{"$unset", bson.D{
+			{"totpSecret", ""},
+			{"totpPending", ""},
+			{"totpLastStep", ""},
+			{"recoveryCodes", ""}}}})
+	if err != nil {
+		s.logger.Error("error while disabling two-factor authentication", zap.Error(err))
+		return nil, err
+	}
+	return serverProof, nil
+}
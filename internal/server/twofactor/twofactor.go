@@ -0,0 +1,157 @@
+// Package twofactor
+// in this package we have TOTP codes (RFC 6238) and recovery codes of two-factor authentication
+package twofactor
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1" //nolint:gosec // TOTP of authenticator apps is HMAC-SHA1
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// Issuer - issuer of TOTP secrets, it is shown by authenticator apps
+	Issuer = "dedicated-vault"
+	// RecoveryCodes - number of recovery codes given to user
+	RecoveryCodes = 10
+	// period - lifetime of TOTP code
+	period = 30 * time.Second
+	// digits - length of TOTP code
+	digits = 6
+	// skew - codes of this number of periods before and after current time are accepted, so clocks may differ a bit
+	skew = 1
+	// secretSize - size of TOTP secret in bytes, it is recommended by RFC 4226
+	secretSize = 20
+	// recoveryCodeSize - size of recovery code in bytes
+	recoveryCodeSize = 10
+	// MaxFailures - wrong codes in a row which are let through before codes of user are locked out
+	MaxFailures = 5
+	// lockout - the first lockout, it doubles with every next wrong code up to maxLockout
+	lockout    = time.Minute
+	maxLockout = time.Hour
+)
+
+// encoding - base32 encoding of secrets without padding, as authenticator apps expect it
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// NewSecret generates a new TOTP secret, it is base32 encoded
+func NewSecret() (string, error) {
+	secret := make([]byte, secretSize)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return encoding.EncodeToString(secret), nil
+}
+
+// URI - otpauth provisioning URI of secret, authenticator apps import secret from it (usually as QR code)
+func URI(account, secret string) string {
+	label := url.PathEscape(Issuer + ":" + account)
+	params := url.Values{}
+	params.Set("secret", secret)
+	params.Set("issuer", Issuer)
+	params.Set("algorithm", "SHA1")
+	params.Set("digits", fmt.Sprint(digits))
+	params.Set("period", fmt.Sprint(int(period.Seconds())))
+	return "otpauth://totp/" + label + "?" + params.Encode()
+}
+
+// IsTOTPCode - whether code looks like TOTP code, other codes are treated as recovery codes
+func IsTOTPCode(code string) bool {
+	code = strings.TrimSpace(code)
+	if len(code) != digits {
+		return false
+	}
+	for _, c := range code {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Verify checks TOTP code of secret at time t and returns time step of the code
+// step must be greater than lastStep, so a code which was already used is not accepted again
+func Verify(secret, code string, t time.Time, lastStep int64) (int64, bool) {
+	key, err := encoding.DecodeString(strings.ToUpper(secret))
+	if err != nil || !IsTOTPCode(code) {
+		return 0, false
+	}
+	code = strings.TrimSpace(code)
+	current := t.Unix() / int64(period.Seconds())
+	for step := current - skew; step <= current+skew; step++ {
+		if step <= lastStep {
+			continue
+		}
+		if hmac.Equal([]byte(generate(key, step)), []byte(code)) {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// generate - HOTP code (RFC 4226) of key and counter
+func generate(key []byte, counter int64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(counter))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", digits, value%1_000_000)
+}
+
+// NewRecoveryCodes generates n recovery codes, they are formatted as groups of 4 characters
+func NewRecoveryCodes(n int) ([]string, error) {
+	codes := make([]string, 0, n)
+	buf := make([]byte, recoveryCodeSize)
+	for i := 0; i < n; i++ {
+		if _, err := rand.Read(buf); err != nil {
+			return nil, err
+		}
+		encoded := strings.ToLower(encoding.EncodeToString(buf))
+		groups := make([]string, 0, len(encoded)/4)
+		for j := 0; j < len(encoded); j += 4 {
+			groups = append(groups, encoded[j:j+4])
+		}
+		codes = append(codes, strings.Join(groups, "-"))
+	}
+	return codes, nil
+}
+
+// HashRecoveryCode - hash of recovery code, only hashes are stored
+// codes are random, so a fast hash is enough; case, spaces and dashes typed by user don't matter
+func HashRecoveryCode(code string) string {
+	normalized := strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, strings.ToLower(strings.TrimSpace(code)))
+	hash := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(hash[:])
+}
+
+// Lockout - time codes of user are not checked after failures wrong codes in a row,
+// a guess of 6 digits needs about 500000 tries, so a few wrong codes of user are let through
+func Lockout(failures int64) time.Duration {
+	if failures < MaxFailures {
+		return 0
+	}
+	// shift is limited, so the duration doesn't overflow
+	shift := failures - MaxFailures
+	if shift > 10 {
+		shift = 10
+	}
+	if d := lockout << shift; d < maxLockout {
+		return d
+	}
+	return maxLockout
+}
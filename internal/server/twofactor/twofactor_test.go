@@ -0,0 +1,88 @@
+package twofactor
+
+import (
+	"encoding/base32"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rfcSecret - secret of test vectors of RFC 6238 for SHA1
+var rfcSecret = base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestVerify(t *testing.T) {
+	tests := []struct {
+		name     string
+		code     string
+		time     time.Time
+		lastStep int64
+		wantStep int64
+		wantOk   bool
+	}{
+		{name: "rfc vector", code: "287082", time: time.Unix(59, 0), wantStep: 1, wantOk: true},
+		{name: "rfc vector 2", code: "081804", time: time.Unix(1111111109, 0), wantStep: 37037036, wantOk: true},
+		{name: "previous period", code: "081804", time: time.Unix(1111111109+30, 0), wantStep: 37037036, wantOk: true},
+		{name: "too old", code: "081804", time: time.Unix(1111111109+60, 0)},
+		{name: "already used", code: "081804", time: time.Unix(1111111109, 0), lastStep: 37037036},
+		{name: "wrong code", code: "123456", time: time.Unix(59, 0)},
+		{name: "not a code", code: "28708a", time: time.Unix(59, 0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := Verify(rfcSecret, tt.code, tt.time, tt.lastStep)
+			assert.Equal(t, tt.wantOk, ok)
+			assert.Equal(t, tt.wantStep, step)
+		})
+	}
+}
+
+func TestURI(t *testing.T) {
+	secret, err := NewSecret()
+	require.NoError(t, err)
+	uri, err := url.Parse(URI("alice@example.com", secret))
+	require.NoError(t, err)
+	assert.Equal(t, "otpauth", uri.Scheme)
+	assert.Equal(t, "totp", uri.Host)
+	assert.Equal(t, "/dedicated-vault:alice@example.com", uri.Path)
+	assert.Equal(t, secret, uri.Query().Get("secret"))
+	assert.Equal(t, Issuer, uri.Query().Get("issuer"))
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := NewRecoveryCodes(RecoveryCodes)
+	require.NoError(t, err)
+	require.Len(t, codes, RecoveryCodes)
+	seen := make(map[string]bool)
+	for _, code := range codes {
+		assert.Len(t, code, 19)
+		assert.False(t, IsTOTPCode(code))
+		hash := HashRecoveryCode(code)
+		assert.False(t, seen[hash])
+		seen[hash] = true
+		typed := " " + strings.ToUpper(strings.ReplaceAll(code, "-", "")) + " "
+		assert.Equal(t, hash, HashRecoveryCode(typed))
+	}
+}
+
+func TestLockout(t *testing.T) {
+	tests := []struct {
+		name     string
+		failures int64
+		want     time.Duration
+	}{
+		{name: "no failures", failures: 0, want: 0},
+		{name: "failures let through", failures: MaxFailures - 1, want: 0},
+		{name: "first lockout", failures: MaxFailures, want: time.Minute},
+		{name: "lockout doubles", failures: MaxFailures + 2, want: 4 * time.Minute},
+		{name: "lockout is limited", failures: MaxFailures + 100, want: time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Lockout(tt.failures))
+		})
+	}
+}
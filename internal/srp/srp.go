@@ -0,0 +1,255 @@
+// Package srp
+// in this package we have SRP-6a (RFC 2945, RFC 5054) password authentication shared by client and server:
+// server keeps only salt and verifier of password and checks proof of password, password itself is never sent
+// password key is derived with Argon2id, so a stolen verifier is as hard to brute-force as the vault passphrase
+package srp
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"math/big"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// groupPrime - prime N of 2048-bit group of RFC 5054, appendix A, its generator is 2
+const groupPrime = "AC6BDB41324A9A9BF166DE5E1389582FAF72B6651987EE07FC3192943DB56050A37329CBB4A099ED8193E0757767A13DD52312AB4B03310D" +
+	"CD7F48A9DA04FD50E8083969EDB767B0CF6095179A163AB3661A05FBD5FAAAE82918A9962F0B93B855F97993EC975EEAA80D740ADBF4FF74" +
+	"7359D041D5C33EA71D281E446B14773BCA97B43A23FB801676BD207A436C6481F1D2B9078717461A5B9D32E688F87748544523B524B0D57D" +
+	"5EA77A2775D2ECFA032CFBDBF52FB3786160279004E57AE6AF874E7303CE53299CCC041C7BC308D82A5698F3A8D0C38271AE35F8E9DBFBB6" +
+	"94B5C803D89F7AE435DE236D525F54759B65E372FCD68EF20FA7111F9E4AFF73"
+
+const (
+	// SaltSize - size of salt of password in bytes
+	SaltSize = 16
+	// secretSize - size of ephemeral secrets of client and server in bytes
+	secretSize = 32
+	// keySize - size of password key derived with Argon2id
+	keySize = 32
+)
+
+var (
+	n = func() *big.Int {
+		prime, _ := new(big.Int).SetString(groupPrime, 16)
+		return prime
+	}()
+	g = big.NewInt(2)
+	// k - multiplier of SRP-6a, k = H(N | PAD(g))
+	k = new(big.Int).SetBytes(hash(n.Bytes(), pad(g)))
+)
+
+// ErrInvalidPublic - public value of peer is 0 mod N, it would make the shared secret known to anyone
+var ErrInvalidPublic = errors.New("srp: invalid public value")
+
+// ErrProofMismatch - proof of peer is wrong: password is wrong or peer doesn't know the verifier
+var ErrProofMismatch = errors.New("srp: proof mismatch")
+
+// Params - Argon2id parameters of password key
+// Memory is in KiB
+type Params struct {
+	Time    uint32 `json:"time" bson:"time"`
+	Memory  uint32 `json:"memory" bson:"memory"`
+	Threads uint8  `json:"threads" bson:"threads"`
+}
+
+// DefaultParams - Argon2id parameters recommended by RFC 9106 for memory constrained environments
+var DefaultParams = Params{
+	Time:    3,
+	Memory:  64 * 1024,
+	Threads: 4,
+}
+
+// minMemory - verifiers of password keys derived with less memory (KiB) are too cheap to brute-force
+const minMemory = 8 * 1024
+
+// maxMemory and maxTime - password key derived with more memory (KiB) or passes would exhaust client,
+// params are sent by server, so they are limited before key is derived
+const (
+	maxMemory = 1024 * 1024
+	maxTime   = 16
+)
+
+// ErrWeakParams - params make password key too cheap to brute-force
+var ErrWeakParams = errors.New("srp: argon2id params are too weak")
+
+// ErrCostlyParams - params make password key too costly to derive
+var ErrCostlyParams = errors.New("srp: argon2id params are too costly")
+
+// Valid checks that params are strong enough for password key of verifier stored on server
+// and not so costly that deriving the key exhausts client
+func (p Params) Valid() error {
+	if p.Time == 0 || p.Threads == 0 || p.Memory < minMemory {
+		return ErrWeakParams
+	}
+	if p.Time > maxTime || p.Memory > maxMemory {
+		return ErrCostlyParams
+	}
+	return nil
+}
+
+// FakeVerifier makes verifier of no password from seed, it lets server answer handshakes of unknown users
+// like handshakes of real ones, so logins of users can't be found out by StartAuth
+func FakeVerifier(seed []byte) []byte {
+	return new(big.Int).Exp(g, new(big.Int).SetBytes(hash(seed)), n).Bytes()
+}
+
+// NewSalt generates a new salt of password
+func NewSalt() ([]byte, error) {
+	salt := make([]byte, SaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	return salt, nil
+}
+
+// Verifier computes verifier of password, v = g^x mod N
+func Verifier(password string, salt []byte, params Params) []byte {
+	return new(big.Int).Exp(g, passwordKey(password, salt, params), n).Bytes()
+}
+
+// passwordKey - x = H(salt | Argon2id(password, salt))
+func passwordKey(password string, salt []byte, params Params) *big.Int {
+	key := argon2.IDKey([]byte(password), salt, params.Time, params.Memory, params.Threads, keySize)
+	return new(big.Int).SetBytes(hash(salt, key))
+}
+
+// Client - client side of one handshake
+type Client struct {
+	login  string
+	a      *big.Int
+	public *big.Int
+	// key and proof are known after Proof
+	key   []byte
+	proof []byte
+}
+
+// NewClient starts handshake of user, its public value is sent to server
+func NewClient(login string) (*Client, error) {
+	a, err := randomSecret()
+	if err != nil {
+		return nil, err
+	}
+	return &Client{
+		login:  login,
+		a:      a,
+		public: new(big.Int).Exp(g, a, n),
+	}, nil
+}
+
+// Public - public value A of client
+func (c *Client) Public() []byte {
+	return pad(c.public)
+}
+
+// Proof computes proof of password M1 with salt, params and public value B given by server
+func (c *Client) Proof(password string, salt []byte, params Params, serverPublic []byte) ([]byte, error) {
+	if err := ValidPublic(serverPublic); err != nil {
+		return nil, err
+	}
+	b := new(big.Int).SetBytes(serverPublic)
+	u := scramble(c.public, b)
+	if u.Sign() == 0 {
+		return nil, ErrInvalidPublic
+	}
+	x := passwordKey(password, salt, params)
+	// S = (B - k * g^x) ^ (a + u * x) mod N
+	base := new(big.Int).Mul(k, new(big.Int).Exp(g, x, n))
+	base.Sub(b, base).Mod(base, n)
+	exponent := new(big.Int).Mul(u, x)
+	exponent.Add(exponent, c.a)
+	secret := new(big.Int).Exp(base, exponent, n)
+	c.key = hash(pad(secret))
+	c.proof = clientProof(c.login, salt, c.public, b, c.key)
+	return c.proof, nil
+}
+
+// VerifyServer checks proof M2 of server, it shows that server knows verifier of password
+func (c *Client) VerifyServer(serverProof []byte) error {
+	if c.key == nil || subtle.ConstantTimeCompare(serverProof, hash(pad(c.public), c.proof, c.key)) != 1 {
+		return ErrProofMismatch
+	}
+	return nil
+}
+
+// ValidPublic checks public value of peer: it has to be of size of N at most and not 0 mod N
+func ValidPublic(public []byte) error {
+	if len(public) > len(n.Bytes()) || new(big.Int).Mod(new(big.Int).SetBytes(public), n).Sign() == 0 {
+		return ErrInvalidPublic
+	}
+	return nil
+}
+
+// ServerChallenge starts handshake on server: it returns secret b, which server keeps until proof is checked,
+// and public value B sent to client
+func ServerChallenge(verifier []byte) (secret, public []byte, err error) {
+	b, err := randomSecret()
+	if err != nil {
+		return nil, nil, err
+	}
+	// B = (k * v + g^b) mod N
+	pub := new(big.Int).Mul(k, new(big.Int).SetBytes(verifier))
+	pub.Add(pub, new(big.Int).Exp(g, b, n)).Mod(pub, n)
+	return b.Bytes(), pad(pub), nil
+}
+
+// VerifyClient checks proof M1 of client and returns proof M2 of server
+func VerifyClient(login string, salt, verifier, clientPublic, secret, serverPublic, proof []byte) ([]byte, error) {
+	if err := ValidPublic(clientPublic); err != nil {
+		return nil, err
+	}
+	a := new(big.Int).SetBytes(clientPublic)
+	b := new(big.Int).SetBytes(serverPublic)
+	u := scramble(a, b)
+	if u.Sign() == 0 {
+		return nil, ErrInvalidPublic
+	}
+	// S = (A * v^u) ^ b mod N
+	base := new(big.Int).Exp(new(big.Int).SetBytes(verifier), u, n)
+	base.Mul(base, a).Mod(base, n)
+	key := hash(pad(new(big.Int).Exp(base, new(big.Int).SetBytes(secret), n)))
+	expected := clientProof(login, salt, a, b, key)
+	if subtle.ConstantTimeCompare(proof, expected) != 1 {
+		return nil, ErrProofMismatch
+	}
+	return hash(pad(a), expected, key), nil
+}
+
+// clientProof - M1 = H(H(N) xor H(g) | H(I) | salt | A | B | K)
+func clientProof(login string, salt []byte, a, b *big.Int, key []byte) []byte {
+	hashN := hash(n.Bytes())
+	hashG := hash(pad(g))
+	for i := range hashN {
+		hashN[i] ^= hashG[i]
+	}
+	return hash(hashN, hash([]byte(login)), salt, pad(a), pad(b), key)
+}
+
+// scramble - u = H(PAD(A) | PAD(B))
+func scramble(a, b *big.Int) *big.Int {
+	return new(big.Int).SetBytes(hash(pad(a), pad(b)))
+}
+
+// randomSecret generates ephemeral secret
+func randomSecret() (*big.Int, error) {
+	secret := make([]byte, secretSize)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(secret), nil
+}
+
+// pad - value left padded to size of N
+func pad(x *big.Int) []byte {
+	return x.FillBytes(make([]byte, len(n.Bytes())))
+}
+
+// hash - SHA-256 of concatenated values
+func hash(values ...[]byte) []byte {
+	h := sha256.New()
+	for _, v := range values {
+		h.Write(v)
+	}
+	return h.Sum(nil)
+}
@@ -0,0 +1,105 @@
+package srp
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testParams - cheap Argon2id parameters, so tests are fast
+var testParams = Params{Time: 1, Memory: 64, Threads: 1}
+
+func TestHandshake(t *testing.T) {
+	salt, err := NewSalt()
+	require.NoError(t, err)
+	verifier := Verifier("password", salt, testParams)
+
+	tests := []struct {
+		name     string
+		password string
+		wantErr  error
+	}{
+		{name: "right password", password: "password"},
+		{name: "wrong password", password: "passw0rd", wantErr: ErrProofMismatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := NewClient("testuser")
+			require.NoError(t, err)
+			secret, serverPublic, err := ServerChallenge(verifier)
+			require.NoError(t, err)
+			proof, err := client.Proof(tt.password, salt, testParams, serverPublic)
+			require.NoError(t, err)
+
+			serverProof, err := VerifyClient("testuser", salt, verifier, client.Public(), secret, serverPublic, proof)
+			assert.ErrorIs(t, err, tt.wantErr)
+			if tt.wantErr == nil {
+				assert.NoError(t, client.VerifyServer(serverProof))
+			}
+		})
+	}
+}
+
+func TestHandshake_BoundToUser(t *testing.T) {
+	salt, err := NewSalt()
+	require.NoError(t, err)
+	verifier := Verifier("password", salt, testParams)
+	client, err := NewClient("testuser")
+	require.NoError(t, err)
+	secret, serverPublic, err := ServerChallenge(verifier)
+	require.NoError(t, err)
+	proof, err := client.Proof("password", salt, testParams, serverPublic)
+	require.NoError(t, err)
+
+	_, err = VerifyClient("otheruser", salt, verifier, client.Public(), secret, serverPublic, proof)
+	assert.ErrorIs(t, err, ErrProofMismatch)
+	assert.ErrorIs(t, client.VerifyServer(make([]byte, 32)), ErrProofMismatch)
+}
+
+func TestInvalidPublic(t *testing.T) {
+	salt, err := NewSalt()
+	require.NoError(t, err)
+	verifier := Verifier("password", salt, testParams)
+	secret, serverPublic, err := ServerChallenge(verifier)
+	require.NoError(t, err)
+	tooLong := append([]byte{1}, make([]byte, len(n.Bytes()))...)
+	for _, public := range [][]byte{nil, n.Bytes(), new(big.Int).Mul(n, big.NewInt(2)).Bytes(), tooLong} {
+		assert.ErrorIs(t, ValidPublic(public), ErrInvalidPublic)
+		_, err = VerifyClient("testuser", salt, verifier, public, secret, serverPublic, nil)
+		assert.ErrorIs(t, err, ErrInvalidPublic)
+
+		client, err := NewClient("testuser")
+		require.NoError(t, err)
+		_, err = client.Proof("password", salt, testParams, public)
+		assert.ErrorIs(t, err, ErrInvalidPublic)
+	}
+}
+
+func TestVerifier(t *testing.T) {
+	salt, err := NewSalt()
+	require.NoError(t, err)
+	otherSalt, err := NewSalt()
+	require.NoError(t, err)
+	assert.Equal(t, Verifier("password", salt, testParams), Verifier("password", salt, testParams))
+	assert.NotEqual(t, Verifier("password", salt, testParams), Verifier("password", otherSalt, testParams))
+	assert.NotEqual(t, Verifier("password", salt, testParams), Verifier("password", salt, Params{Time: 2, Memory: 64, Threads: 1}))
+}
+
+func TestParams_Valid(t *testing.T) {
+	assert.NoError(t, DefaultParams.Valid())
+	assert.Error(t, testParams.Valid())
+	assert.ErrorIs(t, Params{Memory: 64 * 1024, Threads: 4}.Valid(), ErrWeakParams)
+	assert.ErrorIs(t, Params{Time: 3, Memory: 4 * 1024 * 1024, Threads: 4}.Valid(), ErrCostlyParams)
+	assert.ErrorIs(t, Params{Time: 1000, Memory: 64 * 1024, Threads: 4}.Valid(), ErrCostlyParams)
+}
+
+func TestFakeVerifier(t *testing.T) {
+	assert.Equal(t, FakeVerifier([]byte("seed")), FakeVerifier([]byte("seed")))
+	assert.NotEqual(t, FakeVerifier([]byte("seed")), FakeVerifier([]byte("other seed")))
+	// challenge of fake verifier is made like challenge of real one
+	_, public, err := ServerChallenge(FakeVerifier([]byte("seed")))
+	require.NoError(t, err)
+	assert.NoError(t, ValidPublic(public))
+}
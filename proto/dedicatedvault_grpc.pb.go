@@ -19,13 +19,29 @@ import (
 const _ = grpc.SupportPackageIsVersion7
 
 const (
-	DedicatedVault_Register_FullMethodName       = "/DedicatedVault/Register"
-	DedicatedVault_Login_FullMethodName          = "/DedicatedVault/Login"
-	DedicatedVault_ChangePassword_FullMethodName = "/DedicatedVault/ChangePassword"
-	DedicatedVault_SaveSecret_FullMethodName     = "/DedicatedVault/SaveSecret"
-	DedicatedVault_ChangeSecret_FullMethodName   = "/DedicatedVault/ChangeSecret"
-	DedicatedVault_DeleteSecret_FullMethodName   = "/DedicatedVault/DeleteSecret"
-	DedicatedVault_ListSecrets_FullMethodName    = "/DedicatedVault/ListSecrets"
+	DedicatedVault_Register_FullMethodName         = "/DedicatedVault/Register"
+	DedicatedVault_Login_FullMethodName            = "/DedicatedVault/Login"
+	DedicatedVault_ChangePassword_FullMethodName   = "/DedicatedVault/ChangePassword"
+	DedicatedVault_RefreshToken_FullMethodName     = "/DedicatedVault/RefreshToken"
+	DedicatedVault_Logout_FullMethodName           = "/DedicatedVault/Logout"
+	DedicatedVault_ListSessions_FullMethodName     = "/DedicatedVault/ListSessions"
+	DedicatedVault_RevokeSession_FullMethodName    = "/DedicatedVault/RevokeSession"
+	DedicatedVault_EnrollTwoFactor_FullMethodName  = "/DedicatedVault/EnrollTwoFactor"
+	DedicatedVault_ConfirmTwoFactor_FullMethodName = "/DedicatedVault/ConfirmTwoFactor"
+	DedicatedVault_DisableTwoFactor_FullMethodName = "/DedicatedVault/DisableTwoFactor"
+	DedicatedVault_RegisterVerifier_FullMethodName = "/DedicatedVault/RegisterVerifier"
+	DedicatedVault_StartAuth_FullMethodName        = "/DedicatedVault/StartAuth"
+	DedicatedVault_FinishLogin_FullMethodName      = "/DedicatedVault/FinishLogin"
+	DedicatedVault_ChangeVerifier_FullMethodName   = "/DedicatedVault/ChangeVerifier"
+	DedicatedVault_SaveSecret_FullMethodName       = "/DedicatedVault/SaveSecret"
+	DedicatedVault_ChangeSecret_FullMethodName     = "/DedicatedVault/ChangeSecret"
+	DedicatedVault_DeleteSecret_FullMethodName     = "/DedicatedVault/DeleteSecret"
+	DedicatedVault_ListSecrets_FullMethodName      = "/DedicatedVault/ListSecrets"
+	DedicatedVault_SyncChanges_FullMethodName      = "/DedicatedVault/SyncChanges"
+	DedicatedVault_GetMasterKey_FullMethodName     = "/DedicatedVault/GetMasterKey"
+	DedicatedVault_SetMasterKey_FullMethodName     = "/DedicatedVault/SetMasterKey"
+	DedicatedVault_UploadSecret_FullMethodName     = "/DedicatedVault/UploadSecret"
+	DedicatedVault_DownloadSecret_FullMethodName   = "/DedicatedVault/DownloadSecret"
 )
 
 // DedicatedVaultClient is the client API for DedicatedVault service.
@@ -35,10 +51,26 @@ type DedicatedVaultClient interface {
 	Register(ctx context.Context, in *RegisterRequest, opts ...grpc.CallOption) (*RegisterResponse, error)
 	Login(ctx context.Context, in *LoginRequest, opts ...grpc.CallOption) (*LoginResponse, error)
 	ChangePassword(ctx context.Context, in *ChangePasswordRequest, opts ...grpc.CallOption) (*ChangePasswordResponse, error)
+	RefreshToken(ctx context.Context, in *RefreshTokenRequest, opts ...grpc.CallOption) (*RefreshTokenResponse, error)
+	Logout(ctx context.Context, in *LogoutRequest, opts ...grpc.CallOption) (*LogoutResponse, error)
+	ListSessions(ctx context.Context, in *ListSessionsRequest, opts ...grpc.CallOption) (*ListSessionsResponse, error)
+	RevokeSession(ctx context.Context, in *RevokeSessionRequest, opts ...grpc.CallOption) (*RevokeSessionResponse, error)
+	EnrollTwoFactor(ctx context.Context, in *EnrollTwoFactorRequest, opts ...grpc.CallOption) (*EnrollTwoFactorResponse, error)
+	ConfirmTwoFactor(ctx context.Context, in *ConfirmTwoFactorRequest, opts ...grpc.CallOption) (*ConfirmTwoFactorResponse, error)
+	DisableTwoFactor(ctx context.Context, in *DisableTwoFactorRequest, opts ...grpc.CallOption) (*DisableTwoFactorResponse, error)
+	RegisterVerifier(ctx context.Context, in *RegisterVerifierRequest, opts ...grpc.CallOption) (*RegisterResponse, error)
+	StartAuth(ctx context.Context, in *StartAuthRequest, opts ...grpc.CallOption) (*StartAuthResponse, error)
+	FinishLogin(ctx context.Context, in *FinishLoginRequest, opts ...grpc.CallOption) (*FinishLoginResponse, error)
+	ChangeVerifier(ctx context.Context, in *ChangeVerifierRequest, opts ...grpc.CallOption) (*ChangePasswordResponse, error)
 	SaveSecret(ctx context.Context, in *SaveSecretRequest, opts ...grpc.CallOption) (*SaveSecretResponse, error)
 	ChangeSecret(ctx context.Context, in *ChangeSecretRequest, opts ...grpc.CallOption) (*ChangeSecretResponse, error)
 	DeleteSecret(ctx context.Context, in *DeleteSecretRequest, opts ...grpc.CallOption) (*DeleteSecretResponse, error)
 	ListSecrets(ctx context.Context, in *ListSecretsRequest, opts ...grpc.CallOption) (*ListSecretsResponse, error)
+	SyncChanges(ctx context.Context, in *SyncChangesRequest, opts ...grpc.CallOption) (*SyncChangesResponse, error)
+	GetMasterKey(ctx context.Context, in *GetMasterKeyRequest, opts ...grpc.CallOption) (*GetMasterKeyResponse, error)
+	SetMasterKey(ctx context.Context, in *SetMasterKeyRequest, opts ...grpc.CallOption) (*SetMasterKeyResponse, error)
+	UploadSecret(ctx context.Context, opts ...grpc.CallOption) (DedicatedVault_UploadSecretClient, error)
+	DownloadSecret(ctx context.Context, in *DownloadSecretRequest, opts ...grpc.CallOption) (DedicatedVault_DownloadSecretClient, error)
 }
 
 type dedicatedVaultClient struct {
@@ -76,6 +108,105 @@ func (c *dedicatedVaultClient) ChangePassword(ctx context.Context, in *ChangePas
 	return out, nil
 }
 
+func (c *dedicatedVaultClient) RefreshToken(ctx context.Context, in *RefreshTokenRequest, opts ...grpc.CallOption) (*RefreshTokenResponse, error) {
+	out := new(RefreshTokenResponse)
+	err := c.cc.Invoke(ctx, DedicatedVault_RefreshToken_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dedicatedVaultClient) Logout(ctx context.Context, in *LogoutRequest, opts ...grpc.CallOption) (*LogoutResponse, error) {
+	out := new(LogoutResponse)
+	err := c.cc.Invoke(ctx, DedicatedVault_Logout_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dedicatedVaultClient) ListSessions(ctx context.Context, in *ListSessionsRequest, opts ...grpc.CallOption) (*ListSessionsResponse, error) {
+	out := new(ListSessionsResponse)
+	err := c.cc.Invoke(ctx, DedicatedVault_ListSessions_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dedicatedVaultClient) RevokeSession(ctx context.Context, in *RevokeSessionRequest, opts ...grpc.CallOption) (*RevokeSessionResponse, error) {
+	out := new(RevokeSessionResponse)
+	err := c.cc.Invoke(ctx, DedicatedVault_RevokeSession_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dedicatedVaultClient) EnrollTwoFactor(ctx context.Context, in *EnrollTwoFactorRequest, opts ...grpc.CallOption) (*EnrollTwoFactorResponse, error) {
+	out := new(EnrollTwoFactorResponse)
+	err := c.cc.Invoke(ctx, DedicatedVault_EnrollTwoFactor_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dedicatedVaultClient) ConfirmTwoFactor(ctx context.Context, in *ConfirmTwoFactorRequest, opts ...grpc.CallOption) (*ConfirmTwoFactorResponse, error) {
+	out := new(ConfirmTwoFactorResponse)
+	err := c.cc.Invoke(ctx, DedicatedVault_ConfirmTwoFactor_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dedicatedVaultClient) DisableTwoFactor(ctx context.Context, in *DisableTwoFactorRequest, opts ...grpc.CallOption) (*DisableTwoFactorResponse, error) {
+	out := new(DisableTwoFactorResponse)
+	err := c.cc.Invoke(ctx, DedicatedVault_DisableTwoFactor_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dedicatedVaultClient) RegisterVerifier(ctx context.Context, in *RegisterVerifierRequest, opts ...grpc.CallOption) (*RegisterResponse, error) {
+	out := new(RegisterResponse)
+	err := c.cc.Invoke(ctx, DedicatedVault_RegisterVerifier_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dedicatedVaultClient) StartAuth(ctx context.Context, in *StartAuthRequest, opts ...grpc.CallOption) (*StartAuthResponse, error) {
+	out := new(StartAuthResponse)
+	err := c.cc.Invoke(ctx, DedicatedVault_StartAuth_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dedicatedVaultClient) FinishLogin(ctx context.Context, in *FinishLoginRequest, opts ...grpc.CallOption) (*FinishLoginResponse, error) {
+	out := new(FinishLoginResponse)
+	err := c.cc.Invoke(ctx, DedicatedVault_FinishLogin_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dedicatedVaultClient) ChangeVerifier(ctx context.Context, in *ChangeVerifierRequest, opts ...grpc.CallOption) (*ChangePasswordResponse, error) {
+	out := new(ChangePasswordResponse)
+	err := c.cc.Invoke(ctx, DedicatedVault_ChangeVerifier_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *dedicatedVaultClient) SaveSecret(ctx context.Context, in *SaveSecretRequest, opts ...grpc.CallOption) (*SaveSecretResponse, error) {
 	out := new(SaveSecretResponse)
 	err := c.cc.Invoke(ctx, DedicatedVault_SaveSecret_FullMethodName, in, out, opts...)
@@ -112,6 +243,99 @@ func (c *dedicatedVaultClient) ListSecrets(ctx context.Context, in *ListSecretsR
 	return out, nil
 }
 
+func (c *dedicatedVaultClient) SyncChanges(ctx context.Context, in *SyncChangesRequest, opts ...grpc.CallOption) (*SyncChangesResponse, error) {
+	out := new(SyncChangesResponse)
+	err := c.cc.Invoke(ctx, DedicatedVault_SyncChanges_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dedicatedVaultClient) GetMasterKey(ctx context.Context, in *GetMasterKeyRequest, opts ...grpc.CallOption) (*GetMasterKeyResponse, error) {
+	out := new(GetMasterKeyResponse)
+	err := c.cc.Invoke(ctx, DedicatedVault_GetMasterKey_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dedicatedVaultClient) SetMasterKey(ctx context.Context, in *SetMasterKeyRequest, opts ...grpc.CallOption) (*SetMasterKeyResponse, error) {
+	out := new(SetMasterKeyResponse)
+	err := c.cc.Invoke(ctx, DedicatedVault_SetMasterKey_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dedicatedVaultClient) UploadSecret(ctx context.Context, opts ...grpc.CallOption) (DedicatedVault_UploadSecretClient, error) {
+	stream, err := c.cc.NewStream(ctx, &DedicatedVault_ServiceDesc.Streams[0], DedicatedVault_UploadSecret_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &dedicatedVaultUploadSecretClient{stream}
+	return x, nil
+}
+
+type DedicatedVault_UploadSecretClient interface {
+	Send(*UploadSecretRequest) error
+	CloseAndRecv() (*UploadSecretResponse, error)
+	grpc.ClientStream
+}
+
+type dedicatedVaultUploadSecretClient struct {
+	grpc.ClientStream
+}
+
+func (x *dedicatedVaultUploadSecretClient) Send(m *UploadSecretRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *dedicatedVaultUploadSecretClient) CloseAndRecv() (*UploadSecretResponse, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(UploadSecretResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *dedicatedVaultClient) DownloadSecret(ctx context.Context, in *DownloadSecretRequest, opts ...grpc.CallOption) (DedicatedVault_DownloadSecretClient, error) {
+	stream, err := c.cc.NewStream(ctx, &DedicatedVault_ServiceDesc.Streams[1], DedicatedVault_DownloadSecret_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &dedicatedVaultDownloadSecretClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type DedicatedVault_DownloadSecretClient interface {
+	Recv() (*DownloadSecretResponse, error)
+	grpc.ClientStream
+}
+
+type dedicatedVaultDownloadSecretClient struct {
+	grpc.ClientStream
+}
+
+func (x *dedicatedVaultDownloadSecretClient) Recv() (*DownloadSecretResponse, error) {
+	m := new(DownloadSecretResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 // DedicatedVaultServer is the server API for DedicatedVault service.
 // All implementations must embed UnimplementedDedicatedVaultServer
 // for forward compatibility
@@ -119,10 +343,26 @@ type DedicatedVaultServer interface {
 	Register(context.Context, *RegisterRequest) (*RegisterResponse, error)
 	Login(context.Context, *LoginRequest) (*LoginResponse, error)
 	ChangePassword(context.Context, *ChangePasswordRequest) (*ChangePasswordResponse, error)
+	RefreshToken(context.Context, *RefreshTokenRequest) (*RefreshTokenResponse, error)
+	Logout(context.Context, *LogoutRequest) (*LogoutResponse, error)
+	ListSessions(context.Context, *ListSessionsRequest) (*ListSessionsResponse, error)
+	RevokeSession(context.Context, *RevokeSessionRequest) (*RevokeSessionResponse, error)
+	EnrollTwoFactor(context.Context, *EnrollTwoFactorRequest) (*EnrollTwoFactorResponse, error)
+	ConfirmTwoFactor(context.Context, *ConfirmTwoFactorRequest) (*ConfirmTwoFactorResponse, error)
+	DisableTwoFactor(context.Context, *DisableTwoFactorRequest) (*DisableTwoFactorResponse, error)
+	RegisterVerifier(context.Context, *RegisterVerifierRequest) (*RegisterResponse, error)
+	StartAuth(context.Context, *StartAuthRequest) (*StartAuthResponse, error)
+	FinishLogin(context.Context, *FinishLoginRequest) (*FinishLoginResponse, error)
+	ChangeVerifier(context.Context, *ChangeVerifierRequest) (*ChangePasswordResponse, error)
 	SaveSecret(context.Context, *SaveSecretRequest) (*SaveSecretResponse, error)
 	ChangeSecret(context.Context, *ChangeSecretRequest) (*ChangeSecretResponse, error)
 	DeleteSecret(context.Context, *DeleteSecretRequest) (*DeleteSecretResponse, error)
 	ListSecrets(context.Context, *ListSecretsRequest) (*ListSecretsResponse, error)
+	SyncChanges(context.Context, *SyncChangesRequest) (*SyncChangesResponse, error)
+	GetMasterKey(context.Context, *GetMasterKeyRequest) (*GetMasterKeyResponse, error)
+	SetMasterKey(context.Context, *SetMasterKeyRequest) (*SetMasterKeyResponse, error)
+	UploadSecret(DedicatedVault_UploadSecretServer) error
+	DownloadSecret(*DownloadSecretRequest, DedicatedVault_DownloadSecretServer) error
 	mustEmbedUnimplementedDedicatedVaultServer()
 }
 
@@ -139,6 +379,39 @@ func (UnimplementedDedicatedVaultServer) Login(context.Context, *LoginRequest) (
 func (UnimplementedDedicatedVaultServer) ChangePassword(context.Context, *ChangePasswordRequest) (*ChangePasswordResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method ChangePassword not implemented")
 }
+func (UnimplementedDedicatedVaultServer) RefreshToken(context.Context, *RefreshTokenRequest) (*RefreshTokenResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RefreshToken not implemented")
+}
+func (UnimplementedDedicatedVaultServer) Logout(context.Context, *LogoutRequest) (*LogoutResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Logout not implemented")
+}
+func (UnimplementedDedicatedVaultServer) ListSessions(context.Context, *ListSessionsRequest) (*ListSessionsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListSessions not implemented")
+}
+func (UnimplementedDedicatedVaultServer) RevokeSession(context.Context, *RevokeSessionRequest) (*RevokeSessionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RevokeSession not implemented")
+}
+func (UnimplementedDedicatedVaultServer) EnrollTwoFactor(context.Context, *EnrollTwoFactorRequest) (*EnrollTwoFactorResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method EnrollTwoFactor not implemented")
+}
+func (UnimplementedDedicatedVaultServer) ConfirmTwoFactor(context.Context, *ConfirmTwoFactorRequest) (*ConfirmTwoFactorResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ConfirmTwoFactor not implemented")
+}
+func (UnimplementedDedicatedVaultServer) DisableTwoFactor(context.Context, *DisableTwoFactorRequest) (*DisableTwoFactorResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DisableTwoFactor not implemented")
+}
+func (UnimplementedDedicatedVaultServer) RegisterVerifier(context.Context, *RegisterVerifierRequest) (*RegisterResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RegisterVerifier not implemented")
+}
+func (UnimplementedDedicatedVaultServer) StartAuth(context.Context, *StartAuthRequest) (*StartAuthResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method StartAuth not implemented")
+}
+func (UnimplementedDedicatedVaultServer) FinishLogin(context.Context, *FinishLoginRequest) (*FinishLoginResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method FinishLogin not implemented")
+}
+func (UnimplementedDedicatedVaultServer) ChangeVerifier(context.Context, *ChangeVerifierRequest) (*ChangePasswordResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ChangeVerifier not implemented")
+}
 func (UnimplementedDedicatedVaultServer) SaveSecret(context.Context, *SaveSecretRequest) (*SaveSecretResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method SaveSecret not implemented")
 }
@@ -151,6 +424,21 @@ func (UnimplementedDedicatedVaultServer) DeleteSecret(context.Context, *DeleteSe
 func (UnimplementedDedicatedVaultServer) ListSecrets(context.Context, *ListSecretsRequest) (*ListSecretsResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method ListSecrets not implemented")
 }
+func (UnimplementedDedicatedVaultServer) SyncChanges(context.Context, *SyncChangesRequest) (*SyncChangesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SyncChanges not implemented")
+}
+func (UnimplementedDedicatedVaultServer) GetMasterKey(context.Context, *GetMasterKeyRequest) (*GetMasterKeyResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetMasterKey not implemented")
+}
+func (UnimplementedDedicatedVaultServer) SetMasterKey(context.Context, *SetMasterKeyRequest) (*SetMasterKeyResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetMasterKey not implemented")
+}
+func (UnimplementedDedicatedVaultServer) UploadSecret(DedicatedVault_UploadSecretServer) error {
+	return status.Errorf(codes.Unimplemented, "method UploadSecret not implemented")
+}
+func (UnimplementedDedicatedVaultServer) DownloadSecret(*DownloadSecretRequest, DedicatedVault_DownloadSecretServer) error {
+	return status.Errorf(codes.Unimplemented, "method DownloadSecret not implemented")
+}
 func (UnimplementedDedicatedVaultServer) mustEmbedUnimplementedDedicatedVaultServer() {}
 
 // UnsafeDedicatedVaultServer may be embedded to opt out of forward compatibility for this service.
@@ -218,6 +506,204 @@ func _DedicatedVault_ChangePassword_Handler(srv interface{}, ctx context.Context
 	return interceptor(ctx, in, info, handler)
 }
 
+func _DedicatedVault_RefreshToken_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RefreshTokenRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DedicatedVaultServer).RefreshToken(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DedicatedVault_RefreshToken_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DedicatedVaultServer).RefreshToken(ctx, req.(*RefreshTokenRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DedicatedVault_Logout_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LogoutRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DedicatedVaultServer).Logout(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DedicatedVault_Logout_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DedicatedVaultServer).Logout(ctx, req.(*LogoutRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DedicatedVault_ListSessions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListSessionsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DedicatedVaultServer).ListSessions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DedicatedVault_ListSessions_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DedicatedVaultServer).ListSessions(ctx, req.(*ListSessionsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DedicatedVault_RevokeSession_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RevokeSessionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DedicatedVaultServer).RevokeSession(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DedicatedVault_RevokeSession_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DedicatedVaultServer).RevokeSession(ctx, req.(*RevokeSessionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DedicatedVault_EnrollTwoFactor_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(EnrollTwoFactorRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DedicatedVaultServer).EnrollTwoFactor(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DedicatedVault_EnrollTwoFactor_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DedicatedVaultServer).EnrollTwoFactor(ctx, req.(*EnrollTwoFactorRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DedicatedVault_ConfirmTwoFactor_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ConfirmTwoFactorRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DedicatedVaultServer).ConfirmTwoFactor(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DedicatedVault_ConfirmTwoFactor_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DedicatedVaultServer).ConfirmTwoFactor(ctx, req.(*ConfirmTwoFactorRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DedicatedVault_DisableTwoFactor_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DisableTwoFactorRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DedicatedVaultServer).DisableTwoFactor(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DedicatedVault_DisableTwoFactor_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DedicatedVaultServer).DisableTwoFactor(ctx, req.(*DisableTwoFactorRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DedicatedVault_RegisterVerifier_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RegisterVerifierRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DedicatedVaultServer).RegisterVerifier(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DedicatedVault_RegisterVerifier_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DedicatedVaultServer).RegisterVerifier(ctx, req.(*RegisterVerifierRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DedicatedVault_StartAuth_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StartAuthRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DedicatedVaultServer).StartAuth(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DedicatedVault_StartAuth_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DedicatedVaultServer).StartAuth(ctx, req.(*StartAuthRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DedicatedVault_FinishLogin_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FinishLoginRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DedicatedVaultServer).FinishLogin(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DedicatedVault_FinishLogin_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DedicatedVaultServer).FinishLogin(ctx, req.(*FinishLoginRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DedicatedVault_ChangeVerifier_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ChangeVerifierRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DedicatedVaultServer).ChangeVerifier(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DedicatedVault_ChangeVerifier_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DedicatedVaultServer).ChangeVerifier(ctx, req.(*ChangeVerifierRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _DedicatedVault_SaveSecret_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(SaveSecretRequest)
 	if err := dec(in); err != nil {
@@ -290,6 +776,107 @@ func _DedicatedVault_ListSecrets_Handler(srv interface{}, ctx context.Context, d
 	return interceptor(ctx, in, info, handler)
 }
 
+func _DedicatedVault_SyncChanges_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SyncChangesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DedicatedVaultServer).SyncChanges(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DedicatedVault_SyncChanges_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DedicatedVaultServer).SyncChanges(ctx, req.(*SyncChangesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DedicatedVault_GetMasterKey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetMasterKeyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DedicatedVaultServer).GetMasterKey(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DedicatedVault_GetMasterKey_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DedicatedVaultServer).GetMasterKey(ctx, req.(*GetMasterKeyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DedicatedVault_SetMasterKey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetMasterKeyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DedicatedVaultServer).SetMasterKey(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DedicatedVault_SetMasterKey_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DedicatedVaultServer).SetMasterKey(ctx, req.(*SetMasterKeyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DedicatedVault_UploadSecret_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(DedicatedVaultServer).UploadSecret(&dedicatedVaultUploadSecretServer{stream})
+}
+
+type DedicatedVault_UploadSecretServer interface {
+	SendAndClose(*UploadSecretResponse) error
+	Recv() (*UploadSecretRequest, error)
+	grpc.ServerStream
+}
+
+type dedicatedVaultUploadSecretServer struct {
+	grpc.ServerStream
+}
+
+func (x *dedicatedVaultUploadSecretServer) SendAndClose(m *UploadSecretResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *dedicatedVaultUploadSecretServer) Recv() (*UploadSecretRequest, error) {
+	m := new(UploadSecretRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _DedicatedVault_DownloadSecret_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(DownloadSecretRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(DedicatedVaultServer).DownloadSecret(m, &dedicatedVaultDownloadSecretServer{stream})
+}
+
+type DedicatedVault_DownloadSecretServer interface {
+	Send(*DownloadSecretResponse) error
+	grpc.ServerStream
+}
+
+type dedicatedVaultDownloadSecretServer struct {
+	grpc.ServerStream
+}
+
+func (x *dedicatedVaultDownloadSecretServer) Send(m *DownloadSecretResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
 // DedicatedVault_ServiceDesc is the grpc.ServiceDesc for DedicatedVault service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -309,6 +896,50 @@ var DedicatedVault_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "ChangePassword",
 			Handler:    _DedicatedVault_ChangePassword_Handler,
 		},
+		{
+			MethodName: "RefreshToken",
+			Handler:    _DedicatedVault_RefreshToken_Handler,
+		},
+		{
+			MethodName: "Logout",
+			Handler:    _DedicatedVault_Logout_Handler,
+		},
+		{
+			MethodName: "ListSessions",
+			Handler:    _DedicatedVault_ListSessions_Handler,
+		},
+		{
+			MethodName: "RevokeSession",
+			Handler:    _DedicatedVault_RevokeSession_Handler,
+		},
+		{
+			MethodName: "EnrollTwoFactor",
+			Handler:    _DedicatedVault_EnrollTwoFactor_Handler,
+		},
+		{
+			MethodName: "ConfirmTwoFactor",
+			Handler:    _DedicatedVault_ConfirmTwoFactor_Handler,
+		},
+		{
+			MethodName: "DisableTwoFactor",
+			Handler:    _DedicatedVault_DisableTwoFactor_Handler,
+		},
+		{
+			MethodName: "RegisterVerifier",
+			Handler:    _DedicatedVault_RegisterVerifier_Handler,
+		},
+		{
+			MethodName: "StartAuth",
+			Handler:    _DedicatedVault_StartAuth_Handler,
+		},
+		{
+			MethodName: "FinishLogin",
+			Handler:    _DedicatedVault_FinishLogin_Handler,
+		},
+		{
+			MethodName: "ChangeVerifier",
+			Handler:    _DedicatedVault_ChangeVerifier_Handler,
+		},
 		{
 			MethodName: "SaveSecret",
 			Handler:    _DedicatedVault_SaveSecret_Handler,
@@ -325,7 +956,30 @@ var DedicatedVault_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "ListSecrets",
 			Handler:    _DedicatedVault_ListSecrets_Handler,
 		},
+		{
+			MethodName: "SyncChanges",
+			Handler:    _DedicatedVault_SyncChanges_Handler,
+		},
+		{
+			MethodName: "GetMasterKey",
+			Handler:    _DedicatedVault_GetMasterKey_Handler,
+		},
+		{
+			MethodName: "SetMasterKey",
+			Handler:    _DedicatedVault_SetMasterKey_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "UploadSecret",
+			Handler:       _DedicatedVault_UploadSecret_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "DownloadSecret",
+			Handler:       _DedicatedVault_DownloadSecret_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "proto/dedicatedvault.proto",
 }